@@ -0,0 +1,192 @@
+package main
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+
+	"cadence/internal/data"
+	"cadence/internal/export"
+	"cadence/internal/storage"
+	"cadence/internal/syncmap"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Server) HandleListBookmarks(c *gin.Context) {
+	book, ok := s.loadBook(c)
+	if !ok {
+		return
+	}
+
+	bookmarks, err := s.repo.ListBookmarks(CurrentUser(c), book.ID)
+	if err != nil {
+		s.logger.Printf("bookmark list error: %v", err)
+		s.SendError(c, http.StatusInternalServerError, "Failed to list bookmarks", "")
+		return
+	}
+
+	s.SendSuccess(c, http.StatusOK, bookmarks)
+}
+
+// HandleCreateBookmark anchors a bookmark at a chapter offset. When the
+// chapter has a sync map the text range defaults to the sentence being
+// spoken there, and the quoted text is taken from the map.
+func (s *Server) HandleCreateBookmark(c *gin.Context) {
+	book, ok := s.loadBook(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Chapter   *int    `json:"chapter" binding:"required"`
+		Offset    float64 `json:"offset"`
+		TextStart *int    `json:"text_start"`
+		TextEnd   *int    `json:"text_end"`
+		Title     string  `json:"title"`
+		Note      string  `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		s.SendError(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	bookmark := &data.Bookmark{
+		Chapter:   *req.Chapter,
+		Offset:    req.Offset,
+		TextStart: req.TextStart,
+		TextEnd:   req.TextEnd,
+		Title:     req.Title,
+		Note:      req.Note,
+	}
+	if bookmark.Chapter >= 0 && bookmark.Chapter < len(book.Chapters) {
+		if !s.quoteBookmark(c, &book.Chapters[bookmark.Chapter], bookmark) {
+			return
+		}
+	}
+
+	if err := s.repo.CreateBookmark(CurrentUser(c), book, bookmark); err != nil {
+		if errors.Is(err, data.ErrValidation) {
+			s.SendError(c, http.StatusBadRequest, "Validation error", err.Error())
+		} else {
+			s.logger.Printf("bookmark creation error: %v", err)
+			s.SendError(c, http.StatusInternalServerError, "Failed to create bookmark", "")
+		}
+		return
+	}
+
+	s.SendSuccess(c, http.StatusCreated, bookmark)
+}
+
+func (s *Server) HandleUpdateBookmark(c *gin.Context) {
+	bookmark, ok := s.loadBookmark(c)
+	if !ok {
+		return
+	}
+
+	var update data.BookmarkUpdate
+	if err := c.ShouldBindJSON(&update); err != nil {
+		s.SendError(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	if err := s.repo.UpdateBookmark(bookmark, update); err != nil {
+		if errors.Is(err, data.ErrValidation) {
+			s.SendError(c, http.StatusBadRequest, "Validation error", err.Error())
+		} else {
+			s.logger.Printf("bookmark update error: %v", err)
+			s.SendError(c, http.StatusInternalServerError, "Failed to update bookmark", "")
+		}
+		return
+	}
+
+	s.SendSuccess(c, http.StatusOK, bookmark)
+}
+
+func (s *Server) HandleDeleteBookmark(c *gin.Context) {
+	bookmark, ok := s.loadBookmark(c)
+	if !ok {
+		return
+	}
+
+	if err := s.repo.DeleteBookmark(bookmark); err != nil {
+		s.logger.Printf("bookmark deletion error: %v", err)
+		s.SendError(c, http.StatusInternalServerError, "Failed to delete bookmark", "")
+		return
+	}
+
+	s.SendSuccess(c, http.StatusOK, nil)
+}
+
+func (s *Server) HandleExportBookmarks(c *gin.Context) {
+	book, ok := s.loadBook(c)
+	if !ok {
+		return
+	}
+
+	bookmarks, err := s.repo.ListBookmarks(CurrentUser(c), book.ID)
+	if err != nil {
+		s.logger.Printf("bookmark list error: %v", err)
+		s.SendError(c, http.StatusInternalServerError, "Failed to list bookmarks", "")
+		return
+	}
+
+	c.Header("Content-Type", "text/markdown; charset=utf-8")
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": export.Filename(book.Title+" - Notes", ".md")}))
+	c.Status(http.StatusOK)
+
+	if err := export.WriteBookmarks(c.Writer, book, bookmarks); err != nil {
+		s.logger.Printf("book %d: bookmark export error: %v", book.ID, err)
+	}
+}
+
+// quoteBookmark fills in the bookmark's text range and quote from the
+// chapter's sync map. Chapters without one keep whatever range was given.
+func (s *Server) quoteBookmark(c *gin.Context, chapter *data.BookChapter, bookmark *data.Bookmark) bool {
+	m, err := s.readSyncMap(c, chapter)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return true
+		}
+		s.logger.Printf("sync map error: %v", err)
+		s.SendError(c, http.StatusInternalServerError, "Failed to load sync map", "")
+		return false
+	}
+
+	if bookmark.TextStart == nil && bookmark.TextEnd == nil {
+		loc, err := m.At(bookmark.Offset)
+		if err != nil || loc.Sentence == nil {
+			return true
+		}
+		bookmark.TextStart, bookmark.TextEnd = &loc.Sentence.TextStart, &loc.Sentence.TextEnd
+	}
+	if start, end := bookmark.TextStart, bookmark.TextEnd; start != nil && end != nil && *start >= 0 && *end >= *start {
+		bookmark.Quote = m.Slice(syncmap.Span{TextStart: *start, TextEnd: *end})
+	}
+	return true
+}
+
+func (s *Server) loadBookmark(c *gin.Context) (*data.Bookmark, bool) {
+	book, ok := s.loadBook(c)
+	if !ok {
+		return nil, false
+	}
+
+	id, err := ParseIDParam(c, "bookmark")
+	if err != nil {
+		s.SendError(c, http.StatusBadRequest, "Invalid bookmark ID", "")
+		return nil, false
+	}
+
+	bookmark, err := s.repo.GetBookmark(CurrentUser(c), book.ID, id)
+	if err != nil {
+		if errors.Is(err, data.ErrNotFound) {
+			s.SendError(c, http.StatusNotFound, "Bookmark not found", "")
+		} else {
+			s.logger.Printf("bookmark lookup error: %v", err)
+			s.SendError(c, http.StatusInternalServerError, "Failed to load bookmark", "")
+		}
+		return nil, false
+	}
+	return bookmark, true
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"cadence/internal/data"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const maxCoverSize = 10 << 20
+
+func (s *Server) SetupBookRoutes(rg *gin.RouterGroup) {
+	books := rg.Group("/books")
+	{
+		books.GET("", s.HandleListBooks)
+		books.GET("/:id", s.HandleGetBook)
+		books.PATCH("/:id", s.HandleUpdateBook)
+		books.DELETE("/:id", s.HandleDeleteBook)
+		books.GET("/:id/cover", s.HandleGetBookCover)
+		books.PUT("/:id/cover", s.HandleUploadBookCover)
+		books.GET("/:id/chapters/:position/audio", s.HandleChapterAudio)
+		books.HEAD("/:id/chapters/:position/audio", s.HandleChapterAudio)
+		books.GET("/:id/chapters/:position/sync", s.HandleChapterSync)
+		books.GET("/:id/chapters/:position/sync/at", s.HandleSyncLocate)
+		books.GET("/:id/chapters/:position/sync/paragraphs/:index", s.HandleSyncParagraph)
+		books.GET("/:id/export/epub", s.HandleExportEPUB)
+		books.GET("/:id/export/mp3", s.HandleExportMP3)
+		books.POST("/:id/links", s.HandleCreateBookLinks)
+		books.POST("/:id/hls", s.HandlePackageHLS)
+		books.GET("/:id/progress", s.HandleGetProgress)
+		books.PUT("/:id/progress", s.HandleSyncProgress)
+		books.GET("/:id/tags", s.HandleGetBookTags)
+		books.PUT("/:id/tags", s.HandleSetBookTags)
+		books.GET("/:id/shares", s.HandleListShares)
+		books.POST("/:id/shares", s.HandleCreateShare)
+		books.PATCH("/:id/shares/:share", s.HandleUpdateShare)
+		books.DELETE("/:id/shares/:share", s.HandleRevokeShare)
+		books.GET("/:id/bookmarks", s.HandleListBookmarks)
+		books.POST("/:id/bookmarks", s.HandleCreateBookmark)
+		books.GET("/:id/bookmarks/export", s.HandleExportBookmarks)
+		books.PATCH("/:id/bookmarks/:bookmark", s.HandleUpdateBookmark)
+		books.DELETE("/:id/bookmarks/:bookmark", s.HandleDeleteBookmark)
+	}
+}
+
+func (s *Server) HandleListBooks(c *gin.Context) {
+	status, err := data.ParseBookStatus(c.Query("status"))
+	if err != nil {
+		s.SendError(c, http.StatusBadRequest, "Invalid status", err.Error())
+		return
+	}
+
+	page, size := ParsePagination(c, 20, 100)
+	query := data.BookQuery{
+		Search:     c.Query("q"),
+		Author:     c.Query("author"),
+		Series:     c.Query("series"),
+		Narrator:   c.Query("narrator"),
+		Language:   c.Query("language"),
+		Voice:      c.Query("voice"),
+		Tag:        c.Query("tag"),
+		Status:     status,
+		Sort:       c.DefaultQuery("sort", "created"),
+		Descending: strings.EqualFold(c.Query("order"), "desc"),
+		Page:       page,
+		PageSize:   size,
+	}
+
+	books, total, err := s.repo.ListBooks(CurrentUser(c), query)
+	if err != nil {
+		s.logger.Printf("book list error: %v", err)
+		s.SendError(c, http.StatusInternalServerError, "Failed to list books", "")
+		return
+	}
+
+	s.SendSuccess(c, http.StatusOK, gin.H{
+		"items":     books,
+		"total":     total,
+		"page":      page,
+		"page_size": size,
+	})
+}
+
+func (s *Server) HandleGetBook(c *gin.Context) {
+	book, ok := s.loadBook(c)
+	if !ok {
+		return
+	}
+
+	s.SendSuccess(c, http.StatusOK, book)
+}
+
+func (s *Server) HandleUpdateBook(c *gin.Context) {
+	book, ok := s.loadEditableBook(c)
+	if !ok {
+		return
+	}
+
+	var req data.BookUpdate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		s.SendError(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	if err := s.repo.UpdateBook(book, req); err != nil {
+		if errors.Is(err, data.ErrValidation) {
+			s.SendError(c, http.StatusBadRequest, "Validation error", err.Error())
+		} else {
+			s.logger.Printf("book update error: %v", err)
+			s.SendError(c, http.StatusInternalServerError, "Failed to update book", "")
+		}
+		return
+	}
+
+	s.SendSuccess(c, http.StatusOK, book)
+}
+
+func (s *Server) HandleDeleteBook(c *gin.Context) {
+	book, ok := s.loadOwnedBook(c)
+	if !ok {
+		return
+	}
+
+	keys, err := s.repo.DeleteBook(book)
+	if err != nil {
+		s.logger.Printf("book delete error: %v", err)
+		s.SendError(c, http.StatusInternalServerError, "Failed to delete book", "")
+		return
+	}
+	s.deleteFiles(c, keys...)
+
+	s.SendSuccess(c, http.StatusOK, nil)
+}
+
+func (s *Server) HandleGetBookCover(c *gin.Context) {
+	book, ok := s.loadBook(c)
+	if !ok {
+		return
+	}
+	if book.CoverKey == "" {
+		s.SendError(c, http.StatusNotFound, "Book has no cover", "")
+		return
+	}
+
+	s.serveObject(c, book.CoverKey, book.CoverType, "")
+}
+
+func (s *Server) HandleUploadBookCover(c *gin.Context) {
+	book, ok := s.loadEditableBook(c)
+	if !ok {
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxCoverSize+1<<20)
+	file, _, err := c.Request.FormFile("file")
+	if err != nil {
+		s.SendError(c, http.StatusBadRequest, "Missing file", err.Error())
+		return
+	}
+	defer file.Close()
+
+	content, err := io.ReadAll(io.LimitReader(file, maxCoverSize+1))
+	if err != nil || len(content) > maxCoverSize {
+		s.SendError(c, http.StatusRequestEntityTooLarge, "Cover too large", fmt.Sprintf("maximum cover size is %d bytes", maxCoverSize))
+		return
+	}
+
+	contentType := http.DetectContentType(content)
+	switch contentType {
+	case "image/jpeg", "image/png", "image/gif", "image/webp":
+	default:
+		s.SendError(c, http.StatusUnsupportedMediaType, "Unsupported image type", contentType)
+		return
+	}
+
+	previous := book.CoverKey
+	key := fmt.Sprintf("books/%d/%d/cover-%s", book.UserID, book.ID, uuid.NewString())
+	if _, err := s.store.Put(c.Request.Context(), key, bytes.NewReader(content), contentType); err != nil {
+		s.logger.Printf("cover storage error: %v", err)
+		s.SendError(c, http.StatusInternalServerError, "Failed to store cover", "")
+		return
+	}
+
+	if err := s.repo.SetBookCover(book, key, contentType); err != nil {
+		s.logger.Printf("cover update error: %v", err)
+		s.deleteFiles(c, key)
+		s.SendError(c, http.StatusInternalServerError, "Failed to update cover", "")
+		return
+	}
+	if strings.HasPrefix(previous, "books/") {
+		s.deleteFiles(c, previous)
+	}
+
+	s.SendSuccess(c, http.StatusOK, book)
+}
+
+func (s *Server) loadBook(c *gin.Context) (*data.Book, bool) {
+	id, err := ParseIDParam(c, "id")
+	if err != nil {
+		s.SendError(c, http.StatusBadRequest, "Invalid book ID", "")
+		return nil, false
+	}
+
+	book, err := s.repo.GetBook(CurrentUser(c), id)
+	if err != nil {
+		if errors.Is(err, data.ErrNotFound) {
+			s.SendError(c, http.StatusNotFound, "Book not found", "")
+		} else {
+			s.logger.Printf("book lookup error: %v", err)
+			s.SendError(c, http.StatusInternalServerError, "Failed to load book", "")
+		}
+		return nil, false
+	}
+	return book, true
+}
+
+// loadEditableBook is loadBook for requests that change the book, which
+// read-only shares may not.
+func (s *Server) loadEditableBook(c *gin.Context) (*data.Book, bool) {
+	book, ok := s.loadBook(c)
+	if ok && !book.CanEdit() {
+		s.SendError(c, http.StatusForbidden, "This book is shared with you read-only", "")
+		return nil, false
+	}
+	return book, ok
+}
+
+// loadOwnedBook is loadBook for deleting and sharing, which only the
+// owner may do.
+func (s *Server) loadOwnedBook(c *gin.Context) (*data.Book, bool) {
+	book, ok := s.loadBook(c)
+	if ok && !book.IsOwner() {
+		s.SendError(c, http.StatusForbidden, "Only the book's owner can do this", "")
+		return nil, false
+	}
+	return book, ok
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"cadence/internal/data"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Server) SetupCollectionRoutes(rg *gin.RouterGroup) {
+	collections := rg.Group("/collections")
+	{
+		collections.GET("", s.HandleListCollections)
+		collections.POST("", s.HandleCreateCollection)
+		collections.GET("/:id", s.HandleGetCollection)
+		collections.PATCH("/:id", s.HandleUpdateCollection)
+		collections.DELETE("/:id", s.HandleDeleteCollection)
+		collections.PUT("/:id/books", s.HandleSetCollectionBooks)
+		collections.POST("/:id/books", s.HandleAddCollectionBook)
+		collections.DELETE("/:id/books/:book", s.HandleRemoveCollectionBook)
+	}
+}
+
+func (s *Server) HandleListCollections(c *gin.Context) {
+	collections, err := s.repo.ListCollections(CurrentUser(c))
+	if err != nil {
+		s.logger.Printf("collection list error: %v", err)
+		s.SendError(c, http.StatusInternalServerError, "Failed to list collections", "")
+		return
+	}
+
+	s.SendSuccess(c, http.StatusOK, collections)
+}
+
+func (s *Server) HandleCreateCollection(c *gin.Context) {
+	var req struct {
+		Name        string `json:"name" binding:"required"`
+		Description string `json:"description"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		s.SendError(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	collection := &data.Collection{Name: req.Name, Description: req.Description}
+	if err := s.repo.CreateCollection(CurrentUser(c), collection); err != nil {
+		s.sendCollectionError(c, err, "collection creation error", "Failed to create collection")
+		return
+	}
+
+	s.SendSuccess(c, http.StatusCreated, collection)
+}
+
+func (s *Server) HandleGetCollection(c *gin.Context) {
+	collection, ok := s.loadCollection(c)
+	if !ok {
+		return
+	}
+	s.sendCollection(c, collection)
+}
+
+func (s *Server) HandleUpdateCollection(c *gin.Context) {
+	collection, ok := s.loadCollection(c)
+	if !ok {
+		return
+	}
+
+	var update data.CollectionUpdate
+	if err := c.ShouldBindJSON(&update); err != nil {
+		s.SendError(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	if err := s.repo.UpdateCollection(collection, update); err != nil {
+		s.sendCollectionError(c, err, "collection update error", "Failed to update collection")
+		return
+	}
+
+	s.SendSuccess(c, http.StatusOK, collection)
+}
+
+func (s *Server) HandleDeleteCollection(c *gin.Context) {
+	collection, ok := s.loadCollection(c)
+	if !ok {
+		return
+	}
+
+	if err := s.repo.DeleteCollection(collection); err != nil {
+		s.logger.Printf("collection deletion error: %v", err)
+		s.SendError(c, http.StatusInternalServerError, "Failed to delete collection", "")
+		return
+	}
+
+	s.SendSuccess(c, http.StatusOK, nil)
+}
+
+// HandleSetCollectionBooks replaces the collection's books; the order of
+// book_ids is the order of the collection.
+func (s *Server) HandleSetCollectionBooks(c *gin.Context) {
+	collection, ok := s.loadCollection(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		BookIDs []uint `json:"book_ids"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		s.SendError(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	if err := s.repo.SetCollectionBooks(collection, req.BookIDs); err != nil {
+		s.sendCollectionError(c, err, "collection update error", "Failed to update collection")
+		return
+	}
+
+	s.sendCollection(c, collection)
+}
+
+// HandleAddCollectionBook inserts a book at position, or appends it, moving
+// it if it is already in the collection.
+func (s *Server) HandleAddCollectionBook(c *gin.Context) {
+	collection, ok := s.loadCollection(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		BookID   uint `json:"book_id" binding:"required"`
+		Position *int `json:"position"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		s.SendError(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	book, err := s.repo.GetBook(CurrentUser(c), req.BookID)
+	if err == nil {
+		err = s.repo.AddCollectionBook(collection, book, req.Position)
+	}
+	if err != nil {
+		if errors.Is(err, data.ErrNotFound) {
+			s.SendError(c, http.StatusNotFound, "Book not found", "")
+		} else {
+			s.logger.Printf("collection update error: %v", err)
+			s.SendError(c, http.StatusInternalServerError, "Failed to update collection", "")
+		}
+		return
+	}
+
+	s.sendCollection(c, collection)
+}
+
+func (s *Server) HandleRemoveCollectionBook(c *gin.Context) {
+	collection, ok := s.loadCollection(c)
+	if !ok {
+		return
+	}
+
+	bookID, err := ParseIDParam(c, "book")
+	if err != nil {
+		s.SendError(c, http.StatusBadRequest, "Invalid book ID", "")
+		return
+	}
+
+	if err := s.repo.RemoveCollectionBook(collection, bookID); err != nil {
+		if errors.Is(err, data.ErrNotFound) {
+			s.SendError(c, http.StatusNotFound, "Book is not in this collection", "")
+		} else {
+			s.logger.Printf("collection update error: %v", err)
+			s.SendError(c, http.StatusInternalServerError, "Failed to update collection", "")
+		}
+		return
+	}
+
+	s.SendSuccess(c, http.StatusOK, nil)
+}
+
+func (s *Server) sendCollection(c *gin.Context, collection *data.Collection) {
+	books, err := s.repo.CollectionBooks(collection)
+	if err != nil {
+		s.logger.Printf("collection books error: %v", err)
+		s.SendError(c, http.StatusInternalServerError, "Failed to load collection", "")
+		return
+	}
+	collection.BookCount = len(books)
+
+	s.SendSuccess(c, http.StatusOK, gin.H{
+		"collection": collection,
+		"books":      books,
+	})
+}
+
+func (s *Server) sendCollectionError(c *gin.Context, err error, logMessage, message string) {
+	switch {
+	case errors.Is(err, data.ErrValidation):
+		s.SendError(c, http.StatusBadRequest, "Validation error", err.Error())
+	case errors.Is(err, data.ErrNameTaken):
+		s.SendError(c, http.StatusConflict, "A collection with this name already exists", "")
+	default:
+		s.logger.Printf("%s: %v", logMessage, err)
+		s.SendError(c, http.StatusInternalServerError, message, "")
+	}
+}
+
+func (s *Server) loadCollection(c *gin.Context) (*data.Collection, bool) {
+	id, err := ParseIDParam(c, "id")
+	if err != nil {
+		s.SendError(c, http.StatusBadRequest, "Invalid collection ID", "")
+		return nil, false
+	}
+
+	collection, err := s.repo.GetCollection(CurrentUser(c), id)
+	if err != nil {
+		if errors.Is(err, data.ErrNotFound) {
+			s.SendError(c, http.StatusNotFound, "Collection not found", "")
+		} else {
+			s.logger.Printf("collection lookup error: %v", err)
+			s.SendError(c, http.StatusInternalServerError, "Failed to load collection", "")
+		}
+		return nil, false
+	}
+	return collection, true
+}
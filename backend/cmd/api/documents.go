@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"cadence/internal/data"
+	"cadence/internal/ingest"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func (s *Server) SetupDocumentRoutes(rg *gin.RouterGroup) {
+	documents := rg.Group("/documents")
+	{
+		documents.GET("", s.HandleListDocuments)
+		documents.POST("", s.HandleUploadDocument)
+		documents.GET("/:id", s.HandleGetDocument)
+		documents.DELETE("/:id", s.HandleDeleteDocument)
+	}
+}
+
+func (s *Server) HandleUploadDocument(c *gin.Context) {
+	user := CurrentUser(c)
+
+	rc := http.NewResponseController(c.Writer)
+	rc.SetReadDeadline(time.Now().Add(s.config.UploadTimeout))
+	rc.SetWriteDeadline(time.Now().Add(s.config.UploadTimeout))
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, s.config.MaxUploadSize+1<<20)
+
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			s.SendError(c, http.StatusRequestEntityTooLarge, "File too large", fmt.Sprintf("maximum upload size is %d bytes", s.config.MaxUploadSize))
+			return
+		}
+		s.SendError(c, http.StatusBadRequest, "Missing file", err.Error())
+		return
+	}
+	defer file.Close()
+
+	content, err := io.ReadAll(io.LimitReader(file, s.config.MaxUploadSize+1))
+	if err != nil {
+		s.SendError(c, http.StatusBadRequest, "Failed to read file", err.Error())
+		return
+	}
+	if int64(len(content)) > s.config.MaxUploadSize {
+		s.SendError(c, http.StatusRequestEntityTooLarge, "File too large", fmt.Sprintf("maximum upload size is %d bytes", s.config.MaxUploadSize))
+		return
+	}
+
+	filename := path.Base(header.Filename)
+	format, err := ingest.DetectFormat(filename, content)
+	if err != nil || !slices.Contains(s.config.UploadFormats, string(format)) {
+		s.SendError(c, http.StatusUnsupportedMediaType, "Unsupported document type", filename)
+		return
+	}
+
+	parsed, err := ingest.Parse(filename, content)
+	if err != nil {
+		switch {
+		case errors.Is(err, ingest.ErrEncrypted), errors.Is(err, ingest.ErrImageOnly),
+			errors.Is(err, ingest.ErrEmptyDocument), errors.Is(err, ingest.ErrInvalidDocument):
+			s.SendError(c, http.StatusUnprocessableEntity, "Document could not be read", err.Error())
+		case errors.Is(err, ingest.ErrUnsupportedFormat):
+			s.SendError(c, http.StatusUnsupportedMediaType, "Unsupported document type", err.Error())
+		default:
+			s.logger.Printf("document parse error: %v", err)
+			s.SendError(c, http.StatusInternalServerError, "Failed to read document", "")
+		}
+		return
+	}
+
+	sum := sha256.Sum256(content)
+	prefix := fmt.Sprintf("documents/%d/%s", user.ID, uuid.NewString())
+	doc := &data.Document{
+		UserID:			user.ID,
+		Title:			parsed.Title,
+		Authors:		parsed.Authors,
+		Language:		parsed.Language,
+		Format:			string(format),
+		Filename:		filename,
+		ContentType:	format.ContentType(),
+		Size:			int64(len(content)),
+		Checksum:		hex.EncodeToString(sum[:]),
+		StorageKey:		prefix + "/original." + string(format),
+		Properties:		parsed.Properties,
+		ChapterCount:	len(parsed.Chapters),
+	}
+	for i, ch := range parsed.Chapters {
+		count := ch.CharCount()
+		doc.CharCount += count
+		doc.Chapters = append(doc.Chapters, data.Chapter{
+			Position:	i,
+			Title:		ch.Title,
+			Text:		ch.Text(),
+			Notes:		ch.Notes,
+			CharCount:	count,
+		})
+	}
+
+	ctx := c.Request.Context()
+	if _, err := s.store.Put(ctx, doc.StorageKey, bytes.NewReader(content), doc.ContentType); err != nil {
+		s.logger.Printf("document storage error: %v", err)
+		s.SendError(c, http.StatusInternalServerError, "Failed to store document", "")
+		return
+	}
+	if len(parsed.Cover) > 0 {
+		key := prefix + "/cover"
+		if _, err := s.store.Put(ctx, key, bytes.NewReader(parsed.Cover), parsed.CoverType); err != nil {
+			s.logger.Printf("cover storage error: %v", err)
+		} else {
+			doc.CoverKey, doc.CoverType = key, parsed.CoverType
+		}
+	}
+
+	if err := s.repo.CreateDocument(doc); err != nil {
+		s.logger.Printf("document creation error: %v", err)
+		s.deleteFiles(c, doc.StorageKey, doc.CoverKey)
+		s.SendError(c, http.StatusInternalServerError, "Failed to save document", "")
+		return
+	}
+
+	s.SendSuccess(c, http.StatusCreated, doc)
+}
+
+func (s *Server) HandleListDocuments(c *gin.Context) {
+	docs, err := s.repo.ListDocuments(CurrentUser(c))
+	if err != nil {
+		s.logger.Printf("document list error: %v", err)
+		s.SendError(c, http.StatusInternalServerError, "Failed to list documents", "")
+		return
+	}
+
+	s.SendSuccess(c, http.StatusOK, docs)
+}
+
+func (s *Server) HandleGetDocument(c *gin.Context) {
+	doc, ok := s.loadDocument(c)
+	if !ok {
+		return
+	}
+
+	s.SendSuccess(c, http.StatusOK, doc)
+}
+
+func (s *Server) HandleDeleteDocument(c *gin.Context) {
+	doc, ok := s.loadDocument(c)
+	if !ok {
+		return
+	}
+
+	keys, err := s.repo.DeleteDocument(doc)
+	if err != nil {
+		s.logger.Printf("document delete error: %v", err)
+		s.SendError(c, http.StatusInternalServerError, "Failed to delete document", "")
+		return
+	}
+	s.deleteFiles(c, append(keys, doc.StorageKey, doc.CoverKey)...)
+
+	s.SendSuccess(c, http.StatusOK, nil)
+}
+
+func (s *Server) loadDocument(c *gin.Context) (*data.Document, bool) {
+	id, err := ParseIDParam(c, "id")
+	if err != nil {
+		s.SendError(c, http.StatusBadRequest, "Invalid document ID", "")
+		return nil, false
+	}
+
+	doc, err := s.repo.GetDocument(CurrentUser(c), id)
+	if err != nil {
+		if errors.Is(err, data.ErrNotFound) {
+			s.SendError(c, http.StatusNotFound, "Document not found", "")
+		} else {
+			s.logger.Printf("document lookup error: %v", err)
+			s.SendError(c, http.StatusInternalServerError, "Failed to load document", "")
+		}
+		return nil, false
+	}
+	return doc, true
+}
+
+// deleteFiles removes stored objects; a key ending in a slash removes
+// everything under that prefix.
+func (s *Server) deleteFiles(c *gin.Context, keys ...string) {
+	ctx := c.Request.Context()
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+		if strings.HasSuffix(key, "/") {
+			objects, err := s.store.List(ctx, key)
+			if err != nil {
+				s.logger.Printf("file cleanup error: %v", err)
+				continue
+			}
+			for _, object := range objects {
+				if err := s.store.Delete(ctx, object.Key); err != nil {
+					s.logger.Printf("file cleanup error: %v", err)
+				}
+			}
+			continue
+		}
+		if err := s.store.Delete(ctx, key); err != nil {
+			s.logger.Printf("file cleanup error: %v", err)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"cadence/internal/data"
+	"cadence/internal/export"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Server) HandleExportEPUB(c *gin.Context) {
+	book, ok := s.loadBook(c)
+	if !ok {
+		return
+	}
+	if len(book.Chapters) == 0 {
+		s.SendError(c, http.StatusConflict, "Book has no audio to export", "")
+		return
+	}
+
+	ctx := c.Request.Context()
+	src, err := s.exporter.Load(ctx, book)
+	if err != nil {
+		if errors.Is(err, export.ErrMissingSource) {
+			s.SendError(c, http.StatusConflict, "The book's source document is no longer available", "")
+		} else {
+			s.logger.Printf("export load error: %v", err)
+			s.SendError(c, http.StatusInternalServerError, "Failed to prepare export", "")
+		}
+		return
+	}
+
+	http.NewResponseController(c.Writer).SetWriteDeadline(time.Now().Add(s.config.StreamTimeout))
+	c.Header("Content-Type", "application/epub+zip")
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": export.Filename(book.Title, ".epub")}))
+	c.Status(http.StatusOK)
+
+	if err := s.exporter.WriteEPUB(ctx, c.Writer, src); err != nil {
+		s.logger.Printf("book %d: epub export error: %v", book.ID, err)
+	}
+}
+
+func (s *Server) HandleExportMP3(c *gin.Context) {
+	book, ok := s.loadBook(c)
+	if !ok {
+		return
+	}
+	if len(book.Chapters) == 0 {
+		s.SendError(c, http.StatusConflict, "Book has no audio to export", "")
+		return
+	}
+
+	ctx := c.Request.Context()
+	tag, err := s.exporter.MP3Tag(ctx, book)
+	if err != nil {
+		s.logger.Printf("book %d: id3 tag error: %v", book.ID, err)
+		s.SendError(c, http.StatusInternalServerError, "Failed to prepare export", "")
+		return
+	}
+
+	http.NewResponseController(c.Writer).SetWriteDeadline(time.Now().Add(s.config.StreamTimeout))
+	c.Header("Content-Type", "audio/mpeg")
+	c.Header("Content-Length", strconv.FormatInt(export.MP3Size(book, tag), 10))
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": export.Filename(book.Title, ".mp3")}))
+	c.Status(http.StatusOK)
+
+	if err := s.exporter.WriteMP3(ctx, c.Writer, book, tag); err != nil {
+		s.logger.Printf("book %d: mp3 export error: %v", book.ID, err)
+	}
+}
+
+// HandleExportLibrary streams the user's books as a zip in the folder layout
+// Audiobookshelf and Plex import directly. Repeated book_id parameters
+// limit the export to those books.
+func (s *Server) HandleExportLibrary(c *gin.Context) {
+	var ids []uint
+	for _, value := range c.QueryArray("book_id") {
+		id, err := strconv.ParseUint(value, 10, 32)
+		if err != nil || id == 0 {
+			s.SendError(c, http.StatusBadRequest, "Invalid book_id", value)
+			return
+		}
+		ids = append(ids, uint(id))
+	}
+
+	books, err := s.repo.LibraryBooks(CurrentUser(c))
+	if err != nil {
+		s.logger.Printf("library export error: %v", err)
+		s.SendError(c, http.StatusInternalServerError, "Failed to prepare export", "")
+		return
+	}
+	if len(ids) > 0 {
+		books = slices.DeleteFunc(books, func(book data.Book) bool {
+			return !slices.Contains(ids, book.ID)
+		})
+		if len(books) != len(slices.Compact(slices.Sorted(slices.Values(ids)))) {
+			s.SendError(c, http.StatusNotFound, "Book not found", "")
+			return
+		}
+	}
+	books = slices.DeleteFunc(books, func(book data.Book) bool {
+		return len(book.Chapters) == 0
+	})
+	if len(books) == 0 {
+		s.SendError(c, http.StatusConflict, "No books with audio to export", "")
+		return
+	}
+
+	http.NewResponseController(c.Writer).SetWriteDeadline(time.Now().Add(s.config.StreamTimeout))
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": "cadence-library.zip"}))
+	c.Status(http.StatusOK)
+
+	sink := export.NewZipSink(c.Writer)
+	if err := s.exporter.WriteLibrary(c.Request.Context(), sink, books); err != nil {
+		s.logger.Printf("library export error: %v", err)
+		return
+	}
+	if err := sink.Close(); err != nil {
+		s.logger.Printf("library export error: %v", err)
+	}
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"cadence/internal/data"
+	"cadence/internal/feeds"
+	"cadence/internal/signing"
+
+	"github.com/gin-gonic/gin"
+)
+
+const feedKey = "feed"
+
+func (s *Server) SetupFeedRoutes(rg *gin.RouterGroup) {
+	feeds := rg.Group("/feeds")
+	{
+		feeds.GET("", s.HandleListFeeds)
+		feeds.POST("", s.HandleCreateFeed)
+		feeds.DELETE("/:id", s.HandleDeleteFeed)
+	}
+}
+
+func (s *Server) HandleListFeeds(c *gin.Context) {
+	list, err := s.repo.ListFeeds(CurrentUser(c))
+	if err != nil {
+		s.logger.Printf("feed list error: %v", err)
+		s.SendError(c, http.StatusInternalServerError, "Failed to list feeds", "")
+		return
+	}
+
+	s.SendSuccess(c, http.StatusOK, list)
+}
+
+func (s *Server) HandleCreateFeed(c *gin.Context) {
+	var req struct {
+		BookID *uint `json:"book_id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		s.SendError(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	user := CurrentUser(c)
+	var book *data.Book
+	if req.BookID != nil {
+		var err error
+		if book, err = s.repo.GetBook(user, *req.BookID); err != nil {
+			if errors.Is(err, data.ErrNotFound) {
+				s.SendError(c, http.StatusNotFound, "Book not found", "")
+			} else {
+				s.logger.Printf("book lookup error: %v", err)
+				s.SendError(c, http.StatusInternalServerError, "Failed to load book", "")
+			}
+			return
+		}
+	}
+
+	feed, err := s.repo.CreateFeed(user, book)
+	if err != nil {
+		s.logger.Printf("feed creation error: %v", err)
+		s.SendError(c, http.StatusInternalServerError, "Failed to create feed", "")
+		return
+	}
+
+	s.SendSuccess(c, http.StatusCreated, gin.H{
+		"feed": feed,
+		"url":  s.BaseURL() + "/feeds/" + feed.PlainText,
+	})
+}
+
+func (s *Server) HandleDeleteFeed(c *gin.Context) {
+	id, err := ParseIDParam(c, "id")
+	if err != nil {
+		s.SendError(c, http.StatusBadRequest, "Invalid feed ID", "")
+		return
+	}
+
+	feed, err := s.repo.GetFeed(CurrentUser(c), id)
+	if err != nil {
+		if errors.Is(err, data.ErrNotFound) {
+			s.SendError(c, http.StatusNotFound, "Feed not found", "")
+		} else {
+			s.logger.Printf("feed lookup error: %v", err)
+			s.SendError(c, http.StatusInternalServerError, "Failed to load feed", "")
+		}
+		return
+	}
+
+	if err := s.repo.DeleteFeed(feed); err != nil {
+		s.logger.Printf("feed deletion error: %v", err)
+		s.SendError(c, http.StatusInternalServerError, "Failed to revoke feed", "")
+		return
+	}
+
+	s.SendSuccess(c, http.StatusOK, nil)
+}
+
+// SetupPodcastRoutes serves feeds and their episodes under the feed's
+// secret token, so revoking a feed also revokes every URL it handed out.
+// Episode and cover links are also signed, so they expire on their own.
+func (s *Server) SetupPodcastRoutes() {
+	feed := s.router.Group("/feeds/:token")
+	feed.Use(s.FeedTokenMiddleware())
+	{
+		feed.GET("", s.HandlePodcastFeed)
+		feed.HEAD("", s.HandlePodcastFeed)
+	}
+
+	media := feed.Group("/books/:id")
+	media.Use(s.FeedSignatureMiddleware())
+	{
+		media.GET("/cover", s.HandleFeedBookCover)
+		media.GET("/chapters/:position/audio", s.HandleFeedChapterAudio)
+		media.HEAD("/chapters/:position/audio", s.HandleFeedChapterAudio)
+	}
+}
+
+// FeedTokenMiddleware resolves the token to its feed and acts as the feed's
+// owner. The token is the only credential, so unknown and revoked tokens
+// are indistinguishable.
+func (s *Server) FeedTokenMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		feed, user, err := s.repo.FeedByToken(c.Param("token"))
+		if err != nil {
+			if !errors.Is(err, data.ErrNotFound) {
+				s.logger.Printf("feed lookup error: %v", err)
+			}
+			s.AbortWithError(c, http.StatusNotFound, "Feed not found", "")
+			return
+		}
+
+		if err := s.repo.CheckAccess(user); err != nil {
+			s.AbortWithError(c, http.StatusTooManyRequests, "Rate limit exceeded", err.Error())
+			return
+		}
+
+		c.Set("user", user)
+		c.Set(feedKey, feed)
+		c.Next()
+	}
+}
+
+// FeedSignatureMiddleware checks the signature on a feed's episode and
+// cover links, which are signed for the feed's owner.
+func (s *Server) FeedSignatureMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := s.signer.Verify(c.Request.URL.Path, c.Request.URL.Query(), time.Now())
+		if err != nil {
+			s.AbortWithError(c, http.StatusForbidden, "Invalid or expired signature", err.Error())
+			return
+		}
+		if userID != CurrentUser(c).ID {
+			s.AbortWithError(c, http.StatusForbidden, "Invalid or expired signature", "")
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func (s *Server) HandlePodcastFeed(c *gin.Context) {
+	feed := currentFeed(c)
+	user := CurrentUser(c)
+
+	var books []data.Book
+	var err error
+	title := fmt.Sprintf("%s's library", user.Username)
+	description := "Audiobooks converted with Cadence."
+	if feed.BookID != nil {
+		book, err := s.repo.GetBook(user, *feed.BookID)
+		if err != nil {
+			if !errors.Is(err, data.ErrNotFound) {
+				s.logger.Printf("feed book lookup error: %v", err)
+			}
+			s.SendError(c, http.StatusNotFound, "Feed not found", "")
+			return
+		}
+		books = []data.Book{*book}
+		title = book.Title
+		if book.Description != "" {
+			description = book.Description
+		}
+	} else if books, err = s.repo.LibraryBooks(user); err != nil {
+		s.logger.Printf("feed library error: %v", err)
+		s.SendError(c, http.StatusInternalServerError, "Failed to build feed", "")
+		return
+	}
+
+	if err := s.repo.RecordFeedFetch(feed); err != nil {
+		s.logger.Printf("feed %d: fetch record error: %v", feed.ID, err)
+	}
+
+	now := time.Now()
+	links := &feedLinks{
+		base:    s.BaseURL(),
+		feed:    "/feeds/" + c.Param("token"),
+		signer:  s.signer,
+		user:    user,
+		expires: s.feedLinkExpiry(now),
+	}
+	rss := feeds.Podcast(title, description, books, links, now)
+
+	c.Header("Content-Type", "application/rss+xml; charset=utf-8")
+	c.Header("Cache-Control", "private, max-age=300")
+	c.Status(http.StatusOK)
+	if c.Request.Method == http.MethodHead {
+		return
+	}
+	if err := rss.Encode(c.Writer); err != nil {
+		s.logger.Printf("feed %d: encode error: %v", feed.ID, err)
+	}
+}
+
+func (s *Server) HandleFeedBookCover(c *gin.Context) {
+	book, ok := s.loadFeedBook(c)
+	if !ok {
+		return
+	}
+	if book.CoverKey == "" {
+		s.SendError(c, http.StatusNotFound, "Book has no cover", "")
+		return
+	}
+
+	s.serveObject(c, book.CoverKey, book.CoverType, "")
+}
+
+func (s *Server) HandleFeedChapterAudio(c *gin.Context) {
+	book, ok := s.loadFeedBook(c)
+	if !ok {
+		return
+	}
+
+	chapter, ok := s.findBookChapter(c, book)
+	if !ok {
+		return
+	}
+
+	name := fmt.Sprintf("%02d.mp3", chapter.Position+1)
+	s.serveObject(c, chapter.AudioKey, "audio/mpeg", name)
+}
+
+// loadFeedBook is loadBook limited to the books the feed lists: its own
+// book, or for a library feed any book the owner can read.
+func (s *Server) loadFeedBook(c *gin.Context) (*data.Book, bool) {
+	feed := currentFeed(c)
+	if id, err := ParseIDParam(c, "id"); err == nil && feed.BookID != nil && *feed.BookID != id {
+		s.SendError(c, http.StatusNotFound, "Book not found", "")
+		return nil, false
+	}
+	return s.loadBook(c)
+}
+
+func currentFeed(c *gin.Context) *data.Feed {
+	feed, _ := c.MustGet(feedKey).(*data.Feed)
+	return feed
+}
+
+// feedLinkExpiry signs feed enclosures for the longest allowed lifetime,
+// aligned to a fixed window so that refreshing a feed does not change every
+// episode URL.
+func (s *Server) feedLinkExpiry(now time.Time) time.Time {
+	ttl := s.config.SignedURLMaxTTL
+	window := min(24*time.Hour, ttl/2)
+	return now.Truncate(window).Add(ttl)
+}
+
+// feedLinks builds a feed's URLs. feed is the feed's path, which its
+// episode and cover links are signed under.
+type feedLinks struct {
+	base    string
+	feed    string
+	signer  *signing.Signer
+	user    *data.User
+	expires time.Time
+}
+
+func (l *feedLinks) Self() string {
+	return l.base + l.feed
+}
+
+func (l *feedLinks) Site() string {
+	return l.base
+}
+
+func (l *feedLinks) Cover(book *data.Book) string {
+	return l.signer.SignURL(l.base, fmt.Sprintf("%s/books/%d/cover", l.feed, book.ID), l.user.ID, l.expires)
+}
+
+func (l *feedLinks) Audio(book *data.Book, chapter *data.BookChapter) string {
+	return l.signer.SignURL(l.base, fmt.Sprintf("%s/books/%d/chapters/%d/audio", l.feed, book.ID, chapter.Position), l.user.ID, l.expires)
+}
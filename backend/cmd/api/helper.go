@@ -1,12 +1,16 @@
 package main
 
 import (
+	"errors"
 	"strings"
 	"strconv"
 	"time"
 	"log"
+	"net"
 	"os"
 
+	"cadence/internal/data"
+
 	"github.com/gin-gonic/gin"
 )
 
@@ -49,6 +53,13 @@ func (s *Server) SendError(c *gin.Context, code int, message string, details str
 	})
 }
 
+// AbortWithError sends an error from middleware and stops the handlers
+// behind it from running.
+func (s *Server) AbortWithError(c *gin.Context, code int, message string, details string) {
+	s.SendError(c, code, message, details)
+	c.Abort()
+}
+
 func (s *Server) SendSuccess(c *gin.Context, code int, data interface{}) {
 	c.JSON(code, gin.H{
 		"success": true,
@@ -63,4 +74,52 @@ func RandString(n int) string {
 		b[i] = letters[time.Now().UnixNano()%int64(len(letters))]
 	}
 	return string(b)
-}
\ No newline at end of file
+}
+
+func CurrentUser(c *gin.Context) *data.User {
+	user, _ := c.MustGet("user").(*data.User)
+	return user
+}
+
+func ParseIDParam(c *gin.Context, name string) (uint, error) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 32)
+	if err != nil || id == 0 {
+		return 0, errors.New("invalid " + name)
+	}
+	return uint(id), nil
+}
+
+func ParsePagination(c *gin.Context, defaultSize, maxSize int) (int, int) {
+	page, err := strconv.Atoi(c.Query("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	size, err := strconv.Atoi(c.Query("page_size"))
+	if err != nil || size < 1 {
+		size = defaultSize
+	}
+	return page, min(size, maxSize)
+}
+
+// BaseURL is the configured public URL. Request headers are never used to
+// build links, since a client could otherwise point them at another host.
+func (s *Server) BaseURL() string {
+	return s.config.PublicURL
+}
+
+// LocalURL is the URL of a server listening on addr, as reached from the
+// same machine.
+func LocalURL(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "http://localhost"
+	}
+	if ip := net.ParseIP(host); host == "" || ip != nil && ip.IsUnspecified() {
+		host = "localhost"
+	}
+	return "http://" + net.JoinHostPort(host, port)
+}
+
+func (s *Server) SignedURL(path string, user *data.User, ttl time.Duration) string {
+	return s.signer.SignURL(s.BaseURL(), path, user.ID, time.Now().Add(ttl))
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"cadence/internal/hls"
+	"cadence/internal/signing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HandlePackageHLS segments a book for streaming. Books are packaged when
+// their job finishes; this backfills older books and retries failures.
+func (s *Server) HandlePackageHLS(c *gin.Context) {
+	book, ok := s.loadEditableBook(c)
+	if !ok {
+		return
+	}
+	if len(book.Chapters) == 0 {
+		s.SendError(c, http.StatusConflict, "Book has no audio to package", "")
+		return
+	}
+
+	m, err := s.packager.Package(c.Request.Context(), book)
+	if err != nil {
+		s.logger.Printf("book %d: hls packaging error: %v", book.ID, err)
+		s.SendError(c, http.StatusInternalServerError, "Failed to package book for streaming", "")
+		return
+	}
+
+	segments := 0
+	for _, ch := range m.Chapters {
+		segments += len(ch.Segments)
+	}
+	s.SendSuccess(c, http.StatusOK, gin.H{
+		"duration":        m.Duration,
+		"target_duration": m.TargetDuration,
+		"chapters":        len(m.Chapters),
+		"segments":        segments,
+	})
+}
+
+// HandleHLSPlaylist renders the book's playlist. It is only reachable
+// through a signed URL, and every segment URI in it is signed with the
+// same expiry so the whole book stays playable for as long as the link.
+func (s *Server) HandleHLSPlaylist(c *gin.Context) {
+	book, ok := s.loadBook(c)
+	if !ok {
+		return
+	}
+
+	m, err := s.packager.Load(c.Request.Context(), book)
+	if err != nil {
+		if errors.Is(err, hls.ErrNotPackaged) {
+			s.SendError(c, http.StatusNotFound, "Book has not been packaged for streaming", "")
+		} else {
+			s.logger.Printf("book %d: hls manifest error: %v", book.ID, err)
+			s.SendError(c, http.StatusInternalServerError, "Failed to load playlist", "")
+		}
+		return
+	}
+
+	exp, _ := strconv.ParseInt(c.Query(signing.ParamExpires), 10, 64)
+	expires, base, user := time.Unix(exp, 0), s.BaseURL(), CurrentUser(c)
+	playlist := hls.Playlist(m, func(ch *hls.Chapter, seg *hls.Segment) string {
+		path := fmt.Sprintf("/signed/books/%d/hls/%d/%d.mp3", book.ID, ch.Position, seg.Index)
+		return s.signer.SignURL(base, path, user.ID, expires)
+	})
+
+	c.Header("Cache-Control", "private, max-age=0, must-revalidate")
+	c.Data(http.StatusOK, hls.ContentTypePlaylist, []byte(playlist))
+}
+
+func (s *Server) HandleHLSSegment(c *gin.Context) {
+	book, ok := s.loadBook(c)
+	if !ok {
+		return
+	}
+	if !book.HasHLS {
+		s.SendError(c, http.StatusNotFound, "Book has not been packaged for streaming", "")
+		return
+	}
+
+	chapter, ok := s.findBookChapter(c, book)
+	if !ok {
+		return
+	}
+	index, err := strconv.Atoi(strings.TrimSuffix(c.Param("segment"), ".mp3"))
+	if err != nil || index < 0 {
+		s.SendError(c, http.StatusNotFound, "Segment not found", "")
+		return
+	}
+
+	s.serveObject(c, hls.SegmentKey(book, chapter.Position, index), hls.ContentTypeSegment, "")
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"cadence/internal/data"
+	"cadence/internal/events"
+	"cadence/internal/preprocess"
+	"cadence/internal/speech"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Server) SetupJobRoutes(rg *gin.RouterGroup) {
+	jobs := rg.Group("/jobs")
+	{
+		jobs.GET("", s.HandleListJobs)
+		jobs.POST("", s.HandleCreateJob)
+		jobs.GET("/:id", s.HandleGetJob)
+		jobs.POST("/:id/cancel", s.HandleCancelJob)
+		jobs.GET("/:id/events", s.HandleJobEvents)
+	}
+}
+
+func (s *Server) HandleCreateJob(c *gin.Context) {
+	var req struct {
+		DocumentID     uint   `json:"document_id" binding:"required"`
+		Voice          string `json:"voice"`
+		Pitch          string `json:"pitch"`
+		Rate           string `json:"rate"`
+		Volume         string `json:"volume"`
+		FootnotePolicy string `json:"footnote_policy"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		s.SendError(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	settings := speech.Request{Text: "-", Voice: req.Voice, Pitch: req.Pitch, Rate: req.Rate, Volume: req.Volume}
+	settings.SetRequestDefaults()
+	if err := settings.Validate(); err != nil {
+		s.SendError(c, http.StatusBadRequest, "Invalid synthesis settings", err.Error())
+		return
+	}
+
+	policy, err := preprocess.ParseFootnotePolicy(req.FootnotePolicy)
+	if err != nil {
+		s.SendError(c, http.StatusBadRequest, "Invalid footnote policy", err.Error())
+		return
+	}
+
+	doc, err := s.repo.GetDocument(CurrentUser(c), req.DocumentID)
+	if err != nil {
+		if errors.Is(err, data.ErrNotFound) {
+			s.SendError(c, http.StatusNotFound, "Document not found", "")
+		} else {
+			s.logger.Printf("document lookup error: %v", err)
+			s.SendError(c, http.StatusInternalServerError, "Failed to load document", "")
+		}
+		return
+	}
+
+	job := &data.Job{
+		Voice:          settings.Voice,
+		Pitch:          settings.Pitch,
+		Rate:           settings.Rate,
+		Volume:         settings.Volume,
+		FootnotePolicy: string(policy),
+	}
+	if err := s.repo.CreateJob(doc, job); err != nil {
+		s.logger.Printf("job creation error: %v", err)
+		s.SendError(c, http.StatusInternalServerError, "Failed to create job", "")
+		return
+	}
+	s.workers.Notify()
+
+	s.SendSuccess(c, http.StatusCreated, job)
+}
+
+func (s *Server) HandleListJobs(c *gin.Context) {
+	jobs, err := s.repo.ListJobs(CurrentUser(c))
+	if err != nil {
+		s.logger.Printf("job list error: %v", err)
+		s.SendError(c, http.StatusInternalServerError, "Failed to list jobs", "")
+		return
+	}
+
+	s.SendSuccess(c, http.StatusOK, jobs)
+}
+
+func (s *Server) HandleGetJob(c *gin.Context) {
+	job, ok := s.loadJob(c)
+	if !ok {
+		return
+	}
+
+	s.SendSuccess(c, http.StatusOK, job)
+}
+
+func (s *Server) HandleCancelJob(c *gin.Context) {
+	job, ok := s.loadJob(c)
+	if !ok {
+		return
+	}
+
+	if err := s.repo.CancelJob(job); err != nil {
+		if errors.Is(err, data.ErrInvalidState) {
+			s.SendError(c, http.StatusConflict, "Job is already finished", string(job.Status))
+		} else {
+			s.logger.Printf("job cancel error: %v", err)
+			s.SendError(c, http.StatusInternalServerError, "Failed to cancel job", "")
+		}
+		return
+	}
+	s.workers.Cancel(job.ID)
+	if job.Status == data.JobCanceled {
+		s.events.Publish(c.Request.Context(), events.JobTopic(job.ID), events.JobEvent(job, events.EventStatus))
+	}
+
+	s.SendSuccess(c, http.StatusAccepted, job)
+}
+
+func (s *Server) HandleJobEvents(c *gin.Context) {
+	job, ok := s.loadJob(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	stream, unsubscribe := s.events.Subscribe(ctx, events.JobTopic(job.ID))
+	defer unsubscribe()
+
+	if job, err := s.repo.JobByID(job.ID); err == nil {
+		snapshot := events.JobEvent(job, events.EventSnapshot)
+		if !s.sendEvent(c, snapshot) || snapshot.Terminal() {
+			return
+		}
+	}
+
+	keepAlive := time.NewTicker(s.config.EventKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-keepAlive.C:
+			if !s.sendEvent(c, events.Event{}) {
+				return
+			}
+		case event, ok := <-stream:
+			if !ok || !s.sendEvent(c, event) || event.Terminal() {
+				return
+			}
+		}
+	}
+}
+
+func (s *Server) sendEvent(c *gin.Context, event events.Event) bool {
+	if !c.Writer.Written() {
+		http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		c.Header("X-Accel-Buffering", "no")
+		c.Status(http.StatusOK)
+	}
+
+	if event.Type == "" {
+		if _, err := io.WriteString(c.Writer, ": keep-alive\n\n"); err != nil {
+			return false
+		}
+	} else {
+		c.SSEvent(string(event.Type), event)
+	}
+	c.Writer.Flush()
+	return c.Request.Context().Err() == nil
+}
+
+func (s *Server) loadJob(c *gin.Context) (*data.Job, bool) {
+	id, err := ParseIDParam(c, "id")
+	if err != nil {
+		s.SendError(c, http.StatusBadRequest, "Invalid job ID", "")
+		return nil, false
+	}
+
+	job, err := s.repo.GetJob(CurrentUser(c), id)
+	if err != nil {
+		if errors.Is(err, data.ErrNotFound) {
+			s.SendError(c, http.StatusNotFound, "Job not found", "")
+		} else {
+			s.logger.Printf("job lookup error: %v", err)
+			s.SendError(c, http.StatusInternalServerError, "Failed to load job", "")
+		}
+		return nil, false
+	}
+	return job, true
+}
@@ -2,13 +2,18 @@ package main
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 
 	"cadence/internal/data"
+	"cadence/internal/events"
+	"cadence/internal/jobs"
+	"cadence/internal/signing"
 	"cadence/internal/speech"
+	"cadence/internal/storage"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -17,6 +22,16 @@ import (
 func main() {
 	config := LoadServerConfig()
 	logger := InitLogger(config.Environment)
+	if err := config.Jobs.Validate(); err != nil {
+		logger.Fatal("invalid job configuration:", err)
+	}
+	if config.PublicURL == "" {
+		if config.Environment != "development" {
+			logger.Fatal("PUBLIC_URL must be set outside development")
+		}
+		config.PublicURL = LocalURL(config.Port)
+		logger.Printf("PUBLIC_URL not set, links will use %s", config.PublicURL)
+	}
 
 	db, err := gorm.Open(postgres.Open(os.Getenv("DATABASE_URL")), &gorm.Config{})
 	if err != nil {
@@ -32,7 +47,32 @@ func main() {
 		logger.Fatal("voice initialization failed:", err)
 	}
 
-	server := NewServer(config, repo, logger)
+	store, err := storage.New(config.Storage)
+	if err != nil {
+		logger.Fatal("storage initialization failed:", err)
+	}
+
+	broker := events.NewMemoryBroker()
+	workers := jobs.NewPool(repo, store, broker, config.Jobs, logger)
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	workers.Start(workerCtx)
+
+	keys, err := signing.ParseKeys(config.SigningKeys)
+	if errors.Is(err, signing.ErrNoKeys) {
+		logger.Println("SIGNING_KEYS not set, signed URLs will not survive a restart")
+		var key signing.Key
+		key, err = signing.GenerateKey()
+		keys = []signing.Key{key}
+	}
+	if err != nil {
+		logger.Fatal("signing key initialization failed:", err)
+	}
+	signer, err := signing.NewSigner(keys)
+	if err != nil {
+		logger.Fatal("signing key initialization failed:", err)
+	}
+
+	server := NewServer(config, repo, store, broker, workers, signer, logger)
 	srv := &http.Server{
 		Addr:         config.Port,
 		Handler:      server.router,
@@ -60,5 +100,8 @@ func main() {
 		logger.Fatal("forced shutdown:", err)
 	}
 
+	stopWorkers()
+	workers.Wait()
+
 	logger.Println("server stopped")
 }
\ No newline at end of file
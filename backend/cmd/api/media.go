@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"cadence/internal/data"
+	"cadence/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+type objectReader struct {
+	ctx    context.Context
+	store  storage.Storage
+	key    string
+	size   int64
+	offset int64
+	body   io.ReadCloser
+}
+
+func (r *objectReader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+	if r.body == nil {
+		body, err := r.store.GetRange(r.ctx, r.key, r.offset, -1)
+		if err != nil {
+			return 0, err
+		}
+		r.body = body
+	}
+
+	n, err := r.body.Read(p)
+	r.offset += int64(n)
+	if err == io.EOF && r.offset < r.size {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func (r *objectReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	}
+	if offset < 0 {
+		return 0, errors.New("negative seek position")
+	}
+
+	if offset != r.offset {
+		r.Close()
+		r.offset = offset
+	}
+	return offset, nil
+}
+
+func (r *objectReader) Close() error {
+	if r.body == nil {
+		return nil
+	}
+	err := r.body.Close()
+	r.body = nil
+	return err
+}
+
+func (s *Server) SetupSignedRoutes(rg *gin.RouterGroup) {
+	books := rg.Group("/books")
+	{
+		books.GET("/:id/cover", s.HandleGetBookCover)
+		books.GET("/:id/chapters/:position/audio", s.HandleChapterAudio)
+		books.HEAD("/:id/chapters/:position/audio", s.HandleChapterAudio)
+		books.GET("/:id/hls/index.m3u8", s.HandleHLSPlaylist)
+		books.HEAD("/:id/hls/index.m3u8", s.HandleHLSPlaylist)
+		books.GET("/:id/hls/:position/:segment", s.HandleHLSSegment)
+	}
+}
+
+func (s *Server) HandleCreateBookLinks(c *gin.Context) {
+	book, ok := s.loadBook(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		TTLSeconds int `json:"ttl_seconds"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			s.SendError(c, http.StatusBadRequest, "Invalid request body", err.Error())
+			return
+		}
+	}
+
+	ttl := s.config.SignedURLTTL
+	if req.TTLSeconds > 0 {
+		ttl = min(time.Duration(req.TTLSeconds)*time.Second, s.config.SignedURLMaxTTL)
+	}
+
+	user := CurrentUser(c)
+	type chapterLink struct {
+		Position int     `json:"position"`
+		Title    string  `json:"title"`
+		Duration float64 `json:"duration"`
+		URL      string  `json:"url"`
+	}
+	links := make([]chapterLink, 0, len(book.Chapters))
+	for _, ch := range book.Chapters {
+		links = append(links, chapterLink{
+			Position: ch.Position,
+			Title:    ch.Title,
+			Duration: ch.Duration,
+			URL:      s.SignedURL(fmt.Sprintf("/signed/books/%d/chapters/%d/audio", book.ID, ch.Position), user, ttl),
+		})
+	}
+
+	result := gin.H{
+		"expires_at": time.Now().Add(ttl).UTC(),
+		"chapters":   links,
+	}
+	if book.HasCover {
+		result["cover_url"] = s.SignedURL(fmt.Sprintf("/signed/books/%d/cover", book.ID), user, ttl)
+	}
+	if book.HasHLS {
+		result["playlist_url"] = s.SignedURL(fmt.Sprintf("/signed/books/%d/hls/index.m3u8", book.ID), user, ttl)
+	}
+
+	s.SendSuccess(c, http.StatusOK, result)
+}
+
+func (s *Server) HandleChapterAudio(c *gin.Context) {
+	book, ok := s.loadBook(c)
+	if !ok {
+		return
+	}
+
+	chapter, ok := s.findBookChapter(c, book)
+	if !ok {
+		return
+	}
+
+	name := fmt.Sprintf("%02d.mp3", chapter.Position+1)
+	s.serveObject(c, chapter.AudioKey, "audio/mpeg", name)
+}
+
+func (s *Server) findBookChapter(c *gin.Context, book *data.Book) (*data.BookChapter, bool) {
+	position, err := strconv.Atoi(c.Param("position"))
+	if err != nil || position < 0 || position >= len(book.Chapters) {
+		s.SendError(c, http.StatusNotFound, "Chapter not found", "")
+		return nil, false
+	}
+	return &book.Chapters[position], true
+}
+
+func (s *Server) serveObject(c *gin.Context, key, contentType, name string) {
+	ctx := c.Request.Context()
+	info, err := s.store.Stat(ctx, key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			s.SendError(c, http.StatusNotFound, "File not found", "")
+		} else {
+			s.logger.Printf("storage stat error: %v", err)
+			s.SendError(c, http.StatusInternalServerError, "Failed to read file", "")
+		}
+		return
+	}
+
+	http.NewResponseController(c.Writer).SetWriteDeadline(time.Now().Add(s.config.StreamTimeout))
+
+	etag := info.ETag
+	if etag == "" {
+		etag = fmt.Sprintf(`"%x-%x"`, info.ModTime.UnixNano(), info.Size)
+	}
+	c.Header("ETag", etag)
+	c.Header("Accept-Ranges", "bytes")
+	c.Header("Cache-Control", "private, max-age=0, must-revalidate")
+	c.Header("Content-Type", contentType)
+	if name != "" {
+		c.Header("Content-Disposition", fmt.Sprintf(`inline; filename="%s"`, name))
+	}
+
+	reader := &objectReader{ctx: ctx, store: s.store, key: key, size: info.Size}
+	defer reader.Close()
+	http.ServeContent(c.Writer, c.Request, "", info.ModTime, reader)
+}
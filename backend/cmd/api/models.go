@@ -3,8 +3,15 @@ package main
 import (
 	"time"
 	"log"
+	"strings"
 
 	"cadence/internal/data"
+	"cadence/internal/events"
+	"cadence/internal/export"
+	"cadence/internal/hls"
+	"cadence/internal/jobs"
+	"cadence/internal/signing"
+	"cadence/internal/storage"
 
 	"github.com/gin-gonic/gin"
 )
@@ -23,14 +30,32 @@ type ServerConfig struct {
 	ReadTimeout     time.Duration
 	WriteTimeout    time.Duration
 	IdleTimeout     time.Duration
+	Storage         storage.Config
+	MaxUploadSize   int64
+	UploadTimeout   time.Duration
+	UploadFormats   []string
+	Jobs            jobs.Config
+	EventKeepAlive  time.Duration
+	StreamTimeout   time.Duration
+	PublicURL       string
+	SigningKeys     string
+	SignedURLTTL    time.Duration
+	SignedURLMaxTTL time.Duration
+	ShareMaxTTL     time.Duration
 }
 
 type Server struct {
-	config  *ServerConfig
-	router  *gin.Engine
-	repo    *data.Repository
-	logger  *log.Logger
-	metrics *Metrics
+	config   *ServerConfig
+	router   *gin.Engine
+	repo     *data.Repository
+	store    storage.Storage
+	events   events.Broker
+	workers  *jobs.Pool
+	signer   *signing.Signer
+	exporter *export.Exporter
+	packager *hls.Packager
+	logger   *log.Logger
+	metrics  *Metrics
 }
 
 type Metrics struct {
@@ -48,20 +73,44 @@ func LoadServerConfig() *ServerConfig {
 		ReadTimeout:     time.Duration(GetEnvAsIntWithDefault("READ_TIMEOUT_SECONDS", 5))*time.Second,
 		WriteTimeout:    time.Duration(GetEnvAsIntWithDefault("WRITE_TIMEOUT_SECONDS", 10))*time.Second,
 		IdleTimeout:     time.Duration(GetEnvAsIntWithDefault("IDLE_TIMEOUT_SECONDS", 120))*time.Second,
+		Storage:         storage.LoadConfig(),
+		MaxUploadSize:   int64(GetEnvAsIntWithDefault("MAX_UPLOAD_MB", 100)) << 20,
+		UploadTimeout:   time.Duration(GetEnvAsIntWithDefault("UPLOAD_TIMEOUT_SECONDS", 300))*time.Second,
+		UploadFormats:   GetEnvAsSlice("UPLOAD_FORMATS", []string{"epub", "pdf", "docx", "odt", "txt", "md", "html"}),
+		Jobs: jobs.Config{
+			Workers:      GetEnvAsIntWithDefault("JOB_WORKERS", 2),
+			ChunkChars:   GetEnvAsIntWithDefault("JOB_CHUNK_CHARS", 3000),
+			MaxAttempts:  GetEnvAsIntWithDefault("JOB_MAX_ATTEMPTS", 3),
+			PollInterval: time.Duration(GetEnvAsIntWithDefault("JOB_POLL_SECONDS", 5))*time.Second,
+			Lease:        time.Duration(GetEnvAsIntWithDefault("JOB_LEASE_SECONDS", 120))*time.Second,
+		},
+		EventKeepAlive:  time.Duration(GetEnvAsIntWithDefault("EVENT_KEEPALIVE_SECONDS", 15))*time.Second,
+		StreamTimeout:   time.Duration(GetEnvAsIntWithDefault("STREAM_TIMEOUT_SECONDS", 3600))*time.Second,
+		PublicURL:       strings.TrimSuffix(GetEnvWithDefault("PUBLIC_URL", ""), "/"),
+		SigningKeys:     GetEnvWithDefault("SIGNING_KEYS", ""),
+		SignedURLTTL:    time.Duration(GetEnvAsIntWithDefault("SIGNED_URL_TTL_SECONDS", 3600))*time.Second,
+		SignedURLMaxTTL: time.Duration(GetEnvAsIntWithDefault("SIGNED_URL_MAX_TTL_SECONDS", 7*24*3600))*time.Second,
+		ShareMaxTTL:     time.Duration(GetEnvAsIntWithDefault("SHARE_MAX_TTL_SECONDS", 365*24*3600))*time.Second,
 	}
 }
 
-func NewServer(config *ServerConfig, repo *data.Repository, logger *log.Logger) *Server {
+func NewServer(config *ServerConfig, repo *data.Repository, store storage.Storage, broker events.Broker, workers *jobs.Pool, signer *signing.Signer, logger *log.Logger) *Server {
 	if config.Environment != "development" {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
 	s := &Server{
-		config:  config,
-		router:  gin.New(),
-		repo:    repo,
-		logger:  logger,
-		metrics: &Metrics{},
+		config:   config,
+		router:   gin.New(),
+		repo:     repo,
+		store:    store,
+		events:   broker,
+		workers:  workers,
+		signer:   signer,
+		exporter: export.New(repo, store, logger),
+		packager: hls.New(repo, store, logger),
+		logger:   logger,
+		metrics:  &Metrics{},
 	}
 
 	s.SetupMiddleware()
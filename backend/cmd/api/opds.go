@@ -0,0 +1,329 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"cadence/internal/data"
+	"cadence/internal/feeds"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	opdsVersionKey = "opds_version"
+	opdsExpiresKey = "opds_expires"
+
+	opdsV1 = "v1.2"
+	opdsV2 = "v2"
+
+	opdsPageSize = 50
+)
+
+func (s *Server) SetupOPDSRoutes() {
+	opds := s.router.Group("/opds")
+	opds.Use(s.OPDSAuthMiddleware())
+	{
+		for _, version := range []string{opdsV1, opdsV2} {
+			catalog := opds.Group("/"+version, setOPDSVersion(version))
+			catalog.GET("", s.HandleOPDSRoot)
+			catalog.GET("/recent", s.HandleOPDSRecent)
+			catalog.GET("/books", s.HandleOPDSBooks)
+			catalog.GET("/authors", s.HandleOPDSFacet("author", "/authors", "Authors"))
+			catalog.GET("/series", s.HandleOPDSFacet("series", "/series", "Series"))
+		}
+		opds.GET("/opensearch.xml", s.HandleOpenSearch)
+
+		books := opds.Group("/books")
+		books.GET("/:id/cover", s.HandleGetBookCover)
+		books.GET("/:id/original", s.HandleBookOriginal)
+		books.GET("/:id/export/epub", s.HandleExportEPUB)
+		books.GET("/:id/export/mp3", s.HandleExportMP3)
+	}
+}
+
+func setOPDSVersion(version string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(opdsVersionKey, version)
+		c.Next()
+	}
+}
+
+func (s *Server) HandleCreateOPDSLink(c *gin.Context) {
+	var req struct {
+		TTLSeconds int `json:"ttl_seconds"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			s.SendError(c, http.StatusBadRequest, "Invalid request body", err.Error())
+			return
+		}
+	}
+
+	ttl := s.config.SignedURLMaxTTL
+	if req.TTLSeconds > 0 {
+		ttl = min(time.Duration(req.TTLSeconds)*time.Second, s.config.SignedURLMaxTTL)
+	}
+
+	user := CurrentUser(c)
+	s.SendSuccess(c, http.StatusOK, gin.H{
+		"expires_at": time.Now().Add(ttl).UTC(),
+		"opds_1_2":   s.SignedURL("/opds/"+opdsV1, user, ttl),
+		"opds_2_0":   s.SignedURL("/opds/"+opdsV2, user, ttl),
+	})
+}
+
+func (s *Server) HandleOPDSRoot(c *gin.Context) {
+	catalog := s.newCatalog(c, "", "Cadence library", nil)
+	catalog.Navigation = []feeds.Link{
+		{Rel: feeds.RelSortNew, Href: s.opdsURL(c, s.opdsPath(c, "/recent"), nil), Type: feeds.TypeAcquisition, Title: "Recently added"},
+		{Rel: "subsection", Href: s.opdsURL(c, s.opdsPath(c, "/books"), nil), Type: feeds.TypeAcquisition, Title: "All books"},
+		{Rel: "subsection", Href: s.opdsURL(c, s.opdsPath(c, "/authors"), nil), Type: feeds.TypeNavigation, Title: "By author"},
+		{Rel: "subsection", Href: s.opdsURL(c, s.opdsPath(c, "/series"), nil), Type: feeds.TypeNavigation, Title: "By series"},
+	}
+
+	s.sendCatalog(c, catalog, feeds.TypeNavigation)
+}
+
+func (s *Server) HandleOPDSRecent(c *gin.Context) {
+	s.sendBookCatalog(c, "/recent", "Recently added", data.BookQuery{Sort: "created", Descending: true}, nil)
+}
+
+func (s *Server) HandleOPDSBooks(c *gin.Context) {
+	query := data.BookQuery{
+		Search: c.Query("q"),
+		Author: c.Query("author"),
+		Series: c.Query("series"),
+		Sort:   "title",
+	}
+	if query.Search == "" {
+		query.Search = c.Query("query")
+	}
+
+	title := "All books"
+	params := url.Values{}
+	switch {
+	case query.Search != "":
+		title = fmt.Sprintf("Search: %s", query.Search)
+		params.Set("q", query.Search)
+	case query.Series != "":
+		title = query.Series
+		query.Sort = "series"
+		params.Set("series", query.Series)
+	case query.Author != "":
+		title = query.Author
+		params.Set("author", query.Author)
+	}
+
+	s.sendBookCatalog(c, "/books", title, query, params)
+}
+
+func (s *Server) HandleOPDSFacet(field, path, title string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		facets, err := s.repo.BookFacets(CurrentUser(c), field)
+		if err != nil {
+			s.logger.Printf("opds facet error: %v", err)
+			s.SendError(c, http.StatusInternalServerError, "Failed to build catalog", "")
+			return
+		}
+
+		catalog := s.newCatalog(c, path, title, nil)
+		for _, facet := range facets {
+			catalog.Navigation = append(catalog.Navigation, feeds.Link{
+				Rel:   "subsection",
+				Href:  s.opdsURL(c, s.opdsPath(c, "/books"), url.Values{field: {facet.Value}}),
+				Type:  feeds.TypeAcquisition,
+				Title: facet.Value,
+				Count: facet.Count,
+			})
+		}
+
+		s.sendCatalog(c, catalog, feeds.TypeNavigation)
+	}
+}
+
+func (s *Server) HandleOpenSearch(c *gin.Context) {
+	// The template is built by hand: {searchTerms} must survive unescaped.
+	template := s.opdsURL(c, "/opds/"+opdsV1+"/books", nil)
+	if _, ok := c.Get(opdsExpiresKey); ok {
+		template += "&q={searchTerms}"
+	} else {
+		template += "?q={searchTerms}"
+	}
+
+	c.Header("Content-Type", feeds.TypeOpenSearch+"; charset=utf-8")
+	c.Status(http.StatusOK)
+	if err := feeds.OpenSearch("Search the Cadence library", template).Encode(c.Writer); err != nil {
+		s.logger.Printf("opensearch encode error: %v", err)
+	}
+}
+
+func (s *Server) HandleBookOriginal(c *gin.Context) {
+	book, ok := s.loadBook(c)
+	if !ok {
+		return
+	}
+
+	// Access was checked on the book, which may be shared with the user.
+	doc, err := s.repo.DocumentByID(book.DocumentID)
+	if err != nil {
+		if errors.Is(err, data.ErrNotFound) {
+			s.SendError(c, http.StatusNotFound, "Document not found", "")
+		} else {
+			s.logger.Printf("document lookup error: %v", err)
+			s.SendError(c, http.StatusInternalServerError, "Failed to load document", "")
+		}
+		return
+	}
+
+	s.serveObject(c, doc.StorageKey, doc.ContentType, doc.Filename)
+}
+
+func (s *Server) sendBookCatalog(c *gin.Context, path, title string, query data.BookQuery, params url.Values) {
+	user := CurrentUser(c)
+	page, _ := strconv.Atoi(c.Query("page"))
+	query.Page, query.PageSize = max(page, 1), opdsPageSize
+
+	books, total, err := s.repo.ListBooks(user, query)
+	if err != nil {
+		s.logger.Printf("opds book list error: %v", err)
+		s.SendError(c, http.StatusInternalServerError, "Failed to build catalog", "")
+		return
+	}
+
+	ids := make([]uint, 0, len(books))
+	for _, book := range books {
+		ids = append(ids, book.DocumentID)
+	}
+	types, err := s.repo.DocumentContentTypes(ids)
+	if err != nil {
+		s.logger.Printf("opds document lookup error: %v", err)
+		s.SendError(c, http.StatusInternalServerError, "Failed to build catalog", "")
+		return
+	}
+
+	catalog := s.newCatalog(c, path, title, params)
+	catalog.Total, catalog.Page, catalog.PageSize = int(total), query.Page, query.PageSize
+	pageLink := func(rel string, page int) feeds.Link {
+		values := url.Values{}
+		for k, v := range params {
+			values[k] = v
+		}
+		if page > 1 {
+			values.Set("page", strconv.Itoa(page))
+		}
+		return feeds.Link{Rel: rel, Href: s.opdsURL(c, s.opdsPath(c, path), values), Type: feeds.TypeAcquisition}
+	}
+	if query.Page > 1 {
+		catalog.Links = append(catalog.Links, pageLink("first", 1), pageLink("previous", query.Page-1))
+	}
+	if int64(query.Page*query.PageSize) < total {
+		catalog.Links = append(catalog.Links, pageLink("next", query.Page+1))
+	}
+
+	for i := range books {
+		book := &books[i]
+		prefix := fmt.Sprintf("/opds/books/%d", book.ID)
+		pub := feeds.Publication{Book: book}
+		if book.HasCover {
+			cover := s.opdsURL(c, prefix+"/cover", nil)
+			pub.Links = append(pub.Links,
+				feeds.Link{Rel: feeds.RelImage, Href: cover, Type: book.CoverType},
+				feeds.Link{Rel: feeds.RelThumbnail, Href: cover, Type: book.CoverType},
+			)
+		}
+		if book.ChapterCount > 0 {
+			pub.Links = append(pub.Links,
+				feeds.Link{Rel: feeds.RelAcquisition, Href: s.opdsURL(c, prefix+"/export/epub", nil), Type: "application/epub+zip", Title: "EPUB with read-along audio"},
+				feeds.Link{Rel: feeds.RelAcquisition, Href: s.opdsURL(c, prefix+"/export/mp3", nil), Type: "audio/mpeg", Title: "Audiobook (MP3)"},
+			)
+		}
+		if contentType, ok := types[book.DocumentID]; ok {
+			pub.Links = append(pub.Links, feeds.Link{Rel: feeds.RelAcquisition, Href: s.opdsURL(c, prefix+"/original", nil), Type: contentType, Title: "Original document"})
+		}
+		catalog.Publications = append(catalog.Publications, pub)
+	}
+
+	s.sendCatalog(c, catalog, feeds.TypeAcquisition)
+}
+
+func (s *Server) newCatalog(c *gin.Context, path, title string, params url.Values) *feeds.Catalog {
+	start := s.opdsURL(c, s.opdsPath(c, ""), nil)
+	catalog := &feeds.Catalog{
+		ID:      fmt.Sprintf("urn:cadence:opds:%d%s", CurrentUser(c).ID, path),
+		Title:   title,
+		Updated: time.Now(),
+		Links: []feeds.Link{
+			{Rel: "self", Href: s.opdsURL(c, s.opdsPath(c, path), params), Type: feeds.TypeNavigation},
+			{Rel: "start", Href: start, Type: feeds.TypeNavigation},
+		},
+	}
+	if path != "" {
+		catalog.Links = append(catalog.Links, feeds.Link{Rel: "up", Href: start, Type: feeds.TypeNavigation})
+	}
+
+	if s.opdsVersion(c) == opdsV1 {
+		catalog.Links = append(catalog.Links, feeds.Link{Rel: "search", Href: s.opdsURL(c, "/opds/opensearch.xml", nil), Type: feeds.TypeOpenSearch})
+	} else {
+		search := s.opdsURL(c, s.opdsPath(c, "/books"), nil)
+		if _, ok := c.Get(opdsExpiresKey); ok {
+			search += "{&query}"
+		} else {
+			search += "{?query}"
+		}
+		catalog.Links = append(catalog.Links, feeds.Link{Rel: "search", Href: search, Type: feeds.TypeAcquisition, Templated: true})
+	}
+	return catalog
+}
+
+func (s *Server) sendCatalog(c *gin.Context, catalog *feeds.Catalog, kind string) {
+	for i := range catalog.Links {
+		if catalog.Links[i].Rel == "self" {
+			catalog.Links[i].Type = kind
+		}
+	}
+
+	var err error
+	if s.opdsVersion(c) == opdsV1 {
+		c.Header("Content-Type", kind+"; charset=utf-8")
+		c.Status(http.StatusOK)
+		err = catalog.Atom().Encode(c.Writer)
+	} else {
+		c.Header("Content-Type", feeds.TypeOPDS2+"; charset=utf-8")
+		c.Status(http.StatusOK)
+		err = catalog.OPDS2().Encode(c.Writer)
+	}
+	if err != nil {
+		s.logger.Printf("opds encode error: %v", err)
+	}
+}
+
+func (s *Server) opdsVersion(c *gin.Context) string {
+	return c.GetString(opdsVersionKey)
+}
+
+func (s *Server) opdsPath(c *gin.Context, path string) string {
+	return "/opds/" + s.opdsVersion(c) + path
+}
+
+// opdsURL links to another catalog resource. Clients that arrived with a
+// signed URL have no other credentials, so every link they are given is
+// signed with the same expiry.
+func (s *Server) opdsURL(c *gin.Context, path string, query url.Values) string {
+	link := s.BaseURL() + path
+	if exp, ok := c.Get(opdsExpiresKey); ok {
+		link = s.signer.SignURL(s.BaseURL(), path, CurrentUser(c).ID, exp.(time.Time))
+		if len(query) > 0 {
+			link += "&" + query.Encode()
+		}
+		return link
+	}
+	if len(query) > 0 {
+		link += "?" + query.Encode()
+	}
+	return link
+}
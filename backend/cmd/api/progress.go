@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"cadence/internal/data"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Server) HandleListProgress(c *gin.Context) {
+	progress, err := s.repo.ListProgress(CurrentUser(c))
+	if err != nil {
+		s.logger.Printf("progress list error: %v", err)
+		s.SendError(c, http.StatusInternalServerError, "Failed to list progress", "")
+		return
+	}
+
+	s.SendSuccess(c, http.StatusOK, progress)
+}
+
+func (s *Server) HandleGetProgress(c *gin.Context) {
+	book, ok := s.loadBook(c)
+	if !ok {
+		return
+	}
+
+	progress, err := s.repo.GetProgress(CurrentUser(c), book.ID)
+	if err != nil {
+		if errors.Is(err, data.ErrNotFound) {
+			s.SendError(c, http.StatusNotFound, "No progress recorded", "")
+		} else {
+			s.logger.Printf("progress lookup error: %v", err)
+			s.SendError(c, http.StatusInternalServerError, "Failed to load progress", "")
+		}
+		return
+	}
+
+	s.SendSuccess(c, http.StatusOK, progress)
+}
+
+func (s *Server) HandleSyncProgress(c *gin.Context) {
+	book, ok := s.loadBook(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Chapter   *int      `json:"chapter" binding:"required"`
+		Offset    float64   `json:"offset"`
+		Speed     float64   `json:"speed"`
+		DeviceID  string    `json:"device_id"`
+		UpdatedAt time.Time `json:"updated_at"`
+		Strategy  string    `json:"strategy"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		s.SendError(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	strategy, err := data.ParseProgressStrategy(req.Strategy)
+	if err != nil {
+		s.SendError(c, http.StatusBadRequest, "Invalid strategy", err.Error())
+		return
+	}
+
+	incoming := &data.Progress{
+		Chapter:         *req.Chapter,
+		Offset:          req.Offset,
+		Speed:           req.Speed,
+		DeviceID:        req.DeviceID,
+		ClientUpdatedAt: req.UpdatedAt,
+	}
+	progress, accepted, err := s.repo.SyncProgress(CurrentUser(c), book, incoming, strategy)
+	if err != nil {
+		if errors.Is(err, data.ErrValidation) {
+			s.SendError(c, http.StatusBadRequest, "Validation error", err.Error())
+		} else {
+			s.logger.Printf("progress sync error: %v", err)
+			s.SendError(c, http.StatusInternalServerError, "Failed to sync progress", "")
+		}
+		return
+	}
+
+	s.SendSuccess(c, http.StatusOK, gin.H{
+		"progress": progress,
+		"accepted": accepted,
+	})
+}
@@ -20,6 +20,31 @@ func (s *Server) SetupRoutes() {
 	{
 		s.SetupUserRoutes(api)
 		s.SetupSpeechRoutes(api)
+		s.SetupDocumentRoutes(api)
+		s.SetupJobRoutes(api)
+		s.SetupBookRoutes(api)
+		api.GET("/progress", s.HandleListProgress)
+		s.SetupFeedRoutes(api)
+		api.POST("/opds/links", s.HandleCreateOPDSLink)
+		s.SetupSubsonicCredentialRoutes(api)
+		api.GET("/library/export", s.HandleExportLibrary)
+		api.GET("/search", s.HandleSearch)
+		s.SetupCollectionRoutes(api)
+		s.SetupTagRoutes(api)
+		s.SetupShelfRoutes(api)
+		api.GET("/shared", s.HandleSharedWithMe)
+		api.POST("/shares/accept", s.HandleAcceptShare)
+	}
+
+	s.SetupPodcastRoutes()
+	s.SetupOPDSRoutes()
+	s.SetupSubsonicRoutes()
+	s.SetupShareLinkRoutes()
+
+	signed := s.router.Group("/signed")
+	signed.Use(s.SignedURLMiddleware())
+	{
+		s.SetupSignedRoutes(signed)
 	}
 }
 
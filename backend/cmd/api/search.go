@@ -0,0 +1,111 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"cadence/internal/data"
+	"cadence/internal/storage"
+	"cadence/internal/syncmap"
+
+	"github.com/gin-gonic/gin"
+)
+
+type searchResult struct {
+	data.SearchHit
+	// Offset is where the first highlighted word is spoken in the chapter
+	// audio and Time the same point in the whole book. Both are absent when
+	// the chapter has no sync map or the word could not be placed.
+	Offset *float64 `json:"offset"`
+	Time   *float64 `json:"time"`
+}
+
+func (s *Server) HandleSearch(c *gin.Context) {
+	text := c.Query("q")
+	if text == "" {
+		s.SendError(c, http.StatusBadRequest, "Missing search query", "")
+		return
+	}
+
+	page, size := ParsePagination(c, 20, 50)
+	query := data.SearchQuery{Text: text, Page: page, PageSize: size}
+	if id := c.Query("book_id"); id != "" {
+		n, err := strconv.ParseUint(id, 10, 32)
+		if err != nil {
+			s.SendError(c, http.StatusBadRequest, "Invalid book ID", "")
+			return
+		}
+		query.BookID = uint(n)
+	}
+
+	user := CurrentUser(c)
+	hits, more, err := s.repo.Search(user, query)
+	if err != nil {
+		if errors.Is(err, data.ErrValidation) {
+			s.SendError(c, http.StatusBadRequest, "Invalid search query", err.Error())
+		} else {
+			s.logger.Printf("search error: %v", err)
+			s.SendError(c, http.StatusInternalServerError, "Failed to search library", "")
+		}
+		return
+	}
+
+	books := make(map[uint]*data.Book)
+	maps := make(map[uint]*syncmap.Map)
+	results := make([]searchResult, 0, len(hits))
+	for _, hit := range hits {
+		result := searchResult{SearchHit: hit}
+		book, ok := books[hit.BookID]
+		if !ok {
+			if book, err = s.repo.GetBook(user, hit.BookID); err != nil {
+				s.logger.Printf("book %d: search lookup error: %v", hit.BookID, err)
+			}
+			books[hit.BookID] = book
+		}
+		if book != nil && hit.Position < len(book.Chapters) {
+			chapter := &book.Chapters[hit.Position]
+			if offset, ok := s.locateHit(c, chapter, &hit, maps); ok {
+				t := chapter.Start + offset
+				result.Offset, result.Time = &offset, &t
+			}
+		}
+		results = append(results, result)
+	}
+
+	s.SendSuccess(c, http.StatusOK, gin.H{
+		"items":     results,
+		"page":      page,
+		"page_size": size,
+		"has_more":  more,
+	})
+}
+
+// locateHit finds when the first highlighted word of a hit is spoken,
+// using the words leading up to it to pick between repeats. Sync maps are
+// kept in maps by chapter ID, since a page of hits often shares chapters;
+// chapters without one are kept as nil.
+func (s *Server) locateHit(c *gin.Context, chapter *data.BookChapter, hit *data.SearchHit, maps map[uint]*syncmap.Map) (float64, bool) {
+	if len(hit.Highlights) == 0 {
+		return 0, false
+	}
+	m, ok := maps[chapter.ID]
+	if !ok {
+		var err error
+		if m, err = s.readSyncMap(c, chapter); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			s.logger.Printf("book %d: sync map error: %v", hit.BookID, err)
+		}
+		maps[chapter.ID] = m
+	}
+	if m == nil {
+		return 0, false
+	}
+
+	snippet := []rune(hit.Snippet)
+	first := hit.Highlights[0]
+	word, err := m.Find(string(snippet[:first[0]]), string(snippet[first[0]:first[1]]))
+	if err != nil {
+		return 0, false
+	}
+	return word.Begin, true
+}
@@ -2,9 +2,12 @@ package main
 
 import (
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"cadence/internal/data"
+	"cadence/internal/signing"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -45,10 +48,28 @@ func (s *Server) RequestIDMiddleware() gin.HandlerFunc {
 	}
 }
 
+// tokenPrefixes are the paths whose next segment is a token that grants
+// access on its own, so it is kept out of the request log. Matching the raw
+// path rather than the route also covers requests that match no route.
+var tokenPrefixes = []string{"/feeds/", "/share/"}
+
+func redactedPath(path string) string {
+	for _, prefix := range tokenPrefixes {
+		if rest, ok := strings.CutPrefix(path, prefix); ok && rest != "" {
+			tail := ""
+			if i := strings.IndexByte(rest, '/'); i >= 0 {
+				tail = rest[i:]
+			}
+			return prefix + "[redacted]" + tail
+		}
+	}
+	return path
+}
+
 func (s *Server) LoggingMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		start := time.Now()
-		path := c.Request.URL.Path
+		path := redactedPath(c.Request.URL.Path)
 		c.Next()
 		s.logger.Printf("| %3d | %13v | %15s | %s | %s |",
 			c.Writer.Status(),
@@ -75,18 +96,87 @@ func (s *Server) AuthenticationMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		token := c.GetHeader("Authorization")
 		if token == "" {
-			s.SendError(c, http.StatusUnauthorized, "Missing authorization header", "")
+			s.AbortWithError(c, http.StatusUnauthorized, "Missing authorization header", "")
 			return
 		}
 
 		user, err := s.repo.ValidateToken(token)
 		if err != nil {
-			s.SendError(c, http.StatusUnauthorized, "Invalid token", err.Error())
+			s.AbortWithError(c, http.StatusUnauthorized, "Invalid token", err.Error())
+			return
+		}
+
+		if err := s.repo.CheckAccess(user); err != nil {
+			s.AbortWithError(c, http.StatusTooManyRequests, "Rate limit exceeded", err.Error())
+			return
+		}
+
+		c.Set("user", user)
+		c.Next()
+	}
+}
+
+func (s *Server) SignedURLMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := s.signer.Verify(c.Request.URL.Path, c.Request.URL.Query(), time.Now())
+		if err != nil {
+			s.AbortWithError(c, http.StatusForbidden, "Invalid or expired signature", err.Error())
+			return
+		}
+
+		user, err := s.repo.UserByID(userID)
+		if err != nil {
+			s.AbortWithError(c, http.StatusForbidden, "Invalid or expired signature", "")
+			return
+		}
+
+		if err := s.repo.CheckAccess(user); err != nil {
+			s.AbortWithError(c, http.StatusTooManyRequests, "Rate limit exceeded", err.Error())
 			return
 		}
 
+		c.Set("user", user)
+		c.Next()
+	}
+}
+
+// OPDSAuthMiddleware accepts the credentials e-reader apps support: HTTP
+// basic auth with the account password, or a signed URL. Signed requests
+// keep their expiry so the catalog can sign the links it hands out.
+func (s *Server) OPDSAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var user *data.User
+		query := c.Request.URL.Query()
+		if query.Has(signing.ParamSignature) {
+			userID, err := s.signer.Verify(c.Request.URL.Path, query, time.Now())
+			if err != nil {
+				s.AbortWithError(c, http.StatusForbidden, "Invalid or expired signature", err.Error())
+				return
+			}
+			if user, err = s.repo.UserByID(userID); err != nil {
+				s.AbortWithError(c, http.StatusForbidden, "Invalid or expired signature", "")
+				return
+			}
+			exp, _ := strconv.ParseInt(query.Get(signing.ParamExpires), 10, 64)
+			c.Set(opdsExpiresKey, time.Unix(exp, 0))
+		} else {
+			username, password, ok := c.Request.BasicAuth()
+			if !ok {
+				c.Header("WWW-Authenticate", `Basic realm="Cadence", charset="UTF-8"`)
+				s.AbortWithError(c, http.StatusUnauthorized, "Authentication required", "")
+				return
+			}
+
+			var err error
+			if user, err = s.repo.AuthenticateUser(username, password); err != nil {
+				c.Header("WWW-Authenticate", `Basic realm="Cadence", charset="UTF-8"`)
+				s.AbortWithError(c, http.StatusUnauthorized, "Invalid credentials", "")
+				return
+			}
+		}
+
 		if err := s.repo.CheckAccess(user); err != nil {
-			s.SendError(c, http.StatusTooManyRequests, "Rate limit exceeded", err.Error())
+			s.AbortWithError(c, http.StatusTooManyRequests, "Rate limit exceeded", err.Error())
 			return
 		}
 
@@ -99,12 +189,12 @@ func (s *Server) RequirePermission(_ string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		user, exists := c.Get("user")
 		if !exists {
-			s.SendError(c, http.StatusUnauthorized, "User not found in context", "")
+			s.AbortWithError(c, http.StatusUnauthorized, "User not found in context", "")
 			return
 		}
 
 		if typedUser, ok := user.(*data.User); !ok || !typedUser.IsAdmin {
-			s.SendError(c, http.StatusForbidden, "Insufficient permissions", "")
+			s.AbortWithError(c, http.StatusForbidden, "Insufficient permissions", "")
 			return
 		}
 
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"cadence/internal/data"
+
+	"github.com/gin-gonic/gin"
+)
+
+const sharedBookKey = "shared_book"
+
+// SetupShareLinkRoutes serves books to anyone holding a share link. Links
+// only ever grant listening; editing needs the link accepted by an account.
+func (s *Server) SetupShareLinkRoutes() {
+	share := s.router.Group("/share/:token")
+	share.Use(s.ShareLinkMiddleware())
+	{
+		share.GET("", s.HandleSharedBook)
+		share.GET("/cover", s.HandleSharedBookCover)
+		share.GET("/chapters/:position/audio", s.HandleSharedChapterAudio)
+		share.HEAD("/chapters/:position/audio", s.HandleSharedChapterAudio)
+		share.GET("/chapters/:position/sync", s.HandleSharedChapterSync)
+	}
+}
+
+// ShareLinkMiddleware resolves the link token to its book. Like feed
+// tokens, unknown, expired and revoked links are indistinguishable.
+// Listening through a link counts against the owner's rate limit.
+func (s *Server) ShareLinkMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		share, book, err := s.repo.ShareByToken(c.Param("token"))
+		if err != nil {
+			if !errors.Is(err, data.ErrNotFound) {
+				s.logger.Printf("share link lookup error: %v", err)
+			}
+			s.AbortWithError(c, http.StatusNotFound, "Share link not found", "")
+			return
+		}
+
+		if err := s.repo.CheckAccess(share.Owner); err != nil {
+			s.AbortWithError(c, http.StatusTooManyRequests, "Rate limit exceeded", err.Error())
+			return
+		}
+
+		c.Set(sharedBookKey, book)
+		c.Next()
+	}
+}
+
+func (s *Server) HandleListShares(c *gin.Context) {
+	book, ok := s.loadOwnedBook(c)
+	if !ok {
+		return
+	}
+
+	shares, err := s.repo.ListShares(book)
+	if err != nil {
+		s.logger.Printf("share list error: %v", err)
+		s.SendError(c, http.StatusInternalServerError, "Failed to list shares", "")
+		return
+	}
+
+	s.SendSuccess(c, http.StatusOK, shares)
+}
+
+// HandleCreateShare grants a user access to the book, or creates a share
+// link when no username is given. A link's URL is only returned here.
+func (s *Server) HandleCreateShare(c *gin.Context) {
+	book, ok := s.loadOwnedBook(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Username   string `json:"username"`
+		Permission string `json:"permission"`
+		TTLSeconds int    `json:"ttl_seconds"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		s.SendError(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	permission, err := data.ParseSharePermission(req.Permission)
+	if err != nil {
+		s.SendError(c, http.StatusBadRequest, "Invalid permission", err.Error())
+		return
+	}
+	// The TTL is compared in seconds before converting, since a large
+	// value would overflow the duration.
+	var expiresAt *time.Time
+	if req.TTLSeconds > 0 {
+		ttl := s.config.ShareMaxTTL
+		if req.TTLSeconds < int(ttl/time.Second) {
+			ttl = time.Duration(req.TTLSeconds) * time.Second
+		}
+		t := time.Now().Add(ttl)
+		expiresAt = &t
+	}
+
+	if req.Username == "" {
+		share, err := s.repo.CreateShareLink(book, permission, expiresAt)
+		if err != nil {
+			s.logger.Printf("share link creation error: %v", err)
+			s.SendError(c, http.StatusInternalServerError, "Failed to create share link", "")
+			return
+		}
+
+		s.SendSuccess(c, http.StatusCreated, gin.H{
+			"share": share,
+			"url":   s.BaseURL() + "/share/" + share.PlainText,
+			"token": share.PlainText,
+		})
+		return
+	}
+
+	grantee, err := s.repo.UserByUsername(req.Username)
+	if err != nil {
+		if errors.Is(err, data.ErrNotFound) {
+			s.SendError(c, http.StatusNotFound, "User not found", "")
+		} else {
+			s.logger.Printf("user lookup error: %v", err)
+			s.SendError(c, http.StatusInternalServerError, "Failed to share book", "")
+		}
+		return
+	}
+
+	share, err := s.repo.ShareBook(book, grantee, permission, expiresAt)
+	if err != nil {
+		if errors.Is(err, data.ErrValidation) {
+			s.SendError(c, http.StatusBadRequest, "Validation error", err.Error())
+		} else {
+			s.logger.Printf("share creation error: %v", err)
+			s.SendError(c, http.StatusInternalServerError, "Failed to share book", "")
+		}
+		return
+	}
+
+	s.SendSuccess(c, http.StatusCreated, gin.H{"share": share})
+}
+
+func (s *Server) HandleUpdateShare(c *gin.Context) {
+	share, ok := s.loadShare(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Permission string `json:"permission" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		s.SendError(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	permission, err := data.ParseSharePermission(req.Permission)
+	if err != nil {
+		s.SendError(c, http.StatusBadRequest, "Invalid permission", err.Error())
+		return
+	}
+
+	if err := s.repo.UpdateShare(share, permission); err != nil {
+		s.logger.Printf("share update error: %v", err)
+		s.SendError(c, http.StatusInternalServerError, "Failed to update share", "")
+		return
+	}
+
+	s.SendSuccess(c, http.StatusOK, share)
+}
+
+func (s *Server) HandleRevokeShare(c *gin.Context) {
+	share, ok := s.loadShare(c)
+	if !ok {
+		return
+	}
+
+	if err := s.repo.RevokeShare(share); err != nil {
+		s.logger.Printf("share revocation error: %v", err)
+		s.SendError(c, http.StatusInternalServerError, "Failed to revoke share", "")
+		return
+	}
+
+	s.SendSuccess(c, http.StatusOK, nil)
+}
+
+// HandleSharedWithMe lists the books other users have shared with the
+// current user.
+func (s *Server) HandleSharedWithMe(c *gin.Context) {
+	shares, err := s.repo.SharedBooks(CurrentUser(c))
+	if err != nil {
+		s.logger.Printf("shared book list error: %v", err)
+		s.SendError(c, http.StatusInternalServerError, "Failed to list shared books", "")
+		return
+	}
+
+	s.SendSuccess(c, http.StatusOK, shares)
+}
+
+// HandleAcceptShare adds a share link's book to the user's shared books
+// with the permission the link carries.
+func (s *Server) HandleAcceptShare(c *gin.Context) {
+	var req struct {
+		Token string `json:"token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		s.SendError(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	share, err := s.repo.AcceptShareLink(CurrentUser(c), req.Token)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNotFound):
+			s.SendError(c, http.StatusNotFound, "Share link not found", "")
+		case errors.Is(err, data.ErrValidation):
+			s.SendError(c, http.StatusBadRequest, "Validation error", err.Error())
+		default:
+			s.logger.Printf("share accept error: %v", err)
+			s.SendError(c, http.StatusInternalServerError, "Failed to accept share", "")
+		}
+		return
+	}
+
+	s.SendSuccess(c, http.StatusOK, share)
+}
+
+func (s *Server) HandleSharedBook(c *gin.Context) {
+	book := sharedBook(c)
+	base := s.BaseURL() + "/share/" + c.Param("token")
+
+	type chapterLink struct {
+		Position int     `json:"position"`
+		Title    string  `json:"title"`
+		Duration float64 `json:"duration"`
+		URL      string  `json:"url"`
+		SyncURL  string  `json:"sync_url,omitempty"`
+	}
+	links := make([]chapterLink, 0, len(book.Chapters))
+	for _, ch := range book.Chapters {
+		link := chapterLink{
+			Position: ch.Position,
+			Title:    ch.Title,
+			Duration: ch.Duration,
+			URL:      fmt.Sprintf("%s/chapters/%d/audio", base, ch.Position),
+		}
+		if ch.HasSync {
+			link.SyncURL = fmt.Sprintf("%s/chapters/%d/sync", base, ch.Position)
+		}
+		links = append(links, link)
+	}
+
+	// Link holders are anonymous, so they only get what a player needs and
+	// nothing about the owner's account or storage.
+	result := gin.H{
+		"book": gin.H{
+			"id":           book.ID,
+			"title":        book.Title,
+			"author":       book.Author,
+			"series":       book.Series,
+			"series_index": book.SeriesIndex,
+			"narrator":     book.Narrator,
+			"description":  book.Description,
+			"language":     book.Language,
+			"duration":     book.Duration,
+		},
+		"chapters": links,
+	}
+	if book.HasCover {
+		result["cover_url"] = base + "/cover"
+	}
+	s.SendSuccess(c, http.StatusOK, result)
+}
+
+func (s *Server) HandleSharedBookCover(c *gin.Context) {
+	book := sharedBook(c)
+	if book.CoverKey == "" {
+		s.SendError(c, http.StatusNotFound, "Book has no cover", "")
+		return
+	}
+
+	s.serveObject(c, book.CoverKey, book.CoverType, "")
+}
+
+func (s *Server) HandleSharedChapterAudio(c *gin.Context) {
+	chapter, ok := s.findBookChapter(c, sharedBook(c))
+	if !ok {
+		return
+	}
+
+	name := fmt.Sprintf("%02d.mp3", chapter.Position+1)
+	s.serveObject(c, chapter.AudioKey, "audio/mpeg", name)
+}
+
+func (s *Server) HandleSharedChapterSync(c *gin.Context) {
+	chapter, ok := s.findBookChapter(c, sharedBook(c))
+	if !ok {
+		return
+	}
+	if chapter.SyncKey == "" {
+		s.SendError(c, http.StatusNotFound, "No sync map for this chapter", "")
+		return
+	}
+
+	s.serveObject(c, chapter.SyncKey, "application/json", "")
+}
+
+func (s *Server) loadShare(c *gin.Context) (*data.BookShare, bool) {
+	book, ok := s.loadOwnedBook(c)
+	if !ok {
+		return nil, false
+	}
+
+	id, err := ParseIDParam(c, "share")
+	if err != nil {
+		s.SendError(c, http.StatusBadRequest, "Invalid share ID", "")
+		return nil, false
+	}
+
+	share, err := s.repo.GetShare(book, id)
+	if err != nil {
+		if errors.Is(err, data.ErrNotFound) {
+			s.SendError(c, http.StatusNotFound, "Share not found", "")
+		} else {
+			s.logger.Printf("share lookup error: %v", err)
+			s.SendError(c, http.StatusInternalServerError, "Failed to load share", "")
+		}
+		return nil, false
+	}
+	return share, true
+}
+
+func sharedBook(c *gin.Context) *data.Book {
+	book, _ := c.MustGet(sharedBookKey).(*data.Book)
+	return book
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"cadence/internal/data"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Server) SetupShelfRoutes(rg *gin.RouterGroup) {
+	shelves := rg.Group("/shelves")
+	{
+		shelves.GET("", s.HandleListShelves)
+		shelves.POST("", s.HandleCreateShelf)
+		shelves.GET("/:id", s.HandleGetShelf)
+		shelves.PATCH("/:id", s.HandleUpdateShelf)
+		shelves.DELETE("/:id", s.HandleDeleteShelf)
+		shelves.GET("/:id/books", s.HandleShelfBooks)
+	}
+}
+
+func (s *Server) HandleListShelves(c *gin.Context) {
+	shelves, err := s.repo.ListShelves(CurrentUser(c))
+	if err != nil {
+		s.logger.Printf("shelf list error: %v", err)
+		s.SendError(c, http.StatusInternalServerError, "Failed to list shelves", "")
+		return
+	}
+
+	s.SendSuccess(c, http.StatusOK, shelves)
+}
+
+func (s *Server) HandleCreateShelf(c *gin.Context) {
+	var req struct {
+		Name   string           `json:"name" binding:"required"`
+		Filter data.ShelfFilter `json:"filter"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		s.SendError(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	shelf := &data.Shelf{Name: req.Name, Filter: req.Filter}
+	if err := s.repo.CreateShelf(CurrentUser(c), shelf); err != nil {
+		s.sendShelfError(c, err, "shelf creation error", "Failed to create shelf")
+		return
+	}
+
+	s.SendSuccess(c, http.StatusCreated, shelf)
+}
+
+func (s *Server) HandleGetShelf(c *gin.Context) {
+	shelf, ok := s.loadShelf(c)
+	if !ok {
+		return
+	}
+
+	s.SendSuccess(c, http.StatusOK, shelf)
+}
+
+func (s *Server) HandleUpdateShelf(c *gin.Context) {
+	shelf, ok := s.loadShelf(c)
+	if !ok {
+		return
+	}
+
+	var update data.ShelfUpdate
+	if err := c.ShouldBindJSON(&update); err != nil {
+		s.SendError(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	if err := s.repo.UpdateShelf(shelf, update); err != nil {
+		s.sendShelfError(c, err, "shelf update error", "Failed to update shelf")
+		return
+	}
+
+	s.SendSuccess(c, http.StatusOK, shelf)
+}
+
+func (s *Server) HandleDeleteShelf(c *gin.Context) {
+	shelf, ok := s.loadShelf(c)
+	if !ok {
+		return
+	}
+
+	if err := s.repo.DeleteShelf(shelf); err != nil {
+		s.logger.Printf("shelf deletion error: %v", err)
+		s.SendError(c, http.StatusInternalServerError, "Failed to delete shelf", "")
+		return
+	}
+
+	s.SendSuccess(c, http.StatusOK, nil)
+}
+
+// HandleShelfBooks lists the books currently matching the shelf's filter.
+func (s *Server) HandleShelfBooks(c *gin.Context) {
+	shelf, ok := s.loadShelf(c)
+	if !ok {
+		return
+	}
+
+	page, size := ParsePagination(c, 20, 100)
+	books, total, err := s.repo.ListBooks(CurrentUser(c), shelf.Filter.Query(page, size))
+	if err != nil {
+		s.logger.Printf("shelf books error: %v", err)
+		s.SendError(c, http.StatusInternalServerError, "Failed to list books", "")
+		return
+	}
+
+	s.SendSuccess(c, http.StatusOK, gin.H{
+		"items":     books,
+		"total":     total,
+		"page":      page,
+		"page_size": size,
+	})
+}
+
+func (s *Server) sendShelfError(c *gin.Context, err error, logMessage, message string) {
+	switch {
+	case errors.Is(err, data.ErrValidation):
+		s.SendError(c, http.StatusBadRequest, "Validation error", err.Error())
+	case errors.Is(err, data.ErrNameTaken):
+		s.SendError(c, http.StatusConflict, "A shelf with this name already exists", "")
+	default:
+		s.logger.Printf("%s: %v", logMessage, err)
+		s.SendError(c, http.StatusInternalServerError, message, "")
+	}
+}
+
+func (s *Server) loadShelf(c *gin.Context) (*data.Shelf, bool) {
+	id, err := ParseIDParam(c, "id")
+	if err != nil {
+		s.SendError(c, http.StatusBadRequest, "Invalid shelf ID", "")
+		return nil, false
+	}
+
+	shelf, err := s.repo.GetShelf(CurrentUser(c), id)
+	if err != nil {
+		if errors.Is(err, data.ErrNotFound) {
+			s.SendError(c, http.StatusNotFound, "Shelf not found", "")
+		} else {
+			s.logger.Printf("shelf lookup error: %v", err)
+			s.SendError(c, http.StatusInternalServerError, "Failed to load shelf", "")
+		}
+		return nil, false
+	}
+	return shelf, true
+}
@@ -0,0 +1,376 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"cadence/internal/data"
+	"cadence/internal/subsonic"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	subsonicDefaultListSize = 10
+	subsonicMaxListSize     = 500
+)
+
+func (s *Server) SetupSubsonicRoutes() {
+	rest := s.router.Group("/rest")
+	rest.Use(s.SubsonicAuthMiddleware())
+	{
+		endpoints := map[string]gin.HandlerFunc{
+			"ping":              s.HandleSubsonicPing,
+			"getLicense":        s.HandleSubsonicLicense,
+			"getMusicDirectory": s.HandleSubsonicDirectory,
+			"getAlbum":          s.HandleSubsonicAlbum,
+			"getAlbumList2":     s.HandleSubsonicAlbumList,
+			"stream":            s.HandleSubsonicStream,
+			"download":          s.HandleSubsonicStream,
+			"getCoverArt":       s.HandleSubsonicCoverArt,
+			"scrobble":          s.HandleSubsonicScrobble,
+		}
+		// Clients call either /rest/name or /rest/name.view, with parameters
+		// in the query string or a form body.
+		for name, handler := range endpoints {
+			rest.Match([]string{http.MethodGet, http.MethodPost}, "/"+name, handler)
+			rest.Match([]string{http.MethodGet, http.MethodPost}, "/"+name+".view", handler)
+		}
+	}
+}
+
+func (s *Server) SetupSubsonicCredentialRoutes(rg *gin.RouterGroup) {
+	credentials := rg.Group("/subsonic/credentials")
+	{
+		credentials.GET("", s.HandleGetSubsonicCredential)
+		credentials.POST("", s.HandleRotateSubsonicCredential)
+		credentials.DELETE("", s.HandleDeleteSubsonicCredential)
+	}
+}
+
+// SubsonicAuthMiddleware authenticates with the user's Subsonic app
+// password, sent as u plus either t and s (token and salt) or p.
+func (s *Server) SubsonicAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		username := c.Request.FormValue("u")
+		token, salt, password := c.Request.FormValue("t"), c.Request.FormValue("s"), c.Request.FormValue("p")
+		if username == "" || (token == "" && password == "") || (token != "" && salt == "") {
+			s.sendSubsonicError(c, subsonic.ErrMissingParameter, "Required parameter is missing")
+			c.Abort()
+			return
+		}
+
+		user, credential, err := s.repo.SubsonicLogin(username)
+		if err != nil || !subsonic.CheckPassword(credential.Password, token, salt, password) {
+			s.sendSubsonicError(c, subsonic.ErrWrongCredentials, "Wrong username or password")
+			c.Abort()
+			return
+		}
+
+		if err := s.repo.CheckAccess(user); err != nil {
+			s.sendSubsonicError(c, subsonic.ErrNotAuthorized, err.Error())
+			c.Abort()
+			return
+		}
+
+		if credential.LastUsedAt == nil || time.Since(*credential.LastUsedAt) > time.Minute {
+			if err := s.repo.TouchSubsonicCredential(credential); err != nil {
+				s.logger.Printf("subsonic credential update error: %v", err)
+			}
+		}
+
+		c.Set("user", user)
+		c.Next()
+	}
+}
+
+func (s *Server) HandleGetSubsonicCredential(c *gin.Context) {
+	credential, err := s.repo.GetSubsonicCredential(CurrentUser(c))
+	if err != nil {
+		if errors.Is(err, data.ErrNotFound) {
+			s.SendError(c, http.StatusNotFound, "No Subsonic password set", "")
+		} else {
+			s.logger.Printf("subsonic credential lookup error: %v", err)
+			s.SendError(c, http.StatusInternalServerError, "Failed to load Subsonic credentials", "")
+		}
+		return
+	}
+
+	s.SendSuccess(c, http.StatusOK, credential)
+}
+
+func (s *Server) HandleRotateSubsonicCredential(c *gin.Context) {
+	user := CurrentUser(c)
+	credential, err := s.repo.RotateSubsonicPassword(user)
+	if err != nil {
+		s.logger.Printf("subsonic credential error: %v", err)
+		s.SendError(c, http.StatusInternalServerError, "Failed to create Subsonic password", "")
+		return
+	}
+
+	s.SendSuccess(c, http.StatusCreated, gin.H{
+		"server":   s.BaseURL(),
+		"username": user.Username,
+		"password": credential.Password,
+	})
+}
+
+func (s *Server) HandleDeleteSubsonicCredential(c *gin.Context) {
+	if err := s.repo.DeleteSubsonicCredential(CurrentUser(c)); err != nil {
+		if errors.Is(err, data.ErrNotFound) {
+			s.SendError(c, http.StatusNotFound, "No Subsonic password set", "")
+		} else {
+			s.logger.Printf("subsonic credential delete error: %v", err)
+			s.SendError(c, http.StatusInternalServerError, "Failed to revoke Subsonic password", "")
+		}
+		return
+	}
+
+	s.SendSuccess(c, http.StatusOK, nil)
+}
+
+func (s *Server) HandleSubsonicPing(c *gin.Context) {
+	s.sendSubsonic(c, subsonic.OK())
+}
+
+func (s *Server) HandleSubsonicLicense(c *gin.Context) {
+	resp := subsonic.OK()
+	resp.License = &subsonic.License{Valid: true}
+	s.sendSubsonic(c, resp)
+}
+
+func (s *Server) HandleSubsonicDirectory(c *gin.Context) {
+	book, ok := s.loadSubsonicAlbum(c)
+	if !ok {
+		return
+	}
+
+	resp := subsonic.OK()
+	resp.Directory = &subsonic.Directory{
+		ID:    subsonic.AlbumID(book.ID),
+		Name:  book.Title,
+		Child: subsonic.NewAlbumDetail(book, nil).Song,
+	}
+	s.sendSubsonic(c, resp)
+}
+
+func (s *Server) HandleSubsonicAlbum(c *gin.Context) {
+	book, ok := s.loadSubsonicAlbum(c)
+	if !ok {
+		return
+	}
+
+	var played *time.Time
+	if progress, err := s.repo.GetProgress(CurrentUser(c), book.ID); err == nil {
+		played = &progress.ClientUpdatedAt
+	}
+
+	resp := subsonic.OK()
+	resp.Album = subsonic.NewAlbumDetail(book, played)
+	s.sendSubsonic(c, resp)
+}
+
+func (s *Server) HandleSubsonicAlbumList(c *gin.Context) {
+	listType := c.Request.FormValue("type")
+	if listType == "" {
+		s.sendSubsonicError(c, subsonic.ErrMissingParameter, "Required parameter is missing: type")
+		return
+	}
+
+	size, offset := subsonicDefaultListSize, 0
+	if n, err := strconv.Atoi(c.Request.FormValue("size")); err == nil && n > 0 {
+		size = min(n, subsonicMaxListSize)
+	}
+	if n, err := strconv.Atoi(c.Request.FormValue("offset")); err == nil && n > 0 {
+		offset = n
+	}
+
+	user := CurrentUser(c)
+	query := data.BookQuery{Page: 1, PageSize: size, Offset: offset}
+	var books []data.Book
+	var err error
+	switch listType {
+	case "random":
+		query.Sort = "random"
+	case "newest":
+		query.Sort, query.Descending = "created", true
+	case "alphabeticalByName":
+		query.Sort = "title"
+	case "alphabeticalByArtist":
+		query.Sort = "author"
+	case "byGenre":
+		if strings.EqualFold(c.Request.FormValue("genre"), subsonic.Genre) {
+			query.Sort = "title"
+		}
+	case "recent", "frequent":
+		books, err = s.repo.RecentlyPlayedBooks(user, offset, size)
+	case "starred", "highest", "byYear":
+		// Books have no ratings, stars or release year.
+	default:
+		s.sendSubsonicError(c, subsonic.ErrGeneric, fmt.Sprintf("Unsupported list type: %s", listType))
+		return
+	}
+	if query.Sort != "" {
+		books, _, err = s.repo.ListBooks(user, query)
+	}
+	if err != nil {
+		s.logger.Printf("subsonic album list error: %v", err)
+		s.sendSubsonicError(c, subsonic.ErrGeneric, "Failed to list albums")
+		return
+	}
+
+	list := &subsonic.AlbumList{Album: make([]subsonic.Album, 0, len(books))}
+	for i := range books {
+		list.Album = append(list.Album, subsonic.NewAlbum(&books[i], nil))
+	}
+	resp := subsonic.OK()
+	resp.AlbumList2 = list
+	s.sendSubsonic(c, resp)
+}
+
+func (s *Server) HandleSubsonicStream(c *gin.Context) {
+	bookID, position, err := subsonic.ParseSongID(c.Request.FormValue("id"))
+	if err != nil {
+		s.sendSubsonicError(c, subsonic.ErrDataNotFound, "Song not found")
+		return
+	}
+
+	book, ok := s.loadSubsonicBook(c, bookID)
+	if !ok {
+		return
+	}
+	if position >= len(book.Chapters) {
+		s.sendSubsonicError(c, subsonic.ErrDataNotFound, "Song not found")
+		return
+	}
+
+	chapter := &book.Chapters[position]
+	s.serveObject(c, chapter.AudioKey, "audio/mpeg", fmt.Sprintf("%02d.mp3", chapter.Position+1))
+}
+
+func (s *Server) HandleSubsonicCoverArt(c *gin.Context) {
+	bookID, err := subsonic.ParseCoverID(c.Request.FormValue("id"))
+	if err != nil {
+		s.sendSubsonicError(c, subsonic.ErrDataNotFound, "Cover art not found")
+		return
+	}
+
+	book, ok := s.loadSubsonicBook(c, bookID)
+	if !ok {
+		return
+	}
+	if !book.HasCover {
+		s.sendSubsonicError(c, subsonic.ErrDataNotFound, "Cover art not found")
+		return
+	}
+
+	s.serveObject(c, book.CoverKey, book.CoverType, "")
+}
+
+// HandleSubsonicScrobble records plays as listening progress. A submission
+// means the chapter was finished, so progress moves to the start of the
+// next one; a now-playing notification only moves progress forward, since
+// it carries no offset and would otherwise rewind a half-heard chapter.
+func (s *Server) HandleSubsonicScrobble(c *gin.Context) {
+	ids := c.Request.Form["id"]
+	if len(ids) == 0 {
+		s.sendSubsonicError(c, subsonic.ErrMissingParameter, "Required parameter is missing: id")
+		return
+	}
+	times := c.Request.Form["time"]
+	submission := c.Request.FormValue("submission") != "false"
+
+	deviceID := "subsonic"
+	if client := c.Request.FormValue("c"); client != "" {
+		deviceID += ":" + client
+	}
+	if len(deviceID) > 128 {
+		deviceID = deviceID[:128]
+	}
+
+	user := CurrentUser(c)
+	books := map[uint]*data.Book{}
+	for i, id := range ids {
+		bookID, position, err := subsonic.ParseSongID(id)
+		if err != nil {
+			s.sendSubsonicError(c, subsonic.ErrDataNotFound, "Song not found")
+			return
+		}
+
+		book, ok := books[bookID]
+		if !ok {
+			if book, ok = s.loadSubsonicBook(c, bookID); !ok {
+				return
+			}
+			books[bookID] = book
+		}
+		if position >= len(book.Chapters) {
+			s.sendSubsonicError(c, subsonic.ErrDataNotFound, "Song not found")
+			return
+		}
+
+		progress := &data.Progress{Chapter: position, DeviceID: deviceID}
+		strategy := data.ProgressFurthest
+		if submission {
+			strategy = data.ProgressLatest
+			if position+1 < len(book.Chapters) {
+				progress.Chapter = position + 1
+			} else {
+				progress.Offset = book.Chapters[position].Duration
+			}
+		}
+		if i < len(times) {
+			if ms, err := strconv.ParseInt(times[i], 10, 64); err == nil && ms > 0 {
+				progress.ClientUpdatedAt = time.UnixMilli(ms)
+			}
+		}
+
+		if _, _, err := s.repo.SyncProgress(user, book, progress, strategy); err != nil {
+			s.logger.Printf("subsonic scrobble error: %v", err)
+			s.sendSubsonicError(c, subsonic.ErrGeneric, "Failed to record progress")
+			return
+		}
+	}
+
+	s.sendSubsonic(c, subsonic.OK())
+}
+
+func (s *Server) loadSubsonicAlbum(c *gin.Context) (*data.Book, bool) {
+	bookID, err := subsonic.ParseAlbumID(c.Request.FormValue("id"))
+	if err != nil {
+		s.sendSubsonicError(c, subsonic.ErrDataNotFound, "Album not found")
+		return nil, false
+	}
+	return s.loadSubsonicBook(c, bookID)
+}
+
+func (s *Server) loadSubsonicBook(c *gin.Context, id uint) (*data.Book, bool) {
+	book, err := s.repo.GetBook(CurrentUser(c), id)
+	if err != nil {
+		if errors.Is(err, data.ErrNotFound) {
+			s.sendSubsonicError(c, subsonic.ErrDataNotFound, "Album not found")
+		} else {
+			s.logger.Printf("subsonic book lookup error: %v", err)
+			s.sendSubsonicError(c, subsonic.ErrGeneric, "Failed to load album")
+		}
+		return nil, false
+	}
+	return book, true
+}
+
+func (s *Server) sendSubsonicError(c *gin.Context, code subsonic.ErrorCode, message string) {
+	s.sendSubsonic(c, subsonic.Failure(code, message))
+}
+
+func (s *Server) sendSubsonic(c *gin.Context, resp *subsonic.Response) {
+	format, callback := c.Request.FormValue("f"), c.Request.FormValue("callback")
+	c.Header("Content-Type", subsonic.ContentType(format, callback))
+	c.Status(http.StatusOK)
+	if err := resp.Encode(c.Writer, format, callback); err != nil {
+		s.logger.Printf("subsonic encode error: %v", err)
+	}
+}
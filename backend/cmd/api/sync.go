@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"cadence/internal/data"
+	"cadence/internal/storage"
+	"cadence/internal/syncmap"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Server) HandleChapterSync(c *gin.Context) {
+	book, ok := s.loadBook(c)
+	if !ok {
+		return
+	}
+
+	chapter, ok := s.findBookChapter(c, book)
+	if !ok {
+		return
+	}
+	if chapter.SyncKey == "" {
+		s.SendError(c, http.StatusNotFound, "No sync map for this chapter", "")
+		return
+	}
+
+	s.serveObject(c, chapter.SyncKey, "application/json", "")
+}
+
+func (s *Server) HandleSyncLocate(c *gin.Context) {
+	m, ok := s.loadSyncMap(c)
+	if !ok {
+		return
+	}
+
+	t, err := syncmap.ParseTime(c.Query("time"))
+	if err != nil {
+		s.SendError(c, http.StatusBadRequest, "Invalid time", "use seconds, mm:ss or hh:mm:ss")
+		return
+	}
+
+	location, err := m.At(t)
+	if err != nil {
+		s.SendError(c, http.StatusNotFound, "Time is outside the chapter", err.Error())
+		return
+	}
+
+	s.SendSuccess(c, http.StatusOK, location)
+}
+
+func (s *Server) HandleSyncParagraph(c *gin.Context) {
+	m, ok := s.loadSyncMap(c)
+	if !ok {
+		return
+	}
+
+	index, err := strconv.Atoi(c.Param("index"))
+	if err != nil {
+		s.SendError(c, http.StatusBadRequest, "Invalid paragraph index", "")
+		return
+	}
+
+	paragraph, err := m.Paragraph(index)
+	if err != nil {
+		s.SendError(c, http.StatusNotFound, "Paragraph not found", "")
+		return
+	}
+
+	s.SendSuccess(c, http.StatusOK, paragraph)
+}
+
+func (s *Server) loadSyncMap(c *gin.Context) (*syncmap.Map, bool) {
+	book, ok := s.loadBook(c)
+	if !ok {
+		return nil, false
+	}
+
+	chapter, ok := s.findBookChapter(c, book)
+	if !ok {
+		return nil, false
+	}
+
+	m, err := s.readSyncMap(c, chapter)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			s.SendError(c, http.StatusNotFound, "No sync map for this chapter", "")
+		} else {
+			s.logger.Printf("sync map error: %v", err)
+			s.SendError(c, http.StatusInternalServerError, "Failed to load sync map", "")
+		}
+		return nil, false
+	}
+	return m, true
+}
+
+func (s *Server) readSyncMap(c *gin.Context, chapter *data.BookChapter) (*syncmap.Map, error) {
+	if chapter.SyncKey == "" {
+		return nil, storage.ErrNotFound
+	}
+
+	r, err := s.store.Get(c.Request.Context(), chapter.SyncKey)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return syncmap.Decode(r)
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"cadence/internal/data"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Server) SetupTagRoutes(rg *gin.RouterGroup) {
+	tags := rg.Group("/tags")
+	{
+		tags.GET("", s.HandleListTags)
+		tags.POST("", s.HandleCreateTag)
+		tags.PATCH("/:id", s.HandleRenameTag)
+		tags.DELETE("/:id", s.HandleDeleteTag)
+	}
+}
+
+func (s *Server) HandleListTags(c *gin.Context) {
+	tags, err := s.repo.ListTags(CurrentUser(c))
+	if err != nil {
+		s.logger.Printf("tag list error: %v", err)
+		s.SendError(c, http.StatusInternalServerError, "Failed to list tags", "")
+		return
+	}
+
+	s.SendSuccess(c, http.StatusOK, tags)
+}
+
+func (s *Server) HandleCreateTag(c *gin.Context) {
+	var req struct {
+		Name string `json:"name" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		s.SendError(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	tag, err := s.repo.CreateTag(CurrentUser(c), req.Name)
+	if err != nil {
+		s.sendTagError(c, err, "tag creation error", "Failed to create tag")
+		return
+	}
+
+	s.SendSuccess(c, http.StatusCreated, tag)
+}
+
+func (s *Server) HandleRenameTag(c *gin.Context) {
+	tag, ok := s.loadTag(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Name string `json:"name" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		s.SendError(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	if err := s.repo.RenameTag(tag, req.Name); err != nil {
+		s.sendTagError(c, err, "tag update error", "Failed to rename tag")
+		return
+	}
+
+	s.SendSuccess(c, http.StatusOK, tag)
+}
+
+func (s *Server) HandleDeleteTag(c *gin.Context) {
+	tag, ok := s.loadTag(c)
+	if !ok {
+		return
+	}
+
+	if err := s.repo.DeleteTag(tag); err != nil {
+		s.logger.Printf("tag deletion error: %v", err)
+		s.SendError(c, http.StatusInternalServerError, "Failed to delete tag", "")
+		return
+	}
+
+	s.SendSuccess(c, http.StatusOK, nil)
+}
+
+func (s *Server) HandleGetBookTags(c *gin.Context) {
+	book, ok := s.loadBook(c)
+	if !ok {
+		return
+	}
+
+	tags, err := s.repo.BookTags(CurrentUser(c), book)
+	if err != nil {
+		s.logger.Printf("book tags error: %v", err)
+		s.SendError(c, http.StatusInternalServerError, "Failed to load tags", "")
+		return
+	}
+
+	s.SendSuccess(c, http.StatusOK, tags)
+}
+
+// HandleSetBookTags replaces the user's tags on a book by name, creating
+// new tags as needed. Tags are the user's own, so read-only grantees may
+// tag shared books too.
+func (s *Server) HandleSetBookTags(c *gin.Context) {
+	book, ok := s.loadBook(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Tags []string `json:"tags"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		s.SendError(c, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	tags, err := s.repo.SetBookTags(CurrentUser(c), book, req.Tags)
+	if err != nil {
+		s.sendTagError(c, err, "book tags error", "Failed to update tags")
+		return
+	}
+
+	s.SendSuccess(c, http.StatusOK, tags)
+}
+
+func (s *Server) sendTagError(c *gin.Context, err error, logMessage, message string) {
+	switch {
+	case errors.Is(err, data.ErrValidation):
+		s.SendError(c, http.StatusBadRequest, "Validation error", err.Error())
+	case errors.Is(err, data.ErrNameTaken):
+		s.SendError(c, http.StatusConflict, "A tag with this name already exists", "")
+	default:
+		s.logger.Printf("%s: %v", logMessage, err)
+		s.SendError(c, http.StatusInternalServerError, message, "")
+	}
+}
+
+func (s *Server) loadTag(c *gin.Context) (*data.Tag, bool) {
+	id, err := ParseIDParam(c, "id")
+	if err != nil {
+		s.SendError(c, http.StatusBadRequest, "Invalid tag ID", "")
+		return nil, false
+	}
+
+	tag, err := s.repo.GetTag(CurrentUser(c), id)
+	if err != nil {
+		if errors.Is(err, data.ErrNotFound) {
+			s.SendError(c, http.StatusNotFound, "Tag not found", "")
+		} else {
+			s.logger.Printf("tag lookup error: %v", err)
+			s.SendError(c, http.StatusInternalServerError, "Failed to load tag", "")
+		}
+		return nil, false
+	}
+	return tag, true
+}
@@ -0,0 +1,133 @@
+// Command export writes a user's finished books in the folder layout
+// Audiobookshelf and Plex import directly, either into a directory or as a
+// zip archive.
+//
+//	export -user alice -out /srv/audiobooks
+//	export -user alice -book 12 -book 15 -zip - > books.zip
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"syscall"
+
+	"cadence/internal/data"
+	"cadence/internal/export"
+	"cadence/internal/storage"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+type bookIDs []uint
+
+func (b *bookIDs) String() string {
+	return fmt.Sprint([]uint(*b))
+}
+
+func (b *bookIDs) Set(value string) error {
+	id, err := strconv.ParseUint(value, 10, 32)
+	if err != nil || id == 0 {
+		return fmt.Errorf("invalid book id %q", value)
+	}
+	*b = append(*b, uint(id))
+	return nil
+}
+
+func main() {
+	var books bookIDs
+	username := flag.String("user", "", "export this user's library (required)")
+	out := flag.String("out", "", "directory to export into")
+	archive := flag.String("zip", "", "zip file to write, or - for standard output")
+	flag.Var(&books, "book", "export only this book ID (repeatable)")
+	flag.Parse()
+
+	logger := log.New(os.Stderr, "", log.Ldate|log.Ltime)
+	if *username == "" || (*out == "") == (*archive == "") {
+		fmt.Fprintln(os.Stderr, "usage: export -user NAME (-out DIR | -zip FILE) [-book ID ...]")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+
+	db, err := gorm.Open(postgres.Open(os.Getenv("DATABASE_URL")), &gorm.Config{Logger: gormlogger.Default.LogMode(gormlogger.Silent)})
+	if err != nil {
+		logger.Fatal("database connection failed:", err)
+	}
+	repo := data.NewRepository(db)
+
+	store, err := storage.New(storage.LoadConfig())
+	if err != nil {
+		logger.Fatal("storage initialization failed:", err)
+	}
+
+	user, err := repo.UserByUsername(*username)
+	if err != nil {
+		logger.Fatalf("user %q: %v", *username, err)
+	}
+	library, err := repo.LibraryBooks(user)
+	if err != nil {
+		logger.Fatal("library lookup failed:", err)
+	}
+	if len(books) > 0 {
+		library = slices.DeleteFunc(library, func(book data.Book) bool {
+			return !slices.Contains(books, book.ID)
+		})
+		for _, id := range books {
+			if !slices.ContainsFunc(library, func(book data.Book) bool { return book.ID == id }) {
+				logger.Fatalf("book %d not found in %s's library", id, user.Username)
+			}
+		}
+	}
+
+	var sink export.Sink
+	var file *os.File
+	switch {
+	case *out != "":
+		if sink, err = export.NewDirSink(*out); err != nil {
+			logger.Fatal("output directory:", err)
+		}
+	case *archive == "-":
+		sink = export.NewZipSink(os.Stdout)
+	default:
+		if file, err = os.Create(*archive); err != nil {
+			logger.Fatal("output file:", err)
+		}
+		sink = export.NewZipSink(file)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	exporter := export.New(repo, store, logger)
+	err = exporter.WriteLibrary(ctx, sink, library)
+	stop()
+	if err == nil {
+		err = sink.Close()
+	}
+	if file != nil {
+		// Fatal skips deferred calls, so the archive is closed here and a
+		// partial one removed rather than left looking complete.
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(file.Name())
+		}
+	}
+	if err != nil {
+		logger.Fatal("export failed:", err)
+	}
+
+	count := 0
+	for _, book := range library {
+		if len(book.Chapters) > 0 {
+			count++
+		}
+	}
+	logger.Printf("exported %d books", count)
+}
@@ -1,12 +1,17 @@
 package data
 
 import (
+	"cmp"
+	"crypto/sha256"
 	"errors"
 	"fmt"
 	"os"
 	"strconv"
 	"strings"
 	"unicode"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
 func GetEnvAsInt(key string) int {
@@ -51,3 +56,273 @@ func (r *Repository) ValidatePassword(password string) error {
 	}
 	return nil
 }
+
+func likePattern(value string) string {
+	replacer := strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_")
+	return "%" + replacer.Replace(strings.TrimSpace(value)) + "%"
+}
+
+func (q *BookQuery) orderClause() string {
+	column, ok := bookSortColumns[q.Sort]
+	if !ok {
+		column = bookSortColumns["created"]
+	}
+
+	direction := " ASC"
+	if q.Descending {
+		direction = " DESC"
+	}
+	parts := strings.Split(column, ", ")
+	for i := range parts {
+		parts[i] += direction
+	}
+	return strings.Join(parts, ", ") + ", id" + direction
+}
+
+func (u *BookUpdate) Validate() error {
+	if u.Title != nil && strings.TrimSpace(*u.Title) == "" {
+		return errors.New("title cannot be empty")
+	}
+	for _, field := range []*string{u.Title, u.Author, u.Series, u.Narrator, u.Language} {
+		if field != nil && len(*field) > 512 {
+			return errors.New("metadata field too long")
+		}
+	}
+	if u.SeriesIndex != nil && *u.SeriesIndex < 0 {
+		return errors.New("series index cannot be negative")
+	}
+	return nil
+}
+
+// normalizeName trims a user-chosen name and checks its length.
+func normalizeName(name string, maxLength int) (string, error) {
+	name = strings.Join(strings.Fields(name), " ")
+	if name == "" {
+		return "", errors.New("name cannot be empty")
+	}
+	if utf8.RuneCountInString(name) > maxLength {
+		return "", fmt.Errorf("name longer than %d characters", maxLength)
+	}
+	return name, nil
+}
+
+// nameError reports a write rejected by a per-user unique name index as
+// ErrNameTaken, and any other failure as a database error.
+func nameError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+		return ErrNameTaken
+	}
+	return errors.Join(ErrDatabase, err)
+}
+
+func ParseBookStatus(value string) (BookStatus, error) {
+	switch status := BookStatus(strings.ToLower(strings.TrimSpace(value))); status {
+	case "", BookUnstarted, BookUnfinished, BookFinished:
+		return status, nil
+	}
+	return "", fmt.Errorf("unknown book status %q", value)
+}
+
+func (f *ShelfFilter) Validate() error {
+	status, err := ParseBookStatus(string(f.Status))
+	if err != nil {
+		return err
+	}
+	f.Status = status
+	if _, ok := bookSortColumns[f.Sort]; f.Sort != "" && !ok {
+		return fmt.Errorf("unknown sort %q", f.Sort)
+	}
+	for _, field := range []*string{&f.Search, &f.Author, &f.Series, &f.Narrator, &f.Language, &f.Voice, &f.Tag} {
+		if *field = strings.TrimSpace(*field); len(*field) > 512 {
+			return errors.New("filter field too long")
+		}
+	}
+	return nil
+}
+
+// Query is the book list query for one page of the shelf.
+func (f *ShelfFilter) Query(page, size int) BookQuery {
+	return BookQuery{
+		Search:		f.Search,
+		Author:		f.Author,
+		Series:		f.Series,
+		Narrator:	f.Narrator,
+		Language:	f.Language,
+		Voice:		f.Voice,
+		Tag:		f.Tag,
+		Status:		f.Status,
+		Sort:		cmp.Or(f.Sort, "created"),
+		Descending:	f.Descending,
+		Page:		page,
+		PageSize:	size,
+	}
+}
+
+// ParseSharePermission accepts the access levels that can be granted.
+func ParseSharePermission(value string) (BookAccess, error) {
+	switch permission := BookAccess(strings.ToLower(strings.TrimSpace(value))); permission {
+	case "":
+		return AccessRead, nil
+	case AccessRead, AccessEdit:
+		return permission, nil
+	}
+	return "", fmt.Errorf("unknown share permission %q", value)
+}
+
+func (b *Book) CanEdit() bool {
+	return b.Access == AccessOwner || b.Access == AccessEdit
+}
+
+func (b *Book) IsOwner() bool {
+	return b.Access == AccessOwner
+}
+
+// upgradesGrant reports whether accepting a link with the link permission
+// raises an existing grant. Links never lower a grant.
+func upgradesGrant(existing, link BookAccess) bool {
+	return existing == AccessRead && link == AccessEdit
+}
+
+func ParseProgressStrategy(value string) (ProgressStrategy, error) {
+	switch strategy := ProgressStrategy(strings.ToLower(strings.TrimSpace(value))); strategy {
+	case "":
+		return ProgressLatest, nil
+	case ProgressLatest, ProgressFurthest:
+		return strategy, nil
+	}
+	return "", fmt.Errorf("unknown progress strategy %q", value)
+}
+
+func (p *Progress) Validate(book *Book) error {
+	if p.Chapter < 0 || p.Chapter >= len(book.Chapters) {
+		return errors.New("chapter out of range")
+	}
+	chapter := book.Chapters[p.Chapter]
+	if p.Offset < 0 || p.Offset > chapter.Duration+1 {
+		return errors.New("offset out of range")
+	}
+	if p.Speed == 0 {
+		p.Speed = 1
+	}
+	if p.Speed < 0.25 || p.Speed > 4 {
+		return errors.New("speed must be between 0.25 and 4")
+	}
+	if len(p.DeviceID) > 128 {
+		return errors.New("device id too long")
+	}
+
+	p.Offset = min(p.Offset, chapter.Duration)
+	p.Position = chapter.Start + p.Offset
+	return nil
+}
+
+func (b *Bookmark) Validate(book *Book) error {
+	if b.Chapter < 0 || b.Chapter >= len(book.Chapters) {
+		return errors.New("chapter out of range")
+	}
+	chapter := book.Chapters[b.Chapter]
+	if b.Offset < 0 || b.Offset > chapter.Duration+1 {
+		return errors.New("offset out of range")
+	}
+	if (b.TextStart == nil) != (b.TextEnd == nil) {
+		return errors.New("text_start and text_end must be given together")
+	}
+	if b.TextStart != nil && (*b.TextStart < 0 || *b.TextEnd < *b.TextStart) {
+		return errors.New("invalid text range")
+	}
+	if err := b.validateText(); err != nil {
+		return err
+	}
+
+	b.Offset = min(b.Offset, chapter.Duration)
+	b.Position = chapter.Start + b.Offset
+	return nil
+}
+
+// validateText trims the user's text and derives the kind from it.
+func (b *Bookmark) validateText() error {
+	b.Title = strings.TrimSpace(b.Title)
+	b.Note = strings.TrimSpace(b.Note)
+	if utf8.RuneCountInString(b.Title) > maxBookmarkTitle {
+		return errors.New("title too long")
+	}
+	if utf8.RuneCountInString(b.Note) > maxBookmarkNote {
+		return errors.New("note too long")
+	}
+
+	b.Kind = BookmarkPlain
+	if b.Note != "" {
+		b.Kind = BookmarkNote
+	}
+	return nil
+}
+
+func (p *Progress) supersedes(current *Progress, strategy ProgressStrategy) bool {
+	byTime := p.ClientUpdatedAt.Compare(current.ClientUpdatedAt)
+	byPosition := cmp.Compare(p.Position, current.Position)
+
+	order := []int{byTime, byPosition}
+	if strategy == ProgressFurthest {
+		order = []int{byPosition, byTime}
+	}
+	for _, c := range order {
+		if c != 0 {
+			return c > 0
+		}
+	}
+	return p.DeviceID > current.DeviceID
+}
+
+// hashToken is how feed and share link tokens are stored, so the database
+// alone does not give out working URLs.
+func hashToken(token string) []byte {
+	sum := sha256.Sum256([]byte(token))
+	return sum[:]
+}
+
+// searchConfig picks the text search configuration for a BCP 47 language
+// tag by its primary subtag.
+func searchConfig(language string) string {
+	primary, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(language)), "-")
+	primary, _, _ = strings.Cut(primary, "_")
+	if config, ok := searchConfigs[primary]; ok {
+		return config
+	}
+	return "simple"
+}
+
+// parseHeadline strips the match markers from a headline, collapsing runs
+// of whitespace, and reports where the marked ranges fell in the result.
+func parseHeadline(headline string) (string, [][2]int) {
+	var b strings.Builder
+	var highlights [][2]int
+	n, start, space := 0, -1, false
+	write := func(r rune) {
+		b.WriteRune(r)
+		n++
+	}
+	for _, r := range strings.TrimSpace(headline) {
+		switch {
+		case unicode.IsSpace(r):
+			space = true
+			continue
+		case space:
+			write(' ')
+			space = false
+		}
+
+		switch string(r) {
+		case headlineStart:
+			start = n
+		case headlineStop:
+			if start >= 0 && n > start {
+				highlights = append(highlights, [2]int{start, n})
+			}
+			start = -1
+		default:
+			write(r)
+		}
+	}
+	return b.String(), highlights
+}
@@ -0,0 +1,93 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestParseSharePermission(t *testing.T) {
+	tests := []struct {
+		value		string
+		want		BookAccess
+		canEdit		bool
+		wantErr		bool
+	}{
+		{"", AccessRead, false, false},
+		{"read", AccessRead, false, false},
+		{" Edit ", AccessEdit, true, false},
+		{"owner", "", false, true},
+		{"admin", "", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseSharePermission(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSharePermission(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseSharePermission(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+			if err != nil {
+				return
+			}
+
+			book := &Book{Access: got}
+			if book.CanEdit() != tt.canEdit {
+				t.Errorf("CanEdit() = %v, want %v", book.CanEdit(), tt.canEdit)
+			}
+			if book.IsOwner() {
+				t.Error("a granted permission must never make the grantee an owner")
+			}
+		})
+	}
+}
+
+func TestUpgradesGrant(t *testing.T) {
+	tests := []struct {
+		existing	BookAccess
+		link		BookAccess
+		want		bool
+	}{
+		{AccessRead, AccessRead, false},
+		{AccessRead, AccessEdit, true},
+		{AccessEdit, AccessRead, false},
+		{AccessEdit, AccessEdit, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s by %s", tt.existing, tt.link), func(t *testing.T) {
+			if got := upgradesGrant(tt.existing, tt.link); got != tt.want {
+				t.Errorf("upgradesGrant(%q, %q) = %v, want %v", tt.existing, tt.link, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNameError(t *testing.T) {
+	tests := []struct {
+		name	string
+		err		error
+		want	error
+	}{
+		{"unique violation", &pgconn.PgError{Code: uniqueViolation}, ErrNameTaken},
+		{"wrapped unique violation", fmt.Errorf("insert: %w", &pgconn.PgError{Code: uniqueViolation}), ErrNameTaken},
+		{"other constraint", &pgconn.PgError{Code: "23503"}, ErrDatabase},
+		{"other error", errors.New("connection reset"), ErrDatabase},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := nameError(tt.err)
+			if !errors.Is(got, tt.want) {
+				t.Errorf("nameError() = %v, want %v", got, tt.want)
+			}
+			if tt.want == ErrNameTaken && errors.Is(got, ErrDatabase) {
+				t.Error("a taken name must not be reported as a database error")
+			}
+		})
+	}
+}
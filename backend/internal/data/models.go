@@ -15,6 +15,31 @@ var (
 	ErrValidation			= errors.New("validation error")
 	ErrDatabase				= errors.New("database error")
 	ErrUnauthorized			= errors.New("unauthorized access")
+	ErrNotFound				= errors.New("record not found")
+	ErrInvalidState			= errors.New("invalid state transition")
+	ErrLeaseLost			= errors.New("job lease lost")
+	ErrNameTaken			= errors.New("name already in use")
+)
+
+const (
+	maxBookmarkTitle		= 255
+	maxBookmarkNote			= 20000
+	maxNameLength			= 255
+	maxTagLength			= 64
+	feedTokenBytes			= 32
+	shareTokenBytes			= 32
+	subsonicPasswordBytes	= 18
+
+	// A book counts as finished once progress is within this many seconds
+	// of its end, since players rarely report the very last second.
+	finishedMargin			= 30.0
+
+	// shareUseInterval is how stale a share link's last use may get before
+	// another request through it is recorded.
+	shareUseInterval		= time.Minute
+
+	// uniqueViolation is the PostgreSQL error code for a duplicate key.
+	uniqueViolation			= "23505"
 )
 
 type DataConfig struct {
@@ -65,6 +90,417 @@ type Token struct {
 	ExpiresAt		time.Time			`gorm:"not null;index" json:"expires_at"`
 }
 
+type Document struct {
+	Base
+	UserID			uint				`gorm:"not null;index" json:"user_id"`
+	Title			string				`gorm:"size:512;not null" json:"title"`
+	Authors			[]string			`gorm:"serializer:json" json:"authors"`
+	Language		string				`gorm:"size:35" json:"language,omitempty"`
+	Format			string				`gorm:"size:16;not null" json:"format"`
+	Filename		string				`gorm:"size:255" json:"filename"`
+	ContentType		string				`gorm:"size:127" json:"content_type"`
+	Size			int64				`gorm:"not null" json:"size"`
+	Checksum		string				`gorm:"size:64;index" json:"checksum"`
+	StorageKey		string				`gorm:"size:512;not null" json:"-"`
+	CoverKey		string				`gorm:"size:512" json:"-"`
+	CoverType		string				`gorm:"size:127" json:"cover_type,omitempty"`
+	Properties		map[string]string	`gorm:"serializer:json" json:"properties,omitempty"`
+	CharCount		int					`gorm:"not null" json:"char_count"`
+	ChapterCount	int					`gorm:"not null" json:"chapter_count"`
+	Chapters		[]Chapter			`gorm:"constraint:OnDelete:CASCADE" json:"chapters,omitempty"`
+}
+
+type Chapter struct {
+	Base
+	DocumentID		uint				`gorm:"not null;index:idx_chapter_position,unique" json:"document_id"`
+	Position		int					`gorm:"not null;index:idx_chapter_position,unique" json:"position"`
+	Title			string				`gorm:"size:512" json:"title"`
+	Text			string				`gorm:"type:text;not null" json:"-"`
+	Notes			map[string]string	`gorm:"serializer:json" json:"-"`
+	CharCount		int					`gorm:"not null" json:"char_count"`
+	// SearchConfig is the text search configuration the chapter was indexed
+	// with. SearchVector is maintained in SQL and never loaded.
+	SearchConfig	string				`gorm:"size:32;not null;default:simple" json:"-"`
+	SearchVector	string				`gorm:"type:tsvector;->:false;<-:false" json:"-"`
+}
+
+type JobStatus string
+
+const (
+	JobQueued		JobStatus = "queued"
+	JobRunning		JobStatus = "running"
+	JobCompleted	JobStatus = "completed"
+	JobFailed		JobStatus = "failed"
+	JobCanceled		JobStatus = "canceled"
+)
+
+type Job struct {
+	Base
+	UserID				uint				`gorm:"not null;index" json:"user_id"`
+	DocumentID			uint				`gorm:"not null;index" json:"document_id"`
+	Status				JobStatus			`gorm:"size:16;not null;index" json:"status"`
+	Voice				string				`gorm:"size:255;not null" json:"voice"`
+	Pitch				string				`gorm:"size:16;not null" json:"pitch"`
+	Rate				string				`gorm:"size:16;not null" json:"rate"`
+	Volume				string				`gorm:"size:16;not null" json:"volume"`
+	FootnotePolicy		string				`gorm:"size:16;not null" json:"footnote_policy"`
+	TotalChapters		int					`gorm:"not null" json:"total_chapters"`
+	CompletedChapters	int					`gorm:"not null;default:0" json:"completed_chapters"`
+	TotalChars			int					`gorm:"not null" json:"total_chars"`
+	CompletedChars		int					`gorm:"not null;default:0" json:"completed_chars"`
+	Attempts			int					`gorm:"not null;default:0" json:"attempts"`
+	Error				string				`gorm:"type:text" json:"error,omitempty"`
+	CancelRequested		bool				`gorm:"not null;default:false" json:"cancel_requested"`
+	WorkerID			string				`gorm:"size:64" json:"-"`
+	HeartbeatAt			*time.Time			`gorm:"index" json:"-"`
+	StartedAt			*time.Time			`json:"started_at,omitempty"`
+	FinishedAt			*time.Time			`json:"finished_at,omitempty"`
+	BookID				*uint				`gorm:"index" json:"book_id,omitempty"`
+	Chapters			[]JobChapter		`gorm:"constraint:OnDelete:CASCADE" json:"chapters,omitempty"`
+}
+
+type JobChapter struct {
+	Base
+	JobID				uint				`gorm:"not null;index:idx_job_chapter_position,unique" json:"job_id"`
+	ChapterID			uint				`gorm:"not null;index" json:"chapter_id"`
+	Position			int					`gorm:"not null;index:idx_job_chapter_position,unique" json:"position"`
+	Title				string				`gorm:"size:512" json:"title"`
+	Status				JobStatus			`gorm:"size:16;not null" json:"status"`
+	ChunkCount			int					`gorm:"not null;default:0" json:"chunk_count"`
+	CompletedChunks		int					`gorm:"not null;default:0" json:"completed_chunks"`
+	CharCount			int					`gorm:"not null" json:"char_count"`
+	CompletedChars		int					`gorm:"not null;default:0" json:"completed_chars"`
+	AudioKey			string				`gorm:"size:512" json:"-"`
+	SyncKey				string				`gorm:"size:512" json:"-"`
+	AudioSize			int64				`gorm:"not null;default:0" json:"audio_size"`
+	Duration			float64				`gorm:"not null;default:0" json:"duration"`
+}
+
+type Book struct {
+	Base
+	UserID				uint				`gorm:"not null;index" json:"user_id"`
+	DocumentID			uint				`gorm:"not null;index" json:"document_id"`
+	JobID				uint				`gorm:"not null;index" json:"job_id"`
+	Title				string				`gorm:"size:512;not null;index" json:"title"`
+	Author				string				`gorm:"size:512;index" json:"author"`
+	Series				string				`gorm:"size:512;index" json:"series,omitempty"`
+	SeriesIndex			*float64			`json:"series_index,omitempty"`
+	Narrator			string				`gorm:"size:255;index" json:"narrator"`
+	Description			string				`gorm:"type:text" json:"description,omitempty"`
+	Language			string				`gorm:"size:35" json:"language,omitempty"`
+	CoverKey			string				`gorm:"size:512" json:"-"`
+	CoverType			string				`gorm:"size:127" json:"-"`
+	HasCover			bool				`gorm:"-" json:"has_cover"`
+	HLSKey				string				`gorm:"size:512" json:"-"`
+	HasHLS				bool				`gorm:"-" json:"has_hls"`
+	Duration			float64				`gorm:"not null;default:0;index" json:"duration"`
+	Size				int64				`gorm:"not null;default:0" json:"size"`
+	ChapterCount		int					`gorm:"not null;default:0" json:"chapter_count"`
+	Chapters			[]BookChapter		`gorm:"constraint:OnDelete:CASCADE" json:"chapters,omitempty"`
+	Access				BookAccess			`gorm:"-" json:"access,omitempty"`
+}
+
+type BookChapter struct {
+	Base
+	BookID				uint				`gorm:"not null;index:idx_book_chapter_position,unique" json:"book_id"`
+	ChapterID			uint				`gorm:"not null;index" json:"chapter_id"`
+	Position			int					`gorm:"not null;index:idx_book_chapter_position,unique" json:"position"`
+	Title				string				`gorm:"size:512" json:"title"`
+	AudioKey			string				`gorm:"size:512;not null" json:"-"`
+	SyncKey				string				`gorm:"size:512" json:"-"`
+	HasSync				bool				`gorm:"-" json:"has_sync"`
+	AudioSize			int64				`gorm:"not null" json:"size"`
+	Duration			float64				`gorm:"not null" json:"duration"`
+	Start				float64				`gorm:"not null" json:"start"`
+}
+
+type ProgressStrategy string
+
+const (
+	ProgressLatest		ProgressStrategy = "latest"
+	ProgressFurthest	ProgressStrategy = "furthest"
+)
+
+type Progress struct {
+	Base
+	UserID				uint				`gorm:"not null;uniqueIndex:idx_progress_user_book" json:"user_id"`
+	BookID				uint				`gorm:"not null;uniqueIndex:idx_progress_user_book;index" json:"book_id"`
+	Chapter				int					`gorm:"not null" json:"chapter"`
+	Offset				float64				`gorm:"not null" json:"offset"`
+	Position			float64				`gorm:"not null" json:"position"`
+	Speed				float64				`gorm:"not null;default:1" json:"speed"`
+	DeviceID			string				`gorm:"size:128" json:"device_id"`
+	ClientUpdatedAt		time.Time			`gorm:"not null" json:"client_updated_at"`
+}
+
+type BookmarkKind string
+
+const (
+	BookmarkPlain	BookmarkKind = "bookmark"
+	BookmarkNote	BookmarkKind = "note"
+)
+
+// Bookmark marks a point in a book's audio. TextStart and TextEnd are code
+// point offsets into the chapter's sync map text and Quote the text they
+// cover; a bookmark carrying the user's own text is a note.
+type Bookmark struct {
+	Base
+	UserID				uint				`gorm:"not null;index:idx_bookmark_user_book" json:"user_id"`
+	BookID				uint				`gorm:"not null;index:idx_bookmark_user_book" json:"book_id"`
+	Kind				BookmarkKind		`gorm:"size:16;not null" json:"kind"`
+	Chapter				int					`gorm:"not null" json:"chapter"`
+	Offset				float64				`gorm:"not null" json:"offset"`
+	Position			float64				`gorm:"not null" json:"position"`
+	TextStart			*int				`json:"text_start,omitempty"`
+	TextEnd				*int				`json:"text_end,omitempty"`
+	Quote				string				`gorm:"type:text" json:"quote,omitempty"`
+	Title				string				`gorm:"size:255" json:"title,omitempty"`
+	Note				string				`gorm:"type:text" json:"note,omitempty"`
+}
+
+type BookmarkUpdate struct {
+	Title				*string				`json:"title"`
+	Note				*string				`json:"note"`
+}
+
+// Collection is a user-curated, ordered list of books.
+type Collection struct {
+	Base
+	UserID				uint				`gorm:"not null;uniqueIndex:idx_collection_user_name" json:"user_id"`
+	Name				string				`gorm:"size:255;not null;uniqueIndex:idx_collection_user_name" json:"name"`
+	Description			string				`gorm:"type:text" json:"description,omitempty"`
+	BookCount			int					`gorm:"->;-:migration" json:"book_count"`
+}
+
+type CollectionUpdate struct {
+	Name				*string				`json:"name"`
+	Description			*string				`json:"description"`
+}
+
+type CollectionBook struct {
+	CollectionID		uint				`gorm:"primaryKey" json:"collection_id"`
+	BookID				uint				`gorm:"primaryKey;index" json:"book_id"`
+	Position			int					`gorm:"not null" json:"position"`
+	CreatedAt			time.Time			`gorm:"autoCreateTime" json:"created_at"`
+}
+
+type Tag struct {
+	Base
+	UserID				uint				`gorm:"not null;uniqueIndex:idx_tag_user_name" json:"user_id"`
+	Name				string				`gorm:"size:64;not null;uniqueIndex:idx_tag_user_name" json:"name"`
+	BookCount			int					`gorm:"->;-:migration" json:"book_count"`
+}
+
+type BookTag struct {
+	BookID				uint				`gorm:"primaryKey" json:"book_id"`
+	TagID				uint				`gorm:"primaryKey;index" json:"tag_id"`
+}
+
+type BookStatus string
+
+const (
+	BookUnstarted	BookStatus = "unstarted"
+	BookUnfinished	BookStatus = "unfinished"
+	BookFinished	BookStatus = "finished"
+)
+
+// ShelfFilter selects books the way the book list does; a shelf's
+// contents follow the library as it changes.
+type ShelfFilter struct {
+	Search				string				`json:"search,omitempty"`
+	Author				string				`json:"author,omitempty"`
+	Series				string				`json:"series,omitempty"`
+	Narrator			string				`json:"narrator,omitempty"`
+	Language			string				`json:"language,omitempty"`
+	Voice				string				`json:"voice,omitempty"`
+	Tag					string				`json:"tag,omitempty"`
+	Status				BookStatus			`json:"status,omitempty"`
+	Sort				string				`json:"sort,omitempty"`
+	Descending			bool				`json:"descending,omitempty"`
+}
+
+// Shelf is a saved book filter.
+type Shelf struct {
+	Base
+	UserID				uint				`gorm:"not null;uniqueIndex:idx_shelf_user_name" json:"user_id"`
+	Name				string				`gorm:"size:255;not null;uniqueIndex:idx_shelf_user_name" json:"name"`
+	Filter				ShelfFilter			`gorm:"serializer:json;not null" json:"filter"`
+}
+
+type ShelfUpdate struct {
+	Name				*string				`json:"name"`
+	Filter				*ShelfFilter		`json:"filter"`
+}
+
+// BookAccess is what a user may do with a book: owners (and admins) may
+// also delete and share it, editors change its metadata and packaging.
+type BookAccess string
+
+const (
+	AccessOwner		BookAccess = "owner"
+	AccessEdit		BookAccess = "edit"
+	AccessRead		BookAccess = "read"
+)
+
+// BookShare grants a user access to another user's book. Link shares have
+// no grantee; the token lets anyone with the link listen, and lets
+// signed-in users add the book to their shared list.
+type BookShare struct {
+	Base
+	BookID				uint				`gorm:"not null;uniqueIndex:idx_share_book_user" json:"book_id"`
+	Book				*Book				`json:"book,omitempty"`
+	OwnerID				uint				`gorm:"not null;index" json:"owner_id"`
+	Owner				*User				`json:"-"`
+	SharedBy			string				`gorm:"-" json:"shared_by,omitempty"`
+	UserID				*uint				`gorm:"uniqueIndex:idx_share_book_user;index" json:"user_id,omitempty"`
+	User				*User				`json:"-"`
+	Username			string				`gorm:"-" json:"username,omitempty"`
+	Permission			BookAccess			`gorm:"size:8;not null" json:"permission"`
+	TokenHash			[]byte				`gorm:"uniqueIndex" json:"-"`
+	PlainText			string				`gorm:"-" json:"-"`
+	ExpiresAt			*time.Time			`json:"expires_at,omitempty"`
+	LastUsedAt			*time.Time			`json:"last_used_at,omitempty"`
+}
+
+type Feed struct {
+	Base
+	UserID				uint				`gorm:"not null;index" json:"user_id"`
+	BookID				*uint				`gorm:"index" json:"book_id,omitempty"`
+	TokenHash			[]byte				`gorm:"not null;uniqueIndex" json:"-"`
+	PlainText			string				`gorm:"-" json:"-"`
+	LastFetchedAt		*time.Time			`json:"last_fetched_at,omitempty"`
+}
+
+// SubsonicCredential is a per-user app password for Subsonic clients. The
+// protocol's token auth sends md5(password + salt), so unlike the account
+// password it is kept in the clear; it grants nothing but the Subsonic
+// endpoints and can be rotated or revoked on its own.
+type SubsonicCredential struct {
+	Base
+	UserID				uint				`gorm:"not null;uniqueIndex" json:"user_id"`
+	Password			string				`gorm:"size:64;not null" json:"-"`
+	LastUsedAt			*time.Time			`json:"last_used_at,omitempty"`
+}
+
+type BookQuery struct {
+	Search				string
+	Author				string
+	Series				string
+	Narrator			string
+	Language			string
+	Sort				string
+	Descending			bool
+	Page				int
+	PageSize			int
+	Offset				int
+	Voice				string
+	Tag					string
+	Status				BookStatus
+}
+
+type Facet struct {
+	Value				string				`json:"value"`
+	Count				int					`json:"count"`
+}
+
+// SearchHit is a chapter matching a full-text query. Snippet is an excerpt
+// of the chapter around the best match; Highlights are the code point
+// ranges of Snippet that matched.
+type SearchHit struct {
+	BookID				uint				`json:"book_id"`
+	BookTitle			string				`json:"book_title"`
+	Author				string				`json:"author"`
+	ChapterID			uint				`json:"-"`
+	Position			int					`json:"position"`
+	ChapterTitle		string				`json:"chapter_title"`
+	Rank				float64				`json:"rank"`
+	Headline			string				`json:"-"`
+	Snippet				string				`gorm:"-" json:"snippet"`
+	Highlights			[][2]int			`gorm:"-" json:"highlights"`
+}
+
+type SearchQuery struct {
+	Text				string
+	BookID				uint
+	Page				int
+	PageSize			int
+}
+
+type BookUpdate struct {
+	Title				*string				`json:"title"`
+	Author				*string				`json:"author"`
+	Series				*string				`json:"series"`
+	SeriesIndex			*float64			`json:"series_index"`
+	Narrator			*string				`json:"narrator"`
+	Description			*string				`json:"description"`
+	Language			*string				`json:"language"`
+}
+
+var bookSortColumns = map[string]string{
+	"title":		"title",
+	"author":		"author",
+	"series":		"series, series_index",
+	"narrator":		"narrator",
+	"duration":		"duration",
+	"created":		"created_at",
+	"updated":		"updated_at",
+	"random":		"RANDOM()",
+}
+
+const (
+	headlineStart		= "\x02"
+	headlineStop		= "\x03"
+	headlineOptions		= `StartSel="` + headlineStart + `", StopSel="` + headlineStop + `", MaxWords=35, MinWords=15, MaxFragments=1`
+)
+
+// searchConfigs maps ISO 639-1 codes to the PostgreSQL text search
+// configurations that stem them. Other languages are indexed with simple.
+var searchConfigs = map[string]string{
+	"ar":			"arabic",
+	"da":			"danish",
+	"de":			"german",
+	"el":			"greek",
+	"en":			"english",
+	"es":			"spanish",
+	"fi":			"finnish",
+	"fr":			"french",
+	"ga":			"irish",
+	"hu":			"hungarian",
+	"id":			"indonesian",
+	"it":			"italian",
+	"lt":			"lithuanian",
+	"nb":			"norwegian",
+	"ne":			"nepali",
+	"nl":			"dutch",
+	"nn":			"norwegian",
+	"no":			"norwegian",
+	"pt":			"portuguese",
+	"ro":			"romanian",
+	"ru":			"russian",
+	"sr":			"serbian",
+	"sv":			"swedish",
+	"ta":			"tamil",
+	"tr":			"turkish",
+}
+
+var bookFacetColumns = map[string]string{
+	"author":		"author",
+	"series":		"series",
+	"narrator":		"narrator",
+	"language":		"language",
+}
+
+func (b *Book) AfterFind(*gorm.DB) error {
+	b.HasCover = b.CoverKey != ""
+	b.HasHLS = b.HLSKey != ""
+	return nil
+}
+
+func (c *BookChapter) AfterFind(*gorm.DB) error {
+	c.HasSync = c.SyncKey != ""
+	return nil
+}
+
 func LoadConfig() *DataConfig {
     return &DataConfig{
         TokenExpiry: 			GetEnvAsInt("TOKEN_EXPIRY"),
@@ -87,8 +523,11 @@ func NewRepository(db *gorm.DB) *Repository {
 }
 
 func (r *Repository) AutoMigrate() error {
-	if err := r.DB.AutoMigrate(&User{}, &Token{}); err != nil {
+	if err := r.DB.AutoMigrate(&User{}, &Token{}, &Document{}, &Chapter{}, &Job{}, &JobChapter{}, &Book{}, &BookChapter{}, &Progress{}, &Feed{}, &SubsonicCredential{}, &Bookmark{}, &Collection{}, &CollectionBook{}, &Tag{}, &BookTag{}, &Shelf{}, &BookShare{}); err != nil {
 		return errors.Join(ErrDatabase, err)
 	}
-	return nil
+	if err := r.DB.Exec("CREATE INDEX IF NOT EXISTS idx_chapter_search ON chapters USING GIN (search_vector)").Error; err != nil {
+		return errors.Join(ErrDatabase, err)
+	}
+	return r.indexDocuments()
 }
\ No newline at end of file
@@ -2,13 +2,19 @@ package data
 
 import (
 	"crypto/rand"
+	"database/sql"
 	"encoding/base64"
 	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 func (r *Repository) CreateUser(username string, password string, isAdmin bool) error {
@@ -107,6 +113,28 @@ func (r *Repository) ValidateToken(tokenString string) (*User, error) {
 	return nil, ErrInvalidCredentials
 }
 
+func (r *Repository) UserByID(id uint) (*User, error) {
+	var user User
+	if err := r.DB.First(&user, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, errors.Join(ErrDatabase, err)
+	}
+	return &user, nil
+}
+
+func (r *Repository) UserByUsername(username string) (*User, error) {
+	var user User
+	if err := r.DB.Where("username = ?", username).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, errors.Join(ErrDatabase, err)
+	}
+	return &user, nil
+}
+
 func (r *Repository) CheckAccess(user *User) error {
 	if user.IsAdmin {
 		return nil
@@ -150,3 +178,1660 @@ func (r *Repository) UpdateLimits(userID uint, rateLimit int, capacity int) erro
 
 	return nil
 }
+
+func (r *Repository) CreateDocument(doc *Document) error {
+	if err := r.DB.Create(doc).Error; err != nil {
+		return errors.Join(ErrDatabase, err)
+	}
+	return r.IndexDocument(doc)
+}
+
+// IndexDocument rebuilds the search vectors of the document's chapters in
+// the configuration for its language. Titles weigh more than body text.
+func (r *Repository) IndexDocument(doc *Document) error {
+	config := searchConfig(doc.Language)
+	err := r.DB.Exec(`UPDATE chapters SET search_config = @config,
+		search_vector = setweight(to_tsvector(CAST(@config AS regconfig), coalesce(title, '')), 'A') ||
+			setweight(to_tsvector(CAST(@config AS regconfig), text), 'B')
+		WHERE document_id = @document`,
+		sql.Named("config", config), sql.Named("document", doc.ID)).Error
+	if err != nil {
+		return errors.Join(ErrDatabase, err)
+	}
+	return nil
+}
+
+// indexDocuments indexes documents uploaded before search existed.
+func (r *Repository) indexDocuments() error {
+	var docs []Document
+	err := r.DB.Select("id", "language").
+		Where("id IN (?)", r.DB.Model(&Chapter{}).Select("document_id").Where("search_vector IS NULL")).
+		Find(&docs).Error
+	if err != nil {
+		return errors.Join(ErrDatabase, err)
+	}
+	for i := range docs {
+		if err := r.IndexDocument(&docs[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Repository) ListDocuments(user *User) ([]Document, error) {
+	var docs []Document
+	if err := r.DB.Where("user_id = ?", user.ID).Order("created_at DESC").Find(&docs).Error; err != nil {
+		return nil, errors.Join(ErrDatabase, err)
+	}
+	return docs, nil
+}
+
+func (r *Repository) GetDocument(user *User, id uint) (*Document, error) {
+	query := r.DB
+	if !user.IsAdmin {
+		query = query.Where("user_id = ?", user.ID)
+	}
+	return r.findDocument(query, id)
+}
+
+func (r *Repository) DocumentByID(id uint) (*Document, error) {
+	return r.findDocument(r.DB, id)
+}
+
+func (r *Repository) findDocument(query *gorm.DB, id uint) (*Document, error) {
+	var doc Document
+	query = query.Preload("Chapters", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position")
+	})
+
+	if err := query.First(&doc, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, errors.Join(ErrDatabase, err)
+	}
+	return &doc, nil
+}
+
+func (r *Repository) DocumentContentTypes(ids []uint) (map[uint]string, error) {
+	var docs []Document
+	if err := r.DB.Select("id", "content_type").Where("id IN ?", ids).Find(&docs).Error; err != nil {
+		return nil, errors.Join(ErrDatabase, err)
+	}
+
+	types := make(map[uint]string, len(docs))
+	for _, doc := range docs {
+		types[doc.ID] = doc.ContentType
+	}
+	return types, nil
+}
+
+func (r *Repository) DeleteDocument(doc *Document) ([]string, error) {
+	var keys []string
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		var books []Book
+		if err := tx.Preload("Chapters").Where("document_id = ?", doc.ID).Find(&books).Error; err != nil {
+			return errors.Join(ErrDatabase, err)
+		}
+		for i := range books {
+			bookKeys, err := deleteBook(tx, &books[i])
+			if err != nil {
+				return err
+			}
+			keys = append(keys, bookKeys...)
+		}
+
+		now := time.Now()
+		if err := tx.Model(&Job{}).Where("document_id = ? AND status = ?", doc.ID, JobQueued).
+			Updates(map[string]interface{}{"status": JobCanceled, "cancel_requested": true, "finished_at": now}).Error; err != nil {
+			return errors.Join(ErrDatabase, err)
+		}
+		if err := tx.Model(&Job{}).Where("document_id = ? AND status = ?", doc.ID, JobRunning).
+			Update("cancel_requested", true).Error; err != nil {
+			return errors.Join(ErrDatabase, err)
+		}
+
+		if err := tx.Unscoped().Where("document_id = ?", doc.ID).Delete(&Chapter{}).Error; err != nil {
+			return errors.Join(ErrDatabase, err)
+		}
+		if err := tx.Unscoped().Delete(doc).Error; err != nil {
+			return errors.Join(ErrDatabase, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (r *Repository) CreateJob(doc *Document, job *Job) error {
+	job.UserID = doc.UserID
+	job.DocumentID = doc.ID
+	job.Status = JobQueued
+	job.TotalChapters = len(doc.Chapters)
+	job.TotalChars = 0
+	job.Chapters = nil
+	for _, ch := range doc.Chapters {
+		job.TotalChars += ch.CharCount
+		job.Chapters = append(job.Chapters, JobChapter{
+			ChapterID:	ch.ID,
+			Position:	ch.Position,
+			Title:		ch.Title,
+			Status:		JobQueued,
+			CharCount:	ch.CharCount,
+		})
+	}
+
+	if err := r.DB.Create(job).Error; err != nil {
+		return errors.Join(ErrDatabase, err)
+	}
+	return nil
+}
+
+func (r *Repository) ListJobs(user *User) ([]Job, error) {
+	var jobs []Job
+	if err := r.DB.Where("user_id = ?", user.ID).Order("created_at DESC").Find(&jobs).Error; err != nil {
+		return nil, errors.Join(ErrDatabase, err)
+	}
+	return jobs, nil
+}
+
+func (r *Repository) GetJob(user *User, id uint) (*Job, error) {
+	query := r.DB
+	if !user.IsAdmin {
+		query = query.Where("user_id = ?", user.ID)
+	}
+	return r.findJob(query, id)
+}
+
+func (r *Repository) JobByID(id uint) (*Job, error) {
+	return r.findJob(r.DB, id)
+}
+
+func (r *Repository) findJob(query *gorm.DB, id uint) (*Job, error) {
+	var job Job
+	query = query.Preload("Chapters", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position")
+	})
+
+	if err := query.First(&job, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, errors.Join(ErrDatabase, err)
+	}
+	return &job, nil
+}
+
+func (r *Repository) ClaimJob(workerID string, staleBefore time.Time) (*Job, error) {
+	var job Job
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? OR (status = ? AND heartbeat_at < ?)", JobQueued, JobRunning, staleBefore).
+			Order("created_at").
+			First(&job).Error
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		job.Status = JobRunning
+		job.WorkerID = workerID
+		job.HeartbeatAt = &now
+		job.Attempts++
+		if job.StartedAt == nil {
+			job.StartedAt = &now
+		}
+		return tx.Model(&job).Select("Status", "WorkerID", "HeartbeatAt", "Attempts", "StartedAt").Updates(&job).Error
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, errors.Join(ErrDatabase, err)
+	}
+
+	return r.JobByID(job.ID)
+}
+
+func (r *Repository) Heartbeat(job *Job) (bool, error) {
+	var state struct {
+		CancelRequested	bool
+	}
+	now := time.Now()
+	result := r.DB.Model(&Job{}).
+		Where("id = ? AND worker_id = ? AND status = ?", job.ID, job.WorkerID, JobRunning).
+		Update("heartbeat_at", now)
+	if result.Error != nil {
+		return false, errors.Join(ErrDatabase, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return false, ErrLeaseLost
+	}
+
+	if err := r.DB.Model(&Job{}).Select("cancel_requested").Where("id = ?", job.ID).Take(&state).Error; err != nil {
+		return false, errors.Join(ErrDatabase, err)
+	}
+	job.HeartbeatAt = &now
+	return state.CancelRequested, nil
+}
+
+func (r *Repository) SaveJobProgress(job *Job, chapter *JobChapter) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if chapter != nil {
+			if err := tx.Save(chapter).Error; err != nil {
+				return errors.Join(ErrDatabase, err)
+			}
+		}
+		result := tx.Model(job).Where("worker_id = ?", job.WorkerID).
+			Select("CompletedChapters", "CompletedChars", "HeartbeatAt").
+			Updates(job)
+		if result.Error != nil {
+			return errors.Join(ErrDatabase, result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrLeaseLost
+		}
+		return nil
+	})
+}
+
+func (r *Repository) FinishJob(job *Job, status JobStatus, message string) error {
+	now := time.Now()
+	updates := map[string]interface{}{"status": status, "error": message, "heartbeat_at": nil}
+	if status != JobQueued {
+		updates["finished_at"] = now
+	}
+
+	result := r.DB.Model(job).Where("worker_id = ? AND status = ?", job.WorkerID, JobRunning).Updates(updates)
+	if result.Error != nil {
+		return errors.Join(ErrDatabase, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrLeaseLost
+	}
+
+	job.Status, job.Error, job.HeartbeatAt = status, message, nil
+	if status != JobQueued {
+		job.FinishedAt = &now
+	}
+	return nil
+}
+
+func (r *Repository) CancelJob(job *Job) error {
+	switch job.Status {
+	case JobQueued:
+		now := time.Now()
+		result := r.DB.Model(job).Where("status = ?", JobQueued).
+			Updates(map[string]interface{}{"status": JobCanceled, "cancel_requested": true, "finished_at": now})
+		if result.Error != nil {
+			return errors.Join(ErrDatabase, result.Error)
+		}
+		if result.RowsAffected > 0 {
+			job.Status, job.CancelRequested, job.FinishedAt = JobCanceled, true, &now
+			return nil
+		}
+		fallthrough
+	case JobRunning:
+		if err := r.DB.Model(job).Update("cancel_requested", true).Error; err != nil {
+			return errors.Join(ErrDatabase, err)
+		}
+		job.CancelRequested = true
+		return nil
+	}
+	return ErrInvalidState
+}
+
+func (r *Repository) CompleteJob(job *Job) (*Book, error) {
+	var book *Book
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		var doc Document
+		if err := tx.First(&doc, job.DocumentID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrNotFound
+			}
+			return errors.Join(ErrDatabase, err)
+		}
+
+		book = &Book{
+			UserID:			job.UserID,
+			DocumentID:		doc.ID,
+			JobID:			job.ID,
+			Title:			doc.Title,
+			Author:			strings.Join(doc.Authors, ", "),
+			Series:			doc.Properties["series"],
+			Narrator:		job.Voice,
+			Description:	doc.Properties["description"],
+			Language:		doc.Language,
+			CoverKey:		doc.CoverKey,
+			CoverType:		doc.CoverType,
+		}
+		if index, err := strconv.ParseFloat(doc.Properties["series_index"], 64); err == nil {
+			book.SeriesIndex = &index
+		}
+		for _, jc := range job.Chapters {
+			if jc.AudioKey == "" {
+				continue
+			}
+			book.Chapters = append(book.Chapters, BookChapter{
+				ChapterID:	jc.ChapterID,
+				Position:	len(book.Chapters),
+				Title:		jc.Title,
+				AudioKey:	jc.AudioKey,
+				SyncKey:	jc.SyncKey,
+				AudioSize:	jc.AudioSize,
+				Duration:	jc.Duration,
+				Start:		book.Duration,
+			})
+			book.Duration += jc.Duration
+			book.Size += jc.AudioSize
+		}
+		book.ChapterCount = len(book.Chapters)
+
+		if err := tx.Create(book).Error; err != nil {
+			return errors.Join(ErrDatabase, err)
+		}
+
+		now := time.Now()
+		result := tx.Model(job).Where("worker_id = ? AND status = ?", job.WorkerID, JobRunning).
+			Updates(map[string]interface{}{"status": JobCompleted, "error": "", "heartbeat_at": nil, "finished_at": now, "book_id": book.ID})
+		if result.Error != nil {
+			return errors.Join(ErrDatabase, result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrLeaseLost
+		}
+
+		job.Status, job.Error, job.HeartbeatAt, job.FinishedAt, job.BookID = JobCompleted, "", nil, &now, &book.ID
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return book, nil
+}
+
+// ListBooks lists the books the user owns or has been shared.
+func (r *Repository) ListBooks(user *User, q BookQuery) ([]Book, int64, error) {
+	query := r.readableBooks(r.DB, user.ID)
+	if q.Search != "" {
+		pattern := likePattern(q.Search)
+		query = query.Where("title ILIKE ? OR author ILIKE ? OR series ILIKE ?", pattern, pattern, pattern)
+	}
+	if q.Author != "" {
+		query = query.Where("author ILIKE ?", likePattern(q.Author))
+	}
+	if q.Series != "" {
+		query = query.Where("series = ?", q.Series)
+	}
+	if q.Narrator != "" {
+		query = query.Where("narrator = ?", q.Narrator)
+	}
+	if q.Language != "" {
+		query = query.Where("language = ?", q.Language)
+	}
+	if q.Voice != "" {
+		query = query.Where("job_id IN (?)", r.DB.Model(&Job{}).Select("id").Where("voice = ?", q.Voice))
+	}
+	if q.Tag != "" {
+		query = query.Where("id IN (?)", r.DB.Model(&BookTag{}).
+			Select("book_tags.book_id").
+			Joins("JOIN tags ON tags.id = book_tags.tag_id").
+			Where("tags.user_id = ? AND tags.name = ?", user.ID, q.Tag))
+	}
+	switch progress := "SELECT 1 FROM progresses WHERE progresses.book_id = books.id AND progresses.user_id = ? AND progresses.deleted_at IS NULL"; q.Status {
+	case BookUnstarted:
+		query = query.Where("NOT EXISTS ("+progress+")", user.ID)
+	case BookUnfinished:
+		query = query.Where("EXISTS ("+progress+" AND progresses.position < books.duration - ?)", user.ID, finishedMargin)
+	case BookFinished:
+		query = query.Where("EXISTS ("+progress+" AND progresses.position >= books.duration - ?)", user.ID, finishedMargin)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, errors.Join(ErrDatabase, err)
+	}
+
+	// Offset, when set, takes precedence over Page for clients that page by
+	// arbitrary row offsets.
+	offset := (q.Page - 1) * q.PageSize
+	if q.Offset > 0 {
+		offset = q.Offset
+	}
+
+	var books []Book
+	err := query.Order(q.orderClause()).
+		Offset(offset).
+		Limit(q.PageSize).
+		Find(&books).Error
+	if err != nil {
+		return nil, 0, errors.Join(ErrDatabase, err)
+	}
+	if err := r.markAccess(user, books); err != nil {
+		return nil, 0, err
+	}
+	return books, total, nil
+}
+
+// BookFacets lists the distinct non-empty values of a book field in the
+// user's library with the number of books carrying each.
+func (r *Repository) BookFacets(user *User, field string) ([]Facet, error) {
+	column, ok := bookFacetColumns[field]
+	if !ok {
+		return nil, errors.Join(ErrValidation, fmt.Errorf("unknown facet %q", field))
+	}
+
+	var facets []Facet
+	err := r.readableBooks(r.DB, user.ID).
+		Select(column+" AS value, COUNT(*) AS count").
+		Where(column+" <> ''").
+		Group(column).
+		Order(column).
+		Scan(&facets).Error
+	if err != nil {
+		return nil, errors.Join(ErrDatabase, err)
+	}
+	return facets, nil
+}
+
+// LibraryBooks loads every book the user owns or has been shared, with
+// chapters, newest first.
+func (r *Repository) LibraryBooks(user *User) ([]Book, error) {
+	var books []Book
+	err := r.readableBooks(r.DB.Preload("Chapters", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position")
+	}), user.ID).Order("created_at DESC").Find(&books).Error
+	if err != nil {
+		return nil, errors.Join(ErrDatabase, err)
+	}
+	if err := r.markAccess(user, books); err != nil {
+		return nil, err
+	}
+	return books, nil
+}
+
+// GetBook loads a book the user owns or has been shared, recording their
+// access level on it. Books shared with others look missing rather than
+// forbidden to everyone else.
+func (r *Repository) GetBook(user *User, id uint) (*Book, error) {
+	var book Book
+	query := r.DB.Preload("Chapters", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position")
+	})
+	if !user.IsAdmin {
+		query = r.readableBooks(query, user.ID)
+	}
+
+	if err := query.First(&book, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, errors.Join(ErrDatabase, err)
+	}
+
+	book.Access = AccessOwner
+	if book.UserID != user.ID && !user.IsAdmin {
+		var share BookShare
+		if err := r.activeShares().Where("book_id = ? AND user_id = ?", book.ID, user.ID).First(&share).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrNotFound
+			}
+			return nil, errors.Join(ErrDatabase, err)
+		}
+		book.Access = share.Permission
+	}
+	return &book, nil
+}
+
+func (r *Repository) UpdateBook(book *Book, update BookUpdate) error {
+	if err := update.Validate(); err != nil {
+		return errors.Join(ErrValidation, err)
+	}
+
+	fields := map[string]interface{}{}
+	if update.Title != nil {
+		fields["title"] = strings.TrimSpace(*update.Title)
+	}
+	if update.Author != nil {
+		fields["author"] = strings.TrimSpace(*update.Author)
+	}
+	if update.Series != nil {
+		fields["series"] = strings.TrimSpace(*update.Series)
+	}
+	if update.SeriesIndex != nil {
+		fields["series_index"] = *update.SeriesIndex
+	}
+	if update.Narrator != nil {
+		fields["narrator"] = strings.TrimSpace(*update.Narrator)
+	}
+	if update.Description != nil {
+		fields["description"] = strings.TrimSpace(*update.Description)
+	}
+	if update.Language != nil {
+		fields["language"] = strings.TrimSpace(*update.Language)
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+
+	if err := r.DB.Model(book).Updates(fields).Error; err != nil {
+		return errors.Join(ErrDatabase, err)
+	}
+	if err := r.DB.First(book, book.ID).Error; err != nil {
+		return errors.Join(ErrDatabase, err)
+	}
+	return nil
+}
+
+func (r *Repository) SetBookCover(book *Book, key, contentType string) error {
+	if err := r.DB.Model(book).Updates(map[string]interface{}{"cover_key": key, "cover_type": contentType}).Error; err != nil {
+		return errors.Join(ErrDatabase, err)
+	}
+	book.CoverKey, book.CoverType, book.HasCover = key, contentType, key != ""
+	return nil
+}
+
+// SetBookHLS records the manifest of the book's HLS packaging.
+func (r *Repository) SetBookHLS(book *Book, key string) error {
+	if err := r.DB.Model(book).UpdateColumn("hls_key", key).Error; err != nil {
+		return errors.Join(ErrDatabase, err)
+	}
+	book.HLSKey, book.HasHLS = key, key != ""
+	return nil
+}
+
+func (r *Repository) DeleteBook(book *Book) ([]string, error) {
+	var keys []string
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		keys, err = deleteBook(tx, book)
+		return err
+	})
+	return keys, err
+}
+
+func deleteBook(tx *gorm.DB, book *Book) ([]string, error) {
+	var chapters []BookChapter
+	if err := tx.Where("book_id = ?", book.ID).Find(&chapters).Error; err != nil {
+		return nil, errors.Join(ErrDatabase, err)
+	}
+
+	var keys []string
+	for _, ch := range chapters {
+		keys = append(keys, ch.AudioKey)
+		if ch.SyncKey != "" {
+			keys = append(keys, ch.SyncKey)
+		}
+	}
+	if strings.HasPrefix(book.CoverKey, "books/") {
+		keys = append(keys, book.CoverKey)
+	}
+	if book.HLSKey != "" {
+		keys = append(keys, path.Dir(book.HLSKey)+"/")
+	}
+
+	if err := tx.Unscoped().Where("book_id = ?", book.ID).Delete(&BookChapter{}).Error; err != nil {
+		return nil, errors.Join(ErrDatabase, err)
+	}
+	if err := tx.Unscoped().Where("book_id = ?", book.ID).Delete(&Progress{}).Error; err != nil {
+		return nil, errors.Join(ErrDatabase, err)
+	}
+	if err := tx.Unscoped().Where("book_id = ?", book.ID).Delete(&Feed{}).Error; err != nil {
+		return nil, errors.Join(ErrDatabase, err)
+	}
+	if err := tx.Unscoped().Where("book_id = ?", book.ID).Delete(&Bookmark{}).Error; err != nil {
+		return nil, errors.Join(ErrDatabase, err)
+	}
+	if err := tx.Unscoped().Where("book_id = ?", book.ID).Delete(&BookShare{}).Error; err != nil {
+		return nil, errors.Join(ErrDatabase, err)
+	}
+	if err := tx.Where("book_id = ?", book.ID).Delete(&CollectionBook{}).Error; err != nil {
+		return nil, errors.Join(ErrDatabase, err)
+	}
+	if err := tx.Where("book_id = ?", book.ID).Delete(&BookTag{}).Error; err != nil {
+		return nil, errors.Join(ErrDatabase, err)
+	}
+	if err := tx.Model(&Job{}).Where("book_id = ?", book.ID).Update("book_id", nil).Error; err != nil {
+		return nil, errors.Join(ErrDatabase, err)
+	}
+	if err := tx.Unscoped().Delete(book).Error; err != nil {
+		return nil, errors.Join(ErrDatabase, err)
+	}
+	return keys, nil
+}
+
+func (r *Repository) GetProgress(user *User, bookID uint) (*Progress, error) {
+	var progress Progress
+	if err := r.DB.Where("user_id = ? AND book_id = ?", user.ID, bookID).First(&progress).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, errors.Join(ErrDatabase, err)
+	}
+	return &progress, nil
+}
+
+func (r *Repository) ListProgress(user *User) ([]Progress, error) {
+	var progress []Progress
+	if err := r.DB.Where("user_id = ?", user.ID).Order("client_updated_at DESC").Find(&progress).Error; err != nil {
+		return nil, errors.Join(ErrDatabase, err)
+	}
+	return progress, nil
+}
+
+func (r *Repository) SyncProgress(user *User, book *Book, incoming *Progress, strategy ProgressStrategy) (*Progress, bool, error) {
+	if err := incoming.Validate(book); err != nil {
+		return nil, false, errors.Join(ErrValidation, err)
+	}
+
+	now := time.Now()
+	if incoming.ClientUpdatedAt.IsZero() || incoming.ClientUpdatedAt.After(now.Add(time.Minute)) {
+		incoming.ClientUpdatedAt = now
+	}
+	incoming.ID = 0
+	incoming.UserID = user.ID
+	incoming.BookID = book.ID
+
+	var current Progress
+	accepted := false
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(incoming)
+		if result.Error != nil {
+			return errors.Join(ErrDatabase, result.Error)
+		}
+		if result.RowsAffected == 1 {
+			current, accepted = *incoming, true
+			return nil
+		}
+
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND book_id = ?", user.ID, book.ID).
+			First(&current).Error
+		if err != nil {
+			return errors.Join(ErrDatabase, err)
+		}
+		if !incoming.supersedes(&current, strategy) {
+			return nil
+		}
+
+		current.Chapter = incoming.Chapter
+		current.Offset = incoming.Offset
+		current.Position = incoming.Position
+		current.Speed = incoming.Speed
+		current.DeviceID = incoming.DeviceID
+		current.ClientUpdatedAt = incoming.ClientUpdatedAt
+		accepted = true
+		if err := tx.Save(&current).Error; err != nil {
+			return errors.Join(ErrDatabase, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return &current, accepted, nil
+}
+
+func (r *Repository) CreateBookmark(user *User, book *Book, bookmark *Bookmark) error {
+	if err := bookmark.Validate(book); err != nil {
+		return errors.Join(ErrValidation, err)
+	}
+
+	bookmark.ID = 0
+	bookmark.UserID = user.ID
+	bookmark.BookID = book.ID
+	if err := r.DB.Create(bookmark).Error; err != nil {
+		return errors.Join(ErrDatabase, err)
+	}
+	return nil
+}
+
+// ListBookmarks returns the user's bookmarks in a book in listening order.
+func (r *Repository) ListBookmarks(user *User, bookID uint) ([]Bookmark, error) {
+	var bookmarks []Bookmark
+	err := r.DB.Where("user_id = ? AND book_id = ?", user.ID, bookID).
+		Order("position, created_at").
+		Find(&bookmarks).Error
+	if err != nil {
+		return nil, errors.Join(ErrDatabase, err)
+	}
+	return bookmarks, nil
+}
+
+func (r *Repository) GetBookmark(user *User, bookID, id uint) (*Bookmark, error) {
+	var bookmark Bookmark
+	if err := r.DB.Where("user_id = ? AND book_id = ?", user.ID, bookID).First(&bookmark, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, errors.Join(ErrDatabase, err)
+	}
+	return &bookmark, nil
+}
+
+func (r *Repository) UpdateBookmark(bookmark *Bookmark, update BookmarkUpdate) error {
+	if update.Title != nil {
+		bookmark.Title = *update.Title
+	}
+	if update.Note != nil {
+		bookmark.Note = *update.Note
+	}
+	if err := bookmark.validateText(); err != nil {
+		return errors.Join(ErrValidation, err)
+	}
+
+	err := r.DB.Model(bookmark).Select("kind", "title", "note").Updates(bookmark).Error
+	if err != nil {
+		return errors.Join(ErrDatabase, err)
+	}
+	return nil
+}
+
+func (r *Repository) DeleteBookmark(bookmark *Bookmark) error {
+	if err := r.DB.Unscoped().Delete(bookmark).Error; err != nil {
+		return errors.Join(ErrDatabase, err)
+	}
+	return nil
+}
+
+func (r *Repository) CreateFeed(user *User, book *Book) (*Feed, error) {
+	tokenData := make([]byte, feedTokenBytes)
+	if _, err := rand.Read(tokenData); err != nil {
+		return nil, errors.Join(ErrDatabase, err)
+	}
+
+	plainText := base64.RawURLEncoding.EncodeToString(tokenData)
+	feed := &Feed{
+		UserID:		user.ID,
+		TokenHash:	hashToken(plainText),
+		PlainText:	plainText,
+	}
+	if book != nil {
+		feed.BookID = &book.ID
+	}
+
+	if err := r.DB.Create(feed).Error; err != nil {
+		return nil, errors.Join(ErrDatabase, err)
+	}
+	return feed, nil
+}
+
+func (r *Repository) ListFeeds(user *User) ([]Feed, error) {
+	var feeds []Feed
+	if err := r.DB.Where("user_id = ?", user.ID).Order("created_at DESC").Find(&feeds).Error; err != nil {
+		return nil, errors.Join(ErrDatabase, err)
+	}
+	return feeds, nil
+}
+
+func (r *Repository) GetFeed(user *User, id uint) (*Feed, error) {
+	var feed Feed
+	if err := r.DB.Where("user_id = ?", user.ID).First(&feed, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, errors.Join(ErrDatabase, err)
+	}
+	return &feed, nil
+}
+
+// FeedByToken resolves a secret feed URL to its feed and owner.
+func (r *Repository) FeedByToken(token string) (*Feed, *User, error) {
+	var feed Feed
+	if err := r.DB.Where("token_hash = ?", hashToken(token)).First(&feed).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrNotFound
+		}
+		return nil, nil, errors.Join(ErrDatabase, err)
+	}
+
+	user, err := r.UserByID(feed.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &feed, user, nil
+}
+
+// RecordFeedFetch notes that a podcast app fetched the feed document.
+// Episode downloads go through the same token but are not recorded.
+func (r *Repository) RecordFeedFetch(feed *Feed) error {
+	now := time.Now()
+	if err := r.DB.Model(feed).UpdateColumn("last_fetched_at", now).Error; err != nil {
+		return errors.Join(ErrDatabase, err)
+	}
+	feed.LastFetchedAt = &now
+	return nil
+}
+
+func (r *Repository) DeleteFeed(feed *Feed) error {
+	if err := r.DB.Unscoped().Delete(feed).Error; err != nil {
+		return errors.Join(ErrDatabase, err)
+	}
+	return nil
+}
+
+// RotateSubsonicPassword issues a new Subsonic app password for the user,
+// replacing any previous one.
+func (r *Repository) RotateSubsonicPassword(user *User) (*SubsonicCredential, error) {
+	passwordData := make([]byte, subsonicPasswordBytes)
+	if _, err := rand.Read(passwordData); err != nil {
+		return nil, errors.Join(ErrDatabase, err)
+	}
+
+	credential := &SubsonicCredential{
+		UserID:		user.ID,
+		Password:	base64.RawURLEncoding.EncodeToString(passwordData),
+	}
+	err := r.DB.Clauses(clause.OnConflict{
+		Columns:	[]clause.Column{{Name: "user_id"}},
+		DoUpdates:	clause.AssignmentColumns([]string{"password", "updated_at", "last_used_at"}),
+	}).Create(credential).Error
+	if err != nil {
+		return nil, errors.Join(ErrDatabase, err)
+	}
+	return credential, nil
+}
+
+func (r *Repository) GetSubsonicCredential(user *User) (*SubsonicCredential, error) {
+	var credential SubsonicCredential
+	if err := r.DB.Where("user_id = ?", user.ID).First(&credential).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, errors.Join(ErrDatabase, err)
+	}
+	return &credential, nil
+}
+
+func (r *Repository) DeleteSubsonicCredential(user *User) error {
+	result := r.DB.Unscoped().Where("user_id = ?", user.ID).Delete(&SubsonicCredential{})
+	if result.Error != nil {
+		return errors.Join(ErrDatabase, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SubsonicLogin looks up a user and their Subsonic app password by
+// username. The caller checks the password, since Subsonic clients may send
+// it either directly or as a salted token.
+func (r *Repository) SubsonicLogin(username string) (*User, *SubsonicCredential, error) {
+	var user User
+	if err := r.DB.Where("username = ?", username).First(&user).Error; err != nil {
+		return nil, nil, ErrInvalidCredentials
+	}
+
+	var credential SubsonicCredential
+	if err := r.DB.Where("user_id = ?", user.ID).First(&credential).Error; err != nil {
+		return nil, nil, ErrInvalidCredentials
+	}
+	return &user, &credential, nil
+}
+
+func (r *Repository) TouchSubsonicCredential(credential *SubsonicCredential) error {
+	now := time.Now()
+	if err := r.DB.Model(credential).UpdateColumn("last_used_at", now).Error; err != nil {
+		return errors.Join(ErrDatabase, err)
+	}
+	credential.LastUsedAt = &now
+	return nil
+}
+
+// RecentlyPlayedBooks lists the user's books ordered by when playback
+// progress was last recorded for them.
+func (r *Repository) RecentlyPlayedBooks(user *User, offset, limit int) ([]Book, error) {
+	var books []Book
+	err := r.readableBooks(r.DB, user.ID).
+		Select("books.*").
+		Joins("JOIN progresses ON progresses.book_id = books.id AND progresses.user_id = ?", user.ID).
+		Order("progresses.client_updated_at DESC, books.id DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&books).Error
+	if err != nil {
+		return nil, errors.Join(ErrDatabase, err)
+	}
+	if err := r.markAccess(user, books); err != nil {
+		return nil, err
+	}
+	return books, nil
+}
+
+// Search finds the chapters of the user's and shared books matching a web-style query
+// (quoted phrases, or, -exclusion), best matches first. Each chapter is
+// parsed with its own configuration so stemming follows the book's
+// language. It reports whether more hits follow the page.
+func (r *Repository) Search(user *User, q SearchQuery) ([]SearchHit, bool, error) {
+	text := strings.TrimSpace(q.Text)
+	if text == "" {
+		return nil, false, errors.Join(ErrValidation, errors.New("query cannot be empty"))
+	}
+
+	filter := ""
+	if q.BookID != 0 {
+		filter = "AND b.id = @book"
+	}
+
+	// Headlines are expensive, so they are only computed for the page.
+	var hits []SearchHit
+	err := r.DB.Raw(`SELECT hits.*, ts_headline(hits.config, c.text, hits.query, @options) AS headline
+		FROM (
+			SELECT b.id AS book_id, b.title AS book_title, b.author, bc.position, bc.title AS chapter_title,
+				c.id AS chapter_id, q.config, q.query, ts_rank_cd(c.search_vector, q.query) AS rank
+			FROM books b
+			JOIN book_chapters bc ON bc.book_id = b.id AND bc.deleted_at IS NULL
+			JOIN chapters c ON c.id = bc.chapter_id AND c.deleted_at IS NULL
+			CROSS JOIN LATERAL (
+				SELECT CAST(c.search_config AS regconfig) AS config,
+					websearch_to_tsquery(CAST(c.search_config AS regconfig), @text) AS query
+			) q
+			WHERE (b.user_id = @user OR b.id IN (@shared)) AND b.deleted_at IS NULL `+filter+`
+				AND c.search_vector @@ q.query
+			ORDER BY rank DESC, b.id, bc.position
+			LIMIT @limit OFFSET @offset
+		) hits
+		JOIN chapters c ON c.id = hits.chapter_id
+		ORDER BY hits.rank DESC, hits.book_id, hits.position`,
+		sql.Named("options", headlineOptions),
+		sql.Named("text", text),
+		sql.Named("user", user.ID),
+		sql.Named("shared", r.activeShares().Select("book_id").Where("user_id = ?", user.ID)),
+		sql.Named("book", q.BookID),
+		sql.Named("limit", q.PageSize+1),
+		sql.Named("offset", (q.Page-1)*q.PageSize),
+	).Scan(&hits).Error
+	if err != nil {
+		return nil, false, errors.Join(ErrDatabase, err)
+	}
+
+	more := len(hits) > q.PageSize
+	if more {
+		hits = hits[:q.PageSize]
+	}
+	for i := range hits {
+		hits[i].Snippet, hits[i].Highlights = parseHeadline(hits[i].Headline)
+	}
+	return hits, more, nil
+}
+
+func (r *Repository) CreateCollection(user *User, collection *Collection) error {
+	name, err := normalizeName(collection.Name, maxNameLength)
+	if err != nil {
+		return errors.Join(ErrValidation, err)
+	}
+
+	collection.ID = 0
+	collection.UserID = user.ID
+	collection.Name = name
+	collection.Description = strings.TrimSpace(collection.Description)
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := nameAvailable(tx, &Collection{}, user.ID, name, 0); err != nil {
+			return err
+		}
+		if err := tx.Create(collection).Error; err != nil {
+			return nameError(err)
+		}
+		return nil
+	})
+}
+
+func (r *Repository) ListCollections(user *User) ([]Collection, error) {
+	var collections []Collection
+	err := r.DB.Select("collections.*, (SELECT COUNT(*) FROM collection_books WHERE collection_books.collection_id = collections.id) AS book_count").
+		Where("user_id = ?", user.ID).
+		Order("name").
+		Find(&collections).Error
+	if err != nil {
+		return nil, errors.Join(ErrDatabase, err)
+	}
+	return collections, nil
+}
+
+func (r *Repository) GetCollection(user *User, id uint) (*Collection, error) {
+	var collection Collection
+	err := r.DB.Select("collections.*, (SELECT COUNT(*) FROM collection_books WHERE collection_books.collection_id = collections.id) AS book_count").
+		Where("user_id = ?", user.ID).
+		First(&collection, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, errors.Join(ErrDatabase, err)
+	}
+	return &collection, nil
+}
+
+func (r *Repository) UpdateCollection(collection *Collection, update CollectionUpdate) error {
+	fields := map[string]interface{}{}
+	if update.Name != nil {
+		name, err := normalizeName(*update.Name, maxNameLength)
+		if err != nil {
+			return errors.Join(ErrValidation, err)
+		}
+		fields["name"] = name
+	}
+	if update.Description != nil {
+		fields["description"] = strings.TrimSpace(*update.Description)
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if name, ok := fields["name"].(string); ok {
+			if err := nameAvailable(tx, &Collection{}, collection.UserID, name, collection.ID); err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(collection).Updates(fields).Error; err != nil {
+			return nameError(err)
+		}
+		if name, ok := fields["name"].(string); ok {
+			collection.Name = name
+		}
+		if description, ok := fields["description"].(string); ok {
+			collection.Description = description
+		}
+		return nil
+	})
+}
+
+func (r *Repository) DeleteCollection(collection *Collection) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("collection_id = ?", collection.ID).Delete(&CollectionBook{}).Error; err != nil {
+			return errors.Join(ErrDatabase, err)
+		}
+		if err := tx.Unscoped().Delete(collection).Error; err != nil {
+			return errors.Join(ErrDatabase, err)
+		}
+		return nil
+	})
+}
+
+// CollectionBooks returns the collection's books in their curated order.
+func (r *Repository) CollectionBooks(collection *Collection) ([]Book, error) {
+	var books []Book
+	err := r.readableBooks(r.DB, collection.UserID).
+		Select("books.*").
+		Joins("JOIN collection_books ON collection_books.book_id = books.id").
+		Where("collection_books.collection_id = ?", collection.ID).
+		Order("collection_books.position, collection_books.created_at").
+		Find(&books).Error
+	if err != nil {
+		return nil, errors.Join(ErrDatabase, err)
+	}
+	return books, nil
+}
+
+// AddCollectionBook places a book at position in the collection, moving it
+// if it is already there. A nil or out of range position appends it. The
+// book may be the collection owner's own or one shared with them.
+func (r *Repository) AddCollectionBook(collection *Collection, book *Book, position *int) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		var readable int64
+		if err := r.readableBooks(tx, collection.UserID).Where("books.id = ?", book.ID).Count(&readable).Error; err != nil {
+			return errors.Join(ErrDatabase, err)
+		}
+		if readable == 0 {
+			return ErrNotFound
+		}
+
+		ids, err := collectionBookIDs(tx, collection)
+		if err != nil {
+			return err
+		}
+		ids = slices.DeleteFunc(ids, func(id uint) bool { return id == book.ID })
+
+		at := len(ids)
+		if position != nil && *position >= 0 && *position < len(ids) {
+			at = *position
+		}
+		return writeCollectionOrder(tx, collection, slices.Insert(ids, at, book.ID))
+	})
+}
+
+// SetCollectionBooks replaces the collection's contents with books in the
+// given order.
+func (r *Repository) SetCollectionBooks(collection *Collection, ids []uint) error {
+	seen := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			return errors.Join(ErrValidation, fmt.Errorf("book %d listed twice", id))
+		}
+		seen[id] = true
+	}
+
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		var readable int64
+		if err := r.readableBooks(tx, collection.UserID).Where("books.id IN ?", ids).Count(&readable).Error; err != nil {
+			return errors.Join(ErrDatabase, err)
+		}
+		if int(readable) != len(ids) {
+			return errors.Join(ErrValidation, errors.New("unknown book"))
+		}
+
+		keep := append([]uint{0}, ids...)
+		if err := tx.Where("collection_id = ? AND book_id NOT IN ?", collection.ID, keep).Delete(&CollectionBook{}).Error; err != nil {
+			return errors.Join(ErrDatabase, err)
+		}
+		return writeCollectionOrder(tx, collection, ids)
+	})
+}
+
+func (r *Repository) RemoveCollectionBook(collection *Collection, bookID uint) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("collection_id = ? AND book_id = ?", collection.ID, bookID).Delete(&CollectionBook{})
+		if result.Error != nil {
+			return errors.Join(ErrDatabase, result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+
+		ids, err := collectionBookIDs(tx, collection)
+		if err != nil {
+			return err
+		}
+		return writeCollectionOrder(tx, collection, ids)
+	})
+}
+
+func collectionBookIDs(tx *gorm.DB, collection *Collection) ([]uint, error) {
+	var ids []uint
+	err := tx.Model(&CollectionBook{}).
+		Where("collection_id = ?", collection.ID).
+		Order("position, created_at").
+		Pluck("book_id", &ids).Error
+	if err != nil {
+		return nil, errors.Join(ErrDatabase, err)
+	}
+	return ids, nil
+}
+
+// writeCollectionOrder numbers the books from zero in the order given,
+// adding any that are not in the collection yet.
+func writeCollectionOrder(tx *gorm.DB, collection *Collection, ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	rows := make([]CollectionBook, len(ids))
+	for i, id := range ids {
+		rows[i] = CollectionBook{CollectionID: collection.ID, BookID: id, Position: i}
+	}
+
+	err := tx.Clauses(clause.OnConflict{
+		Columns:	[]clause.Column{{Name: "collection_id"}, {Name: "book_id"}},
+		DoUpdates:	clause.AssignmentColumns([]string{"position"}),
+	}).Create(&rows).Error
+	if err != nil {
+		return errors.Join(ErrDatabase, err)
+	}
+	return nil
+}
+
+func (r *Repository) ListTags(user *User) ([]Tag, error) {
+	var tags []Tag
+	err := r.DB.Select("tags.*, (SELECT COUNT(*) FROM book_tags WHERE book_tags.tag_id = tags.id) AS book_count").
+		Where("user_id = ?", user.ID).
+		Order("name").
+		Find(&tags).Error
+	if err != nil {
+		return nil, errors.Join(ErrDatabase, err)
+	}
+	return tags, nil
+}
+
+func (r *Repository) GetTag(user *User, id uint) (*Tag, error) {
+	var tag Tag
+	if err := r.DB.Where("user_id = ?", user.ID).First(&tag, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, errors.Join(ErrDatabase, err)
+	}
+	return &tag, nil
+}
+
+func (r *Repository) CreateTag(user *User, name string) (*Tag, error) {
+	name, err := normalizeName(name, maxTagLength)
+	if err != nil {
+		return nil, errors.Join(ErrValidation, err)
+	}
+
+	tag := &Tag{UserID: user.ID, Name: name}
+	err = r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := nameAvailable(tx, &Tag{}, user.ID, name, 0); err != nil {
+			return err
+		}
+		if err := tx.Create(tag).Error; err != nil {
+			return nameError(err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
+
+func (r *Repository) RenameTag(tag *Tag, name string) error {
+	name, err := normalizeName(name, maxTagLength)
+	if err != nil {
+		return errors.Join(ErrValidation, err)
+	}
+
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := nameAvailable(tx, &Tag{}, tag.UserID, name, tag.ID); err != nil {
+			return err
+		}
+		if err := tx.Model(tag).Update("name", name).Error; err != nil {
+			return nameError(err)
+		}
+		return nil
+	})
+}
+
+func (r *Repository) DeleteTag(tag *Tag) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("tag_id = ?", tag.ID).Delete(&BookTag{}).Error; err != nil {
+			return errors.Join(ErrDatabase, err)
+		}
+		if err := tx.Unscoped().Delete(tag).Error; err != nil {
+			return errors.Join(ErrDatabase, err)
+		}
+		return nil
+	})
+}
+
+// BookTags lists the user's tags on the book. Tags are personal, so the
+// owner of a shared book and its grantees each see only their own.
+func (r *Repository) BookTags(user *User, book *Book) ([]Tag, error) {
+	var tags []Tag
+	err := r.DB.Select("tags.*").
+		Joins("JOIN book_tags ON book_tags.tag_id = tags.id").
+		Where("book_tags.book_id = ? AND tags.user_id = ?", book.ID, user.ID).
+		Order("tags.name").
+		Find(&tags).Error
+	if err != nil {
+		return nil, errors.Join(ErrDatabase, err)
+	}
+	return tags, nil
+}
+
+// SetBookTags replaces the user's tags on the book with the named ones,
+// creating any tags the user does not have yet. Other users' tags on the
+// book are left alone.
+func (r *Repository) SetBookTags(user *User, book *Book, names []string) ([]Tag, error) {
+	var normalized []string
+	for _, name := range names {
+		name, err := normalizeName(name, maxTagLength)
+		if err != nil {
+			return nil, errors.Join(ErrValidation, err)
+		}
+		if !slices.Contains(normalized, name) {
+			normalized = append(normalized, name)
+		}
+	}
+
+	var tags []Tag
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		if len(normalized) > 0 {
+			missing := make([]Tag, len(normalized))
+			for i, name := range normalized {
+				missing[i] = Tag{UserID: user.ID, Name: name}
+			}
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&missing).Error; err != nil {
+				return errors.Join(ErrDatabase, err)
+			}
+			if err := tx.Where("user_id = ? AND name IN ?", user.ID, normalized).Order("name").Find(&tags).Error; err != nil {
+				return errors.Join(ErrDatabase, err)
+			}
+		}
+
+		ids := []uint{0}
+		links := make([]BookTag, len(tags))
+		for i, tag := range tags {
+			ids = append(ids, tag.ID)
+			links[i] = BookTag{BookID: book.ID, TagID: tag.ID}
+		}
+		owned := tx.Model(&Tag{}).Select("id").Where("user_id = ?", user.ID)
+		if err := tx.Where("book_id = ? AND tag_id NOT IN ? AND tag_id IN (?)", book.ID, ids, owned).Delete(&BookTag{}).Error; err != nil {
+			return errors.Join(ErrDatabase, err)
+		}
+		if len(links) > 0 {
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&links).Error; err != nil {
+				return errors.Join(ErrDatabase, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+func (r *Repository) CreateShelf(user *User, shelf *Shelf) error {
+	name, err := normalizeName(shelf.Name, maxNameLength)
+	if err != nil {
+		return errors.Join(ErrValidation, err)
+	}
+	if err := shelf.Filter.Validate(); err != nil {
+		return errors.Join(ErrValidation, err)
+	}
+
+	shelf.ID = 0
+	shelf.UserID = user.ID
+	shelf.Name = name
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := nameAvailable(tx, &Shelf{}, user.ID, name, 0); err != nil {
+			return err
+		}
+		if err := tx.Create(shelf).Error; err != nil {
+			return nameError(err)
+		}
+		return nil
+	})
+}
+
+func (r *Repository) ListShelves(user *User) ([]Shelf, error) {
+	var shelves []Shelf
+	if err := r.DB.Where("user_id = ?", user.ID).Order("name").Find(&shelves).Error; err != nil {
+		return nil, errors.Join(ErrDatabase, err)
+	}
+	return shelves, nil
+}
+
+func (r *Repository) GetShelf(user *User, id uint) (*Shelf, error) {
+	var shelf Shelf
+	if err := r.DB.Where("user_id = ?", user.ID).First(&shelf, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, errors.Join(ErrDatabase, err)
+	}
+	return &shelf, nil
+}
+
+func (r *Repository) UpdateShelf(shelf *Shelf, update ShelfUpdate) error {
+	updated := *shelf
+	if update.Name != nil {
+		name, err := normalizeName(*update.Name, maxNameLength)
+		if err != nil {
+			return errors.Join(ErrValidation, err)
+		}
+		updated.Name = name
+	}
+	if update.Filter != nil {
+		updated.Filter = *update.Filter
+		if err := updated.Filter.Validate(); err != nil {
+			return errors.Join(ErrValidation, err)
+		}
+	}
+
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := nameAvailable(tx, &Shelf{}, shelf.UserID, updated.Name, shelf.ID); err != nil {
+			return err
+		}
+		if err := tx.Model(&updated).Select("name", "filter").Updates(&updated).Error; err != nil {
+			return nameError(err)
+		}
+		*shelf = updated
+		return nil
+	})
+}
+
+func (r *Repository) DeleteShelf(shelf *Shelf) error {
+	if err := r.DB.Unscoped().Delete(shelf).Error; err != nil {
+		return errors.Join(ErrDatabase, err)
+	}
+	return nil
+}
+
+// nameAvailable checks that none of the user's other records of model's
+// kind already uses name. A concurrent request can still take the name
+// before the write, which nameError then reports the same way.
+func nameAvailable(tx *gorm.DB, model interface{}, userID uint, name string, except uint) error {
+	var count int64
+	if err := tx.Model(model).Where("user_id = ? AND name = ? AND id <> ?", userID, name, except).Count(&count).Error; err != nil {
+		return errors.Join(ErrDatabase, err)
+	}
+	if count > 0 {
+		return ErrNameTaken
+	}
+	return nil
+}
+
+// ShareBook grants another user access to the book, replacing any grant
+// they already have.
+func (r *Repository) ShareBook(book *Book, grantee *User, permission BookAccess, expiresAt *time.Time) (*BookShare, error) {
+	if grantee.ID == book.UserID {
+		return nil, errors.Join(ErrValidation, errors.New("cannot share a book with its owner"))
+	}
+
+	share := &BookShare{
+		BookID:		book.ID,
+		OwnerID:	book.UserID,
+		UserID:		&grantee.ID,
+		Username:	grantee.Username,
+		Permission:	permission,
+		ExpiresAt:	expiresAt,
+	}
+	if err := r.grantShare(r.DB, share); err != nil {
+		return nil, err
+	}
+	return share, nil
+}
+
+// CreateShareLink creates a link share. Only the token's hash is stored, so
+// PlainText is the only chance to read it.
+func (r *Repository) CreateShareLink(book *Book, permission BookAccess, expiresAt *time.Time) (*BookShare, error) {
+	tokenData := make([]byte, shareTokenBytes)
+	if _, err := rand.Read(tokenData); err != nil {
+		return nil, errors.Join(ErrDatabase, err)
+	}
+
+	plainText := base64.RawURLEncoding.EncodeToString(tokenData)
+	share := &BookShare{
+		BookID:		book.ID,
+		OwnerID:	book.UserID,
+		Permission:	permission,
+		TokenHash:	hashToken(plainText),
+		PlainText:	plainText,
+		ExpiresAt:	expiresAt,
+	}
+	if err := r.DB.Create(share).Error; err != nil {
+		return nil, errors.Join(ErrDatabase, err)
+	}
+	return share, nil
+}
+
+func (r *Repository) ListShares(book *Book) ([]BookShare, error) {
+	var shares []BookShare
+	if err := r.DB.Preload("User").Where("book_id = ?", book.ID).Order("created_at").Find(&shares).Error; err != nil {
+		return nil, errors.Join(ErrDatabase, err)
+	}
+	for i := range shares {
+		if shares[i].User != nil {
+			shares[i].Username = shares[i].User.Username
+		}
+	}
+	return shares, nil
+}
+
+func (r *Repository) GetShare(book *Book, id uint) (*BookShare, error) {
+	var share BookShare
+	if err := r.DB.Preload("User").Where("book_id = ?", book.ID).First(&share, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, errors.Join(ErrDatabase, err)
+	}
+	if share.User != nil {
+		share.Username = share.User.Username
+	}
+	return &share, nil
+}
+
+func (r *Repository) UpdateShare(share *BookShare, permission BookAccess) error {
+	if err := r.DB.Model(share).Update("permission", permission).Error; err != nil {
+		return errors.Join(ErrDatabase, err)
+	}
+	return nil
+}
+
+// RevokeShare removes a grant or link. Access ends immediately, including
+// for signed URLs already handed out, since every request rechecks it.
+func (r *Repository) RevokeShare(share *BookShare) error {
+	if err := r.DB.Unscoped().Delete(share).Error; err != nil {
+		return errors.Join(ErrDatabase, err)
+	}
+	return nil
+}
+
+// SharedBooks lists the grants other users have given the user, newest
+// first, with the books they cover.
+func (r *Repository) SharedBooks(user *User) ([]BookShare, error) {
+	var shares []BookShare
+	err := r.activeShares().
+		Preload("Book").
+		Preload("Owner").
+		Where("user_id = ?", user.ID).
+		Order("created_at DESC").
+		Find(&shares).Error
+	if err != nil {
+		return nil, errors.Join(ErrDatabase, err)
+	}
+
+	for i := range shares {
+		if shares[i].Owner != nil {
+			shares[i].SharedBy = shares[i].Owner.Username
+		}
+		if shares[i].Book != nil {
+			shares[i].Book.Access = shares[i].Permission
+		}
+	}
+	return shares, nil
+}
+
+// ShareByToken resolves a share link to its share, with the owner loaded,
+// and book, recording the use. Link holders can only read, whatever the
+// share grants on accepting.
+func (r *Repository) ShareByToken(token string) (*BookShare, *Book, error) {
+	var share BookShare
+	if err := r.activeShares().Where("token_hash = ?", hashToken(token)).First(&share).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrNotFound
+		}
+		return nil, nil, errors.Join(ErrDatabase, err)
+	}
+
+	owner, err := r.UserByID(share.OwnerID)
+	if err != nil {
+		return nil, nil, err
+	}
+	book, err := r.GetBook(owner, share.BookID)
+	if err != nil {
+		return nil, nil, err
+	}
+	book.Access = AccessRead
+	share.Owner = owner
+
+	// Every chapter request resolves the link, so use is only recorded
+	// once per shareUseInterval.
+	now := time.Now()
+	if share.LastUsedAt == nil || now.Sub(*share.LastUsedAt) >= shareUseInterval {
+		if err := r.DB.Model(&share).UpdateColumn("last_used_at", now).Error; err != nil {
+			return nil, nil, errors.Join(ErrDatabase, err)
+		}
+		share.LastUsedAt = &now
+	}
+	return &share, book, nil
+}
+
+// AcceptShareLink turns a share link into a grant for the user, so the book
+// appears in their shared list with the link's permission.
+func (r *Repository) AcceptShareLink(user *User, token string) (*BookShare, error) {
+	link, book, err := r.ShareByToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if book.UserID == user.ID {
+		return nil, errors.Join(ErrValidation, errors.New("cannot accept a share of your own book"))
+	}
+
+	var existing BookShare
+	err = r.activeShares().Where("book_id = ? AND user_id = ?", book.ID, user.ID).First(&existing).Error
+	if err == nil && !upgradesGrant(existing.Permission, link.Permission) {
+		return &existing, nil
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.Join(ErrDatabase, err)
+	}
+
+	share := &BookShare{
+		BookID:		book.ID,
+		OwnerID:	book.UserID,
+		UserID:		&user.ID,
+		Permission:	link.Permission,
+		ExpiresAt:	link.ExpiresAt,
+	}
+	if err := r.grantShare(r.DB, share); err != nil {
+		return nil, err
+	}
+	return share, nil
+}
+
+func (r *Repository) grantShare(tx *gorm.DB, share *BookShare) error {
+	err := tx.Clauses(clause.OnConflict{
+		Columns:	[]clause.Column{{Name: "book_id"}, {Name: "user_id"}},
+		DoUpdates:	clause.AssignmentColumns([]string{"permission", "expires_at", "updated_at", "deleted_at"}),
+	}).Create(share).Error
+	if err != nil {
+		return errors.Join(ErrDatabase, err)
+	}
+	return nil
+}
+
+func (r *Repository) activeShares() *gorm.DB {
+	return r.DB.Model(&BookShare{}).Where("expires_at IS NULL OR expires_at > ?", time.Now())
+}
+
+// readableBooks limits a book query on db to the books userID owns or has
+// an active share of.
+func (r *Repository) readableBooks(db *gorm.DB, userID uint) *gorm.DB {
+	return db.Model(&Book{}).Where("books.user_id = ? OR books.id IN (?)", userID, r.activeShares().Select("book_id").Where("user_id = ?", userID))
+}
+
+// markAccess records the user's access level on listed books, as GetBook
+// does for a single one.
+func (r *Repository) markAccess(user *User, books []Book) error {
+	var shared []uint
+	for i := range books {
+		if books[i].UserID == user.ID || user.IsAdmin {
+			books[i].Access = AccessOwner
+		} else {
+			shared = append(shared, books[i].ID)
+		}
+	}
+	if len(shared) == 0 {
+		return nil
+	}
+
+	var shares []BookShare
+	if err := r.activeShares().Where("user_id = ? AND book_id IN ?", user.ID, shared).Find(&shares).Error; err != nil {
+		return errors.Join(ErrDatabase, err)
+	}
+	for i := range books {
+		for _, share := range shares {
+			if share.BookID == books[i].ID {
+				books[i].Access = share.Permission
+			}
+		}
+	}
+	return nil
+}
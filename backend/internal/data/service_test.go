@@ -0,0 +1,475 @@
+package data
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// testRepository runs against TEST_DATABASE_URL inside a transaction that
+// is rolled back when the test ends.
+func testRepository(t *testing.T) *Repository {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: gormlogger.Default.LogMode(gormlogger.Silent)})
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	tx := db.Begin()
+	t.Cleanup(func() { tx.Rollback() })
+
+	// NewRepository reads its settings from the environment, which tests
+	// do not set.
+	repo := &Repository{DB: tx, Config: &DataConfig{}}
+	if err := repo.AutoMigrate(); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	return repo
+}
+
+func testUser(t *testing.T, repo *Repository, username string, isAdmin bool) *User {
+	t.Helper()
+	user := &User{
+		Username:		username,
+		PasswordHash:	[]byte("x"),
+		IsAdmin:		isAdmin,
+		Usage:			Usage{RateLimit: 100, Capacity: 100, LastRequest: time.Now()},
+	}
+	if err := repo.DB.Create(user).Error; err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	return user
+}
+
+func testBook(t *testing.T, repo *Repository, owner *User, title string) *Book {
+	t.Helper()
+	book := &Book{UserID: owner.ID, Title: title, Duration: 3600, ChapterCount: 1}
+	if err := repo.DB.Create(book).Error; err != nil {
+		t.Fatalf("failed to create book: %v", err)
+	}
+	return book
+}
+
+// dryRunQuery is a statement built by dryRunRepository, with its arguments
+// inlined into sql for matching.
+type dryRunQuery struct {
+	sql		string
+	vars	[]interface{}
+}
+
+// dryRunRepository builds SQL without a database, recording every query,
+// so access rules can be checked without TEST_DATABASE_URL.
+func dryRunRepository(t *testing.T) (*Repository, *[]dryRunQuery) {
+	t.Helper()
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost", PreferSimpleProtocol: true}), &gorm.Config{
+		DryRun:					true,
+		DisableAutomaticPing:	true,
+		Logger:					gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("failed to open: %v", err)
+	}
+
+	var queries []dryRunQuery
+	record := func(tx *gorm.DB) {
+		queries = append(queries, dryRunQuery{
+			sql:	tx.Dialector.Explain(tx.Statement.SQL.String(), tx.Statement.Vars...),
+			vars:	tx.Statement.Vars,
+		})
+	}
+	if err := db.Callback().Query().After("gorm:query").Register("test:record", record); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Callback().Create().After("gorm:create").Register("test:record", record); err != nil {
+		t.Fatal(err)
+	}
+	return &Repository{DB: db, Config: &DataConfig{}}, &queries
+}
+
+func TestGetBookScope(t *testing.T) {
+	tests := []struct {
+		name		string
+		user		*User
+		contains	[]string
+		excludes	[]string
+	}{
+		{"user", &User{Base: Base{ID: 7}}, []string{
+			"books.user_id = 7 OR books.id IN (SELECT",
+			`FROM "book_shares" WHERE (expires_at IS NULL OR expires_at > `,
+			"AND user_id = 7",
+		}, nil},
+		{"admin", &User{Base: Base{ID: 7}, IsAdmin: true}, nil, []string{"user_id", "book_shares"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, queries := dryRunRepository(t)
+			repo.GetBook(tt.user, 3)
+			i := slices.IndexFunc(*queries, func(q dryRunQuery) bool { return strings.Contains(q.sql, `FROM "books"`) })
+			if i < 0 {
+				t.Fatalf("GetBook() ran %v, want a books query", *queries)
+			}
+			query := (*queries)[i].sql
+			for _, want := range tt.contains {
+				if !strings.Contains(query, want) {
+					t.Errorf("GetBook() query = %s\nwant it to contain %s", query, want)
+				}
+			}
+			for _, unwanted := range tt.excludes {
+				if strings.Contains(query, unwanted) {
+					t.Errorf("GetBook() query = %s\nwant it not to contain %s", query, unwanted)
+				}
+			}
+		})
+	}
+}
+
+func TestMarkAccess(t *testing.T) {
+	user := &User{Base: Base{ID: 7}}
+	admin := &User{Base: Base{ID: 8}, IsAdmin: true}
+
+	tests := []struct {
+		name	string
+		user	*User
+		owners	[]uint
+		want	[]BookAccess
+		queries	int
+	}{
+		{"owned", user, []uint{7, 7}, []BookAccess{AccessOwner, AccessOwner}, 0},
+		{"admin", admin, []uint{7, 9}, []BookAccess{AccessOwner, AccessOwner}, 0},
+		{"not shared", user, []uint{7, 9}, []BookAccess{AccessOwner, ""}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, queries := dryRunRepository(t)
+			books := make([]Book, len(tt.owners))
+			for i, owner := range tt.owners {
+				books[i] = Book{Base: Base{ID: uint(i + 1)}, UserID: owner}
+			}
+
+			if err := repo.markAccess(tt.user, books); err != nil {
+				t.Fatalf("markAccess() error = %v", err)
+			}
+			for i := range books {
+				if books[i].Access != tt.want[i] {
+					t.Errorf("book %d access = %q, want %q", i, books[i].Access, tt.want[i])
+				}
+			}
+			if len(*queries) != tt.queries {
+				t.Errorf("markAccess() ran %d queries, want %d", len(*queries), tt.queries)
+			}
+			if tt.queries > 0 && !strings.Contains((*queries)[0].sql, "user_id = 7 AND book_id IN (2)") {
+				t.Errorf("markAccess() query = %s, want only the other user's book", (*queries)[0].sql)
+			}
+		})
+	}
+}
+
+func TestShareBookWithOwner(t *testing.T) {
+	repo, queries := dryRunRepository(t)
+	owner := &User{Base: Base{ID: 7}}
+	book := &Book{Base: Base{ID: 3}, UserID: owner.ID}
+
+	if _, err := repo.ShareBook(book, owner, AccessEdit, nil); !errors.Is(err, ErrValidation) {
+		t.Errorf("ShareBook() error = %v, want %v", err, ErrValidation)
+	}
+	if len(*queries) != 0 {
+		t.Errorf("ShareBook() ran %v, want no queries", *queries)
+	}
+}
+
+func TestShareByTokenHashesToken(t *testing.T) {
+	repo, queries := dryRunRepository(t)
+	const token = "plain-share-token"
+
+	repo.ShareByToken(token)
+	if len(*queries) == 0 {
+		t.Fatal("ShareByToken() ran no query")
+	}
+	query := (*queries)[0]
+	hashed := slices.ContainsFunc(query.vars, func(v interface{}) bool {
+		b, ok := v.([]byte)
+		return ok && bytes.Equal(b, hashToken(token))
+	})
+	if strings.Contains(query.sql, token) || !hashed {
+		t.Errorf("ShareByToken() query = %s %v, want it to match the token's hash only", query.sql, query.vars)
+	}
+	if !strings.Contains(query.sql, "expires_at IS NULL OR expires_at > ") {
+		t.Errorf("ShareByToken() query = %s, want expired links excluded", query.sql)
+	}
+}
+
+func TestGetBookAccess(t *testing.T) {
+	repo := testRepository(t)
+	owner := testUser(t, repo, "test-owner", false)
+	book := testBook(t, repo, owner, "Shared")
+
+	grant := func(username string, permission BookAccess, expiresAt *time.Time) *User {
+		user := testUser(t, repo, username, false)
+		if _, err := repo.ShareBook(book, user, permission, expiresAt); err != nil {
+			t.Fatalf("ShareBook() error = %v", err)
+		}
+		return user
+	}
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name	string
+		user	*User
+		want	BookAccess
+		err		error
+	}{
+		{"owner", owner, AccessOwner, nil},
+		{"admin", testUser(t, repo, "test-admin", true), AccessOwner, nil},
+		{"reader", grant("test-reader", AccessRead, nil), AccessRead, nil},
+		{"editor", grant("test-editor", AccessEdit, &future), AccessEdit, nil},
+		{"expired", grant("test-expired", AccessEdit, &past), "", ErrNotFound},
+		{"stranger", testUser(t, repo, "test-stranger", false), "", ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := repo.GetBook(tt.user, book.ID)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("GetBook() error = %v, want %v", err, tt.err)
+			}
+			if err == nil && got.Access != tt.want {
+				t.Errorf("GetBook() access = %q, want %q", got.Access, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetBookTags(t *testing.T) {
+	repo := testRepository(t)
+	owner := testUser(t, repo, "test-owner", false)
+	reader := testUser(t, repo, "test-reader", false)
+	book := testBook(t, repo, owner, "Tagged")
+	if _, err := repo.ShareBook(book, reader, AccessRead, nil); err != nil {
+		t.Fatalf("ShareBook() error = %v", err)
+	}
+	if _, err := repo.CreateTag(owner, "existing"); err != nil {
+		t.Fatalf("CreateTag() error = %v", err)
+	}
+
+	// Each step checks both users' tags, since one user's changes must
+	// never touch the other's.
+	tests := []struct {
+		name		string
+		user		*User
+		tags		[]string
+		owner		[]string
+		reader		[]string
+		err			error
+	}{
+		{"creates and sorts", owner, []string{"sci-fi", " existing ", "classic"}, []string{"classic", "existing", "sci-fi"}, nil, nil},
+		{"drops duplicates", owner, []string{"classic", "classic"}, []string{"classic"}, nil, nil},
+		{"grantee tags shared book", reader, []string{"classic", "later"}, []string{"classic"}, []string{"classic", "later"}, nil},
+		{"owner replaces own", owner, []string{"new"}, []string{"new"}, []string{"classic", "later"}, nil},
+		{"rejects empty names", owner, []string{"ok", "  "}, []string{"new"}, []string{"classic", "later"}, ErrValidation},
+		{"grantee clears own", reader, nil, []string{"new"}, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := repo.SetBookTags(tt.user, book, tt.tags)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("SetBookTags() error = %v, want %v", err, tt.err)
+			}
+
+			for _, check := range []struct {
+				user	*User
+				want	[]string
+			}{{owner, tt.owner}, {reader, tt.reader}} {
+				got, err := repo.BookTags(check.user, book)
+				if err != nil {
+					t.Fatalf("BookTags() error = %v", err)
+				}
+				if names := tagNames(got); !slices.Equal(names, check.want) {
+					t.Errorf("BookTags(%s) = %v, want %v", check.user.Username, names, check.want)
+				}
+			}
+		})
+	}
+}
+
+func TestCollectionOrder(t *testing.T) {
+	repo := testRepository(t)
+	owner := testUser(t, repo, "test-owner", false)
+	a := testBook(t, repo, owner, "A")
+	b := testBook(t, repo, owner, "B")
+	c := testBook(t, repo, owner, "C")
+	other := testBook(t, repo, testUser(t, repo, "test-other", false), "Other")
+
+	collection := &Collection{Name: "Queue"}
+	if err := repo.CreateCollection(owner, collection); err != nil {
+		t.Fatalf("CreateCollection() error = %v", err)
+	}
+	at := func(position int) *int { return &position }
+
+	tests := []struct {
+		name	string
+		apply	func() error
+		want	[]uint
+		err		error
+	}{
+		{"set", func() error { return repo.SetCollectionBooks(collection, []uint{b.ID, a.ID}) }, []uint{b.ID, a.ID}, nil},
+		{"append", func() error { return repo.AddCollectionBook(collection, c, nil) }, []uint{b.ID, a.ID, c.ID}, nil},
+		{"move to front", func() error { return repo.AddCollectionBook(collection, c, at(0)) }, []uint{c.ID, b.ID, a.ID}, nil},
+		{"out of range appends", func() error { return repo.AddCollectionBook(collection, c, at(9)) }, []uint{b.ID, a.ID, c.ID}, nil},
+		{"remove", func() error { return repo.RemoveCollectionBook(collection, a.ID) }, []uint{b.ID, c.ID}, nil},
+		{"remove missing", func() error { return repo.RemoveCollectionBook(collection, a.ID) }, []uint{b.ID, c.ID}, ErrNotFound},
+		{"set duplicate", func() error { return repo.SetCollectionBooks(collection, []uint{a.ID, a.ID}) }, []uint{b.ID, c.ID}, ErrValidation},
+		{"set unknown", func() error { return repo.SetCollectionBooks(collection, []uint{other.ID}) }, []uint{b.ID, c.ID}, ErrValidation},
+		{"add unknown", func() error { return repo.AddCollectionBook(collection, other, nil) }, []uint{b.ID, c.ID}, ErrNotFound},
+		{"clear", func() error { return repo.SetCollectionBooks(collection, nil) }, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.apply(); !errors.Is(err, tt.err) {
+				t.Fatalf("error = %v, want %v", err, tt.err)
+			}
+
+			books, err := repo.CollectionBooks(collection)
+			if err != nil {
+				t.Fatalf("CollectionBooks() error = %v", err)
+			}
+			var got []uint
+			for _, book := range books {
+				got = append(got, book.ID)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("CollectionBooks() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListBooksStatus(t *testing.T) {
+	repo := testRepository(t)
+	user := testUser(t, repo, "test-reader", false)
+	unstarted := testBook(t, repo, user, "Unstarted")
+	unfinished := testBook(t, repo, user, "Unfinished")
+	nearlyDone := testBook(t, repo, user, "Nearly done")
+	finished := testBook(t, repo, user, "Finished")
+
+	// Someone else's progress must not count towards user's status.
+	other := testUser(t, repo, "test-other", false)
+	listen := func(user *User, book *Book, position float64) {
+		progress := &Progress{UserID: user.ID, BookID: book.ID, Position: position, Speed: 1, ClientUpdatedAt: time.Now()}
+		if err := repo.DB.Create(progress).Error; err != nil {
+			t.Fatalf("failed to create progress: %v", err)
+		}
+	}
+	listen(user, unfinished, 600)
+	listen(user, nearlyDone, nearlyDone.Duration-finishedMargin/2)
+	listen(user, finished, finished.Duration)
+	listen(other, unstarted, unstarted.Duration)
+
+	tests := []struct {
+		status	BookStatus
+		want	[]uint
+	}{
+		{"", []uint{unstarted.ID, unfinished.ID, nearlyDone.ID, finished.ID}},
+		{BookUnstarted, []uint{unstarted.ID}},
+		{BookUnfinished, []uint{unfinished.ID}},
+		{BookFinished, []uint{nearlyDone.ID, finished.ID}},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.status), func(t *testing.T) {
+			books, total, err := repo.ListBooks(user, BookQuery{Status: tt.status, Sort: "created", Page: 1, PageSize: 10})
+			if err != nil {
+				t.Fatalf("ListBooks() error = %v", err)
+			}
+			var got []uint
+			for _, book := range books {
+				got = append(got, book.ID)
+			}
+			if !slices.Equal(got, tt.want) || total != int64(len(tt.want)) {
+				t.Errorf("ListBooks() = %v (total %d), want %v", got, total, tt.want)
+			}
+		})
+	}
+}
+
+func tagNames(tags []Tag) []string {
+	var names []string
+	for _, tag := range tags {
+		names = append(names, tag.Name)
+	}
+	return names
+}
+
+func TestSharedBooksListed(t *testing.T) {
+	repo := testRepository(t)
+	owner := testUser(t, repo, "test-owner", false)
+	reader := testUser(t, repo, "test-reader", false)
+	own := testBook(t, repo, reader, "Own")
+	shared := testBook(t, repo, owner, "Shared")
+	expired := testBook(t, repo, owner, "Expired")
+	private := testBook(t, repo, owner, "Private")
+
+	past := time.Now().Add(-time.Hour)
+	if _, err := repo.ShareBook(shared, reader, AccessEdit, nil); err != nil {
+		t.Fatalf("ShareBook() error = %v", err)
+	}
+	if _, err := repo.ShareBook(expired, reader, AccessRead, &past); err != nil {
+		t.Fatalf("ShareBook() error = %v", err)
+	}
+
+	collection := &Collection{Name: "Mixed"}
+	if err := repo.CreateCollection(reader, collection); err != nil {
+		t.Fatalf("CreateCollection() error = %v", err)
+	}
+
+	tests := []struct {
+		book	*Book
+		listed	bool
+		access	BookAccess
+	}{
+		{own, true, AccessOwner},
+		{shared, true, AccessEdit},
+		{expired, false, ""},
+		{private, false, ""},
+	}
+
+	books, _, err := repo.ListBooks(reader, BookQuery{Page: 1, PageSize: 10})
+	if err != nil {
+		t.Fatalf("ListBooks() error = %v", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.book.Title, func(t *testing.T) {
+			i := slices.IndexFunc(books, func(book Book) bool { return book.ID == tt.book.ID })
+			if (i >= 0) != tt.listed {
+				t.Fatalf("ListBooks() lists %q = %v, want %v", tt.book.Title, i >= 0, tt.listed)
+			}
+			if i >= 0 && books[i].Access != tt.access {
+				t.Errorf("ListBooks() access = %q, want %q", books[i].Access, tt.access)
+			}
+
+			err := repo.AddCollectionBook(collection, tt.book, nil)
+			if tt.listed && err != nil {
+				t.Errorf("AddCollectionBook() error = %v", err)
+			}
+			if !tt.listed && !errors.Is(err, ErrNotFound) {
+				t.Errorf("AddCollectionBook() error = %v, want %v", err, ErrNotFound)
+			}
+		})
+	}
+}
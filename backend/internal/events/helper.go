@@ -0,0 +1,47 @@
+package events
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"cadence/internal/data"
+)
+
+func JobTopic(jobID uint) string {
+	return fmt.Sprintf("job:%d", jobID)
+}
+
+func (e Event) Terminal() bool {
+	switch e.Status {
+	case data.JobCompleted, data.JobFailed, data.JobCanceled:
+		return e.Type == EventStatus || e.Type == EventSnapshot
+	}
+	return false
+}
+
+func JobEvent(job *data.Job, eventType EventType) Event {
+	event := Event{
+		Type:				eventType,
+		JobID:				job.ID,
+		Status:				job.Status,
+		CompletedChapters:	job.CompletedChapters,
+		TotalChapters:		job.TotalChapters,
+		Error:				job.Error,
+		BookID:				job.BookID,
+		Time:				time.Now(),
+	}
+	switch {
+	case job.Status == data.JobCompleted:
+		event.Percent = 100
+	case job.TotalChars > 0:
+		event.Percent = math.Round(float64(job.CompletedChars)*1000/float64(job.TotalChars)) / 10
+	}
+	return event
+}
+
+func (e Event) WithChapter(chapter *data.JobChapter) Event {
+	position := chapter.Position
+	e.Chapter, e.ChapterTitle = &position, chapter.Title
+	return e
+}
@@ -0,0 +1,46 @@
+package events
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"cadence/internal/data"
+)
+
+type EventType string
+
+const (
+	EventSnapshot		EventType = "snapshot"
+	EventStatus			EventType = "status"
+	EventProgress		EventType = "progress"
+	EventChapter		EventType = "chapter"
+	EventError			EventType = "error"
+)
+
+const subscriberBuffer = 64
+
+type Event struct {
+	Type				EventType		`json:"type"`
+	JobID				uint			`json:"job_id"`
+	Status				data.JobStatus	`json:"status,omitempty"`
+	Chapter				*int			`json:"chapter,omitempty"`
+	ChapterTitle		string			`json:"chapter_title,omitempty"`
+	CompletedChapters	int				`json:"completed_chapters"`
+	TotalChapters		int				`json:"total_chapters"`
+	Percent				float64			`json:"percent"`
+	ETASeconds			*int			`json:"eta_seconds,omitempty"`
+	Error				string			`json:"error,omitempty"`
+	BookID				*uint			`json:"book_id,omitempty"`
+	Time				time.Time		`json:"time"`
+}
+
+type Broker interface {
+	Publish(ctx context.Context, topic string, event Event) error
+	Subscribe(ctx context.Context, topic string) (<-chan Event, func())
+}
+
+type MemoryBroker struct {
+	mu					sync.RWMutex
+	subscribers			map[string]map[chan Event]struct{}
+}
@@ -0,0 +1,295 @@
+package export
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strings"
+	"time"
+
+	"cadence/internal/ingest"
+
+	"github.com/google/uuid"
+)
+
+// WriteEPUB streams the book as an EPUB 3 with Media Overlays: one XHTML
+// document per chapter with every paragraph and sentence addressable by
+// ID, an SMIL file pairing each sentence with its clip of the chapter
+// audio, and the audio itself.
+func (e *Exporter) WriteEPUB(ctx context.Context, w io.Writer, src *Source) error {
+	zw := zip.NewWriter(w)
+
+	// The mimetype entry must come first, stored and without a data
+	// descriptor, so its header is written raw with the sizes known.
+	mimetype, err := zw.CreateRaw(&zip.FileHeader{
+		Name:				"mimetype",
+		Method:				zip.Store,
+		CRC32:				crc32.ChecksumIEEE([]byte(epubMimetype)),
+		CompressedSize64:	uint64(len(epubMimetype)),
+		UncompressedSize64:	uint64(len(epubMimetype)),
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(mimetype, epubMimetype); err != nil {
+		return err
+	}
+
+	files := []entry{
+		{"META-INF/container.xml", epubContainer()},
+		{"OEBPS/content.opf", epubPackage(src)},
+		{"OEBPS/nav.xhtml", epubNav(src)},
+		{"OEBPS/style.css", epubStylesheet},
+	}
+	for i, ch := range src.Chapters {
+		name := chapterName(i)
+		files = append(files,
+			entry{"OEBPS/text/" + name + ".xhtml", epubChapter(src, &ch)},
+			entry{"OEBPS/smil/" + name + ".smil", epubOverlay(name, &ch)},
+		)
+	}
+	for _, f := range files {
+		fw, err := zw.Create(f.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(fw, f.content); err != nil {
+			return err
+		}
+	}
+
+	if ext, ok := coverExtensions[src.Book.CoverType]; ok && src.Book.HasCover {
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: "OEBPS/images/cover" + ext, Method: zip.Store})
+		if err != nil {
+			return err
+		}
+		if err := e.copyObject(ctx, fw, src.Book.CoverKey); err != nil {
+			return err
+		}
+	}
+
+	for i, ch := range src.Chapters {
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: "OEBPS/audio/" + chapterName(i) + ".mp3", Method: zip.Store})
+		if err != nil {
+			return err
+		}
+		if err := e.copyObject(ctx, fw, ch.AudioKey); err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+func epubContainer() string {
+	return `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+`
+}
+
+func epubIdentifier(src *Source) string {
+	return "urn:uuid:" + uuid.NewSHA1(uuid.NameSpaceOID, []byte(fmt.Sprintf("cadence/book/%d/%d", src.Book.ID, src.Book.JobID))).String()
+}
+
+func epubLanguage(src *Source) string {
+	if src.Book.Language != "" {
+		return src.Book.Language
+	}
+	return "en"
+}
+
+func epubPackage(src *Source) string {
+	book := src.Book
+	var b strings.Builder
+
+	fmt.Fprintf(&b, `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="book-id" xml:lang="%s" prefix="media: http://www.idpf.org/epub/vocab/overlays/#">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="book-id">%s</dc:identifier>
+    <dc:title>%s</dc:title>
+    <dc:language>%s</dc:language>
+`, escape(epubLanguage(src)), epubIdentifier(src), escape(book.Title), escape(epubLanguage(src)))
+	if book.Author != "" {
+		fmt.Fprintf(&b, "    <dc:creator>%s</dc:creator>\n", escape(book.Author))
+	}
+	if book.Description != "" {
+		fmt.Fprintf(&b, "    <dc:description>%s</dc:description>\n", escape(book.Description))
+	}
+	if book.Series != "" {
+		fmt.Fprintf(&b, "    <meta property=\"belongs-to-collection\" id=\"series\">%s</meta>\n", escape(book.Series))
+		fmt.Fprintf(&b, "    <meta refines=\"#series\" property=\"collection-type\">series</meta>\n")
+		if book.SeriesIndex != nil {
+			fmt.Fprintf(&b, "    <meta refines=\"#series\" property=\"group-position\">%g</meta>\n", *book.SeriesIndex)
+		}
+	}
+	fmt.Fprintf(&b, "    <meta property=\"dcterms:modified\">%s</meta>\n", book.UpdatedAt.UTC().Format(time.RFC3339))
+	if book.Narrator != "" {
+		fmt.Fprintf(&b, "    <meta property=\"media:narrator\">%s</meta>\n", escape(book.Narrator))
+	}
+	fmt.Fprintf(&b, "    <meta property=\"media:active-class\">%s</meta>\n", overlayActiveClass)
+
+	var total float64
+	for i, ch := range src.Chapters {
+		total += ch.Sync.Duration
+		fmt.Fprintf(&b, "    <meta property=\"media:duration\" refines=\"#mo-%s\">%s</meta>\n", chapterName(i), clock(ch.Sync.Duration))
+	}
+	fmt.Fprintf(&b, "    <meta property=\"media:duration\">%s</meta>\n", clock(total))
+
+	coverExt, hasCover := coverExtensions[book.CoverType]
+	hasCover = hasCover && book.HasCover
+	if hasCover {
+		b.WriteString("    <meta name=\"cover\" content=\"cover-image\"/>\n")
+	}
+	b.WriteString("  </metadata>\n  <manifest>\n")
+	b.WriteString("    <item id=\"nav\" href=\"nav.xhtml\" media-type=\"application/xhtml+xml\" properties=\"nav\"/>\n")
+	b.WriteString("    <item id=\"css\" href=\"style.css\" media-type=\"text/css\"/>\n")
+	if hasCover {
+		fmt.Fprintf(&b, "    <item id=\"cover-image\" href=\"images/cover%s\" media-type=\"%s\" properties=\"cover-image\"/>\n", coverExt, book.CoverType)
+	}
+	for i := range src.Chapters {
+		name := chapterName(i)
+		fmt.Fprintf(&b, "    <item id=\"%s\" href=\"text/%s.xhtml\" media-type=\"application/xhtml+xml\" media-overlay=\"mo-%s\"/>\n", name, name, name)
+		fmt.Fprintf(&b, "    <item id=\"mo-%s\" href=\"smil/%s.smil\" media-type=\"application/smil+xml\"/>\n", name, name)
+		fmt.Fprintf(&b, "    <item id=\"audio-%s\" href=\"audio/%s.mp3\" media-type=\"audio/mpeg\"/>\n", name, name)
+	}
+	b.WriteString("  </manifest>\n  <spine>\n")
+	for i := range src.Chapters {
+		fmt.Fprintf(&b, "    <itemref idref=\"%s\"/>\n", chapterName(i))
+	}
+	b.WriteString("  </spine>\n</package>\n")
+	return b.String()
+}
+
+func epubNav(src *Source) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" xml:lang="%s" lang="%s">
+<head>
+  <title>%s</title>
+</head>
+<body>
+  <nav epub:type="toc" id="toc">
+    <h1>Contents</h1>
+    <ol>
+`, escape(epubLanguage(src)), escape(epubLanguage(src)), escape(src.Book.Title))
+	for i, ch := range src.Chapters {
+		fmt.Fprintf(&b, "      <li><a href=\"text/%s.xhtml\">%s</a></li>\n", chapterName(i), escape(ch.Title))
+	}
+	b.WriteString("    </ol>\n  </nav>\n</body>\n</html>\n")
+	return b.String()
+}
+
+// epubChapter renders a chapter with every paragraph and sentence
+// addressable by ID. Paragraphs read from markup are copies of their
+// source element, keeping its inline markup and IDs; the rest are rebuilt
+// from the text the audio was generated from, keeping the kind and ID of
+// the original block where the source document provides one.
+func epubChapter(src *Source, ch *Chapter) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" xml:lang="%s" lang="%s">
+<head>
+  <title>%s</title>
+  <link rel="stylesheet" type="text/css" href="../style.css"/>
+</head>
+<body>
+  <section epub:type="chapter">
+`, escape(epubLanguage(src)), escape(epubLanguage(src)), escape(ch.Title))
+
+	m := ch.Sync
+	text := []rune(m.Text)
+	used := make(map[string]bool)
+	s := 0
+	for i, p := range m.Paragraphs {
+		first := s
+		for s < len(m.Sentences) && m.Sentences[s].TextStart < p.TextEnd {
+			s++
+		}
+		sentences := m.Sentences[first:s]
+		fallback := fmt.Sprintf("cadence-p%04d", i+1)
+
+		var block ingest.Block
+		if ch.Blocks != nil {
+			block = ch.Blocks[i]
+		}
+		if block.Element != nil {
+			var source strings.Builder
+			source.WriteString("    ")
+			if sourceBlock(&source, block.Element, fallback, text, p, sentences, first, used) {
+				b.WriteString(source.String())
+				b.WriteString("\n")
+				continue
+			}
+		}
+
+		tag, class, id := "p", "", fallback
+		switch block.Kind {
+		case ingest.BlockHeading:
+			tag = fmt.Sprintf("h%d", min(max(block.Level, 1), 6))
+		case ingest.BlockListItem:
+			class = "list-item"
+		}
+		if validID(block.ID) && !used[block.ID] && !strings.HasPrefix(block.ID, "cadence-") {
+			id = block.ID
+		}
+		used[id] = true
+
+		fmt.Fprintf(&b, "    <%s id=\"%s\"", tag, escape(id))
+		if class != "" {
+			fmt.Fprintf(&b, " class=\"%s\"", class)
+		}
+		b.WriteString(">")
+
+		pos := p.TextStart
+		for k, sentence := range sentences {
+			b.WriteString(escape(string(text[pos:sentence.TextStart])))
+			fmt.Fprintf(&b, "<span id=\"%s\">%s</span>", sentenceID(first+k), escape(string(text[sentence.TextStart:sentence.TextEnd])))
+			pos = sentence.TextEnd
+		}
+		b.WriteString(escape(string(text[pos:p.TextEnd])))
+		fmt.Fprintf(&b, "</%s>\n", tag)
+	}
+
+	b.WriteString("  </section>\n</body>\n</html>\n")
+	return b.String()
+}
+
+func epubOverlay(name string, ch *Chapter) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `<?xml version="1.0" encoding="UTF-8"?>
+<smil xmlns="http://www.w3.org/ns/SMIL" xmlns:epub="http://www.idpf.org/2007/ops" version="3.0">
+  <body>
+    <seq id="seq-%s" epub:textref="../text/%s.xhtml" epub:type="chapter">
+`, name, name)
+
+	m := ch.Sync
+	for i, sentence := range m.Sentences {
+		end := m.Duration
+		if i+1 < len(m.Sentences) {
+			end = m.Sentences[i+1].Begin
+		}
+		if end <= sentence.Begin {
+			continue
+		}
+		fmt.Fprintf(&b, `      <par id="par-%s">
+        <text src="../text/%s.xhtml#%s"/>
+        <audio src="../audio/%s.mp3" clipBegin="%s" clipEnd="%s"/>
+      </par>
+`, sentenceID(i), name, sentenceID(i), name, clock(sentence.Begin), clock(end))
+	}
+
+	b.WriteString("    </seq>\n  </body>\n</smil>\n")
+	return b.String()
+}
+
+func sentenceID(index int) string {
+	return fmt.Sprintf("cadence-s%05d", index+1)
+}
@@ -0,0 +1,81 @@
+package export
+
+import (
+	"strings"
+	"testing"
+
+	"cadence/internal/data"
+	"cadence/internal/ingest"
+	"cadence/internal/syncmap"
+)
+
+func TestEPUBChapter(t *testing.T) {
+	tests := []struct {
+		name	string
+		markup	string
+		text	string
+		want	[]string
+		reject	[]string
+	}{
+		{
+			name:	"source markup",
+			markup:	`<p id="intro" class="first">It was <em>cold</em>. The clocks struck.</p>`,
+			text:	"It was cold. The clocks struck.",
+			want:	[]string{`<p id="intro" class="first">`, `<span id="cadence-s00001">It was </span><em><span>cold</span></em><span>.</span>`, `<span id="cadence-s00002">The clocks struck.</span>`},
+		},
+		{
+			name:	"stripped note marker",
+			markup:	`<p>Winston slipped in.<a href="#n1" epub:type="noteref">1</a> He stopped.</p>`,
+			text:	"Winston slipped in. He stopped.",
+			want:	[]string{`<p id="cadence-p0001">`, `<span id="cadence-s00001">Winston slipped in.</span><a href="#n1" epub:type="noteref">1</a> <span id="cadence-s00002">He stopped.</span>`},
+		},
+		{
+			name:	"dropped media and foreign links",
+			markup:	`<p onclick="x()">See <img src="a.png"/><a href="other.xhtml#x">this</a>.</p>`,
+			text:	"See this.",
+			want:	[]string{`<p id="cadence-p0001"><span id="cadence-s00001">See </span><a><span>this</span></a><span>.</span></p>`},
+			reject:	[]string{"img", "onclick", "other.xhtml"},
+		},
+		{
+			name:	"list item rebuilt",
+			markup:	`<li id="item">First thing.</li>`,
+			text:	"First thing.",
+			want:	[]string{`<p id="item" class="list-item"><span id="cadence-s00001">First thing.</span></p>`},
+		},
+		{
+			name:	"unmatched text rebuilt",
+			markup:	`<p id="other">Entirely different words.</p>`,
+			text:	"Nothing in common?",
+			want:	[]string{`<p id="other"><span id="cadence-s00001">Nothing in common?</span></p>`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := ingest.ParseHTML([]byte("<html><body>" + tt.markup + "</body></html>"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			blocks := doc.Chapters[0].Blocks
+			if len(blocks) != 1 {
+				t.Fatalf("parsed %d blocks, want 1", len(blocks))
+			}
+			blocks[0].Text = tt.text
+
+			m := syncmap.Build(tt.text, []syncmap.Segment{{Start: 0, End: len(tt.text), Duration: 10}})
+			src := &Source{Book: &data.Book{Title: "Book"}}
+			got := epubChapter(src, &Chapter{BookChapter: &data.BookChapter{Title: "One"}, Sync: m, Blocks: blocks})
+
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("chapter is missing %s\n%s", want, got)
+				}
+			}
+			for _, reject := range tt.reject {
+				if strings.Contains(got, reject) {
+					t.Errorf("chapter contains %s\n%s", reject, got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package export
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"unicode"
+)
+
+func escape(s string) string {
+	return html.EscapeString(s)
+}
+
+// clock formats seconds as an SMIL full clock value.
+func clock(seconds float64) string {
+	ms := int64(seconds*1000 + 0.5)
+	return fmt.Sprintf("%d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+func validID(id string) bool {
+	if id == "" {
+		return false
+	}
+	for i, r := range id {
+		switch {
+		case unicode.IsLetter(r), r == '_':
+		case i > 0 && (unicode.IsDigit(r) || r == '-' || r == '.'):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func Filename(title, ext string) string {
+	name := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|`, r) || unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(title))
+	if name = strings.Trim(name, ". "); name == "" {
+		name = "book"
+	}
+	return name + ext
+}
+
+func chapterName(index int) string {
+	return fmt.Sprintf("chapter-%04d", index+1)
+}
@@ -0,0 +1,264 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"cadence/internal/data"
+)
+
+func NewDirSink(root string) (*DirSink, error) {
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+	return &DirSink{root: root}, nil
+}
+
+func (s *DirSink) Create(name string, modified time.Time) (io.Writer, error) {
+	if err := s.Close(); err != nil {
+		return nil, err
+	}
+
+	target := filepath.Join(s.root, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return nil, err
+	}
+	file, err := os.Create(target)
+	if err != nil {
+		return nil, err
+	}
+	s.file, s.modified = file, modified
+	return file, nil
+}
+
+func (s *DirSink) Close() error {
+	if s.file == nil {
+		return nil
+	}
+	file := s.file
+	s.file = nil
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Chtimes(file.Name(), s.modified, s.modified)
+}
+
+func NewZipSink(w io.Writer) *ZipSink {
+	return &ZipSink{zw: zip.NewWriter(w)}
+}
+
+func (s *ZipSink) Create(name string, modified time.Time) (io.Writer, error) {
+	method := zip.Deflate
+	if storedExtensions[strings.ToLower(path.Ext(name))] {
+		method = zip.Store
+	}
+	return s.zw.CreateHeader(&zip.FileHeader{Name: name, Method: method, Modified: modified})
+}
+
+func (s *ZipSink) Close() error {
+	return s.zw.Close()
+}
+
+// LibraryPath is the folder a book is exported to, laid out the way
+// Audiobookshelf and Plex expect: Author/Series/Title, or Author/Title for
+// books outside a series.
+func LibraryPath(book *data.Book) string {
+	author := book.Author
+	if author == "" {
+		author = unknownAuthor
+	}
+	parts := []string{Filename(author, "")}
+	if book.Series != "" {
+		parts = append(parts, Filename(book.Series, ""))
+	}
+	return path.Join(append(parts, Filename(book.Title, ""))...)
+}
+
+// WriteLibrary exports books with audio as folders of per-chapter MP3s
+// with ID3 tags, a metadata.json and metadata.opf sidecar and the cover.
+// Books without chapters are skipped. Two books mapping to the same folder
+// are told apart by ID.
+func (e *Exporter) WriteLibrary(ctx context.Context, sink Sink, books []data.Book) error {
+	used := make(map[string]bool)
+	for i := range books {
+		book := &books[i]
+		if len(book.Chapters) == 0 {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		dir := LibraryPath(book)
+		if used[dir] {
+			dir = fmt.Sprintf("%s (%d)", dir, book.ID)
+		}
+		used[dir] = true
+
+		if err := e.writeLibraryBook(ctx, sink, dir, book); err != nil {
+			return fmt.Errorf("book %d: %w", book.ID, err)
+		}
+	}
+	return nil
+}
+
+func (e *Exporter) writeLibraryBook(ctx context.Context, sink Sink, dir string, book *data.Book) error {
+	modified := book.UpdatedAt
+	metadata, err := json.MarshalIndent(libraryJSON(book), "", "  ")
+	if err != nil {
+		return err
+	}
+	files := []entry{
+		{"metadata.json", string(metadata) + "\n"},
+		{"metadata.opf", libraryOPF(book)},
+	}
+	for _, f := range files {
+		w, err := sink.Create(path.Join(dir, f.name), modified)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, f.content); err != nil {
+			return err
+		}
+	}
+
+	if ext, ok := coverExtensions[book.CoverType]; ok && book.HasCover {
+		w, err := sink.Create(path.Join(dir, "cover"+ext), modified)
+		if err != nil {
+			return err
+		}
+		if err := e.copyObject(ctx, w, book.CoverKey); err != nil {
+			return err
+		}
+	}
+
+	width := max(2, len(strconv.Itoa(len(book.Chapters))))
+	for i := range book.Chapters {
+		ch := &book.Chapters[i]
+		name := fmt.Sprintf("%0*d - %s", width, ch.Position+1, Filename(chapterTitle(ch), ".mp3"))
+		w, err := sink.Create(path.Join(dir, name), modified)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(trackTag(book, ch)); err != nil {
+			return err
+		}
+		if err := e.copyObject(ctx, w, ch.AudioKey); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func libraryJSON(book *data.Book) *libraryMetadata {
+	meta := &libraryMetadata{
+		Tags:			[]string{},
+		Title:			book.Title,
+		Authors:		[]string{},
+		Narrators:		[]string{},
+		Series:			[]string{},
+		Genres:			[]string{"Audiobook"},
+		Description:	book.Description,
+	}
+	if book.Author != "" {
+		meta.Authors = append(meta.Authors, book.Author)
+	}
+	if book.Narrator != "" {
+		meta.Narrators = append(meta.Narrators, book.Narrator)
+	}
+	if book.Series != "" {
+		series := book.Series
+		if book.SeriesIndex != nil {
+			series += fmt.Sprintf(" #%g", *book.SeriesIndex)
+		}
+		meta.Series = append(meta.Series, series)
+	}
+	if book.Language != "" {
+		meta.Language = &book.Language
+	}
+	for i := range book.Chapters {
+		ch := &book.Chapters[i]
+		meta.Chapters = append(meta.Chapters, libraryChapter{
+			ID:		i,
+			Start:	ch.Start,
+			End:	ch.Start + ch.Duration,
+			Title:	chapterTitle(ch),
+		})
+	}
+	return meta
+}
+
+// libraryOPF writes an OPF 2.0 metadata file with Calibre's series
+// properties, which both Audiobookshelf and Calibre-aware tools read.
+func libraryOPF(book *data.Book) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="2.0" unique-identifier="book-id">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:opf="http://www.idpf.org/2007/opf">
+`)
+	fmt.Fprintf(&b, "    <dc:identifier id=\"book-id\">cadence:book:%d</dc:identifier>\n", book.ID)
+	fmt.Fprintf(&b, "    <dc:title>%s</dc:title>\n", escape(book.Title))
+	if book.Author != "" {
+		fmt.Fprintf(&b, "    <dc:creator opf:role=\"aut\">%s</dc:creator>\n", escape(book.Author))
+	}
+	if book.Narrator != "" {
+		fmt.Fprintf(&b, "    <dc:contributor opf:role=\"nrt\">%s</dc:contributor>\n", escape(book.Narrator))
+	}
+	if book.Description != "" {
+		fmt.Fprintf(&b, "    <dc:description>%s</dc:description>\n", escape(book.Description))
+	}
+	if book.Language != "" {
+		fmt.Fprintf(&b, "    <dc:language>%s</dc:language>\n", escape(book.Language))
+	}
+	b.WriteString("    <dc:subject>Audiobook</dc:subject>\n")
+	if book.Series != "" {
+		fmt.Fprintf(&b, "    <meta name=\"calibre:series\" content=\"%s\"/>\n", escape(book.Series))
+		if book.SeriesIndex != nil {
+			fmt.Fprintf(&b, "    <meta name=\"calibre:series_index\" content=\"%g\"/>\n", *book.SeriesIndex)
+		}
+	}
+	b.WriteString("  </metadata>\n</package>\n")
+	return b.String()
+}
+
+// trackTag is the ID3v2.3 tag for one chapter file: the chapter as the
+// track title and the book as the album, so players group the files.
+func trackTag(book *data.Book, ch *data.BookChapter) []byte {
+	var frames bytes.Buffer
+	writeFrame(&frames, "TIT2", textFrame(chapterTitle(ch)))
+	writeFrame(&frames, "TALB", textFrame(book.Title))
+	if book.Author != "" {
+		writeFrame(&frames, "TPE1", textFrame(book.Author))
+		writeFrame(&frames, "TPE2", textFrame(book.Author))
+	}
+	if book.Narrator != "" {
+		writeFrame(&frames, "TCOM", textFrame(book.Narrator))
+	}
+	if book.Series != "" {
+		writeFrame(&frames, "TXXX", userTextFrame("SERIES", book.Series))
+		if book.SeriesIndex != nil {
+			writeFrame(&frames, "TXXX", userTextFrame("SERIES-PART", fmt.Sprintf("%g", *book.SeriesIndex)))
+		}
+	}
+	writeFrame(&frames, "TRCK", textFrame(fmt.Sprintf("%d/%d", ch.Position+1, len(book.Chapters))))
+	writeFrame(&frames, "TCON", textFrame("Audiobook"))
+	writeFrame(&frames, "TLEN", textFrame(fmt.Sprint(milliseconds(ch.Duration))))
+	return id3Tag(frames.Bytes())
+}
+
+func chapterTitle(ch *data.BookChapter) string {
+	if strings.TrimSpace(ch.Title) != "" {
+		return ch.Title
+	}
+	return fmt.Sprintf("Chapter %d", ch.Position+1)
+}
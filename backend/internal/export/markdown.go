@@ -0,0 +1,62 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"cadence/internal/data"
+)
+
+// WriteBookmarks renders a book's bookmarks and notes as Markdown, grouped
+// by chapter in the order given.
+func WriteBookmarks(w io.Writer, book *data.Book, bookmarks []data.Bookmark) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "# %s\n\n", markdownLine(book.Title))
+	if book.Author != "" {
+		fmt.Fprintf(bw, "*%s*\n\n", markdownLine(book.Author))
+	}
+	if len(bookmarks) == 0 {
+		bw.WriteString("No bookmarks.\n")
+	}
+
+	chapter := -1
+	for _, b := range bookmarks {
+		if b.Chapter != chapter && b.Chapter < len(book.Chapters) {
+			chapter = b.Chapter
+			fmt.Fprintf(bw, "## %s\n\n", markdownLine(chapterTitle(&book.Chapters[chapter])))
+		}
+
+		title := b.Title
+		if title == "" {
+			title = "Bookmark"
+			if b.Kind == data.BookmarkNote {
+				title = "Note"
+			}
+		}
+		fmt.Fprintf(bw, "### %s · %s\n\n", timestamp(b.Position), markdownLine(title))
+		if b.Quote != "" {
+			for _, line := range strings.Split(strings.TrimSpace(b.Quote), "\n") {
+				fmt.Fprintf(bw, "> %s\n", line)
+			}
+			bw.WriteString("\n")
+		}
+		if b.Note != "" {
+			fmt.Fprintf(bw, "%s\n\n", b.Note)
+		}
+	}
+	return bw.Flush()
+}
+
+// markdownLine flattens text onto one line and escapes it.
+func markdownLine(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	return markdownEscaper.Replace(s)
+}
+
+// timestamp formats a book position as h:mm:ss.
+func timestamp(seconds float64) string {
+	s := int64(seconds)
+	return fmt.Sprintf("%d:%02d:%02d", s/3600, s/60%60, s%60)
+}
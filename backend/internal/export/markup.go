@@ -0,0 +1,256 @@
+package export
+
+import (
+	"strings"
+	"unicode"
+
+	"cadence/internal/syncmap"
+
+	"golang.org/x/net/html"
+)
+
+// sourceBlock writes a copy of the element a paragraph was read from with
+// each of its sentences wrapped in a span. The synthesized text differs
+// from the element's in small ways, such as stripped footnote markers,
+// inlined notes and collapsed whitespace, so the two are matched on their
+// longest common subsequence. It writes nothing and reports false when a
+// sentence is not mostly found in the element.
+func sourceBlock(b *strings.Builder, n *html.Node, id string, text []rune, paragraph syncmap.Span, sentences []syncmap.Span, first int, used map[string]bool) bool {
+	if !portableElements[n.Data] {
+		return false
+	}
+	el := cloneElement(n)
+
+	var nodes []*html.Node
+	var source []textPosition
+	var visit func(*html.Node)
+	visit = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.TextNode {
+				for i, r := range []rune(c.Data) {
+					if !unicode.IsSpace(r) {
+						source = append(source, textPosition{node: len(nodes), offset: i, r: r})
+					}
+				}
+				nodes = append(nodes, c)
+			}
+			visit(c)
+		}
+	}
+	visit(el)
+
+	var target []textPosition
+	for i := paragraph.TextStart; i < paragraph.TextEnd; i++ {
+		if !unicode.IsSpace(text[i]) {
+			target = append(target, textPosition{offset: i, r: text[i]})
+		}
+	}
+	matches, ok := align(source, target)
+	if !ok {
+		return false
+	}
+
+	// Each sentence covers the source from its first to its last matched
+	// character, so text between sentences stays outside every span.
+	cuts := make([][]textCut, len(nodes))
+	t := 0
+	for k, sentence := range sentences {
+		from, to, count, matched := -1, -1, 0, 0
+		for ; t < len(target) && target[t].offset < sentence.TextEnd; t++ {
+			if target[t].offset < sentence.TextStart {
+				continue
+			}
+			count++
+			if matches[t] < 0 {
+				continue
+			}
+			if from < 0 {
+				from = matches[t]
+			}
+			to = matches[t]
+			matched++
+		}
+		if from < 0 || float64(matched) < minSentenceMatch*float64(count) {
+			return false
+		}
+
+		start, end := source[from], source[to]
+		for node := start.node; node <= end.node; node++ {
+			cut := textCut{start: 0, end: len([]rune(nodes[node].Data)), sentence: first + k}
+			if node == start.node {
+				cut.start = start.offset
+			}
+			if node == end.node {
+				cut.end = end.offset + 1
+			}
+			cuts[node] = append(cuts[node], cut)
+		}
+	}
+
+	if existing := attr(el, "id"); validID(existing) && !used[existing] && !strings.HasPrefix(existing, "cadence-") {
+		id = existing
+	}
+	setAttr(el, "id", id)
+	used[id] = true
+	claimIDs(el, used)
+
+	wrapped := make(map[int]bool)
+	for i, node := range nodes {
+		wrapSentences(node, cuts[i], wrapped)
+	}
+	return html.Render(b, el) == nil
+}
+
+// align pairs each target character with a source character, or -1, on
+// a longest common subsequence of the two.
+func align(source, target []textPosition) ([]int, bool) {
+	n, m := len(source), len(target)
+	if n == 0 || m == 0 || (n+1)*(m+1) > maxAlignCells {
+		return nil, false
+	}
+
+	// lcs[i*(m+1)+j] is the length of the longest common subsequence of
+	// source[i:] and target[j:]; it never exceeds min(n, m), which the cell
+	// limit keeps well inside 16 bits.
+	lcs := make([]uint16, (n+1)*(m+1))
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			switch {
+			case source[i].r == target[j].r:
+				lcs[i*(m+1)+j] = lcs[(i+1)*(m+1)+j+1] + 1
+			default:
+				lcs[i*(m+1)+j] = max(lcs[(i+1)*(m+1)+j], lcs[i*(m+1)+j+1])
+			}
+		}
+	}
+
+	matches := make([]int, m)
+	for j := range matches {
+		matches[j] = -1
+	}
+	for i, j := 0, 0; i < n && j < m; {
+		switch {
+		case source[i].r == target[j].r && lcs[i*(m+1)+j] == lcs[(i+1)*(m+1)+j+1]+1:
+			matches[j] = i
+			i++
+			j++
+		case lcs[(i+1)*(m+1)+j] >= lcs[i*(m+1)+j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return matches, true
+}
+
+// wrapSentences replaces a text node with its pieces, wrapping each cut in
+// a span. Only a sentence's first span carries its ID, since an overlay
+// points at a single element.
+func wrapSentences(node *html.Node, cuts []textCut, wrapped map[int]bool) {
+	if len(cuts) == 0 {
+		return
+	}
+
+	runes := []rune(node.Data)
+	parent := node.Parent
+	insert := func(n *html.Node) {
+		parent.InsertBefore(n, node)
+	}
+	pos := 0
+	for _, cut := range cuts {
+		if cut.start > pos {
+			insert(&html.Node{Type: html.TextNode, Data: string(runes[pos:cut.start])})
+		}
+		span := &html.Node{Type: html.ElementNode, Data: "span"}
+		if !wrapped[cut.sentence] {
+			span.Attr = []html.Attribute{{Key: "id", Val: sentenceID(cut.sentence)}}
+			wrapped[cut.sentence] = true
+		}
+		span.AppendChild(&html.Node{Type: html.TextNode, Data: string(runes[cut.start:cut.end])})
+		insert(span)
+		pos = cut.end
+	}
+	if pos < len(runes) {
+		insert(&html.Node{Type: html.TextNode, Data: string(runes[pos:])})
+	}
+	parent.RemoveChild(node)
+}
+
+// cloneElement deep-copies an element, leaving out what an exported
+// chapter cannot carry: embedded media, scripts, attributes from
+// namespaces the chapter does not declare and links to other files.
+func cloneElement(n *html.Node) *html.Node {
+	clone := &html.Node{Type: n.Type, DataAtom: n.DataAtom, Data: n.Data, Namespace: n.Namespace}
+	for _, a := range n.Attr {
+		key := strings.ToLower(a.Key)
+		switch {
+		case a.Namespace != "", strings.Contains(key, ":") && key != "epub:type" && key != "xml:lang":
+		case strings.HasPrefix(key, "on"), key == "src", key == "srcset":
+		case key == "href" && !strings.HasPrefix(a.Val, "#") && !externalLink(a.Val):
+		default:
+			clone.Attr = append(clone.Attr, a)
+		}
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		switch {
+		case c.Type == html.TextNode:
+			clone.AppendChild(&html.Node{Type: html.TextNode, Data: c.Data})
+		case c.Type == html.ElementNode && !droppedElements[c.Data]:
+			clone.AppendChild(cloneElement(c))
+		}
+	}
+	return clone
+}
+
+// claimIDs keeps the IDs of an element's descendants that are still free
+// in the chapter and drops the rest, since chapters can join documents.
+func claimIDs(n *html.Node, used map[string]bool) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode {
+			continue
+		}
+		if id := attr(c, "id"); id != "" {
+			if validID(id) && !used[id] && !strings.HasPrefix(id, "cadence-") {
+				used[id] = true
+			} else {
+				removeAttr(c, "id")
+			}
+		}
+		claimIDs(c, used)
+	}
+}
+
+func externalLink(href string) bool {
+	lower := strings.ToLower(href)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://") || strings.HasPrefix(lower, "mailto:")
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func setAttr(n *html.Node, key, value string) {
+	for i := range n.Attr {
+		if n.Attr[i].Key == key {
+			n.Attr[i].Val = value
+			return
+		}
+	}
+	n.Attr = append(n.Attr, html.Attribute{Key: key, Val: value})
+}
+
+func removeAttr(n *html.Node, key string) {
+	attrs := n.Attr[:0]
+	for _, a := range n.Attr {
+		if a.Key != key {
+			attrs = append(attrs, a)
+		}
+	}
+	n.Attr = attrs
+}
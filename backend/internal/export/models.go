@@ -0,0 +1,170 @@
+package export
+
+import (
+	"archive/zip"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"cadence/internal/data"
+	"cadence/internal/ingest"
+	"cadence/internal/storage"
+	"cadence/internal/syncmap"
+)
+
+const (
+	epubMimetype		= "application/epub+zip"
+	overlayActiveClass	= "-epub-media-overlay-active"
+
+	id3Version			= 3
+	id3HeaderSize		= 10
+	maxTOCEntries		= 255
+	apicFrontCover		= 3
+
+	unknownAuthor		= "Unknown Author"
+
+	// maxAlignCells bounds the work of matching a paragraph to its source
+	// element; longer paragraphs are rendered from the synthesized text.
+	maxAlignCells		= 4 << 20
+	// minSentenceMatch is the share of a sentence's characters that must be
+	// found in the source element for the element to be used.
+	minSentenceMatch	= 0.8
+)
+
+var ErrMissingSource = errors.New("book source is no longer available")
+
+// markdownEscaper escapes the characters Markdown reads as inline
+// formatting in titles.
+var markdownEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "_", `\_`, "`", "\\`", "[", `\[`, "]", `\]`, "#", `\#`, "<", `\<`)
+
+type Exporter struct {
+	repo			*data.Repository
+	store			storage.Storage
+	logger			*log.Logger
+}
+
+// Source is everything an export needs about a book: its chapters'
+// audio, the text each chapter was synthesized from and, when the
+// original document can still be parsed, the block structure of that text
+// and the markup it was read from.
+type Source struct {
+	Book			*data.Book
+	Document		*data.Document
+	Chapters		[]Chapter
+}
+
+type Chapter struct {
+	*data.BookChapter
+	Sync			*syncmap.Map
+	Blocks			[]ingest.Block
+}
+
+// Sink receives the files of a library export one at a time; each writer
+// is valid until the next call to Create or Close.
+type Sink interface {
+	Create(name string, modified time.Time) (io.Writer, error)
+	Close() error
+}
+
+type DirSink struct {
+	root			string
+	file			*os.File
+	modified		time.Time
+}
+
+type ZipSink struct {
+	zw				*zip.Writer
+}
+
+// libraryMetadata is the metadata.json sidecar Audiobookshelf reads from a
+// book folder.
+type libraryMetadata struct {
+	Tags			[]string			`json:"tags"`
+	Chapters		[]libraryChapter	`json:"chapters"`
+	Title			string				`json:"title"`
+	Subtitle		*string				`json:"subtitle"`
+	Authors			[]string			`json:"authors"`
+	Narrators		[]string			`json:"narrators"`
+	Series			[]string			`json:"series"`
+	Genres			[]string			`json:"genres"`
+	PublishedYear	*string				`json:"publishedYear"`
+	PublishedDate	*string				`json:"publishedDate"`
+	Publisher		*string				`json:"publisher"`
+	Description		string				`json:"description"`
+	ISBN			*string				`json:"isbn"`
+	ASIN			*string				`json:"asin"`
+	Language		*string				`json:"language"`
+	Explicit		bool				`json:"explicit"`
+	Abridged		bool				`json:"abridged"`
+}
+
+type libraryChapter struct {
+	ID				int					`json:"id"`
+	Start			float64				`json:"start"`
+	End				float64				`json:"end"`
+	Title			string				`json:"title"`
+}
+
+// textPosition is a non-space character of a paragraph, located by its
+// offset into the chapter text or into one of a source element's text
+// nodes.
+type textPosition struct {
+	node			int
+	offset			int
+	r				rune
+}
+
+// textCut is the part of a text node, in runes, that belongs to a sentence.
+type textCut struct {
+	start			int
+	end				int
+	sentence		int
+}
+
+type entry struct {
+	name			string
+	content			string
+}
+
+// storedExtensions are already compressed and go into zips uncompressed.
+var storedExtensions = map[string]bool{
+	".mp3":		true,
+	".jpg":		true,
+	".png":		true,
+	".gif":		true,
+	".webp":	true,
+}
+
+// portableElements can be copied from a source document into a chapter
+// as they are; list items and table cells need their container, so those
+// blocks are rendered from their text instead.
+var portableElements = map[string]bool{
+	"p": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"blockquote": true, "div": true, "pre": true, "address": true, "aside": true,
+	"section": true, "article": true, "header": true, "footer": true, "figcaption": true,
+}
+
+// droppedElements are left out of copied source elements: the export
+// carries no media but the audio, and nothing else is read aloud.
+var droppedElements = map[string]bool{
+	"img": true, "image": true, "svg": true, "math": true, "picture": true, "video": true,
+	"audio": true, "source": true, "iframe": true, "object": true, "embed": true,
+	"script": true, "style": true, "noscript": true, "template": true, "link": true,
+	"form": true, "input": true, "button": true, "select": true, "textarea": true,
+}
+
+var coverExtensions = map[string]string{
+	"image/jpeg":	".jpg",
+	"image/png":	".png",
+	"image/gif":	".gif",
+	"image/webp":	".webp",
+}
+
+const epubStylesheet = `body { margin: 0 5%; line-height: 1.5; }
+h1, h2, h3, h4, h5, h6 { text-align: center; }
+p.list-item { margin-left: 2em; }
+.` + overlayActiveClass + ` { background-color: #fff3b0; }
+`
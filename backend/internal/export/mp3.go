@@ -0,0 +1,223 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"unicode/utf16"
+
+	"cadence/internal/data"
+)
+
+// MP3Tag builds the ID3v2.3 tag that precedes the concatenated chapter
+// audio: book metadata, the cover as an APIC frame and one CHAP frame per
+// chapter, listed in order by CTOC frames so players offer chapter
+// navigation.
+func (e *Exporter) MP3Tag(ctx context.Context, book *data.Book) ([]byte, error) {
+	var frames bytes.Buffer
+	writeFrame(&frames, "TIT2", textFrame(book.Title))
+	writeFrame(&frames, "TALB", textFrame(book.Title))
+	if book.Author != "" {
+		writeFrame(&frames, "TPE1", textFrame(book.Author))
+		writeFrame(&frames, "TPE2", textFrame(book.Author))
+	}
+	if book.Narrator != "" {
+		writeFrame(&frames, "TCOM", textFrame(book.Narrator))
+		writeFrame(&frames, "TXXX", userTextFrame("NARRATOR", book.Narrator))
+	}
+	if book.Series != "" {
+		writeFrame(&frames, "TXXX", userTextFrame("SERIES", book.Series))
+		if book.SeriesIndex != nil {
+			writeFrame(&frames, "TXXX", userTextFrame("SERIES-PART", fmt.Sprintf("%g", *book.SeriesIndex)))
+		}
+	}
+	writeFrame(&frames, "TCON", textFrame("Audiobook"))
+	writeFrame(&frames, "TLEN", textFrame(fmt.Sprint(milliseconds(book.Duration))))
+	if book.Description != "" {
+		writeFrame(&frames, "COMM", commentFrame(book.Description))
+	}
+
+	if book.HasCover {
+		var cover bytes.Buffer
+		if err := e.copyObject(ctx, &cover, book.CoverKey); err != nil {
+			return nil, err
+		}
+		writeFrame(&frames, "APIC", pictureFrame(book.CoverType, cover.Bytes()))
+	}
+
+	// CHAP offsets are absolute, so the tag's own size is needed before
+	// the chapter frames can be written. Every CHAP and CTOC frame has a
+	// fixed size given its title, which lets it be computed up front.
+	chapterFrames := chapterTOC(book, 0)
+	offset := uint32(id3HeaderSize + frames.Len() + len(chapterFrames))
+	frames.Write(chapterTOC(book, offset))
+
+	return id3Tag(frames.Bytes()), nil
+}
+
+// MP3Size is the length of the file WriteMP3 produces for tag.
+func MP3Size(book *data.Book, tag []byte) int64 {
+	size := int64(len(tag))
+	for _, ch := range book.Chapters {
+		size += ch.AudioSize
+	}
+	return size
+}
+
+func (e *Exporter) WriteMP3(ctx context.Context, w io.Writer, book *data.Book, tag []byte) error {
+	if _, err := w.Write(tag); err != nil {
+		return err
+	}
+	for _, ch := range book.Chapters {
+		if err := e.copyObject(ctx, w, ch.AudioKey); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func chapterTOC(book *data.Book, offset uint32) []byte {
+	var frames bytes.Buffer
+	ids := make([]string, len(book.Chapters))
+	for i, ch := range book.Chapters {
+		ids[i] = fmt.Sprintf("chp%d", i)
+
+		var body bytes.Buffer
+		body.WriteString(ids[i])
+		body.WriteByte(0)
+		binary.Write(&body, binary.BigEndian, [4]uint32{
+			milliseconds(ch.Start),
+			milliseconds(ch.Start + ch.Duration),
+			offset,
+			offset + uint32(ch.AudioSize),
+		})
+		writeFrame(&body, "TIT2", textFrame(ch.Title))
+		writeFrame(&frames, "CHAP", body.Bytes())
+		offset += uint32(ch.AudioSize)
+	}
+
+	// A CTOC lists at most 255 children, so longer books get a second
+	// level of tables under the top-level one.
+	if len(ids) <= maxTOCEntries {
+		writeFrame(&frames, "CTOC", tocFrame("toc", true, ids, book.Title))
+		return frames.Bytes()
+	}
+	var tables []string
+	for start := 0; start < len(ids); start += maxTOCEntries {
+		id := fmt.Sprintf("toc%d", len(tables))
+		end := min(start+maxTOCEntries, len(ids))
+		writeFrame(&frames, "CTOC", tocFrame(id, false, ids[start:end], fmt.Sprintf("Chapters %d-%d", start+1, end)))
+		tables = append(tables, id)
+	}
+	writeFrame(&frames, "CTOC", tocFrame("toc", true, tables, book.Title))
+	return frames.Bytes()
+}
+
+func tocFrame(id string, topLevel bool, children []string, title string) []byte {
+	var body bytes.Buffer
+	body.WriteString(id)
+	body.WriteByte(0)
+	flags := byte(0x01)
+	if topLevel {
+		flags |= 0x02
+	}
+	body.WriteByte(flags)
+	body.WriteByte(byte(len(children)))
+	for _, child := range children {
+		body.WriteString(child)
+		body.WriteByte(0)
+	}
+	writeFrame(&body, "TIT2", textFrame(title))
+	return body.Bytes()
+}
+
+func id3Tag(frames []byte) []byte {
+	header := make([]byte, id3HeaderSize, id3HeaderSize+len(frames))
+	copy(header, "ID3")
+	header[3], header[4] = id3Version, 0
+	putSyncsafe(header[6:], uint32(len(frames)))
+	return append(header, frames...)
+}
+
+func writeFrame(w *bytes.Buffer, id string, body []byte) {
+	w.WriteString(id)
+	binary.Write(w, binary.BigEndian, uint32(len(body)))
+	w.Write([]byte{0, 0})
+	w.Write(body)
+}
+
+func textFrame(value string) []byte {
+	enc, text := encodeText(value)
+	return append([]byte{enc}, text...)
+}
+
+// userTextFrame encodes description and value alike, so the encoding has
+// to fit both.
+func userTextFrame(description, value string) []byte {
+	enc, _ := encodeText(description + value)
+	_, desc := encodeTextAs(enc, description)
+	_, text := encodeTextAs(enc, value)
+	body := append([]byte{enc}, desc...)
+	body = append(body, terminator(enc)...)
+	return append(body, text...)
+}
+
+func commentFrame(value string) []byte {
+	enc, text := encodeText(value)
+	body := append([]byte{enc}, "eng"...)
+	body = append(body, terminator(enc)...)
+	return append(body, text...)
+}
+
+func pictureFrame(contentType string, image []byte) []byte {
+	body := append([]byte{0}, contentType...)
+	body = append(body, 0, apicFrontCover, 0)
+	return append(body, image...)
+}
+
+// encodeText uses ISO-8859-1 when the value fits and UTF-16 with a byte
+// order mark otherwise, the two encodings every ID3v2.3 reader supports.
+func encodeText(value string) (byte, []byte) {
+	for _, r := range value {
+		if r > 0xff {
+			return encodeTextAs(1, value)
+		}
+	}
+	return encodeTextAs(0, value)
+}
+
+func encodeTextAs(enc byte, value string) (byte, []byte) {
+	if enc == 0 {
+		text := make([]byte, 0, len(value))
+		for _, r := range value {
+			text = append(text, byte(r))
+		}
+		return 0, text
+	}
+
+	text := []byte{0xff, 0xfe}
+	for _, u := range utf16.Encode([]rune(value)) {
+		text = binary.LittleEndian.AppendUint16(text, u)
+	}
+	return 1, text
+}
+
+func terminator(enc byte) []byte {
+	if enc == 1 {
+		return []byte{0, 0}
+	}
+	return []byte{0}
+}
+
+func putSyncsafe(b []byte, n uint32) {
+	for i := 3; i >= 0; i-- {
+		b[i] = byte(n & 0x7f)
+		n >>= 7
+	}
+}
+
+func milliseconds(seconds float64) uint32 {
+	return uint32(seconds*1000 + 0.5)
+}
@@ -0,0 +1,95 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"testing"
+
+	"cadence/internal/data"
+)
+
+func TestID3Frames(t *testing.T) {
+	bom := []byte{0xff, 0xfe}
+
+	tests := []struct {
+		name	string
+		got		[]byte
+		want	[]byte
+	}{
+		{"latin1 text", textFrame("Café"), []byte("\x00Caf\xe9")},
+		{"utf16 text", textFrame("猫"), append([]byte{1}, append(bom, 0x2b, 0x73)...)},
+		{"latin1 user text", userTextFrame("Narrator", "Ann"), []byte("\x00Narrator\x00Ann")},
+		{"utf16 value", userTextFrame("N", "猫"), []byte{1, 0xff, 0xfe, 'N', 0, 0, 0, 0xff, 0xfe, 0x2b, 0x73}},
+		{"utf16 description", userTextFrame("猫", "A"), []byte{1, 0xff, 0xfe, 0x2b, 0x73, 0, 0, 0xff, 0xfe, 'A', 0}},
+		{"comment", commentFrame("Hi"), []byte("\x00eng\x00Hi")},
+		{"picture", pictureFrame("image/png", []byte{1, 2}), []byte("\x00image/png\x00\x03\x00\x01\x02")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !bytes.Equal(tt.got, tt.want) {
+				t.Errorf("frame = % x, want % x", tt.got, tt.want)
+			}
+		})
+	}
+}
+
+func TestID3Header(t *testing.T) {
+	tests := []struct {
+		size	int
+		want	[4]byte
+	}{
+		{0, [4]byte{0, 0, 0, 0}},
+		{127, [4]byte{0, 0, 0, 0x7f}},
+		{128, [4]byte{0, 0, 1, 0}},
+		{1 << 14, [4]byte{0, 1, 0, 0}},
+		{1 << 21, [4]byte{1, 0, 0, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.size), func(t *testing.T) {
+			tag := id3Tag(make([]byte, tt.size))
+			if string(tag[:3]) != "ID3" || tag[3] != id3Version {
+				t.Fatalf("header = % x, want ID3v2.%d", tag[:id3HeaderSize], id3Version)
+			}
+			if got := [4]byte(tag[6:10]); got != tt.want {
+				t.Errorf("size = % x, want % x", got, tt.want)
+			}
+			if len(tag) != id3HeaderSize+tt.size {
+				t.Errorf("len = %d, want %d", len(tag), id3HeaderSize+tt.size)
+			}
+		})
+	}
+}
+
+func TestChapterTOC(t *testing.T) {
+	tests := []struct {
+		chapters	int
+		tables		int
+	}{
+		{1, 1},
+		{maxTOCEntries, 1},
+		{maxTOCEntries + 1, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.chapters), func(t *testing.T) {
+			book := &data.Book{Title: "Book"}
+			for i := 0; i < tt.chapters; i++ {
+				book.Chapters = append(book.Chapters, data.BookChapter{Start: float64(i), Duration: 1, AudioSize: 100})
+			}
+
+			counts := map[string]int{}
+			frames := chapterTOC(book, 10)
+			for len(frames) >= 10 {
+				size := binary.BigEndian.Uint32(frames[4:8])
+				counts[string(frames[:4])]++
+				frames = frames[10+size:]
+			}
+			if counts["CHAP"] != tt.chapters || counts["CTOC"] != tt.tables {
+				t.Errorf("frames = %v, want %d CHAP and %d CTOC", counts, tt.chapters, tt.tables)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package export
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+
+	"cadence/internal/data"
+	"cadence/internal/ingest"
+	"cadence/internal/preprocess"
+	"cadence/internal/storage"
+	"cadence/internal/syncmap"
+)
+
+func New(repo *data.Repository, store storage.Storage, logger *log.Logger) *Exporter {
+	return &Exporter{repo: repo, store: store, logger: logger}
+}
+
+func (e *Exporter) Load(ctx context.Context, book *data.Book) (*Source, error) {
+	doc, err := e.repo.DocumentByID(book.DocumentID)
+	if err != nil {
+		if errors.Is(err, data.ErrNotFound) {
+			return nil, ErrMissingSource
+		}
+		return nil, err
+	}
+
+	policy, _ := preprocess.ParseFootnotePolicy("")
+	if job, err := e.repo.JobByID(book.JobID); err == nil {
+		policy = preprocess.FootnotePolicy(job.FootnotePolicy)
+	}
+
+	chapters := make(map[uint]*data.Chapter, len(doc.Chapters))
+	for i := range doc.Chapters {
+		chapters[doc.Chapters[i].ID] = &doc.Chapters[i]
+	}
+	parsed := e.parse(ctx, doc, policy)
+
+	src := &Source{Book: book, Document: doc}
+	for i := range book.Chapters {
+		bc := &book.Chapters[i]
+		chapter, ok := chapters[bc.ChapterID]
+		if !ok {
+			return nil, ErrMissingSource
+		}
+
+		m, err := e.syncMap(ctx, bc, chapter, policy)
+		if err != nil {
+			return nil, err
+		}
+
+		ch := Chapter{BookChapter: bc, Sync: m}
+		if parsed != nil {
+			blocks := parsed.Chapters[chapter.Position].Blocks
+			if len(blocks) == len(m.Paragraphs) {
+				ch.Blocks = blocks
+			}
+		}
+		src.Chapters = append(src.Chapters, ch)
+	}
+	return src, nil
+}
+
+// parse re-reads the original document for its block structure. Exports
+// fall back to plain paragraphs when it no longer parses the same way.
+func (e *Exporter) parse(ctx context.Context, doc *data.Document, policy preprocess.FootnotePolicy) *ingest.Document {
+	r, err := e.store.Get(ctx, doc.StorageKey)
+	if err != nil {
+		e.logger.Printf("document %d: original unavailable for export: %v", doc.ID, err)
+		return nil
+	}
+	content, err := io.ReadAll(r)
+	r.Close()
+	if err != nil {
+		e.logger.Printf("document %d: original unreadable for export: %v", doc.ID, err)
+		return nil
+	}
+
+	parsed, err := ingest.Parse(doc.Filename, content)
+	if err != nil || len(parsed.Chapters) != len(doc.Chapters) {
+		return nil
+	}
+	parsed.ApplyFootnotePolicy(policy)
+	return parsed
+}
+
+// syncMap loads the chapter's stored sync map. Chapters generated before
+// sync maps were recorded get one interpolated from the chapter text.
+func (e *Exporter) syncMap(ctx context.Context, bc *data.BookChapter, chapter *data.Chapter, policy preprocess.FootnotePolicy) (*syncmap.Map, error) {
+	if bc.SyncKey != "" {
+		r, err := e.store.Get(ctx, bc.SyncKey)
+		if err == nil {
+			defer r.Close()
+			return syncmap.Decode(r)
+		}
+		if !errors.Is(err, storage.ErrNotFound) {
+			return nil, err
+		}
+	}
+
+	text := preprocess.PrepareText(chapter.Text, chapter.Notes, policy)
+	return syncmap.Build(text, []syncmap.Segment{{Start: 0, End: len(text), Duration: bc.Duration}}), nil
+}
+
+func (e *Exporter) copyObject(ctx context.Context, w io.Writer, key string) error {
+	r, err := e.store.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	_, err = io.Copy(w, r)
+	return err
+}
@@ -0,0 +1,253 @@
+package feeds
+
+import (
+	"encoding/xml"
+	"time"
+
+	"cadence/internal/data"
+)
+
+const (
+	itunesNamespace		= "http://www.itunes.com/dtds/podcast-1.0.dtd"
+	atomNamespace		= "http://www.w3.org/2005/Atom"
+	opdsNamespace		= "http://opds-spec.org/2010/catalog"
+	dcNamespace			= "http://purl.org/dc/terms/"
+	openSearchNamespace	= "http://a9.com/-/spec/opensearch/1.1/"
+	threadNamespace		= "http://purl.org/syndication/thread/1.0"
+	generator			= "Cadence"
+)
+
+const (
+	TypeNavigation		= "application/atom+xml;profile=opds-catalog;kind=navigation"
+	TypeAcquisition		= "application/atom+xml;profile=opds-catalog;kind=acquisition"
+	TypeOpenSearch		= "application/opensearchdescription+xml"
+	TypeOPDS2			= "application/opds+json"
+	TypeOPDS2Publication	= "application/opds-publication+json"
+
+	RelAcquisition		= "http://opds-spec.org/acquisition"
+	RelImage			= "http://opds-spec.org/image"
+	RelThumbnail		= "http://opds-spec.org/image/thumbnail"
+	RelSortNew			= "http://opds-spec.org/sort/new"
+)
+
+// Links produces the absolute URLs a feed refers to. Audio and cover links
+// are expected to be signed, since podcast clients fetch them without the
+// user's credentials.
+type Links interface {
+	Self() string
+	Site() string
+	Cover(book *data.Book) string
+	Audio(book *data.Book, chapter *data.BookChapter) string
+}
+
+type RSS struct {
+	XMLName			xml.Name	`xml:"rss"`
+	Version			string		`xml:"version,attr"`
+	ITunes			string		`xml:"xmlns:itunes,attr"`
+	Atom			string		`xml:"xmlns:atom,attr"`
+	Channel			Channel		`xml:"channel"`
+}
+
+type Channel struct {
+	Title			string		`xml:"title"`
+	Link			string		`xml:"link"`
+	Description		string		`xml:"description"`
+	Language		string		`xml:"language,omitempty"`
+	LastBuildDate	string		`xml:"lastBuildDate"`
+	Generator		string		`xml:"generator"`
+	Self			AtomLink	`xml:"atom:link"`
+	Image			*Image		`xml:"image,omitempty"`
+	Author			string		`xml:"itunes:author,omitempty"`
+	ITunesImage		*ITunesImage	`xml:"itunes:image,omitempty"`
+	Category		Category	`xml:"itunes:category"`
+	Explicit		string		`xml:"itunes:explicit"`
+	Type			string		`xml:"itunes:type"`
+	Block			string		`xml:"itunes:block"`
+	Items			[]Item		`xml:"item"`
+}
+
+type AtomLink struct {
+	Href			string		`xml:"href,attr"`
+	Rel				string		`xml:"rel,attr"`
+	Type			string		`xml:"type,attr"`
+}
+
+type Image struct {
+	URL				string		`xml:"url"`
+	Title			string		`xml:"title"`
+	Link			string		`xml:"link"`
+}
+
+type ITunesImage struct {
+	Href			string		`xml:"href,attr"`
+}
+
+type Category struct {
+	Text			string		`xml:"text,attr"`
+}
+
+type Item struct {
+	Title			string		`xml:"title"`
+	GUID			GUID		`xml:"guid"`
+	PubDate			string		`xml:"pubDate"`
+	Description		string		`xml:"description"`
+	Enclosure		Enclosure	`xml:"enclosure"`
+	Duration		int			`xml:"itunes:duration"`
+	Author			string		`xml:"itunes:author,omitempty"`
+	Episode			int			`xml:"itunes:episode,omitempty"`
+	EpisodeType		string		`xml:"itunes:episodeType"`
+	ITunesImage		*ITunesImage	`xml:"itunes:image,omitempty"`
+}
+
+type GUID struct {
+	IsPermaLink		bool		`xml:"isPermaLink,attr"`
+	Value			string		`xml:",chardata"`
+}
+
+type Enclosure struct {
+	URL				string		`xml:"url,attr"`
+	Length			int64		`xml:"length,attr"`
+	Type			string		`xml:"type,attr"`
+}
+
+// Catalog is one page of an OPDS catalog, independent of the version it
+// is rendered as. Link types are given for OPDS 1.2; Atom renders them
+// as is and OPDS 2.0 maps catalog types to their JSON equivalent.
+type Catalog struct {
+	ID				string
+	Title			string
+	Updated			time.Time
+	Links			[]Link
+	Navigation		[]Link
+	Publications	[]Publication
+	Total			int
+	Page			int
+	PageSize		int
+}
+
+type Link struct {
+	Rel				string		`xml:"rel,attr,omitempty" json:"rel,omitempty"`
+	Href			string		`xml:"href,attr" json:"href"`
+	Type			string		`xml:"type,attr,omitempty" json:"type,omitempty"`
+	Title			string		`xml:"title,attr,omitempty" json:"title,omitempty"`
+	Count			int			`xml:"thr:count,attr,omitempty" json:"-"`
+	Templated		bool		`xml:"-" json:"templated,omitempty"`
+}
+
+type Publication struct {
+	Book			*data.Book
+	Links			[]Link
+}
+
+type AtomFeed struct {
+	XMLName			xml.Name	`xml:"feed"`
+	Xmlns			string		`xml:"xmlns,attr"`
+	OPDS			string		`xml:"xmlns:opds,attr"`
+	DC				string		`xml:"xmlns:dc,attr"`
+	OpenSearch		string		`xml:"xmlns:opensearch,attr"`
+	Thread			string		`xml:"xmlns:thr,attr"`
+	ID				string		`xml:"id"`
+	Title			string		`xml:"title"`
+	Updated			string		`xml:"updated"`
+	Author			AtomPerson	`xml:"author"`
+	TotalResults	int			`xml:"opensearch:totalResults,omitempty"`
+	ItemsPerPage	int			`xml:"opensearch:itemsPerPage,omitempty"`
+	StartIndex		int			`xml:"opensearch:startIndex,omitempty"`
+	Links			[]Link		`xml:"link"`
+	Entries			[]AtomEntry	`xml:"entry"`
+}
+
+type AtomPerson struct {
+	Name			string		`xml:"name"`
+}
+
+type AtomEntry struct {
+	ID				string		`xml:"id"`
+	Title			string		`xml:"title"`
+	Updated			string		`xml:"updated"`
+	Authors			[]AtomPerson	`xml:"author,omitempty"`
+	Language		string		`xml:"dc:language,omitempty"`
+	Issued			string		`xml:"dc:issued,omitempty"`
+	Categories		[]AtomCategory	`xml:"category,omitempty"`
+	Summary			string		`xml:"summary,omitempty"`
+	Content			*AtomContent	`xml:"content,omitempty"`
+	Links			[]Link		`xml:"link"`
+}
+
+type AtomCategory struct {
+	Term			string		`xml:"term,attr"`
+	Label			string		`xml:"label,attr,omitempty"`
+}
+
+type AtomContent struct {
+	Type			string		`xml:"type,attr"`
+	Value			string		`xml:",chardata"`
+}
+
+type OpenSearchDescription struct {
+	XMLName			xml.Name	`xml:"OpenSearchDescription"`
+	Xmlns			string		`xml:"xmlns,attr"`
+	ShortName		string		`xml:"ShortName"`
+	Description		string		`xml:"Description"`
+	InputEncoding	string		`xml:"InputEncoding"`
+	OutputEncoding	string		`xml:"OutputEncoding"`
+	URL				OpenSearchURL	`xml:"Url"`
+}
+
+type OpenSearchURL struct {
+	Type			string		`xml:"type,attr"`
+	Template		string		`xml:"template,attr"`
+}
+
+type OPDS2Feed struct {
+	Metadata		OPDS2Metadata		`json:"metadata"`
+	Links			[]Link				`json:"links"`
+	Navigation		[]OPDS2Link			`json:"navigation,omitempty"`
+	Publications	[]OPDS2Publication	`json:"publications,omitempty"`
+}
+
+type OPDS2Metadata struct {
+	Title			string		`json:"title"`
+	Modified		string		`json:"modified,omitempty"`
+	NumberOfItems	int			`json:"numberOfItems,omitempty"`
+	ItemsPerPage	int			`json:"itemsPerPage,omitempty"`
+	CurrentPage		int			`json:"currentPage,omitempty"`
+}
+
+type OPDS2Link struct {
+	Link
+	Properties		*OPDS2Properties	`json:"properties,omitempty"`
+}
+
+type OPDS2Properties struct {
+	NumberOfItems	int			`json:"numberOfItems,omitempty"`
+}
+
+type OPDS2Publication struct {
+	Metadata		OPDS2PublicationMetadata	`json:"metadata"`
+	Links			[]Link						`json:"links"`
+	Images			[]Link						`json:"images,omitempty"`
+}
+
+type OPDS2PublicationMetadata struct {
+	Type			string		`json:"@type"`
+	Identifier		string		`json:"identifier"`
+	Title			string		`json:"title"`
+	Author			string		`json:"author,omitempty"`
+	Narrator		string		`json:"narrator,omitempty"`
+	Language		string		`json:"language,omitempty"`
+	Description		string		`json:"description,omitempty"`
+	Modified		string		`json:"modified"`
+	Published		string		`json:"published,omitempty"`
+	Duration		float64		`json:"duration,omitempty"`
+	BelongsTo		*OPDS2BelongsTo	`json:"belongsTo,omitempty"`
+}
+
+type OPDS2BelongsTo struct {
+	Series			[]OPDS2Series	`json:"series"`
+}
+
+type OPDS2Series struct {
+	Name			string		`json:"name"`
+	Position		*float64	`json:"position,omitempty"`
+}
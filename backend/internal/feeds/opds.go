@@ -0,0 +1,199 @@
+package feeds
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"cadence/internal/data"
+)
+
+func BookID(book *data.Book) string {
+	return fmt.Sprintf("urn:cadence:book:%d", book.ID)
+}
+
+// Atom renders the catalog as an OPDS 1.2 Atom feed. Navigation links
+// become entries pointing at their subsection.
+func (c *Catalog) Atom() *AtomFeed {
+	feed := &AtomFeed{
+		Xmlns:		atomNamespace,
+		OPDS:		opdsNamespace,
+		DC:			dcNamespace,
+		OpenSearch:	openSearchNamespace,
+		Thread:		threadNamespace,
+		ID:			c.ID,
+		Title:		c.Title,
+		Updated:	c.Updated.UTC().Format(time.RFC3339),
+		Author:		AtomPerson{Name: generator},
+		Links:		c.Links,
+	}
+	if c.PageSize > 0 {
+		feed.TotalResults = c.Total
+		feed.ItemsPerPage = c.PageSize
+		feed.StartIndex = (c.Page-1)*c.PageSize + 1
+	}
+
+	for _, nav := range c.Navigation {
+		feed.Entries = append(feed.Entries, AtomEntry{
+			ID:			c.ID + ":" + url.QueryEscape(nav.Title),
+			Title:		nav.Title,
+			Updated:	feed.Updated,
+			Content:	navigationContent(nav),
+			Links:		[]Link{nav},
+		})
+	}
+
+	for _, p := range c.Publications {
+		book := p.Book
+		entry := AtomEntry{
+			ID:			BookID(book),
+			Title:		book.Title,
+			Updated:	book.UpdatedAt.UTC().Format(time.RFC3339),
+			Language:	book.Language,
+			Issued:		book.CreatedAt.UTC().Format("2006-01-02"),
+			Summary:	book.Description,
+			Links:		p.Links,
+		}
+		if book.Author != "" {
+			entry.Authors = append(entry.Authors, AtomPerson{Name: book.Author})
+		}
+		if book.Series != "" {
+			label := book.Series
+			if book.SeriesIndex != nil {
+				label = fmt.Sprintf("%s #%g", book.Series, *book.SeriesIndex)
+			}
+			entry.Categories = append(entry.Categories, AtomCategory{Term: book.Series, Label: label})
+		}
+		if book.Narrator != "" {
+			entry.Content = &AtomContent{Type: "text", Value: fmt.Sprintf("Narrated by %s. %s", book.Narrator, formatDuration(book.Duration))}
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+	return feed
+}
+
+// OPDS2 renders the catalog as an OPDS 2.0 JSON feed.
+func (c *Catalog) OPDS2() *OPDS2Feed {
+	feed := &OPDS2Feed{
+		Metadata:	OPDS2Metadata{Title: c.Title, Modified: c.Updated.UTC().Format(time.RFC3339)},
+		Links:		make([]Link, 0, len(c.Links)),
+	}
+	if c.PageSize > 0 {
+		feed.Metadata.NumberOfItems = c.Total
+		feed.Metadata.ItemsPerPage = c.PageSize
+		feed.Metadata.CurrentPage = c.Page
+	}
+
+	for _, link := range c.Links {
+		if link.Type == TypeOpenSearch {
+			continue
+		}
+		feed.Links = append(feed.Links, opds2Link(link))
+	}
+	for _, nav := range c.Navigation {
+		link := OPDS2Link{Link: opds2Link(nav)}
+		if nav.Count > 0 {
+			link.Properties = &OPDS2Properties{NumberOfItems: nav.Count}
+		}
+		feed.Navigation = append(feed.Navigation, link)
+	}
+
+	for _, p := range c.Publications {
+		book := p.Book
+		pub := OPDS2Publication{
+			Metadata: OPDS2PublicationMetadata{
+				Type:			"http://schema.org/Audiobook",
+				Identifier:		BookID(book),
+				Title:			book.Title,
+				Author:			book.Author,
+				Narrator:		book.Narrator,
+				Language:		book.Language,
+				Description:	book.Description,
+				Modified:		book.UpdatedAt.UTC().Format(time.RFC3339),
+				Published:		book.CreatedAt.UTC().Format("2006-01-02"),
+				Duration:		book.Duration,
+			},
+			Links:	[]Link{},
+		}
+		if book.Series != "" {
+			pub.Metadata.BelongsTo = &OPDS2BelongsTo{Series: []OPDS2Series{{Name: book.Series, Position: book.SeriesIndex}}}
+		}
+		for _, link := range p.Links {
+			switch link.Rel {
+			case RelImage, RelThumbnail:
+				pub.Images = append(pub.Images, Link{Href: link.Href, Type: link.Type})
+			default:
+				pub.Links = append(pub.Links, opds2Link(link))
+			}
+		}
+		feed.Publications = append(feed.Publications, pub)
+	}
+	return feed
+}
+
+func (f *AtomFeed) Encode(w io.Writer) error {
+	return encodeXML(w, f)
+}
+
+func (f *OPDS2Feed) Encode(w io.Writer) error {
+	return json.NewEncoder(w).Encode(f)
+}
+
+func OpenSearch(title, template string) *OpenSearchDescription {
+	return &OpenSearchDescription{
+		Xmlns:			"http://a9.com/-/spec/opensearch/1.1/",
+		ShortName:		"Cadence",
+		Description:	title,
+		InputEncoding:	"UTF-8",
+		OutputEncoding:	"UTF-8",
+		URL:			OpenSearchURL{Type: TypeAcquisition, Template: template},
+	}
+}
+
+func (d *OpenSearchDescription) Encode(w io.Writer) error {
+	return encodeXML(w, d)
+}
+
+func encodeXML(w io.Writer, v any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func opds2Link(link Link) Link {
+	link.Count = 0
+	switch {
+	case strings.HasPrefix(link.Type, "application/atom+xml;profile=opds-catalog"):
+		link.Type = TypeOPDS2
+	case link.Type == "application/atom+xml;type=entry;profile=opds-catalog":
+		link.Type = TypeOPDS2Publication
+	}
+	return link
+}
+
+func navigationContent(nav Link) *AtomContent {
+	if nav.Count == 0 {
+		return nil
+	}
+	noun := "books"
+	if nav.Count == 1 {
+		noun = "book"
+	}
+	return &AtomContent{Type: "text", Value: fmt.Sprintf("%d %s", nav.Count, noun)}
+}
+
+func formatDuration(seconds float64) string {
+	d := time.Duration(seconds) * time.Second
+	return fmt.Sprintf("%dh %02dm", int(d.Hours()), int(d.Minutes())%60)
+}
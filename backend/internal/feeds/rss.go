@@ -0,0 +1,81 @@
+package feeds
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"cadence/internal/data"
+)
+
+// Podcast builds an RSS 2.0 feed with one episode per chapter. A single
+// book is presented as a serial show; a library feed lists every book's
+// chapters with the book's own artwork on each episode.
+func Podcast(title, description string, books []data.Book, links Links, now time.Time) *RSS {
+	channel := Channel{
+		Title:			title,
+		Link:			links.Site(),
+		Description:	description,
+		LastBuildDate:	now.UTC().Format(time.RFC1123Z),
+		Generator:		generator,
+		Self:			AtomLink{Href: links.Self(), Rel: "self", Type: "application/rss+xml"},
+		Category:		Category{Text: "Arts"},
+		Explicit:		"false",
+		Type:			"episodic",
+		Block:			"Yes",
+	}
+
+	single := len(books) == 1
+	if single {
+		book := &books[0]
+		channel.Author = book.Author
+		channel.Language = book.Language
+		channel.Type = "serial"
+		if book.HasCover {
+			cover := links.Cover(book)
+			channel.Image = &Image{URL: cover, Title: title, Link: links.Site()}
+			channel.ITunesImage = &ITunesImage{Href: cover}
+		}
+	}
+
+	for i := range books {
+		book := &books[i]
+		var image *ITunesImage
+		if !single && book.HasCover {
+			image = &ITunesImage{Href: links.Cover(book)}
+		}
+
+		for j := range book.Chapters {
+			chapter := &book.Chapters[j]
+			item := Item{
+				Title:			chapter.Title,
+				GUID:			GUID{Value: fmt.Sprintf("cadence:book:%d:job:%d:chapter:%d", book.ID, book.JobID, chapter.Position)},
+				PubDate:		episodeDate(book, chapter).Format(time.RFC1123Z),
+				Description:	fmt.Sprintf("%s, chapter %d of %d.", book.Title, chapter.Position+1, len(book.Chapters)),
+				Enclosure:		Enclosure{URL: links.Audio(book, chapter), Length: chapter.AudioSize, Type: "audio/mpeg"},
+				Duration:		int(chapter.Duration + 0.5),
+				Author:			book.Author,
+				EpisodeType:	"full",
+				ITunesImage:	image,
+			}
+			if single {
+				item.Episode = chapter.Position + 1
+			} else {
+				item.Title = fmt.Sprintf("%s: %s", book.Title, chapter.Title)
+			}
+			channel.Items = append(channel.Items, item)
+		}
+	}
+
+	return &RSS{Version: "2.0", ITunes: itunesNamespace, Atom: atomNamespace, Channel: channel}
+}
+
+func (r *RSS) Encode(w io.Writer) error {
+	return encodeXML(w, r)
+}
+
+// episodeDate staggers chapters a minute apart from the book's creation so
+// clients that sort by date keep them in reading order.
+func episodeDate(book *data.Book, chapter *data.BookChapter) time.Time {
+	return book.CreatedAt.UTC().Truncate(time.Second).Add(time.Duration(chapter.Position) * time.Minute)
+}
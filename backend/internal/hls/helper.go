@@ -0,0 +1,188 @@
+package hls
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+
+	"cadence/internal/data"
+)
+
+// Prefix is where a book's packaging is stored, next to its chapter audio.
+func Prefix(book *data.Book) string {
+	return fmt.Sprintf("audio/%d/%d/hls/", book.DocumentID, book.JobID)
+}
+
+func ManifestKey(book *data.Book) string {
+	return Prefix(book) + "manifest.json"
+}
+
+func SegmentKey(book *data.Book, position, index int) string {
+	return path.Join(Prefix(book), fmt.Sprintf("%04d", position), fmt.Sprintf("%05d.mp3", index))
+}
+
+// frameHeader decodes the MPEG audio frame header at the start of b and
+// reports the frame's length in bytes and duration in seconds.
+func frameHeader(b []byte) (int, float64, bool) {
+	if len(b) < 4 || b[0] != 0xFF || b[1]&0xE0 != 0xE0 {
+		return 0, 0, false
+	}
+	version := int(b[1]>>3) & 3
+	layer := 4 - int(b[1]>>1)&3
+	bitrateIndex := int(b[2] >> 4)
+	rateIndex := int(b[2]>>2) & 3
+	padding := int(b[2]>>1) & 1
+	if version == 1 || layer == 4 || bitrateIndex == 0 || bitrateIndex == 15 || rateIndex == 3 {
+		return 0, 0, false
+	}
+
+	mpeg1 := 0
+	if version == 3 {
+		mpeg1 = 1
+	}
+	bitrate := bitrates[mpeg1][layer-1][bitrateIndex] * 1000
+	rate := sampleRates[version][rateIndex]
+
+	var samples, size int
+	switch {
+	case layer == 1:
+		samples = 384
+		size = (12*bitrate/rate + padding) * 4
+	case layer == 3 && mpeg1 == 0:
+		samples = 576
+		size = 72*bitrate/rate + padding
+	default:
+		samples = 1152
+		size = 144*bitrate/rate + padding
+	}
+	return size, float64(samples) / float64(rate), true
+}
+
+// id3Size reports the length of an ID3v2 tag at the start of b, or zero.
+func id3Size(b []byte) int {
+	if len(b) < id3HeaderSize || string(b[:3]) != "ID3" {
+		return 0
+	}
+	size := int(b[6])<<21 | int(b[7])<<14 | int(b[8])<<7 | int(b[9])
+	size += id3HeaderSize
+	if b[5]&0x10 != 0 {
+		size += id3HeaderSize
+	}
+	return size
+}
+
+func newFrameReader(r io.Reader) *frameReader {
+	return &frameReader{r: bufio.NewReaderSize(r, readBufferSize)}
+}
+
+// next reads the next whole MPEG audio frame and its duration, or returns
+// io.EOF. Tags and stray bytes between frames are dropped, which is how
+// concatenated synthesis chunks are joined without glitches.
+func (f *frameReader) next() ([]byte, float64, error) {
+	for {
+		if b, _ := f.r.Peek(id3HeaderSize); id3Size(b) > 0 {
+			if _, err := f.r.Discard(id3Size(b)); err != nil {
+				return nil, 0, f.end(err)
+			}
+			f.synced = false
+			continue
+		}
+
+		b, err := f.r.Peek(4)
+		if len(b) < 4 {
+			return nil, 0, f.end(err)
+		}
+
+		// A header found right after the previous frame is trusted; one
+		// found while resynchronising must be followed by another.
+		size, duration, ok := frameHeader(b)
+		if ok {
+			if b, err = f.r.Peek(size + id3HeaderSize); f.end(err) != io.EOF {
+				return nil, 0, err
+			}
+			if !f.synced && len(b) > size {
+				_, _, next := frameHeader(b[size:])
+				ok = next || id3Size(b[size:]) > 0
+			}
+		}
+		if !ok || len(b) < size {
+			f.r.Discard(1)
+			f.synced = false
+			continue
+		}
+
+		frame := make([]byte, size)
+		if _, err := io.ReadFull(f.r, frame); err != nil {
+			return nil, 0, err
+		}
+		f.synced = true
+		return frame, duration, nil
+	}
+}
+
+// end reports the end of the audio as io.EOF and anything else as the
+// read error it is.
+func (f *frameReader) end(err error) error {
+	if err == nil || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return io.EOF
+	}
+	return err
+}
+
+// timestampTag is the ID3 tag HLS requires at the start of each packed
+// audio segment, giving the presentation time of its first sample.
+func timestampTag(seconds float64) []byte {
+	pts := uint64(seconds*mpegClock+0.5) & (1<<33 - 1)
+
+	body := append([]byte(timestampOwner), 0)
+	body = binary.BigEndian.AppendUint64(body, pts)
+
+	tag := make([]byte, 0, 2*id3HeaderSize+len(body))
+	tag = append(tag, 'I', 'D', '3', 4, 0, 0)
+	tag = appendSyncsafe(tag, uint32(id3HeaderSize+len(body)))
+	tag = append(tag, 'P', 'R', 'I', 'V')
+	tag = appendSyncsafe(tag, uint32(len(body)))
+	tag = append(tag, 0, 0)
+	return append(tag, body...)
+}
+
+func appendSyncsafe(b []byte, n uint32) []byte {
+	return append(b, byte(n>>21&0x7F), byte(n>>14&0x7F), byte(n>>7&0x7F), byte(n&0x7F))
+}
+
+// Playlist renders the manifest as a VOD media playlist. Each chapter after
+// the first starts with a discontinuity so players reset their decoder at
+// the join; segment URIs come from uri.
+func Playlist(m *Manifest, uri func(chapter *Chapter, segment *Segment) string) string {
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n#EXT-X-VERSION:3\n")
+	fmt.Fprintf(&b, "#EXT-X-TARGETDURATION:%d\n", m.TargetDuration)
+	b.WriteString("#EXT-X-MEDIA-SEQUENCE:0\n#EXT-X-PLAYLIST-TYPE:VOD\n#EXT-X-INDEPENDENT-SEGMENTS\n")
+
+	for i := range m.Chapters {
+		ch := &m.Chapters[i]
+		if i > 0 {
+			b.WriteString("#EXT-X-DISCONTINUITY\n")
+		}
+		for j := range ch.Segments {
+			seg := &ch.Segments[j]
+			title := ""
+			if j == 0 {
+				title = playlistTitle(ch.Title)
+			}
+			fmt.Fprintf(&b, "#EXTINF:%s,%s\n%s\n", strconv.FormatFloat(seg.Duration, 'f', 3, 64), title, uri(ch, seg))
+		}
+	}
+
+	b.WriteString("#EXT-X-ENDLIST\n")
+	return b.String()
+}
+
+func playlistTitle(title string) string {
+	return strings.Join(strings.Fields(title), " ")
+}
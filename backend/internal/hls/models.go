@@ -0,0 +1,91 @@
+package hls
+
+import (
+	"bufio"
+	"errors"
+	"log"
+
+	"cadence/internal/data"
+	"cadence/internal/storage"
+)
+
+const (
+	ContentTypePlaylist	= "application/vnd.apple.mpegurl"
+	ContentTypeSegment	= "audio/mpeg"
+
+	// TargetDuration is the segment length aimed for, in seconds. Segments
+	// end on the first frame boundary past it.
+	TargetDuration		= 10.0
+	manifestVersion		= 1
+
+	timestampOwner		= "com.apple.streaming.transportStreamTimestamp"
+	mpegClock			= 90000
+	id3HeaderSize		= 10
+	readBufferSize		= 64 << 10
+)
+
+var (
+	ErrNotPackaged		= errors.New("book has not been packaged for streaming")
+	ErrNoFrames			= errors.New("no mpeg audio frames found")
+	ErrInvalidManifest	= errors.New("invalid hls manifest")
+)
+
+type Packager struct {
+	repo			*data.Repository
+	store			storage.Storage
+	logger			*log.Logger
+}
+
+// Manifest records how a book was segmented. Playlists are rendered from it
+// on each request, since segment URLs are signed per client.
+type Manifest struct {
+	Version			int				`json:"version"`
+	TargetDuration	int				`json:"target_duration"`
+	Duration		float64			`json:"duration"`
+	Chapters		[]Chapter		`json:"chapters"`
+}
+
+type Chapter struct {
+	Position		int				`json:"position"`
+	Title			string			`json:"title"`
+	Start			float64			`json:"start"`
+	Segments		[]Segment		`json:"segments"`
+}
+
+type Segment struct {
+	Index			int				`json:"index"`
+	Start			float64			`json:"start"`
+	Duration		float64			`json:"duration"`
+	Size			int64			`json:"size"`
+}
+
+// frameReader reads the MPEG audio frames of a chapter file as it streams
+// in, so packaging never holds more than a segment in memory.
+type frameReader struct {
+	r				*bufio.Reader
+	synced			bool
+}
+
+var (
+	// bitrates in kbps, indexed by [version is MPEG-1][layer - 1][index].
+	bitrates = [2][3][16]int{
+		{
+			{0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256},
+			{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+			{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+		},
+		{
+			{0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448},
+			{0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384},
+			{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320},
+		},
+	}
+
+	// sampleRates indexed by the header's version bits, then rate index.
+	sampleRates = [4][3]int{
+		{11025, 12000, 8000},
+		{},
+		{22050, 24000, 16000},
+		{44100, 48000, 32000},
+	}
+)
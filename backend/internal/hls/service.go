@@ -0,0 +1,132 @@
+package hls
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+
+	"cadence/internal/data"
+	"cadence/internal/storage"
+)
+
+func New(repo *data.Repository, store storage.Storage, logger *log.Logger) *Packager {
+	return &Packager{repo: repo, store: store, logger: logger}
+}
+
+// Package segments every chapter of the book at frame boundaries, stores
+// the segments with their timestamp tags and records the manifest on the
+// book. Timestamps run continuously across the whole book.
+func (p *Packager) Package(ctx context.Context, book *data.Book) (*Manifest, error) {
+	m := &Manifest{Version: manifestVersion}
+	longest := 0.0
+	for i := range book.Chapters {
+		bc := &book.Chapters[i]
+		ch, err := p.packageChapter(ctx, book, bc, m.Duration)
+		if err != nil {
+			return nil, fmt.Errorf("chapter %d: %w", bc.Position+1, err)
+		}
+		for _, seg := range ch.Segments {
+			m.Duration += seg.Duration
+			longest = max(longest, seg.Duration)
+		}
+		m.Chapters = append(m.Chapters, *ch)
+	}
+	m.TargetDuration = int(math.Ceil(longest))
+
+	encoded, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	key := ManifestKey(book)
+	if _, err := p.store.Put(ctx, key, bytes.NewReader(encoded), "application/json"); err != nil {
+		return nil, err
+	}
+	if err := p.repo.SetBookHLS(book, key); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (p *Packager) Load(ctx context.Context, book *data.Book) (*Manifest, error) {
+	if !book.HasHLS {
+		return nil, ErrNotPackaged
+	}
+
+	r, err := p.store.Get(ctx, book.HLSKey)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, ErrNotPackaged
+		}
+		return nil, err
+	}
+	defer r.Close()
+
+	var m Manifest
+	if err := json.NewDecoder(r).Decode(&m); err != nil {
+		return nil, errors.Join(ErrInvalidManifest, err)
+	}
+	if m.Version != manifestVersion {
+		return nil, errors.Join(ErrInvalidManifest, fmt.Errorf("unsupported version %d", m.Version))
+	}
+	return &m, nil
+}
+
+// packageChapter segments one chapter as its audio streams in from
+// storage, with timestamps starting at start.
+func (p *Packager) packageChapter(ctx context.Context, book *data.Book, bc *data.BookChapter, start float64) (*Chapter, error) {
+	r, err := p.store.Get(ctx, bc.AudioKey)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	ch := &Chapter{Position: bc.Position, Title: bc.Title, Start: start}
+	seg := Segment{Start: start}
+	var buf bytes.Buffer
+	flush := func() error {
+		seg.Size = int64(buf.Len())
+		if _, err := p.store.Put(ctx, SegmentKey(book, bc.Position, seg.Index), &buf, ContentTypeSegment); err != nil {
+			return err
+		}
+		ch.Segments = append(ch.Segments, seg)
+		seg = Segment{Index: seg.Index + 1, Start: seg.Start + seg.Duration}
+		buf.Reset()
+		return nil
+	}
+
+	frames := newFrameReader(r)
+	for {
+		frame, duration, err := frames.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if buf.Len() == 0 {
+			buf.Write(timestampTag(seg.Start))
+		}
+		buf.Write(frame)
+		seg.Duration += duration
+		if seg.Duration >= TargetDuration {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if buf.Len() > 0 {
+		if err := flush(); err != nil {
+			return nil, err
+		}
+	}
+	if len(ch.Segments) == 0 {
+		return nil, ErrNoFrames
+	}
+	return ch, nil
+}
@@ -0,0 +1,344 @@
+package ingest
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+type docxStyles struct {
+	Styles			[]struct {
+		ID			string		`xml:"styleId,attr"`
+		Type		string		`xml:"type,attr"`
+		Name		struct {
+			Val		string		`xml:"val,attr"`
+		}						`xml:"name"`
+		BasedOn		struct {
+			Val		string		`xml:"val,attr"`
+		}						`xml:"basedOn"`
+		Outline		*struct {
+			Val		int			`xml:"val,attr"`
+		}						`xml:"pPr>outlineLvl"`
+	}							`xml:"style"`
+}
+
+type officeProperties struct {
+	Title			string		`xml:"title"`
+	Subject			string		`xml:"subject"`
+	Creator			string		`xml:"creator"`
+	InitialCreator	string		`xml:"initial-creator"`
+	Language		string		`xml:"language"`
+	Description		string		`xml:"description"`
+	Keywords		string		`xml:"keywords"`
+	Keyword			[]string	`xml:"keyword"`
+	Created			string		`xml:"created"`
+	CreationDate	string		`xml:"creation-date"`
+	Modified		string		`xml:"modified"`
+	Date			string		`xml:"date"`
+}
+
+type officeParagraph struct {
+	style		string
+	level		int
+	list		bool
+	text		strings.Builder
+}
+
+func ParseDOCX(content []byte) (*Document, error) {
+	archive, err := openArchive(content)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := archive.read("word/document.xml")
+	if err != nil {
+		return nil, err
+	}
+
+	headings := make(map[string]int)
+	if archive.has("word/styles.xml") {
+		var styles docxStyles
+		if err := archive.decode("word/styles.xml", &styles); err == nil {
+			headings = styles.headingLevels()
+		}
+	}
+
+	doc := &Document{Properties: make(map[string]string)}
+	if archive.has("docProps/core.xml") {
+		var props officeProperties
+		if err := archive.decode("docProps/core.xml", &props); err == nil {
+			props.apply(doc)
+		}
+	}
+
+	notes := make(map[string]string)
+	for _, name := range []string{"word/footnotes.xml", "word/endnotes.xml"} {
+		if !archive.has(name) {
+			continue
+		}
+		part, err := archive.read(name)
+		if err != nil {
+			return nil, err
+		}
+		if err := readDOCXNotes(part, notes); err != nil {
+			return nil, err
+		}
+	}
+
+	blocks, refs, err := readDOCXBody(body, headings)
+	if err != nil {
+		return nil, err
+	}
+
+	kept := blocks[:0]
+	for _, b := range blocks {
+		if b.Kind == BlockHeading && b.Level == 0 {
+			if doc.Title == "" {
+				doc.Title = b.Text
+			}
+			for i := range refs {
+				if refs[i].Block > len(kept) {
+					refs[i].Block--
+				}
+			}
+			continue
+		}
+		kept = append(kept, b)
+	}
+	blocks = kept
+
+	title, chapters, starts := splitChapters(blocks, false)
+	if doc.Title == "" {
+		doc.Title = title
+	}
+	assignNotes(chapters, starts, refs, notes)
+	doc.Chapters = chapters
+	return doc, nil
+}
+
+func (s *docxStyles) headingLevels() map[string]int {
+	levels := make(map[string]int)
+	based := make(map[string]string)
+	for _, style := range s.Styles {
+		if style.Type != "" && style.Type != "paragraph" {
+			continue
+		}
+
+		name := strings.ToLower(strings.TrimSpace(style.Name.Val))
+		switch {
+		case name == "title":
+			levels[style.ID] = 0
+		case strings.HasPrefix(name, "heading "):
+			if level, err := strconv.Atoi(strings.TrimPrefix(name, "heading ")); err == nil && level >= 1 && level <= 9 {
+				levels[style.ID] = level
+			}
+		case style.Outline != nil && style.Outline.Val < 9:
+			levels[style.ID] = style.Outline.Val + 1
+		default:
+			if style.BasedOn.Val != "" {
+				based[style.ID] = style.BasedOn.Val
+			}
+		}
+	}
+
+	for id, parent := range based {
+		for depth := 0; depth < 10 && parent != ""; depth++ {
+			if level, ok := levels[parent]; ok {
+				levels[id] = level
+				break
+			}
+			parent = based[parent]
+		}
+	}
+	return levels
+}
+
+func (p *officeProperties) apply(doc *Document) {
+	doc.Title = CleanText(p.Title)
+	doc.Language = CleanText(p.Language)
+	for _, creator := range []string{p.Creator, p.InitialCreator} {
+		if creator = CleanText(creator); creator != "" {
+			doc.Authors = append(doc.Authors, creator)
+			break
+		}
+	}
+
+	keywords := p.Keywords
+	if keywords == "" {
+		keywords = strings.Join(p.Keyword, ", ")
+	}
+	props := map[string]string{
+		"subject":		p.Subject,
+		"description":	p.Description,
+		"keywords":		keywords,
+		"created":		p.Created + p.CreationDate,
+		"modified":		p.Modified + p.Date,
+	}
+	for key, value := range props {
+		if value = CleanText(value); value != "" {
+			doc.Properties[key] = value
+		}
+	}
+}
+
+func readDOCXBody(content []byte, headings map[string]int) ([]Block, []noteReference, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	decoder.Strict = false
+
+	var blocks []Block
+	var refs []noteReference
+	var para *officeParagraph
+	skip := 0
+	inText := false
+
+	for {
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, errors.Join(ErrInvalidDocument, fmt.Errorf("failed to parse document body: %w", err))
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if skip > 0 {
+				skip++
+				continue
+			}
+			switch t.Name.Local {
+			case "del", "instrText", "delText", "commentReference":
+				skip = 1
+			case "p":
+				blocks = appendOfficeParagraph(blocks, para, headings)
+				para = &officeParagraph{level: -1}
+			case "pStyle":
+				if para != nil {
+					para.style = xmlAttr(t, "val")
+				}
+			case "outlineLvl":
+				if para != nil {
+					if level, err := strconv.Atoi(xmlAttr(t, "val")); err == nil && level < 9 {
+						para.level = level + 1
+					}
+				}
+			case "numPr":
+				if para != nil {
+					para.list = true
+				}
+			case "t":
+				inText = true
+			case "tab", "br", "cr":
+				if para != nil {
+					para.text.WriteByte(' ')
+				}
+			case "footnoteReference", "endnoteReference":
+				if para != nil {
+					key := strconv.Itoa(len(refs) + 1)
+					refs = append(refs, noteReference{Block: len(blocks), Key: key, Target: t.Name.Local[:1] + ":" + xmlAttr(t, "id")})
+					para.text.WriteString("[" + key + "]")
+				}
+			}
+		case xml.EndElement:
+			if skip > 0 {
+				skip--
+				continue
+			}
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				blocks = appendOfficeParagraph(blocks, para, headings)
+				para = nil
+			}
+		case xml.CharData:
+			if inText && skip == 0 && para != nil {
+				para.text.Write(t)
+			}
+		}
+	}
+
+	return appendOfficeParagraph(blocks, para, headings), refs, nil
+}
+
+func readDOCXNotes(content []byte, notes map[string]string) error {
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	decoder.Strict = false
+
+	var id string
+	var text strings.Builder
+	inText := false
+
+	for {
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.Join(ErrInvalidDocument, fmt.Errorf("failed to parse notes: %w", err))
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "footnote", "endnote":
+				id = t.Name.Local[:1] + ":" + xmlAttr(t, "id")
+				text.Reset()
+			case "t":
+				inText = true
+			case "p", "tab", "br":
+				text.WriteByte(' ')
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "footnote", "endnote":
+				if note := CleanText(text.String()); note != "" && id != "" {
+					notes[id] = note
+				}
+				id = ""
+			}
+		case xml.CharData:
+			if inText && id != "" {
+				text.Write(t)
+			}
+		}
+	}
+}
+
+func appendOfficeParagraph(blocks []Block, para *officeParagraph, headings map[string]int) []Block {
+	if para == nil {
+		return blocks
+	}
+	text := CleanText(para.text.String())
+	if text == "" {
+		return blocks
+	}
+
+	block := Block{Kind: BlockParagraph, Text: text}
+	level, ok := headings[para.style]
+	if para.level > 0 {
+		level, ok = para.level, true
+	}
+	switch {
+	case ok:
+		block.Kind, block.Level = BlockHeading, level
+	case para.list:
+		block.Kind = BlockListItem
+	}
+	return append(blocks, block)
+}
+
+func xmlAttr(el xml.StartElement, local string) string {
+	for _, a := range el.Attr {
+		if a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
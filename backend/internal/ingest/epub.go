@@ -0,0 +1,471 @@
+package ingest
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"cadence/internal/preprocess"
+
+	"golang.org/x/net/html"
+)
+
+type epubContainer struct {
+	Rootfiles		[]struct {
+		FullPath	string		`xml:"full-path,attr"`
+		MediaType	string		`xml:"media-type,attr"`
+	}							`xml:"rootfiles>rootfile"`
+}
+
+type opfPackage struct {
+	Version			string		`xml:"version,attr"`
+	Metadata		opfMetadata	`xml:"metadata"`
+	Manifest		[]opfItem	`xml:"manifest>item"`
+	Spine			opfSpine	`xml:"spine"`
+}
+
+type opfMetadata struct {
+	Titles			[]string	`xml:"title"`
+	Creators		[]struct {
+		Name		string		`xml:",chardata"`
+		Role		string		`xml:"role,attr"`
+		ID			string		`xml:"id,attr"`
+	}							`xml:"creator"`
+	Languages		[]string	`xml:"language"`
+	Publisher		string		`xml:"publisher"`
+	Date			string		`xml:"date"`
+	Description		string		`xml:"description"`
+	Identifier		string		`xml:"identifier"`
+	Subjects		[]string	`xml:"subject"`
+	Metas			[]struct {
+		Name		string		`xml:"name,attr"`
+		Content		string		`xml:"content,attr"`
+		Property	string		`xml:"property,attr"`
+		Refines		string		`xml:"refines,attr"`
+		Value		string		`xml:",chardata"`
+	}							`xml:"meta"`
+}
+
+type opfItem struct {
+	ID				string		`xml:"id,attr"`
+	Href			string		`xml:"href,attr"`
+	MediaType		string		`xml:"media-type,attr"`
+	Properties		string		`xml:"properties,attr"`
+}
+
+type opfSpine struct {
+	Toc				string		`xml:"toc,attr"`
+	Items			[]struct {
+		IDRef		string		`xml:"idref,attr"`
+		Linear		string		`xml:"linear,attr"`
+	}							`xml:"itemref"`
+}
+
+type ncxNavPoint struct {
+	Label			string			`xml:"navLabel>text"`
+	Content			struct {
+		Src			string			`xml:"src,attr"`
+	}								`xml:"content"`
+	Children		[]ncxNavPoint	`xml:"navPoint"`
+}
+
+type ncxDocument struct {
+	Points			[]ncxNavPoint	`xml:"navMap>navPoint"`
+}
+
+type encryptionDocument struct {
+	Data			[]struct {
+		Method		struct {
+			Algorithm	string		`xml:"Algorithm,attr"`
+		}							`xml:"EncryptionMethod"`
+	}								`xml:"EncryptedData"`
+}
+
+type spineDocument struct {
+	path		string
+	blocks		[]Block
+	anchors		map[string]int
+	refs		[]noteReference
+}
+
+type chapterStart struct {
+	file		int
+	block		int
+	title		string
+}
+
+var fontObfuscation = map[string]bool{
+	"http://www.idpf.org/2008/embedding":	true,
+	"http://ns.adobe.com/pdf/enc#RC":		true,
+}
+
+func ParseEPUB(content []byte) (*Document, error) {
+	archive, err := openArchive(content)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := archive.checkEncryption(); err != nil {
+		return nil, err
+	}
+
+	var container epubContainer
+	if err := archive.decode("META-INF/container.xml", &container); err != nil {
+		return nil, err
+	}
+	if len(container.Rootfiles) == 0 {
+		return nil, errors.Join(ErrInvalidDocument, errors.New("epub container has no rootfile"))
+	}
+
+	opfPath := container.Rootfiles[0].FullPath
+	var pkg opfPackage
+	if err := archive.decode(opfPath, &pkg); err != nil {
+		return nil, err
+	}
+
+	manifest := make(map[string]opfItem, len(pkg.Manifest))
+	for _, item := range pkg.Manifest {
+		item.Href = resolveHref(opfPath, item.Href)
+		manifest[item.ID] = item
+	}
+
+	doc := &Document{Properties: make(map[string]string)}
+	pkg.Metadata.apply(doc)
+	doc.Cover, doc.CoverType = archive.cover(pkg, manifest)
+
+	var docs []spineDocument
+	notes := make(map[string]string)
+	for _, ref := range pkg.Spine.Items {
+		item, ok := manifest[ref.IDRef]
+		if !ok || strings.EqualFold(ref.Linear, "no") || !isMarkupType(item.MediaType) {
+			continue
+		}
+
+		sd, err := archive.convert(item.Href, notes)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, sd)
+	}
+
+	toc := archive.toc(pkg, manifest)
+	doc.Chapters = buildChapters(docs, toc, notes)
+	return doc, nil
+}
+
+func (m *opfMetadata) apply(doc *Document) {
+	if len(m.Titles) > 0 {
+		doc.Title = m.Titles[0]
+	}
+	if len(m.Languages) > 0 {
+		doc.Language = strings.TrimSpace(m.Languages[0])
+	}
+
+	roles := make(map[string]string)
+	for _, meta := range m.Metas {
+		if meta.Property == "role" && strings.HasPrefix(meta.Refines, "#") {
+			roles[strings.TrimPrefix(meta.Refines, "#")] = strings.TrimSpace(meta.Value)
+		}
+	}
+	for _, creator := range m.Creators {
+		role := creator.Role
+		if role == "" {
+			role = roles[creator.ID]
+		}
+		if role != "" && role != "aut" {
+			continue
+		}
+		if name := CleanText(creator.Name); name != "" {
+			doc.Authors = append(doc.Authors, name)
+		}
+	}
+
+	props := map[string]string{
+		"publisher":	m.Publisher,
+		"date":			m.Date,
+		"description":	m.Description,
+		"identifier":	m.Identifier,
+		"subjects":		strings.Join(m.Subjects, ", "),
+	}
+	for key, value := range props {
+		if value = CleanText(value); value != "" {
+			doc.Properties[key] = value
+		}
+	}
+}
+
+func (a *zipArchive) checkEncryption() error {
+	if _, ok := a.files["META-INF/encryption.xml"]; !ok {
+		return nil
+	}
+
+	var enc encryptionDocument
+	if err := a.decode("META-INF/encryption.xml", &enc); err != nil {
+		return err
+	}
+	for _, data := range enc.Data {
+		if !fontObfuscation[data.Method.Algorithm] {
+			return ErrEncrypted
+		}
+	}
+	return nil
+}
+
+func (a *zipArchive) cover(pkg opfPackage, manifest map[string]opfItem) ([]byte, string) {
+	var item opfItem
+	for _, candidate := range pkg.Manifest {
+		if strings.Contains(candidate.Properties, "cover-image") {
+			item = manifest[candidate.ID]
+			break
+		}
+	}
+	if item.Href == "" {
+		for _, meta := range pkg.Metadata.Metas {
+			if meta.Name == "cover" {
+				item = manifest[meta.Content]
+				break
+			}
+		}
+	}
+	if item.Href == "" {
+		for _, candidate := range pkg.Manifest {
+			if strings.HasPrefix(candidate.MediaType, "image/") && strings.Contains(strings.ToLower(candidate.ID+candidate.Href), "cover") {
+				item = manifest[candidate.ID]
+				break
+			}
+		}
+	}
+	if item.Href == "" || !strings.HasPrefix(item.MediaType, "image/") {
+		return nil, ""
+	}
+
+	content, err := a.readLimited(item.Href, maxImageSize)
+	if err != nil {
+		return nil, ""
+	}
+	return content, item.MediaType
+}
+
+func (a *zipArchive) convert(name string, notes map[string]string) (spineDocument, error) {
+	content, err := a.read(name)
+	if err != nil {
+		return spineDocument{}, err
+	}
+
+	root, err := parseMarkup(content, true)
+	if err != nil {
+		return spineDocument{}, errors.Join(ErrInvalidDocument, fmt.Errorf("failed to parse %s: %w", name, err))
+	}
+
+	hc := newHTMLConverter()
+	hc.convert(root)
+
+	for id, text := range hc.notes {
+		notes[name+"#"+id] = text
+	}
+	for id, idx := range hc.anchors {
+		if _, ok := notes[name+"#"+id]; !ok && idx < len(hc.blocks) {
+			notes[name+"#"+id] = cleanNote(hc.blocks[idx].Text)
+		}
+	}
+
+	refs := make([]noteReference, 0, len(hc.refs))
+	for _, ref := range hc.refs {
+		ref.Target = resolveHref(name, ref.Target)
+		refs = append(refs, ref)
+	}
+
+	return spineDocument{path: name, blocks: hc.blocks, anchors: hc.anchors, refs: refs}, nil
+}
+
+func (a *zipArchive) toc(pkg opfPackage, manifest map[string]opfItem) []tocEntry {
+	for _, item := range pkg.Manifest {
+		if !strings.Contains(item.Properties, "nav") {
+			continue
+		}
+		if entries := a.navTOC(manifest[item.ID].Href); len(entries) > 0 {
+			return entries
+		}
+	}
+
+	ncx, ok := manifest[pkg.Spine.Toc]
+	if !ok {
+		for _, item := range manifest {
+			if item.MediaType == "application/x-dtbncx+xml" {
+				ncx, ok = item, true
+				break
+			}
+		}
+	}
+	if !ok {
+		return nil
+	}
+
+	var doc ncxDocument
+	if err := a.decode(ncx.Href, &doc); err != nil {
+		return nil
+	}
+
+	var entries []tocEntry
+	var visit func(points []ncxNavPoint, depth int)
+	visit = func(points []ncxNavPoint, depth int) {
+		for _, p := range points {
+			entries = append(entries, tocEntry{Title: CleanText(p.Label), Href: resolveHref(ncx.Href, p.Content.Src), Depth: depth})
+			visit(p.Children, depth+1)
+		}
+	}
+	visit(doc.Points, 0)
+	return entries
+}
+
+func (a *zipArchive) navTOC(name string) []tocEntry {
+	content, err := a.read(name)
+	if err != nil {
+		return nil
+	}
+	root, err := parseMarkup(content, true)
+	if err != nil {
+		return nil
+	}
+
+	nav := findElement(root, func(n *html.Node) bool {
+		return n.Data == "nav" && strings.Contains(attr(n, "epub:type"), "toc")
+	})
+	if nav == nil {
+		nav = findElement(root, func(n *html.Node) bool { return n.Data == "nav" })
+	}
+	if nav == nil {
+		return nil
+	}
+
+	var entries []tocEntry
+	var visit func(n *html.Node, depth int)
+	visit = func(n *html.Node, depth int) {
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type != html.ElementNode {
+				continue
+			}
+			switch child.Data {
+			case "li":
+				if link := findElement(child, func(n *html.Node) bool { return n.Data == "a" }); link != nil && attr(link, "href") != "" {
+					entries = append(entries, tocEntry{Title: CleanText(textContent(link)), Href: resolveHref(name, attr(link, "href")), Depth: depth})
+				}
+				for sub := child.FirstChild; sub != nil; sub = sub.NextSibling {
+					if sub.Type == html.ElementNode && sub.Data == "ol" {
+						visit(sub, depth+1)
+					}
+				}
+			default:
+				visit(child, depth)
+			}
+		}
+	}
+	visit(nav, 0)
+	return entries
+}
+
+func buildChapters(docs []spineDocument, toc []tocEntry, notes map[string]string) []Chapter {
+	fileIndex := make(map[string]int, len(docs))
+	for i, sd := range docs {
+		fileIndex[sd.path] = i
+	}
+
+	var starts []chapterStart
+	for _, entry := range toc {
+		file, fragment, _ := strings.Cut(entry.Href, "#")
+		fi, ok := fileIndex[file]
+		if !ok {
+			continue
+		}
+
+		block := 0
+		if fragment != "" {
+			if idx, ok := docs[fi].anchors[fragment]; ok {
+				block = idx
+			}
+		}
+
+		start := chapterStart{file: fi, block: block, title: entry.Title}
+		if n := len(starts); n > 0 {
+			last := starts[n-1]
+			if last.file == start.file && last.block == start.block {
+				continue
+			}
+			if last.file > start.file || (last.file == start.file && last.block > start.block) {
+				continue
+			}
+		}
+		starts = append(starts, start)
+	}
+
+	var chapters []Chapter
+	var refs []noteReference
+	current := -1
+	next := 0
+
+	closeChapter := func() {
+		if current < 0 {
+			return
+		}
+		c := &chapters[current]
+		c.Notes = make(preprocess.Notes)
+		for _, ref := range refs {
+			if text, ok := notes[ref.Target]; ok && text != "" {
+				c.Notes[ref.Key] = text
+			}
+		}
+		refs = nil
+	}
+
+	for fi, sd := range docs {
+		if next == 0 && (len(starts) == 0 || fi < starts[0].file) {
+			closeChapter()
+			chapters = append(chapters, Chapter{})
+			current = len(chapters) - 1
+		}
+
+		for bi, block := range sd.blocks {
+			for next < len(starts) && starts[next].file == fi && starts[next].block <= bi {
+				closeChapter()
+				chapters = append(chapters, Chapter{Title: starts[next].title})
+				current = len(chapters) - 1
+				next++
+			}
+			if current < 0 {
+				chapters = append(chapters, Chapter{})
+				current = 0
+			}
+			chapters[current].Blocks = append(chapters[current].Blocks, block)
+			for _, ref := range sd.refs {
+				if ref.Block == bi {
+					refs = append(refs, ref)
+				}
+			}
+		}
+	}
+	closeChapter()
+
+	return chapters
+}
+
+func resolveHref(base, href string) string {
+	if unescaped, err := url.PathUnescape(href); err == nil {
+		href = unescaped
+	}
+	file, fragment, hasFragment := strings.Cut(href, "#")
+	if file == "" {
+		file = base
+	} else {
+		file = path.Join(path.Dir(base), file)
+	}
+	if hasFragment {
+		return file + "#" + fragment
+	}
+	return file
+}
+
+func isMarkupType(mediaType string) bool {
+	return mediaType == "application/xhtml+xml" || mediaType == "text/html" || mediaType == "application/x-dtbook+xml"
+}
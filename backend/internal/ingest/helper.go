@@ -0,0 +1,310 @@
+package ingest
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"cadence/internal/preprocess"
+)
+
+func CleanText(text string) string {
+	text = invisibleReplacer.Replace(text)
+	text = whitespacePattern.ReplaceAllString(text, " ")
+	return strings.TrimSpace(text)
+}
+
+func (c *Chapter) Paragraphs() []string {
+	paragraphs := make([]string, 0, len(c.Blocks))
+	for _, b := range c.Blocks {
+		paragraphs = append(paragraphs, b.Text)
+	}
+	return paragraphs
+}
+
+func (c *Chapter) Text() string {
+	return strings.Join(c.Paragraphs(), "\n\n")
+}
+
+func (c *Chapter) CharCount() int {
+	count := 0
+	for _, b := range c.Blocks {
+		count += len([]rune(b.Text))
+	}
+	return count
+}
+
+func (c *Chapter) extractNotes() {
+	body, notes := preprocess.ExtractNotes(c.Paragraphs())
+	if len(body) == len(c.Blocks) && len(notes) == 0 {
+		return
+	}
+
+	kept := make([]Block, 0, len(body))
+	j := 0
+	for _, b := range c.Blocks {
+		if j < len(body) && b.Text == body[j] {
+			kept = append(kept, b)
+			j++
+		}
+	}
+	c.Blocks = kept
+
+	if c.Notes == nil {
+		c.Notes = make(preprocess.Notes)
+	}
+	for key, text := range notes {
+		if _, ok := c.Notes[key]; !ok {
+			c.Notes[key] = text
+		}
+	}
+}
+
+func (d *Document) normalize(filename string) error {
+	chapters := make([]Chapter, 0, len(d.Chapters))
+	for _, c := range d.Chapters {
+		blocks := c.Blocks[:0]
+		for _, b := range c.Blocks {
+			if b.Text = CleanText(b.Text); b.Text != "" {
+				blocks = append(blocks, b)
+			}
+		}
+		c.Blocks = blocks
+		c.extractNotes()
+		if len(c.Blocks) == 0 {
+			continue
+		}
+
+		c.Title = CleanText(c.Title)
+		if c.Title == "" && c.Blocks[0].Kind == BlockHeading {
+			c.Title = c.Blocks[0].Text
+		}
+		if c.Title == "" {
+			c.Title = fmt.Sprintf("Chapter %d", len(chapters)+1)
+		}
+		chapters = append(chapters, c)
+	}
+
+	if len(chapters) == 0 {
+		return ErrEmptyDocument
+	}
+	d.Chapters = chapters
+	d.shareNotes()
+
+	d.Title = CleanText(d.Title)
+	if d.Title == "" {
+		d.Title = strings.TrimSuffix(path.Base(filename), path.Ext(filename))
+	}
+	for i := range d.Authors {
+		d.Authors[i] = CleanText(d.Authors[i])
+	}
+	if d.Properties == nil {
+		d.Properties = make(map[string]string)
+	}
+	return nil
+}
+
+func splitChapters(blocks []Block, useSeparators bool) (string, []Chapter, []int) {
+	top, count := 0, 0
+	for _, b := range blocks {
+		if b.Kind != BlockHeading {
+			continue
+		}
+		switch {
+		case top == 0 || b.Level < top:
+			top, count = b.Level, 1
+		case b.Level == top:
+			count++
+		}
+	}
+
+	title, skip := "", -1
+	if count == 1 {
+		next := 0
+		for i, b := range blocks {
+			switch {
+			case b.Kind != BlockHeading:
+			case b.Level == top && i <= 2:
+				skip = i
+			case b.Level > top && (next == 0 || b.Level < next):
+				next = b.Level
+			}
+		}
+		if skip >= 0 && next > 0 {
+			title, top = blocks[skip].Text, next
+		} else {
+			skip = -1
+		}
+	}
+
+	isStart := func(b Block) bool {
+		if top > 0 {
+			return b.Kind == BlockHeading && b.Level <= top
+		}
+		return useSeparators && b.Kind == blockSeparator
+	}
+
+	var chapters []Chapter
+	var starts []int
+	for i, b := range blocks {
+		if i == skip {
+			continue
+		}
+		if len(chapters) == 0 || isStart(b) {
+			heading := ""
+			if b.Kind == BlockHeading {
+				heading = b.Text
+			}
+			chapters = append(chapters, Chapter{Title: heading})
+			starts = append(starts, i)
+		}
+		if b.Kind == blockSeparator {
+			continue
+		}
+		chapters[len(chapters)-1].Blocks = append(chapters[len(chapters)-1].Blocks, b)
+	}
+	return title, chapters, starts
+}
+
+func (d *Document) shareNotes() {
+	shared := make(preprocess.Notes)
+	for _, c := range d.Chapters {
+		for key, text := range c.Notes {
+			if _, ok := shared[key]; !ok {
+				shared[key] = text
+			}
+		}
+	}
+	if len(shared) == 0 {
+		return
+	}
+
+	for i := range d.Chapters {
+		c := &d.Chapters[i]
+		for _, p := range c.Paragraphs() {
+			for _, key := range preprocess.FindMarkers(p, shared) {
+				if _, ok := c.Notes[key]; ok {
+					continue
+				}
+				if text, ok := shared[key]; ok {
+					if c.Notes == nil {
+						c.Notes = make(preprocess.Notes)
+					}
+					c.Notes[key] = text
+				}
+			}
+		}
+	}
+}
+
+func openArchive(content []byte) (*zipArchive, error) {
+	zr, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return nil, errors.Join(ErrInvalidDocument, fmt.Errorf("failed to open archive: %w", err))
+	}
+
+	archive := &zipArchive{files: make(map[string]*zip.File, len(zr.File))}
+	for _, f := range zr.File {
+		archive.files[f.Name] = f
+	}
+	return archive, nil
+}
+
+func (a *zipArchive) has(name string) bool {
+	_, ok := a.files[name]
+	return ok
+}
+
+func (a *zipArchive) read(name string) ([]byte, error) {
+	return a.readLimited(name, maxEntrySize)
+}
+
+// readLimited reads an entry of at most limit bytes, counting it against
+// the archive's total.
+func (a *zipArchive) readLimited(name string, limit int64) ([]byte, error) {
+	f, ok := a.files[name]
+	if !ok {
+		return nil, errors.Join(ErrInvalidDocument, fmt.Errorf("missing archive entry %s", name))
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return nil, errors.Join(ErrInvalidDocument, fmt.Errorf("failed to open archive entry %s: %w", name, err))
+	}
+	defer rc.Close()
+
+	limit = min(limit, maxArchiveSize-a.total)
+	content, err := io.ReadAll(io.LimitReader(rc, limit+1))
+	a.total += int64(len(content))
+	if err != nil {
+		return nil, errors.Join(ErrInvalidDocument, fmt.Errorf("failed to read archive entry %s: %w", name, err))
+	}
+	if int64(len(content)) > limit {
+		if a.total > maxArchiveSize {
+			return nil, errors.Join(ErrInvalidDocument, errors.New("archive content is too large"))
+		}
+		return nil, errors.Join(ErrInvalidDocument, fmt.Errorf("archive entry %s is too large", name))
+	}
+	return content, nil
+}
+
+func (a *zipArchive) decode(name string, v interface{}) error {
+	content, err := a.read(name)
+	if err != nil {
+		return err
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	decoder.Strict = false
+	decoder.Entity = xml.HTMLEntity
+	if err := decoder.Decode(v); err != nil {
+		return errors.Join(ErrInvalidDocument, fmt.Errorf("failed to parse %s: %w", name, err))
+	}
+	return nil
+}
+
+func assignNotes(chapters []Chapter, starts []int, refs []noteReference, notes map[string]string) {
+	for _, ref := range refs {
+		text, ok := notes[ref.Target]
+		if !ok || text == "" {
+			continue
+		}
+
+		c := len(starts) - 1
+		for c > 0 && starts[c] > ref.Block {
+			c--
+		}
+		if c < 0 || c >= len(chapters) {
+			continue
+		}
+		if chapters[c].Notes == nil {
+			chapters[c].Notes = make(preprocess.Notes)
+		}
+		chapters[c].Notes[ref.Key] = text
+	}
+}
+
+func (f Format) ContentType() string {
+	switch f {
+	case FormatEPUB:
+		return "application/epub+zip"
+	case FormatText:
+		return "text/plain; charset=utf-8"
+	case FormatMarkdown:
+		return "text/markdown; charset=utf-8"
+	case FormatHTML:
+		return "text/html"
+	case FormatDOCX:
+		return "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	case FormatODT:
+		return "application/vnd.oasis.opendocument.text"
+	case FormatPDF:
+		return "application/pdf"
+	}
+	return "application/octet-stream"
+}
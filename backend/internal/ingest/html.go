@@ -0,0 +1,395 @@
+package ingest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"cadence/internal/preprocess"
+
+	"golang.org/x/net/html"
+)
+
+type noteReference struct {
+	Block		int
+	Key			string
+	Target		string
+}
+
+type htmlConverter struct {
+	blocks		[]Block
+	anchors		map[string]int
+	notes		map[string]string
+	refs		[]noteReference
+	inline		strings.Builder
+	kinds		[]Block
+	pendingID	string
+	boilerplate	bool
+}
+
+func newHTMLConverter() *htmlConverter {
+	return &htmlConverter{
+		anchors:	make(map[string]int),
+		notes:		make(map[string]string),
+	}
+}
+
+func ParseHTML(content []byte) (*Document, error) {
+	declared := ""
+	if m := metaCharsetPattern.FindSubmatch(content[:min(len(content), 2048)]); m != nil {
+		declared = string(m[1])
+	}
+
+	text, enc := DecodeText(content, declared)
+	root, err := parseMarkup([]byte(text), false)
+	if err != nil {
+		return nil, errors.Join(ErrInvalidDocument, fmt.Errorf("failed to parse html: %w", err))
+	}
+
+	doc := &Document{Properties: map[string]string{"encoding": enc}}
+	htmlMetadata(root, doc)
+
+	hc := newHTMLConverter()
+	hc.boilerplate = true
+	hc.convert(contentRoot(root))
+
+	title, chapters, starts := splitChapters(hc.blocks, false)
+	if doc.Title == "" {
+		doc.Title = title
+	}
+
+	notes := make(map[string]string)
+	for _, ref := range hc.refs {
+		_, id, _ := strings.Cut(ref.Target, "#")
+		if text, ok := hc.notes[id]; ok {
+			notes[ref.Target] = text
+		} else if idx, ok := hc.anchors[id]; ok && idx < len(hc.blocks) {
+			notes[ref.Target] = cleanNote(hc.blocks[idx].Text)
+		}
+	}
+	assignNotes(chapters, starts, hc.refs, notes)
+
+	doc.Chapters = chapters
+	return doc, nil
+}
+
+func htmlMetadata(root *html.Node, doc *Document) {
+	if el := findElement(root, func(n *html.Node) bool { return n.Data == "html" }); el != nil {
+		doc.Language = attr(el, "lang")
+	}
+
+	var visit func(n *html.Node)
+	visit = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "body":
+				return
+			case "title":
+				if doc.Title == "" {
+					doc.Title = textContent(n)
+				}
+			case "meta":
+				name := strings.ToLower(attr(n, "name") + attr(n, "property"))
+				value := strings.TrimSpace(attr(n, "content"))
+				switch {
+				case value == "":
+				case name == "og:title" || name == "dc.title":
+					doc.Title = value
+				case name == "author" || name == "dc.creator" || name == "article:author":
+					doc.Authors = append(doc.Authors, value)
+				case name == "description" || name == "og:description":
+					doc.Properties["description"] = value
+				}
+			}
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			visit(child)
+		}
+	}
+	visit(root)
+}
+
+func contentRoot(root *html.Node) *html.Node {
+	for _, tag := range []string{"main", "article"} {
+		var found []*html.Node
+		var visit func(n *html.Node)
+		visit = func(n *html.Node) {
+			if n.Type == html.ElementNode && (n.Data == tag || (tag == "main" && attr(n, "role") == "main")) {
+				found = append(found, n)
+				return
+			}
+			for child := n.FirstChild; child != nil; child = child.NextSibling {
+				visit(child)
+			}
+		}
+		visit(root)
+		if len(found) == 1 {
+			return found[0]
+		}
+	}
+	return root
+}
+
+func isBoilerplate(n *html.Node) bool {
+	switch n.Data {
+	case "html", "body", "main", "article":
+		return false
+	case "footer":
+		return true
+	case "aside":
+		return !isNote(n)
+	case "header":
+		return findElement(n, func(h *html.Node) bool { return h.Data == "h1" || h.Data == "h2" || h.Data == "h3" }) == nil
+	}
+
+	switch strings.ToLower(attr(n, "role")) {
+	case "navigation", "banner", "contentinfo", "search", "complementary", "menu", "menubar":
+		return true
+	}
+
+	if !boilerplatePattern.MatchString(attr(n, "class") + " " + attr(n, "id")) {
+		return false
+	}
+	text := CleanText(textContent(n))
+	return len(text) < 500 || linkDensity(n, len(text)) > 0.3 || strings.Contains(strings.ToLower(attr(n, "class")+attr(n, "id")), "comment")
+}
+
+func linkDensity(n *html.Node, total int) float64 {
+	if total == 0 {
+		return 1
+	}
+
+	linked := 0
+	var visit func(n *html.Node)
+	visit = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "a" {
+			linked += len(CleanText(textContent(n)))
+			return
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			visit(child)
+		}
+	}
+	visit(n)
+	return float64(linked) / float64(total)
+}
+
+func parseMarkup(content []byte, xhtml bool) (*html.Node, error) {
+	if xhtml {
+		content = selfClosingPattern.ReplaceAllFunc(content, func(tag []byte) []byte {
+			m := selfClosingPattern.FindSubmatch(tag)
+			if voidElements[strings.ToLower(string(m[1]))] {
+				return tag
+			}
+			return []byte("<" + string(m[1]) + string(m[2]) + "></" + string(m[1]) + ">")
+		})
+	}
+
+	root, err := html.Parse(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+func (hc *htmlConverter) convert(n *html.Node) {
+	hc.walk(n)
+	hc.flush()
+}
+
+func (hc *htmlConverter) walk(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		hc.inline.WriteString(n.Data)
+		return
+	case html.ElementNode:
+	default:
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			hc.walk(child)
+		}
+		return
+	}
+
+	tag := strings.ToLower(n.Data)
+	if skippedElements[tag] || (hc.boilerplate && isBoilerplate(n)) {
+		return
+	}
+
+	if isNote(n) {
+		if id := attr(n, "id"); id != "" {
+			hc.notes[id] = noteText(n)
+		}
+		return
+	}
+
+	if id := attr(n, "id"); id != "" {
+		if _, ok := hc.anchors[id]; !ok {
+			hc.anchors[id] = len(hc.blocks)
+		}
+		if hc.pendingID == "" && strings.TrimSpace(hc.inline.String()) == "" {
+			hc.pendingID = id
+		}
+	}
+
+	switch tag {
+	case "br":
+		hc.inline.WriteByte(' ')
+		return
+	case "img":
+		return
+	case "a":
+		if key, target, ok := noteRef(n); ok {
+			hc.refs = append(hc.refs, noteReference{Block: len(hc.blocks), Key: key, Target: target})
+			hc.inline.WriteString("[" + key + "]")
+			return
+		}
+	}
+
+	if !blockElements[tag] {
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			hc.walk(child)
+		}
+		return
+	}
+
+	hc.flush()
+	block := Block{Kind: BlockParagraph}
+	switch {
+	case len(tag) == 2 && tag[0] == 'h' && tag[1] >= '1' && tag[1] <= '6':
+		block = Block{Kind: BlockHeading, Level: int(tag[1] - '0')}
+	case tag == "li" || tag == "dt" || tag == "dd":
+		block = Block{Kind: BlockListItem}
+	}
+	hc.kinds = append(hc.kinds, block)
+
+	start := len(hc.blocks)
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		hc.walk(child)
+	}
+
+	hc.flush()
+	hc.kinds = hc.kinds[:len(hc.kinds)-1]
+	if len(hc.blocks) == start+1 && hc.blocks[start].Element == nil {
+		hc.blocks[start].Element = n
+	}
+}
+
+func (hc *htmlConverter) flush() {
+	text := CleanText(hc.inline.String())
+	hc.inline.Reset()
+	if text == "" {
+		return
+	}
+
+	block := Block{Kind: BlockParagraph}
+	if len(hc.kinds) > 0 {
+		block = hc.kinds[len(hc.kinds)-1]
+	}
+	block.Text = text
+	block.ID = hc.pendingID
+	hc.pendingID = ""
+	hc.blocks = append(hc.blocks, block)
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if strings.EqualFold(a.Key, key) || (a.Namespace != "" && strings.EqualFold(a.Namespace+":"+a.Key, key)) {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func semanticType(n *html.Node) string {
+	return strings.ToLower(attr(n, "epub:type") + " " + attr(n, "role"))
+}
+
+func isNote(n *html.Node) bool {
+	kind := semanticType(n)
+	if strings.Contains(kind, "noteref") || strings.Contains(kind, "backlink") {
+		return false
+	}
+	return strings.Contains(kind, "footnote") || strings.Contains(kind, "endnote") || strings.Contains(kind, "rearnote")
+}
+
+func noteRef(n *html.Node) (string, string, bool) {
+	href := attr(n, "href")
+	hash := strings.IndexByte(href, '#')
+	if hash < 0 {
+		return "", "", false
+	}
+
+	text := strings.TrimSpace(textContent(n))
+	key := preprocess.NormalizeMarker(strings.Trim(text, "()"))
+	if key == "" || len(key) > 3 {
+		return "", "", false
+	}
+
+	if strings.Contains(semanticType(n), "noteref") {
+		return key, href, true
+	}
+	if _, err := strconv.Atoi(key); err != nil {
+		return "", "", false
+	}
+
+	for p := n; p != nil; p = p.Parent {
+		if p.Type == html.ElementNode && strings.EqualFold(p.Data, "sup") {
+			return key, href, true
+		}
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode && strings.EqualFold(child.Data, "sup") {
+			return key, href, true
+		}
+	}
+	return "", "", false
+}
+
+func noteText(n *html.Node) string {
+	hc := newHTMLConverter()
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode && strings.Contains(semanticType(child), "backlink") {
+			continue
+		}
+		hc.walk(child)
+	}
+	hc.flush()
+
+	parts := make([]string, 0, len(hc.blocks))
+	for _, b := range hc.blocks {
+		parts = append(parts, b.Text)
+	}
+	return cleanNote(strings.Join(parts, " "))
+}
+
+func cleanNote(text string) string {
+	text = noteLeadPattern.ReplaceAllString(text, "")
+	text = noteTrailPattern.ReplaceAllString(text, "")
+	return strings.TrimSpace(text)
+}
+
+func textContent(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+
+	var b strings.Builder
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		b.WriteString(textContent(child))
+	}
+	return b.String()
+}
+
+func findElement(n *html.Node, match func(*html.Node) bool) *html.Node {
+	if n.Type == html.ElementNode && match(n) {
+		return n
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if found := findElement(child, match); found != nil {
+			return found
+		}
+	}
+	return nil
+}
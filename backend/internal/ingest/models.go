@@ -0,0 +1,149 @@
+package ingest
+
+import (
+	"archive/zip"
+	"errors"
+	"regexp"
+	"strings"
+
+	"cadence/internal/preprocess"
+
+	"golang.org/x/net/html"
+)
+
+type Format string
+
+const (
+	FormatEPUB			Format = "epub"
+	FormatText			Format = "txt"
+	FormatMarkdown		Format = "md"
+	FormatHTML			Format = "html"
+	FormatDOCX			Format = "docx"
+	FormatODT			Format = "odt"
+	FormatPDF			Format = "pdf"
+)
+
+type BlockKind string
+
+const (
+	BlockHeading		BlockKind = "heading"
+	BlockParagraph		BlockKind = "paragraph"
+	BlockListItem		BlockKind = "list_item"
+
+	blockSeparator		BlockKind = "separator"
+)
+
+const (
+	// maxEntrySize bounds a single markup entry of an archive and
+	// maxImageSize a cover image; maxArchiveSize bounds everything read
+	// from one archive, so many entries just under the limit still fail.
+	maxEntrySize		= 8 << 20
+	maxImageSize		= 32 << 20
+	maxArchiveSize		= 256 << 20
+)
+
+const (
+	// maxStreamSize bounds a single decoded PDF stream.
+	maxStreamSize		= 64 << 20
+
+	// RC4 key lengths in bytes the standard security handler allows.
+	minKeyLength		= 5
+	maxKeyLength		= 16
+)
+
+var (
+	ErrUnsupportedFormat	= errors.New("unsupported document format")
+	ErrInvalidDocument		= errors.New("invalid document")
+	ErrEmptyDocument		= errors.New("document contains no readable text")
+	ErrEncrypted			= errors.New("document is DRM protected")
+	ErrImageOnly			= errors.New("document contains only scanned images; OCR is required")
+)
+
+type Document struct {
+	Format			Format				`json:"format"`
+	Title			string				`json:"title"`
+	Authors			[]string			`json:"authors"`
+	Language		string				`json:"language,omitempty"`
+	Cover			[]byte				`json:"-"`
+	CoverType		string				`json:"cover_type,omitempty"`
+	Properties		map[string]string	`json:"properties,omitempty"`
+	Chapters		[]Chapter			`json:"chapters"`
+}
+
+type Chapter struct {
+	Title			string				`json:"title"`
+	Blocks			[]Block				`json:"blocks"`
+	Notes			preprocess.Notes	`json:"notes,omitempty"`
+}
+
+type Block struct {
+	Kind			BlockKind			`json:"kind"`
+	Level			int					`json:"level,omitempty"`
+	Text			string				`json:"text"`
+	ID				string				`json:"id,omitempty"`
+	// Element is the markup element the block was read from, set when the
+	// block holds all of the element's text.
+	Element			*html.Node			`json:"-"`
+}
+
+type zipArchive struct {
+	files			map[string]*zip.File
+	total			int64
+}
+
+type tocEntry struct {
+	Title			string
+	Href			string
+	Depth			int
+}
+
+var (
+	whitespacePattern	= regexp.MustCompile(`\s+`)
+	pdfObjectPattern	= regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+	pdfTrailerPattern	= regexp.MustCompile(`\btrailer\b`)
+	pageNumberPattern	= regexp.MustCompile(`^(?i)(?:page\s+)?(?:\d{1,4}|[ivxlc]{1,7})(?:\s+of\s+\d{1,4})?$|^[-–—]\s*\d{1,4}\s*[-–—]$`)
+	selfClosingPattern	= regexp.MustCompile(`<([a-zA-Z][\w:-]*)(\s[^<>]*?)?\s*/>`)
+	noteLeadPattern		= regexp.MustCompile(`^\s*(?:\[?\^?(?:\d{1,3}|[a-z])[\].)]\s*|\d{1,3}\s+|[↩↑^]\s*)+`)
+	noteTrailPattern	= regexp.MustCompile(`\s*(?:↩|↑|\^|back)\s*$`)
+	metaCharsetPattern	= regexp.MustCompile(`(?i)<meta[^>]+charset\s*=\s*["']?([\w.:-]+)`)
+
+	separatorPattern		= regexp.MustCompile(`^(?:[*#=~_•·-]\s*){3,}$`)
+	partHeadingPattern		= regexp.MustCompile(`(?i)^(?:part|book|volume)\s+(?:\d+|[ivxlcdm]+|one|two|three|four|five|six|seven|eight|nine|ten|first|second|third)\b[.:]?(?:\s+\S.*)?$`)
+	chapterHeadingPattern	= regexp.MustCompile(`(?i)^(?:(?:chapter|chap\.?|letter|section)\s+(?:\d+|[ivxlcdm]+|[a-z]+(?:[- ][a-z]+)?)\b[.:]?(?:\s+\S.*)?|prologue|epilogue|preface|foreword|introduction|afterword|interlude|appendix(?:\s+\w+)?)[.:]?$`)
+	romanHeadingPattern		= regexp.MustCompile(`^(?:[IVXLC]{1,7}|\d{1,3})\.?$`)
+	gutenbergStartPattern	= regexp.MustCompile(`^\*{3}\s*START OF (?:THE|THIS) PROJECT GUTENBERG`)
+	gutenbergEndPattern		= regexp.MustCompile(`^\*{3}\s*END OF (?:THE|THIS) PROJECT GUTENBERG`)
+
+	atxHeadingPattern		= regexp.MustCompile(`^(#{1,6})\s+(.+?)\s*#*$`)
+	setextPattern			= regexp.MustCompile(`^(?:=+|-+)$`)
+	markdownRulePattern		= regexp.MustCompile(`^(?:(?:\*\s*){3,}|(?:-\s*){3,}|(?:_\s*){3,})$`)
+	listItemPattern			= regexp.MustCompile(`^(?:[-*+]|\d{1,3}[.)])\s+`)
+	tableRulePattern		= regexp.MustCompile(`^\|?[\s:|-]+\|?$`)
+	markdownImagePattern	= regexp.MustCompile(`!\[[^\]]*\]\([^)]*\)`)
+	markdownLinkPattern		= regexp.MustCompile(`\[([^\]^][^\]]*)\]\([^)]*\)`)
+	markdownTagPattern		= regexp.MustCompile(`</?[a-zA-Z][^>]*>`)
+	markdownEmphasisPattern	= regexp.MustCompile(`(?:\*{1,3}|\b_{1,3}|~~)([^*_~]+?)(?:\*{1,3}|_{1,3}\b|~~)`)
+
+	boilerplatePattern		= regexp.MustCompile(`(?i)(?:^|[\s_-])(?:nav|navbar|navigation|menu|sidebar|breadcrumbs?|cookie|banner|footer|share|sharing|social|comments?|advert|ads|promo|related|subscribe|newsletter|skip-link|masthead|toolbar|pagination)(?:$|[\s_-])`)
+)
+
+var invisibleReplacer = strings.NewReplacer("\u00ad", "", "\u200b", "", "\ufeff", "", "\u00a0", " ", "\u2009", " ", "\u202f", " ")
+
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+	"input": true, "link": true, "meta": true, "source": true, "track": true, "wbr": true,
+}
+
+var blockElements = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true, "body": true, "dd": true,
+	"div": true, "dl": true, "dt": true, "figcaption": true, "figure": true, "footer": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true, "header": true,
+	"hr": true, "li": true, "main": true, "ol": true, "p": true, "pre": true, "section": true,
+	"table": true, "td": true, "th": true, "tr": true, "ul": true, "caption": true,
+}
+
+var skippedElements = map[string]bool{
+	"head": true, "script": true, "style": true, "noscript": true, "template": true,
+	"svg": true, "math": true, "nav": true, "iframe": true, "object": true, "button": true,
+	"form": true, "select": true, "textarea": true,
+}
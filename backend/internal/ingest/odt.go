@@ -0,0 +1,169 @@
+package ingest
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var odtSkipped = map[string]bool{
+	"tracked-changes":		true,
+	"annotation":			true,
+	"sequence-decls":		true,
+	"table-of-content":		true,
+	"alphabetical-index":	true,
+	"illustration-index":	true,
+	"frame":				true,
+}
+
+func ParseODT(content []byte) (*Document, error) {
+	archive, err := openArchive(content)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := archive.read("content.xml")
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &Document{Properties: make(map[string]string)}
+	if archive.has("meta.xml") {
+		var meta struct {
+			Props		officeProperties	`xml:"meta"`
+		}
+		if err := archive.decode("meta.xml", &meta); err == nil {
+			meta.Props.apply(doc)
+		}
+	}
+
+	blocks, refs, notes, err := readODTBody(body)
+	if err != nil {
+		return nil, err
+	}
+
+	title, chapters, starts := splitChapters(blocks, false)
+	if doc.Title == "" {
+		doc.Title = title
+	}
+	assignNotes(chapters, starts, refs, notes)
+	doc.Chapters = chapters
+	return doc, nil
+}
+
+func readODTBody(content []byte) ([]Block, []noteReference, map[string]string, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	decoder.Strict = false
+
+	var blocks []Block
+	var refs []noteReference
+	notes := make(map[string]string)
+
+	var para *officeParagraph
+	var note, citation strings.Builder
+	inNote, inCitation := false, false
+	listDepth, skip := 0, 0
+
+	write := func(s string) {
+		switch {
+		case inCitation:
+			citation.WriteString(s)
+		case inNote:
+			note.WriteString(s)
+		case para != nil:
+			para.text.WriteString(s)
+		}
+	}
+
+	for {
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, nil, errors.Join(ErrInvalidDocument, fmt.Errorf("failed to parse content: %w", err))
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if skip > 0 {
+				skip++
+				continue
+			}
+			switch t.Name.Local {
+			case "list-item", "list-header":
+				listDepth++
+			case "h", "p":
+				if inNote {
+					note.WriteByte(' ')
+					continue
+				}
+				blocks = appendOfficeParagraph(blocks, para, nil)
+				para = &officeParagraph{level: -1, list: listDepth > 0}
+				if t.Name.Local == "h" {
+					para.level = 1
+					if level, err := strconv.Atoi(xmlAttr(t, "outline-level")); err == nil && level > 0 {
+						para.level = level
+					}
+				}
+			case "note":
+				inNote = true
+				note.Reset()
+				citation.Reset()
+			case "note-citation":
+				inCitation = true
+			case "s":
+				count := 1
+				if c, err := strconv.Atoi(xmlAttr(t, "c")); err == nil && c > 0 {
+					count = c
+				}
+				write(strings.Repeat(" ", count))
+			case "tab", "line-break":
+				write(" ")
+			default:
+				if odtSkipped[t.Name.Local] {
+					skip = 1
+				}
+			}
+		case xml.EndElement:
+			if skip > 0 {
+				skip--
+				continue
+			}
+			switch t.Name.Local {
+			case "list-item", "list-header":
+				listDepth--
+			case "h", "p":
+				if inNote {
+					continue
+				}
+				blocks = appendOfficeParagraph(blocks, para, nil)
+				para = nil
+			case "note-citation":
+				inCitation = false
+			case "note":
+				inNote = false
+				key := CleanText(citation.String())
+				if key == "" {
+					key = strconv.Itoa(len(refs) + 1)
+				}
+				target := strconv.Itoa(len(refs))
+				refs = append(refs, noteReference{Block: len(blocks), Key: key, Target: target})
+				notes[target] = CleanText(note.String())
+				if para != nil {
+					para.text.WriteString("[" + key + "]")
+				}
+			}
+		case xml.CharData:
+			if skip == 0 {
+				write(string(t))
+			}
+		}
+	}
+
+	return appendOfficeParagraph(blocks, para, nil), refs, notes, nil
+}
@@ -0,0 +1,655 @@
+package ingest
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type pdfPage struct {
+	dict		pdfDict
+	resources	pdfDict
+}
+
+type pdfLine struct {
+	text		string
+	x			float64
+	x2			float64
+	y			float64
+	size		float64
+	page		int
+	column		int
+}
+
+type pdfPosition struct {
+	page		int
+	y			float64
+}
+
+type pdfOutlineEntry struct {
+	title		string
+	page		int
+	top			float64
+	depth		int
+}
+
+func ParsePDF(content []byte) (*Document, error) {
+	f, err := openPDF(content)
+	if err != nil {
+		return nil, err
+	}
+
+	root := f.dict(f.trailer["Root"])
+	pages, pageIndex := f.pages(root)
+	if len(pages) == 0 {
+		return nil, errors.Join(ErrInvalidDocument, errors.New("pdf has no pages"))
+	}
+
+	reader := &pdfPageReader{file: f, fonts: make(map[*pdfStream]*pdfFont), dictFonts: make(map[string]*pdfFont)}
+	lines := make([][]pdfLine, len(pages))
+	chars := 0
+	for i, p := range pages {
+		reader.runs = reader.runs[:0]
+		reader.readContent(f.contents(p.dict), p.resources, identityMatrix)
+		for _, run := range reader.runs {
+			chars += utf8.RuneCountInString(strings.TrimSpace(run.text))
+		}
+		lines[i] = layoutPage(reader.runs, i)
+	}
+
+	if f.err != nil {
+		return nil, f.err
+	}
+	if chars < 10*len(pages) && reader.images > 0 {
+		return nil, ErrImageOnly
+	}
+
+	removeRunningLines(lines)
+	blocks, positions := pdfBlocks(lines)
+
+	doc := &Document{Properties: make(map[string]string)}
+	f.metadata(root, doc)
+
+	if outline := f.outline(root, pageIndex); len(outline) > 0 {
+		doc.Chapters = chaptersFromOutline(blocks, positions, outline)
+	} else {
+		title, chapters, _ := splitChapters(blocks, false)
+		if doc.Title == "" {
+			doc.Title = title
+		}
+		doc.Chapters = chapters
+	}
+	return doc, nil
+}
+
+func (f *pdfFile) pages(root pdfDict) ([]pdfPage, map[int]int) {
+	var pages []pdfPage
+	index := make(map[int]int)
+	visited := make(map[int]bool)
+
+	var visit func(v interface{}, resources pdfDict, depth int)
+	visit = func(v interface{}, resources pdfDict, depth int) {
+		if ref, ok := v.(pdfRef); ok {
+			if visited[ref.num] {
+				return
+			}
+			visited[ref.num] = true
+		}
+
+		node := f.dict(v)
+		if node == nil || depth > 64 {
+			return
+		}
+		if res := f.dict(node["Resources"]); res != nil {
+			resources = res
+		}
+
+		if kids := f.array(node["Kids"]); kids != nil && node["Type"] != pdfName("Page") {
+			for _, kid := range kids {
+				visit(kid, resources, depth+1)
+			}
+			return
+		}
+
+		if ref, ok := v.(pdfRef); ok {
+			index[ref.num] = len(pages)
+		}
+		pages = append(pages, pdfPage{dict: node, resources: resources})
+	}
+
+	visit(root["Pages"], nil, 0)
+	return pages, index
+}
+
+func (f *pdfFile) contents(page pdfDict) []byte {
+	var streams []interface{}
+	switch v := f.resolve(page["Contents"]).(type) {
+	case *pdfStream:
+		streams = append(streams, v)
+	case pdfArray:
+		streams = v
+	}
+
+	var buf bytes.Buffer
+	for _, s := range streams {
+		if stream, ok := f.resolve(s).(*pdfStream); ok {
+			buf.Write(f.decodeStream(stream))
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.Bytes()
+}
+
+func (f *pdfFile) metadata(root pdfDict, doc *Document) {
+	if lang, ok := f.resolve(root["Lang"]).(pdfString); ok {
+		doc.Language = decodePDFText(lang)
+	}
+
+	info := f.dict(f.trailer["Info"])
+	text := func(key pdfName) string {
+		if s, ok := f.resolve(info[key]).(pdfString); ok {
+			return CleanText(decodePDFText(s))
+		}
+		return ""
+	}
+
+	doc.Title = text("Title")
+	if author := text("Author"); author != "" {
+		for _, name := range strings.Split(author, ";") {
+			if name = strings.TrimSpace(name); name != "" {
+				doc.Authors = append(doc.Authors, name)
+			}
+		}
+	}
+	for key, name := range map[string]pdfName{"subject": "Subject", "keywords": "Keywords", "created": "CreationDate", "producer": "Producer"} {
+		if value := text(name); value != "" {
+			doc.Properties[key] = value
+		}
+	}
+}
+
+func (f *pdfFile) outline(root pdfDict, pageIndex map[int]int) []pdfOutlineEntry {
+	outlines := f.dict(root["Outlines"])
+	if outlines == nil {
+		return nil
+	}
+
+	var entries []pdfOutlineEntry
+	visited := make(map[int]bool)
+	var visit func(v interface{}, depth int)
+	visit = func(v interface{}, depth int) {
+		for v != nil && depth < 16 {
+			ref, ok := v.(pdfRef)
+			if !ok || visited[ref.num] {
+				return
+			}
+			visited[ref.num] = true
+
+			item := f.dict(v)
+			if item == nil {
+				return
+			}
+
+			dest := item["Dest"]
+			if action := f.dict(item["A"]); dest == nil && action != nil && action["S"] == pdfName("GoTo") {
+				dest = action["D"]
+			}
+			if page, top, ok := f.destination(root, dest, pageIndex); ok {
+				title, _ := f.resolve(item["Title"]).(pdfString)
+				entries = append(entries, pdfOutlineEntry{title: CleanText(decodePDFText(title)), page: page, top: top, depth: depth})
+			}
+
+			visit(item["First"], depth+1)
+			v = item["Next"]
+		}
+	}
+	visit(outlines["First"], 0)
+
+	top := 0
+	count := 0
+	for _, e := range entries {
+		if e.depth == 0 {
+			count++
+		}
+	}
+	if count == 1 {
+		top = 1
+	}
+
+	var selected []pdfOutlineEntry
+	for _, e := range entries {
+		if e.depth == top {
+			selected = append(selected, e)
+		}
+	}
+	if len(selected) < 2 {
+		return nil
+	}
+
+	sort.SliceStable(selected, func(i, j int) bool {
+		if selected[i].page != selected[j].page {
+			return selected[i].page < selected[j].page
+		}
+		return selected[i].top > selected[j].top
+	})
+	return selected
+}
+
+func (f *pdfFile) destination(root pdfDict, dest interface{}, pageIndex map[int]int) (int, float64, bool) {
+	dest = f.resolve(dest)
+	switch d := dest.(type) {
+	case pdfName:
+		dest = f.namedDestination(root, string(d))
+	case pdfString:
+		dest = f.namedDestination(root, string(d))
+	}
+	if dict := f.dict(dest); dict != nil {
+		dest = dict["D"]
+	}
+
+	arr := f.array(dest)
+	if len(arr) == 0 {
+		return 0, 0, false
+	}
+
+	page := -1
+	switch p := arr[0].(type) {
+	case pdfRef:
+		if idx, ok := pageIndex[p.num]; ok {
+			page = idx
+		}
+	case float64:
+		page = int(p)
+	}
+	if page < 0 {
+		return 0, 0, false
+	}
+
+	top := math.Inf(1)
+	if len(arr) > 1 {
+		switch arr[1] {
+		case pdfName("XYZ"):
+			if len(arr) > 3 {
+				if v, ok := f.resolve(arr[3]).(float64); ok {
+					top = v
+				}
+			}
+		case pdfName("FitH"), pdfName("FitBH"):
+			if len(arr) > 2 {
+				if v, ok := f.resolve(arr[2]).(float64); ok {
+					top = v
+				}
+			}
+		}
+	}
+	return page, top, true
+}
+
+func (f *pdfFile) namedDestination(root pdfDict, name string) interface{} {
+	if dests := f.dict(root["Dests"]); dests != nil {
+		if v, ok := dests[pdfName(name)]; ok {
+			return f.resolve(v)
+		}
+	}
+
+	names := f.dict(root["Names"])
+	if names == nil {
+		return nil
+	}
+
+	var search func(v interface{}, depth int) interface{}
+	search = func(v interface{}, depth int) interface{} {
+		node := f.dict(v)
+		if node == nil || depth > 32 {
+			return nil
+		}
+		pairs := f.array(node["Names"])
+		for i := 0; i+1 < len(pairs); i += 2 {
+			if key, ok := f.resolve(pairs[i]).(pdfString); ok && string(key) == name {
+				return f.resolve(pairs[i+1])
+			}
+		}
+		for _, kid := range f.array(node["Kids"]) {
+			if found := search(kid, depth+1); found != nil {
+				return found
+			}
+		}
+		return nil
+	}
+	return search(names["Dests"], 0)
+}
+
+func layoutPage(runs []pdfRun, page int) []pdfLine {
+	var lines []pdfLine
+	for col, column := range splitColumns(runs) {
+		sort.SliceStable(column, func(i, j int) bool {
+			if math.Abs(column[i].y-column[j].y) > 0.4*math.Max(column[i].size, column[j].size) {
+				return column[i].y > column[j].y
+			}
+			return column[i].x < column[j].x
+		})
+
+		var current []pdfRun
+		flush := func() {
+			if len(current) > 0 {
+				lines = append(lines, joinRuns(current, page, col))
+			}
+			current = current[:0]
+		}
+
+		for _, run := range column {
+			if len(current) > 0 && math.Abs(run.y-current[0].y) > 0.4*math.Max(run.size, current[0].size) {
+				flush()
+			}
+			current = append(current, run)
+		}
+		flush()
+	}
+	return lines
+}
+
+func splitColumns(runs []pdfRun) [][]pdfRun {
+	if len(runs) == 0 {
+		return nil
+	}
+
+	minX, maxX := math.Inf(1), math.Inf(-1)
+	for _, r := range runs {
+		minX, maxX = math.Min(minX, r.x), math.Max(maxX, r.x2)
+	}
+	width := maxX - minX
+	if width < 200 {
+		return [][]pdfRun{runs}
+	}
+
+	const bin = 2.0
+	covered := make([]bool, int(width/bin)+2)
+	for _, r := range runs {
+		if r.x2-r.x > 0.6*width {
+			continue
+		}
+		for i := int((r.x - minX) / bin); i <= int((r.x2-minX)/bin) && i < len(covered); i++ {
+			if i >= 0 {
+				covered[i] = true
+			}
+		}
+	}
+
+	bestStart, bestLen := -1, 0
+	for i := 0; i < len(covered); {
+		if covered[i] {
+			i++
+			continue
+		}
+		j := i
+		for j < len(covered) && !covered[j] {
+			j++
+		}
+		center := minX + float64(i+j)/2*bin
+		if j-i > bestLen && center > minX+0.3*width && center < minX+0.7*width {
+			bestStart, bestLen = i, j-i
+		}
+		i = j
+	}
+	if bestStart < 0 || float64(bestLen)*bin < 10 {
+		return [][]pdfRun{runs}
+	}
+
+	gutter := minX + (float64(bestStart)+float64(bestLen)/2)*bin
+	var left, right []pdfRun
+	leftChars, rightChars := 0, 0
+	for _, r := range runs {
+		if r.x >= gutter {
+			right = append(right, r)
+			rightChars += len(r.text)
+		} else {
+			left = append(left, r)
+			leftChars += len(r.text)
+		}
+	}
+	total := leftChars + rightChars
+	if leftChars*5 < total || rightChars*5 < total {
+		return [][]pdfRun{runs}
+	}
+	return [][]pdfRun{left, right}
+}
+
+func joinRuns(runs []pdfRun, page, column int) pdfLine {
+	line := pdfLine{x: runs[0].x, x2: runs[0].x2, y: runs[0].y, page: page, column: column}
+	var b strings.Builder
+	var prev *pdfRun
+
+	for i := range runs {
+		r := &runs[i]
+		if prev != nil {
+			if r.text == prev.text && math.Abs(r.x-prev.x) < r.size*0.5 {
+				continue
+			}
+			if r.x-prev.x2 > 0.15*r.size && !strings.HasSuffix(prev.text, " ") && !strings.HasPrefix(r.text, " ") {
+				b.WriteByte(' ')
+			}
+		}
+		b.WriteString(r.text)
+		line.x2 = math.Max(line.x2, r.x2)
+		line.size = math.Max(line.size, r.size)
+		prev = r
+	}
+
+	line.text = CleanText(b.String())
+	return line
+}
+
+func removeRunningLines(pages [][]pdfLine) {
+	counts := make(map[string]int)
+	candidates := make([]map[int]string, len(pages))
+
+	for p, lines := range pages {
+		candidates[p] = make(map[int]string)
+		order := make([]int, len(lines))
+		for i := range order {
+			order[i] = i
+		}
+		sort.Slice(order, func(i, j int) bool { return lines[order[i]].y > lines[order[j]].y })
+
+		for k, idx := range order {
+			if k >= 2 && k < len(order)-2 {
+				continue
+			}
+			key := runningKey(lines[idx].text)
+			if _, seen := candidates[p][idx]; !seen {
+				candidates[p][idx] = key
+			}
+		}
+
+		seen := make(map[string]bool)
+		for _, key := range candidates[p] {
+			if !seen[key] {
+				seen[key] = true
+				counts[key]++
+			}
+		}
+	}
+
+	for p, lines := range pages {
+		kept := lines[:0]
+		for i, line := range lines {
+			key, candidate := candidates[p][i]
+			repeated := counts[key] >= 3 && counts[key]*5 >= len(pages)*2
+			if candidate && (repeated || pageNumberPattern.MatchString(line.text)) {
+				continue
+			}
+			kept = append(kept, line)
+		}
+		pages[p] = kept
+	}
+}
+
+func runningKey(text string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.IsDigit(r):
+			b.WriteByte('#')
+		case unicode.IsSpace(r):
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func pdfBlocks(pages [][]pdfLine) ([]Block, []pdfPosition) {
+	var sizes []float64
+	for _, lines := range pages {
+		for _, line := range lines {
+			for range utf8.RuneCountInString(line.text) / 10 {
+				sizes = append(sizes, line.size)
+			}
+		}
+	}
+	body := 10.0
+	if len(sizes) > 0 {
+		sort.Float64s(sizes)
+		body = sizes[len(sizes)/2]
+	}
+
+	var blocks []Block
+	var positions []pdfPosition
+	var text strings.Builder
+	var prev *pdfLine
+	kind := BlockParagraph
+	level := 0
+
+	flush := func() {
+		if text.Len() > 0 {
+			blocks = append(blocks, Block{Kind: kind, Level: level, Text: text.String()})
+		}
+		text.Reset()
+	}
+
+	for p, lines := range pages {
+		spacing := lineSpacing(lines)
+		right := make(map[int]float64)
+		for _, line := range lines {
+			right[line.column] = math.Max(right[line.column], line.x2)
+		}
+
+		for i := range lines {
+			line := &lines[i]
+			if line.text == "" {
+				continue
+			}
+
+			lineKind, lineLevel := BlockParagraph, 0
+			if level, ok := headingLevel(line.text); ok {
+				lineKind, lineLevel = BlockHeading, level
+			} else if line.size >= body*1.2 && len(line.text) < 120 {
+				lineKind, lineLevel = BlockHeading, 1
+				if line.size < body*1.6 {
+					lineLevel = 2
+				}
+			}
+
+			if prev == nil || text.Len() == 0 || lineKind != kind || lineLevel != level || startsParagraph(prev, line, spacing, right[prev.column]) {
+				flush()
+				kind, level = lineKind, lineLevel
+				positions = append(positions, pdfPosition{page: p, y: line.y})
+				text.WriteString(line.text)
+				prev = line
+				continue
+			}
+
+			current := text.String()
+			switch {
+			case strings.HasSuffix(current, "­"):
+				text.Reset()
+				text.WriteString(strings.TrimSuffix(current, "­") + line.text)
+			case strings.HasSuffix(current, "-") && dehyphenate(current, line.text):
+				text.Reset()
+				text.WriteString(strings.TrimSuffix(current, "-") + line.text)
+			default:
+				text.WriteByte(' ')
+				text.WriteString(line.text)
+			}
+			prev = line
+		}
+	}
+	flush()
+
+	return blocks, positions
+}
+
+func lineSpacing(lines []pdfLine) float64 {
+	var gaps []float64
+	for i := 1; i < len(lines); i++ {
+		if lines[i].column != lines[i-1].column {
+			continue
+		}
+		if dy := lines[i-1].y - lines[i].y; dy > 0 && dy < 4*lines[i].size {
+			gaps = append(gaps, dy)
+		}
+	}
+	if len(gaps) == 0 {
+		return 0
+	}
+	sort.Float64s(gaps)
+	return gaps[len(gaps)/2]
+}
+
+func startsParagraph(prev, line *pdfLine, spacing, right float64) bool {
+	ended := endsSentence(prev.text)
+	if prev.page != line.page || prev.column != line.column {
+		return ended
+	}
+
+	dy := prev.y - line.y
+	switch {
+	case dy < 0:
+		return ended
+	case spacing > 0 && dy > spacing*1.6:
+		return true
+	case math.Abs(line.size-prev.size) > prev.size*0.15:
+		return true
+	case line.x > prev.x+line.size*0.8:
+		return true
+	case ended && prev.x2 < right-line.size*3:
+		return true
+	}
+	return false
+}
+
+func endsSentence(text string) bool {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return true
+	}
+	r, _ := utf8.DecodeLastRuneInString(text)
+	return strings.ContainsRune(`.!?:"”’)…`, r)
+}
+
+func dehyphenate(current, next string) bool {
+	before, _ := utf8.DecodeLastRuneInString(strings.TrimSuffix(current, "-"))
+	after, _ := utf8.DecodeRuneInString(next)
+	return unicode.IsLetter(before) && unicode.IsLower(after)
+}
+
+func chaptersFromOutline(blocks []Block, positions []pdfPosition, outline []pdfOutlineEntry) []Chapter {
+	var chapters []Chapter
+	next := 0
+	for i, b := range blocks {
+		pos := positions[i]
+		for next < len(outline) && (outline[next].page < pos.page || (outline[next].page == pos.page && pos.y <= outline[next].top+2)) {
+			chapters = append(chapters, Chapter{Title: outline[next].title})
+			next++
+		}
+		if len(chapters) == 0 {
+			chapters = append(chapters, Chapter{})
+		}
+		chapters[len(chapters)-1].Blocks = append(chapters[len(chapters)-1].Blocks, b)
+	}
+	return chapters
+}
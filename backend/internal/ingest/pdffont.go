@@ -0,0 +1,554 @@
+package ingest
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+type pdfFont struct {
+	codeBytes		int
+	toUnicode		map[int]string
+	encoding		map[int]string
+	widths			map[int]float64
+	defaultWidth	float64
+	scale			float64
+}
+
+type pdfGlyph struct {
+	text		string
+	width		float64
+	space		bool
+}
+
+type pdfMatrix [6]float64
+
+type pdfRun struct {
+	text		string
+	x			float64
+	y			float64
+	x2			float64
+	size		float64
+}
+
+type pdfTextState struct {
+	font		*pdfFont
+	size		float64
+	charSpace	float64
+	wordSpace	float64
+	scale		float64
+	leading		float64
+	rise		float64
+}
+
+type pdfGraphicsState struct {
+	ctm			pdfMatrix
+	text		pdfTextState
+}
+
+type pdfPageReader struct {
+	file		*pdfFile
+	fonts		map[*pdfStream]*pdfFont
+	dictFonts	map[string]*pdfFont
+	runs		[]pdfRun
+	images		int
+	depth		int
+}
+
+var identityMatrix = pdfMatrix{1, 0, 0, 1, 0, 0}
+
+var glyphNames = map[string]string{
+	"space": " ", "exclam": "!", "quotedbl": "\"", "numbersign": "#", "dollar": "$", "percent": "%",
+	"ampersand": "&", "quotesingle": "'", "quoteright": "’", "quoteleft": "‘", "quotedblleft": "“",
+	"quotedblright": "”", "parenleft": "(", "parenright": ")", "asterisk": "*", "plus": "+",
+	"comma": ",", "hyphen": "-", "period": ".", "slash": "/", "colon": ":", "semicolon": ";",
+	"less": "<", "equal": "=", "greater": ">", "question": "?", "at": "@", "bracketleft": "[",
+	"backslash": "\\", "bracketright": "]", "underscore": "_", "grave": "`", "braceleft": "{",
+	"bar": "|", "braceright": "}", "asciitilde": "~", "endash": "–", "emdash": "—", "bullet": "•",
+	"ellipsis": "…", "fi": "fi", "fl": "fl", "ff": "ff", "ffi": "ffi", "ffl": "ffl", "dagger": "†",
+	"daggerdbl": "‡", "section": "§", "paragraph": "¶", "copyright": "©", "registered": "®",
+	"trademark": "™", "degree": "°", "minus": "−", "quotesinglbase": "‚", "quotedblbase": "„",
+	"guillemotleft": "«", "guillemotright": "»", "exclamdown": "¡", "questiondown": "¿",
+	"zero": "0", "one": "1", "two": "2", "three": "3", "four": "4", "five": "5", "six": "6",
+	"seven": "7", "eight": "8", "nine": "9", "nbspace": " ", "sfthyphen": "-",
+	"eacute": "é", "egrave": "è", "ecircumflex": "ê", "edieresis": "ë", "aacute": "á", "agrave": "à",
+	"acircumflex": "â", "adieresis": "ä", "atilde": "ã", "aring": "å", "ccedilla": "ç", "iacute": "í",
+	"igrave": "ì", "icircumflex": "î", "idieresis": "ï", "ntilde": "ñ", "oacute": "ó", "ograve": "ò",
+	"ocircumflex": "ô", "odieresis": "ö", "otilde": "õ", "oslash": "ø", "uacute": "ú", "ugrave": "ù",
+	"ucircumflex": "û", "udieresis": "ü", "germandbls": "ß", "ae": "æ", "oe": "œ", "AE": "Æ", "OE": "Œ",
+}
+
+func (m pdfMatrix) multiply(n pdfMatrix) pdfMatrix {
+	return pdfMatrix{
+		m[0]*n[0] + m[1]*n[2],
+		m[0]*n[1] + m[1]*n[3],
+		m[2]*n[0] + m[3]*n[2],
+		m[2]*n[1] + m[3]*n[3],
+		m[4]*n[0] + m[5]*n[2] + n[4],
+		m[4]*n[1] + m[5]*n[3] + n[5],
+	}
+}
+
+func (m pdfMatrix) apply(x, y float64) (float64, float64) {
+	return x*m[0] + y*m[2] + m[4], x*m[1] + y*m[3] + m[5]
+}
+
+func (r *pdfPageReader) font(v interface{}) *pdfFont {
+	key := ""
+	if ref, ok := v.(pdfRef); ok {
+		key = strconv.Itoa(ref.num)
+		if font, ok := r.dictFonts[key]; ok {
+			return font
+		}
+	}
+
+	dict := r.file.dict(v)
+	font := &pdfFont{codeBytes: 1, defaultWidth: 0.5, scale: 0.001, widths: make(map[int]float64)}
+	if dict == nil {
+		return font
+	}
+
+	subtype := r.file.resolve(dict["Subtype"])
+	if subtype == pdfName("Type0") {
+		font.codeBytes = 2
+		if descendants := r.file.array(dict["DescendantFonts"]); len(descendants) > 0 {
+			font.readCIDWidths(r.file, r.file.dict(descendants[0]))
+		}
+	} else {
+		font.readSimpleWidths(r.file, dict)
+		font.encoding = simpleEncoding(r.file, dict)
+	}
+	if subtype == pdfName("Type3") {
+		if matrix := r.file.array(dict["FontMatrix"]); len(matrix) == 6 {
+			font.scale = r.file.number(matrix[0], 0.001)
+		}
+	}
+
+	if stream, ok := r.file.resolve(dict["ToUnicode"]).(*pdfStream); ok {
+		if cached, ok := r.fonts[stream]; ok && cached.toUnicode != nil {
+			font.toUnicode, font.codeBytes = cached.toUnicode, cached.codeBytes
+		} else {
+			font.parseCMap(r.file.decodeStream(stream))
+			r.fonts[stream] = font
+		}
+	}
+
+	if key != "" {
+		r.dictFonts[key] = font
+	}
+	return font
+}
+
+func (f *pdfFont) readSimpleWidths(file *pdfFile, dict pdfDict) {
+	first := int(file.number(dict["FirstChar"], 0))
+	for i, w := range file.array(dict["Widths"]) {
+		f.widths[first+i] = file.number(w, 0)
+	}
+	if desc := file.dict(dict["FontDescriptor"]); desc != nil {
+		if missing := file.number(desc["MissingWidth"], 0); missing > 0 {
+			f.defaultWidth = missing * 0.001
+		}
+	}
+}
+
+func (f *pdfFont) readCIDWidths(file *pdfFile, dict pdfDict) {
+	if dict == nil {
+		return
+	}
+	f.defaultWidth = file.number(dict["DW"], 1000) * 0.001
+
+	w := file.array(dict["W"])
+	for i := 0; i < len(w); {
+		start := int(file.number(w[i], 0))
+		if i+1 >= len(w) {
+			return
+		}
+		if list := file.array(w[i+1]); list != nil {
+			for j, width := range list {
+				f.widths[start+j] = file.number(width, 0)
+			}
+			i += 2
+			continue
+		}
+		if i+2 >= len(w) {
+			return
+		}
+		end := int(file.number(w[i+1], 0))
+		width := file.number(w[i+2], 0)
+		for c := start; c <= end && c-start < 65536; c++ {
+			f.widths[c] = width
+		}
+		i += 3
+	}
+}
+
+func simpleEncoding(file *pdfFile, dict pdfDict) map[int]string {
+	base := pdfName("WinAnsiEncoding")
+	var differences pdfArray
+
+	switch enc := file.resolve(dict["Encoding"]).(type) {
+	case pdfName:
+		base = enc
+	case pdfDict:
+		if name, ok := file.resolve(enc["BaseEncoding"]).(pdfName); ok {
+			base = name
+		}
+		differences = file.array(enc["Differences"])
+	}
+
+	table := charmap.Windows1252
+	if base == "MacRomanEncoding" {
+		table = charmap.Macintosh
+	}
+
+	encoding := make(map[int]string, 256)
+	for c := 0; c < 256; c++ {
+		if r := table.DecodeByte(byte(c)); r != '\ufffd' {
+			encoding[c] = string(r)
+		}
+	}
+
+	code := 0
+	for _, item := range differences {
+		switch v := file.resolve(item).(type) {
+		case float64:
+			code = int(v)
+		case pdfName:
+			if text, ok := glyphText(string(v)); ok {
+				encoding[code] = text
+			}
+			code++
+		}
+	}
+	return encoding
+}
+
+func glyphText(name string) (string, bool) {
+	if text, ok := glyphNames[name]; ok {
+		return text, true
+	}
+	if base, _, ok := strings.Cut(name, "."); ok && base != "" {
+		return glyphText(base)
+	}
+	if len(name) == 1 {
+		return name, true
+	}
+	for _, prefix := range []string{"uni", "u"} {
+		if hexCode := strings.TrimPrefix(name, prefix); hexCode != name && len(hexCode) >= 4 && len(hexCode)%4 == 0 {
+			var b strings.Builder
+			for i := 0; i+4 <= len(hexCode); i += 4 {
+				v, err := strconv.ParseUint(hexCode[i:i+4], 16, 32)
+				if err != nil {
+					return "", false
+				}
+				b.WriteRune(rune(v))
+			}
+			return b.String(), true
+		}
+	}
+	return "", false
+}
+
+func (f *pdfFont) parseCMap(data []byte) {
+	if data == nil {
+		return
+	}
+	f.toUnicode = make(map[int]string)
+
+	lx := &pdfLexer{data: data}
+	var operands []interface{}
+	mode := ""
+	for !lx.eof() {
+		tok := lx.next()
+		kw, ok := tok.(pdfKeyword)
+		if !ok {
+			operands = append(operands, tok)
+			if mode == "bfchar" && len(operands) == 2 {
+				if src, ok := operands[0].(pdfString); ok {
+					f.toUnicode[codeValue(src)] = utf16Text(operands[1])
+				}
+				operands = operands[:0]
+			}
+			if mode == "bfrange" && len(operands) == 3 {
+				f.addRange(operands)
+				operands = operands[:0]
+			}
+			if mode == "codespacerange" && len(operands) == 2 {
+				if lo, ok := operands[0].(pdfString); ok && len(lo) > 0 {
+					f.codeBytes = len(lo)
+				}
+				operands = operands[:0]
+			}
+			continue
+		}
+
+		switch kw {
+		case "begincodespacerange":
+			mode = "codespacerange"
+		case "beginbfchar":
+			mode = "bfchar"
+		case "beginbfrange":
+			mode = "bfrange"
+		case "endcodespacerange", "endbfchar", "endbfrange":
+			mode = ""
+		}
+		operands = operands[:0]
+	}
+}
+
+func (f *pdfFont) addRange(operands []interface{}) {
+	lo, ok1 := operands[0].(pdfString)
+	hi, ok2 := operands[1].(pdfString)
+	if !ok1 || !ok2 {
+		return
+	}
+	start, end := codeValue(lo), codeValue(hi)
+	if end < start || end-start > 65535 {
+		return
+	}
+
+	switch dst := operands[2].(type) {
+	case pdfString:
+		base := []rune(utf16Text(dst))
+		if len(base) == 0 {
+			return
+		}
+		for c := start; c <= end; c++ {
+			text := append([]rune(nil), base...)
+			text[len(text)-1] += rune(c - start)
+			f.toUnicode[c] = string(text)
+		}
+	case pdfArray:
+		for i, item := range dst {
+			if start+i > end {
+				break
+			}
+			f.toUnicode[start+i] = utf16Text(item)
+		}
+	}
+}
+
+func codeValue(s pdfString) int {
+	v := 0
+	for i := 0; i < len(s); i++ {
+		v = v<<8 | int(s[i])
+	}
+	return v
+}
+
+func utf16Text(v interface{}) string {
+	s, ok := v.(pdfString)
+	if !ok {
+		return ""
+	}
+	if len(s) == 1 {
+		return string(rune(s[0]))
+	}
+
+	u := make([]uint16, 0, len(s)/2)
+	for i := 0; i+1 < len(s); i += 2 {
+		u = append(u, uint16(s[i])<<8|uint16(s[i+1]))
+	}
+	return string(utf16.Decode(u))
+}
+
+func (f *pdfFont) decode(s pdfString) []pdfGlyph {
+	glyphs := make([]pdfGlyph, 0, len(s))
+	for i := 0; i+f.codeBytes <= len(s); i += f.codeBytes {
+		code := codeValue(s[i : i+f.codeBytes])
+
+		text, ok := f.toUnicode[code]
+		if !ok && f.encoding != nil {
+			text = f.encoding[code]
+		}
+
+		width, ok := f.widths[code]
+		if ok {
+			width *= f.scale
+		} else {
+			width = f.defaultWidth
+		}
+
+		glyphs = append(glyphs, pdfGlyph{text: text, width: width, space: f.codeBytes == 1 && code == 32})
+	}
+	return glyphs
+}
+
+func (r *pdfPageReader) readContent(data []byte, resources pdfDict, ctm pdfMatrix) {
+	if r.depth > 8 || data == nil {
+		return
+	}
+	r.depth++
+	defer func() { r.depth-- }()
+
+	fonts := r.file.dict(resources["Font"])
+	xobjects := r.file.dict(resources["XObject"])
+
+	state := pdfGraphicsState{ctm: ctm, text: pdfTextState{scale: 1}}
+	var stack []pdfGraphicsState
+	var tm, lm pdfMatrix
+
+	lx := &pdfLexer{data: data}
+	var operands []interface{}
+
+	for !lx.eof() {
+		tok := lx.next()
+		op, ok := tok.(pdfKeyword)
+		if !ok {
+			operands = append(operands, tok)
+			continue
+		}
+
+		num := func(i int) float64 {
+			if i < len(operands) {
+				if v, ok := operands[i].(float64); ok {
+					return v
+				}
+			}
+			return 0
+		}
+
+		switch op {
+		case "q":
+			stack = append(stack, state)
+		case "Q":
+			if len(stack) > 0 {
+				state = stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+			}
+		case "cm":
+			if len(operands) == 6 {
+				state.ctm = pdfMatrix{num(0), num(1), num(2), num(3), num(4), num(5)}.multiply(state.ctm)
+			}
+		case "BT":
+			tm, lm = identityMatrix, identityMatrix
+		case "Tf":
+			if len(operands) == 2 {
+				if name, ok := operands[0].(pdfName); ok && fonts != nil {
+					state.text.font = r.font(fonts[name])
+				}
+				state.text.size = num(1)
+			}
+		case "Tc":
+			state.text.charSpace = num(0)
+		case "Tw":
+			state.text.wordSpace = num(0)
+		case "Tz":
+			state.text.scale = num(0) / 100
+		case "TL":
+			state.text.leading = num(0)
+		case "Ts":
+			state.text.rise = num(0)
+		case "Td":
+			lm = pdfMatrix{1, 0, 0, 1, num(0), num(1)}.multiply(lm)
+			tm = lm
+		case "TD":
+			state.text.leading = -num(1)
+			lm = pdfMatrix{1, 0, 0, 1, num(0), num(1)}.multiply(lm)
+			tm = lm
+		case "Tm":
+			if len(operands) == 6 {
+				lm = pdfMatrix{num(0), num(1), num(2), num(3), num(4), num(5)}
+				tm = lm
+			}
+		case "T*":
+			lm = pdfMatrix{1, 0, 0, 1, 0, -state.text.leading}.multiply(lm)
+			tm = lm
+		case "Tj":
+			if len(operands) > 0 {
+				tm = r.show(pdfArray{operands[len(operands)-1]}, &state, tm)
+			}
+		case "TJ":
+			if len(operands) > 0 {
+				if arr, ok := operands[len(operands)-1].(pdfArray); ok {
+					tm = r.show(arr, &state, tm)
+				}
+			}
+		case "'", "\"":
+			if op == "\"" && len(operands) == 3 {
+				state.text.wordSpace, state.text.charSpace = num(0), num(1)
+			}
+			lm = pdfMatrix{1, 0, 0, 1, 0, -state.text.leading}.multiply(lm)
+			tm = lm
+			if len(operands) > 0 {
+				tm = r.show(pdfArray{operands[len(operands)-1]}, &state, tm)
+			}
+		case "Do":
+			if len(operands) > 0 && xobjects != nil {
+				if name, ok := operands[0].(pdfName); ok {
+					r.drawXObject(xobjects[name], resources, state.ctm)
+				}
+			}
+		case "BI":
+			lx.skipInlineImage()
+			r.images++
+		}
+		operands = operands[:0]
+	}
+}
+
+func (r *pdfPageReader) drawXObject(v interface{}, parent pdfDict, ctm pdfMatrix) {
+	stream, ok := r.file.resolve(v).(*pdfStream)
+	if !ok {
+		return
+	}
+
+	switch r.file.resolve(stream.dict["Subtype"]) {
+	case pdfName("Image"):
+		r.images++
+	case pdfName("Form"):
+		resources := r.file.dict(stream.dict["Resources"])
+		if resources == nil {
+			resources = parent
+		}
+		if m := r.file.array(stream.dict["Matrix"]); len(m) == 6 {
+			var fm pdfMatrix
+			for i := range fm {
+				fm[i] = r.file.number(m[i], 0)
+			}
+			ctm = fm.multiply(ctm)
+		}
+		r.readContent(r.file.decodeStream(stream), resources, ctm)
+	}
+}
+
+func (r *pdfPageReader) show(items pdfArray, state *pdfGraphicsState, tm pdfMatrix) pdfMatrix {
+	ts := &state.text
+	if ts.font == nil {
+		ts.font = &pdfFont{codeBytes: 1, defaultWidth: 0.5, scale: 0.001}
+	}
+
+	trm := tm.multiply(state.ctm)
+	x, y := trm.apply(0, ts.rise)
+	size := ts.size * math.Hypot(trm[2], trm[3])
+	var text strings.Builder
+
+	for _, item := range items {
+		switch v := item.(type) {
+		case float64:
+			shift := -v / 1000 * ts.size * ts.scale
+			tm = pdfMatrix{1, 0, 0, 1, shift, 0}.multiply(tm)
+			if -v > 200 && text.Len() > 0 {
+				text.WriteByte(' ')
+			}
+		case pdfString:
+			for _, g := range ts.font.decode(v) {
+				text.WriteString(g.text)
+				advance := g.width*ts.size + ts.charSpace
+				if g.space {
+					advance += ts.wordSpace
+				}
+				tm = pdfMatrix{1, 0, 0, 1, advance * ts.scale, 0}.multiply(tm)
+			}
+		}
+	}
+
+	x2, _ := tm.multiply(state.ctm).apply(0, ts.rise)
+	if content := text.String(); strings.TrimSpace(content) != "" {
+		r.runs = append(r.runs, pdfRun{text: content, x: x, y: y, x2: math.Max(x2, x), size: math.Max(size, 1)})
+	}
+	return tm
+}
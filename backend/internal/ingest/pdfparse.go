@@ -0,0 +1,796 @@
+package ingest
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/md5"
+	"crypto/rc4"
+	"encoding/ascii85"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"unicode/utf16"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+type pdfName string
+
+type pdfKeyword string
+
+type pdfString string
+
+type pdfArray []interface{}
+
+type pdfDict map[pdfName]interface{}
+
+type pdfRef struct {
+	num			int
+	gen			int
+}
+
+type pdfStream struct {
+	dict		pdfDict
+	raw			[]byte
+	ref			pdfRef
+}
+
+type pdfFile struct {
+	data		[]byte
+	offsets		map[int]int
+	packed		map[int]interface{}
+	cache		map[int]interface{}
+	trailer		pdfDict
+	crypt		*pdfCrypt
+	// err is the first stream that could not be decoded safely; parsing
+	// stops at the next check rather than working from partial content.
+	err			error
+}
+
+type pdfCrypt struct {
+	key			[]byte
+	aes			bool
+}
+
+type pdfLexer struct {
+	data		[]byte
+	pos			int
+	file		*pdfFile
+	ref			pdfRef
+}
+
+var pdfPadding = []byte{
+	0x28, 0xBF, 0x4E, 0x5E, 0x4E, 0x75, 0x8A, 0x41, 0x64, 0x00, 0x4E, 0x56, 0xFF, 0xFA, 0x01, 0x08,
+	0x2E, 0x2E, 0x00, 0xB6, 0xD0, 0x68, 0x3E, 0x80, 0x2F, 0x0C, 0xA9, 0xFE, 0x64, 0x53, 0x69, 0x7A,
+}
+
+func openPDF(data []byte) (*pdfFile, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(data[:min(len(data), 1024)], "\x00\t\r\n "), []byte("%PDF-")) && !bytes.Contains(data[:min(len(data), 1024)], []byte("%PDF-")) {
+		return nil, errors.Join(ErrInvalidDocument, errors.New("missing pdf header"))
+	}
+
+	f := &pdfFile{
+		data:		data,
+		offsets:	make(map[int]int),
+		packed:		make(map[int]interface{}),
+		cache:		make(map[int]interface{}),
+		trailer:	make(pdfDict),
+	}
+
+	for _, m := range pdfObjectPattern.FindAllSubmatchIndex(data, -1) {
+		num, err := strconv.Atoi(string(data[m[2]:m[3]]))
+		if err != nil {
+			continue
+		}
+		f.offsets[num] = m[0]
+	}
+	if len(f.offsets) == 0 {
+		return nil, errors.Join(ErrInvalidDocument, errors.New("no pdf objects found"))
+	}
+
+	for _, m := range pdfTrailerPattern.FindAllIndex(data, -1) {
+		lx := &pdfLexer{data: data, pos: m[1], file: f}
+		if dict, ok := lx.parseObject().(pdfDict); ok {
+			for k, v := range dict {
+				f.trailer[k] = v
+			}
+		}
+	}
+	for num, off := range f.offsets {
+		if !bytes.Contains(data[off:min(len(data), off+1024)], []byte("/XRef")) {
+			continue
+		}
+		if stream, ok := f.object(num).(*pdfStream); ok && stream.dict["Type"] == pdfName("XRef") {
+			for _, key := range []pdfName{"Root", "Info", "Encrypt", "ID"} {
+				if v, ok := stream.dict[key]; ok {
+					f.trailer[key] = v
+				}
+			}
+		}
+	}
+
+	if encrypt, ok := f.trailer["Encrypt"]; ok {
+		if err := f.setupCrypt(); err != nil {
+			return nil, err
+		}
+
+		f.cache = make(map[int]interface{})
+		if ref, ok := encrypt.(pdfRef); ok {
+			f.cache[ref.num] = f.dict(encrypt)
+		}
+	}
+
+	for num, off := range f.offsets {
+		if bytes.Contains(data[off:min(len(data), off+1024)], []byte("/ObjStm")) {
+			f.unpack(num)
+		}
+	}
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	if _, ok := f.resolve(f.trailer["Root"]).(pdfDict); !ok {
+		for num := range f.offsets {
+			if dict, ok := f.object(num).(pdfDict); ok && dict["Type"] == pdfName("Catalog") {
+				f.trailer["Root"] = pdfRef{num: num}
+				break
+			}
+		}
+	}
+	if _, ok := f.resolve(f.trailer["Root"]).(pdfDict); !ok {
+		return nil, errors.Join(ErrInvalidDocument, errors.New("pdf catalog not found"))
+	}
+	return f, nil
+}
+
+func (f *pdfFile) object(num int) interface{} {
+	if v, ok := f.cache[num]; ok {
+		return v
+	}
+	f.cache[num] = nil
+
+	var v interface{}
+	if off, ok := f.offsets[num]; ok {
+		lx := &pdfLexer{data: f.data, pos: off, file: f}
+		v = lx.parseObject()
+	} else {
+		v = f.packed[num]
+	}
+
+	f.cache[num] = v
+	return v
+}
+
+func (f *pdfFile) resolve(v interface{}) interface{} {
+	for depth := 0; depth < 16; depth++ {
+		ref, ok := v.(pdfRef)
+		if !ok {
+			return v
+		}
+		v = f.object(ref.num)
+	}
+	return nil
+}
+
+func (f *pdfFile) dict(v interface{}) pdfDict {
+	switch t := f.resolve(v).(type) {
+	case pdfDict:
+		return t
+	case *pdfStream:
+		return t.dict
+	}
+	return nil
+}
+
+func (f *pdfFile) array(v interface{}) pdfArray {
+	a, _ := f.resolve(v).(pdfArray)
+	return a
+}
+
+func (f *pdfFile) number(v interface{}, fallback float64) float64 {
+	if n, ok := f.resolve(v).(float64); ok {
+		return n
+	}
+	return fallback
+}
+
+func (f *pdfFile) unpack(num int) {
+	stream, ok := f.object(num).(*pdfStream)
+	if !ok || stream.dict["Type"] != pdfName("ObjStm") {
+		return
+	}
+
+	content := f.decodeStream(stream)
+	first := int(f.number(stream.dict["First"], 0))
+	count := int(f.number(stream.dict["N"], 0))
+	if content == nil || first <= 0 || first > len(content) {
+		return
+	}
+
+	header := &pdfLexer{data: content[:first], file: f}
+	for i := 0; i < count; i++ {
+		objNum, ok1 := header.parseObject().(float64)
+		off, ok2 := header.parseObject().(float64)
+		if !ok1 || !ok2 || first+int(off) >= len(content) {
+			return
+		}
+		if _, direct := f.offsets[int(objNum)]; direct {
+			continue
+		}
+		lx := &pdfLexer{data: content, pos: first + int(off), file: f}
+		f.packed[int(objNum)] = lx.parseObject()
+	}
+}
+
+func (f *pdfFile) decodeStream(s *pdfStream) []byte {
+	data := s.raw
+	if f.crypt != nil && s.dict["Type"] != pdfName("XRef") {
+		data = f.crypt.decrypt(s.ref, data)
+	}
+
+	var filters, params pdfArray
+	switch t := f.resolve(s.dict["Filter"]).(type) {
+	case pdfName:
+		filters = pdfArray{t}
+	case pdfArray:
+		filters = t
+	}
+	switch t := f.resolve(s.dict["DecodeParms"]).(type) {
+	case pdfDict:
+		params = pdfArray{t}
+	case pdfArray:
+		params = t
+	}
+
+	for i, filter := range filters {
+		var parms pdfDict
+		if i < len(params) {
+			parms = f.dict(params[i])
+		}
+
+		switch f.resolve(filter) {
+		case pdfName("FlateDecode"), pdfName("Fl"):
+			var err error
+			if data, err = inflate(data); err != nil {
+				if f.err == nil {
+					f.err = errors.Join(ErrInvalidDocument, err)
+				}
+				return nil
+			}
+			if predictor := f.number(parms["Predictor"], 1); predictor >= 10 {
+				data = unpredictPNG(data, int(f.number(parms["Columns"], 1))*int(f.number(parms["Colors"], 1)))
+			}
+		case pdfName("ASCIIHexDecode"), pdfName("AHx"):
+			data = decodeASCIIHex(data)
+		case pdfName("ASCII85Decode"), pdfName("A85"):
+			data = decodeASCII85(data)
+		default:
+			return nil
+		}
+		if data == nil {
+			return nil
+		}
+	}
+	return data
+}
+
+func (f *pdfFile) setupCrypt() error {
+	enc := f.dict(f.trailer["Encrypt"])
+	if enc == nil || enc["Filter"] != pdfName("Standard") {
+		return ErrEncrypted
+	}
+
+	revision := int(f.number(enc["R"], 0))
+	if revision < 2 || revision > 4 {
+		return ErrEncrypted
+	}
+
+	length := int(f.number(enc["Length"], 40)) / 8
+	if revision == 2 {
+		length = 5
+	}
+	if length < minKeyLength || length > maxKeyLength {
+		return ErrEncrypted
+	}
+	owner, _ := f.resolve(enc["O"]).(pdfString)
+	user, _ := f.resolve(enc["U"]).(pdfString)
+	var id []byte
+	if ids := f.array(f.trailer["ID"]); len(ids) > 0 {
+		if s, ok := f.resolve(ids[0]).(pdfString); ok {
+			id = []byte(s)
+		}
+	}
+
+	h := md5.New()
+	h.Write(pdfPadding)
+	h.Write([]byte(owner))
+	perms := make([]byte, 4)
+	binary.LittleEndian.PutUint32(perms, uint32(int32(f.number(enc["P"], 0))))
+	h.Write(perms)
+	h.Write(id)
+	if revision >= 4 && enc["EncryptMetadata"] == false {
+		h.Write([]byte{0xFF, 0xFF, 0xFF, 0xFF})
+	}
+	key := h.Sum(nil)
+	if revision >= 3 {
+		for i := 0; i < 50; i++ {
+			sum := md5.Sum(key[:length])
+			key = sum[:]
+		}
+	}
+	key = key[:length]
+
+	var check []byte
+	if revision == 2 {
+		c, err := rc4.NewCipher(key)
+		if err != nil {
+			return errors.Join(ErrEncrypted, err)
+		}
+		check = make([]byte, 32)
+		c.XORKeyStream(check, pdfPadding)
+	} else {
+		h := md5.New()
+		h.Write(pdfPadding)
+		h.Write(id)
+		check = h.Sum(nil)
+		for i := 0; i < 20; i++ {
+			k := make([]byte, len(key))
+			for j := range key {
+				k[j] = key[j] ^ byte(i)
+			}
+			c, err := rc4.NewCipher(k)
+			if err != nil {
+				return errors.Join(ErrEncrypted, err)
+			}
+			c.XORKeyStream(check, check)
+		}
+	}
+	if len(user) < 16 || !bytes.Equal(check[:16], []byte(user)[:16]) {
+		return ErrEncrypted
+	}
+
+	useAES := false
+	if revision == 4 {
+		if filters := f.dict(enc["CF"]); filters != nil {
+			if std := f.dict(filters[pdfName(fmt.Sprint(f.resolve(enc["StmF"])))]); std != nil {
+				useAES = std["CFM"] == pdfName("AESV2")
+			}
+		}
+	}
+
+	f.crypt = &pdfCrypt{key: key, aes: useAES}
+	return nil
+}
+
+func (c *pdfCrypt) decrypt(ref pdfRef, data []byte) []byte {
+	if c == nil || ref.num == 0 {
+		return data
+	}
+
+	h := md5.New()
+	h.Write(c.key)
+	h.Write([]byte{byte(ref.num), byte(ref.num >> 8), byte(ref.num >> 16), byte(ref.gen), byte(ref.gen >> 8)})
+	if c.aes {
+		h.Write([]byte("sAlT"))
+	}
+	key := h.Sum(nil)[:min(len(c.key)+5, 16)]
+
+	if !c.aes {
+		cipherRC4, err := rc4.NewCipher(key)
+		if err != nil {
+			return nil
+		}
+		out := make([]byte, len(data))
+		cipherRC4.XORKeyStream(out, data)
+		return out
+	}
+
+	if len(data) < 2*aes.BlockSize || len(data)%aes.BlockSize != 0 {
+		return nil
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil
+	}
+	out := make([]byte, len(data)-aes.BlockSize)
+	cipher.NewCBCDecrypter(block, data[:aes.BlockSize]).CryptBlocks(out, data[aes.BlockSize:])
+	if pad := int(out[len(out)-1]); pad > 0 && pad <= aes.BlockSize {
+		out = out[:len(out)-pad]
+	}
+	return out
+}
+
+func (lx *pdfLexer) skipSpace() {
+	for lx.pos < len(lx.data) {
+		switch c := lx.data[lx.pos]; {
+		case isPDFSpace(c):
+			lx.pos++
+		case c == '%':
+			for lx.pos < len(lx.data) && lx.data[lx.pos] != '\n' && lx.data[lx.pos] != '\r' {
+				lx.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (lx *pdfLexer) eof() bool {
+	lx.skipSpace()
+	return lx.pos >= len(lx.data)
+}
+
+func (lx *pdfLexer) parseObject() interface{} {
+	v := lx.next()
+	num, ok := v.(float64)
+	if !ok || num != float64(int(num)) {
+		return v
+	}
+
+	save := lx.pos
+	if gen, ok := lx.next().(float64); ok {
+		switch lx.next() {
+		case pdfKeyword("R"):
+			return pdfRef{num: int(num), gen: int(gen)}
+		case pdfKeyword("obj"):
+			lx.ref = pdfRef{num: int(num), gen: int(gen)}
+			return lx.parseObject()
+		}
+	}
+	lx.pos = save
+	return v
+}
+
+func (lx *pdfLexer) next() interface{} {
+	lx.skipSpace()
+	if lx.pos >= len(lx.data) {
+		return nil
+	}
+
+	c := lx.data[lx.pos]
+	switch {
+	case c == '/':
+		lx.pos++
+		return lx.readName()
+	case c == '(':
+		lx.pos++
+		return lx.decryptString(lx.readLiteral())
+	case c == '<' && lx.pos+1 < len(lx.data) && lx.data[lx.pos+1] == '<':
+		lx.pos += 2
+		return lx.readDict()
+	case c == '<':
+		lx.pos++
+		return lx.decryptString(lx.readHex())
+	case c == '[':
+		lx.pos++
+		var arr pdfArray
+		for !lx.eof() && lx.data[lx.pos] != ']' {
+			start := lx.pos
+			arr = append(arr, lx.parseObject())
+			if lx.pos == start {
+				lx.pos++
+			}
+		}
+		lx.pos++
+		return arr
+	case c == ']' || c == '>' || c == ')' || c == '{' || c == '}':
+		lx.pos++
+		return pdfKeyword(string(c))
+	case c == '+' || c == '-' || c == '.' || (c >= '0' && c <= '9'):
+		start := lx.pos
+		lx.pos++
+		for lx.pos < len(lx.data) && (lx.data[lx.pos] == '.' || (lx.data[lx.pos] >= '0' && lx.data[lx.pos] <= '9')) {
+			lx.pos++
+		}
+		n, err := strconv.ParseFloat(string(lx.data[start:lx.pos]), 64)
+		if err != nil {
+			return float64(0)
+		}
+		return n
+	}
+
+	start := lx.pos
+	for lx.pos < len(lx.data) && !isPDFSpace(lx.data[lx.pos]) && !isPDFDelimiter(lx.data[lx.pos]) {
+		lx.pos++
+	}
+	if lx.pos == start {
+		lx.pos++
+	}
+
+	switch word := string(lx.data[start:lx.pos]); word {
+	case "true":
+		return true
+	case "false":
+		return false
+	case "null":
+		return nil
+	default:
+		return pdfKeyword(word)
+	}
+}
+
+func (lx *pdfLexer) readName() pdfName {
+	var b []byte
+	for lx.pos < len(lx.data) && !isPDFSpace(lx.data[lx.pos]) && !isPDFDelimiter(lx.data[lx.pos]) {
+		c := lx.data[lx.pos]
+		if c == '#' && lx.pos+2 < len(lx.data) {
+			if v, err := hex.DecodeString(string(lx.data[lx.pos+1 : lx.pos+3])); err == nil {
+				b = append(b, v[0])
+				lx.pos += 3
+				continue
+			}
+		}
+		b = append(b, c)
+		lx.pos++
+	}
+	return pdfName(b)
+}
+
+func (lx *pdfLexer) readLiteral() []byte {
+	var b []byte
+	depth := 1
+	for lx.pos < len(lx.data) {
+		c := lx.data[lx.pos]
+		lx.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return b
+			}
+		case '\\':
+			if lx.pos >= len(lx.data) {
+				return b
+			}
+			e := lx.data[lx.pos]
+			lx.pos++
+			switch e {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				if lx.pos < len(lx.data) && lx.data[lx.pos] == '\n' {
+					lx.pos++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if e >= '0' && e <= '7' {
+					v := int(e - '0')
+					for i := 0; i < 2 && lx.pos < len(lx.data) && lx.data[lx.pos] >= '0' && lx.data[lx.pos] <= '7'; i++ {
+						v = v*8 + int(lx.data[lx.pos]-'0')
+						lx.pos++
+					}
+					c = byte(v)
+				} else {
+					c = e
+				}
+			}
+		}
+		b = append(b, c)
+	}
+	return b
+}
+
+func (lx *pdfLexer) readHex() []byte {
+	var digits []byte
+	for lx.pos < len(lx.data) && lx.data[lx.pos] != '>' {
+		if c := lx.data[lx.pos]; !isPDFSpace(c) {
+			digits = append(digits, c)
+		}
+		lx.pos++
+	}
+	lx.pos++
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	b, _ := hex.DecodeString(string(digits))
+	return b
+}
+
+func (lx *pdfLexer) readDict() interface{} {
+	dict := make(pdfDict)
+	for !lx.eof() {
+		if lx.data[lx.pos] == '>' {
+			lx.pos += 2
+			break
+		}
+		key, ok := lx.next().(pdfName)
+		if !ok {
+			continue
+		}
+		dict[key] = lx.parseObject()
+	}
+
+	save := lx.pos
+	if lx.next() != pdfKeyword("stream") {
+		lx.pos = save
+		return dict
+	}
+
+	if lx.pos < len(lx.data) && lx.data[lx.pos] == '\r' {
+		lx.pos++
+	}
+	if lx.pos < len(lx.data) && lx.data[lx.pos] == '\n' {
+		lx.pos++
+	}
+
+	start := lx.pos
+	end := -1
+	if length, ok := dict["Length"].(float64); ok {
+		end = start + int(length)
+	} else if ref, ok := dict["Length"].(pdfRef); ok && lx.file != nil && ref.num != lx.ref.num {
+		if length, ok := lx.file.object(ref.num).(float64); ok {
+			end = start + int(length)
+		}
+	}
+	if end < start || end > len(lx.data) || !bytes.Contains(lx.data[end:min(len(lx.data), end+32)], []byte("endstream")) {
+		idx := bytes.Index(lx.data[start:], []byte("endstream"))
+		if idx < 0 {
+			end = len(lx.data)
+		} else {
+			end = start + idx
+		}
+	}
+
+	lx.pos = end
+	if idx := bytes.Index(lx.data[end:min(len(lx.data), end+32)], []byte("endstream")); idx >= 0 {
+		lx.pos = end + idx + len("endstream")
+	}
+	return &pdfStream{dict: dict, raw: lx.data[start:end], ref: lx.ref}
+}
+
+func (lx *pdfLexer) decryptString(b []byte) pdfString {
+	if lx.file != nil && lx.file.crypt != nil && lx.ref.num != 0 {
+		b = lx.file.crypt.decrypt(lx.ref, b)
+	}
+	return pdfString(b)
+}
+
+func (lx *pdfLexer) skipInlineImage() {
+	idx := bytes.Index(lx.data[lx.pos:], []byte("ID"))
+	if idx < 0 {
+		lx.pos = len(lx.data)
+		return
+	}
+	lx.pos += idx + 3
+	for lx.pos < len(lx.data)-2 {
+		if lx.data[lx.pos] == 'E' && lx.data[lx.pos+1] == 'I' && isPDFSpace(lx.data[lx.pos-1]) && (lx.pos+2 == len(lx.data) || isPDFSpace(lx.data[lx.pos+2])) {
+			lx.pos += 2
+			return
+		}
+		lx.pos++
+	}
+	lx.pos = len(lx.data)
+}
+
+func isPDFSpace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+func isPDFDelimiter(c byte) bool {
+	return c == '(' || c == ')' || c == '<' || c == '>' || c == '[' || c == ']' || c == '{' || c == '}' || c == '/' || c == '%'
+}
+
+// inflate decompresses a FlateDecode stream. Truncated streams yield what
+// could be read, as readers are lenient about them; streams inflating past
+// maxStreamSize are refused.
+func inflate(data []byte) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil
+	}
+	out, _ := io.ReadAll(io.LimitReader(r, maxStreamSize+1))
+	if len(out) > maxStreamSize {
+		return nil, errors.New("pdf stream is too large")
+	}
+	return out, nil
+}
+
+func unpredictPNG(data []byte, columns int) []byte {
+	if columns <= 0 {
+		return data
+	}
+
+	var out []byte
+	prev := make([]byte, columns)
+	for len(data) >= columns+1 {
+		kind, row := data[0], append([]byte(nil), data[1:columns+1]...)
+		data = data[columns+1:]
+		for i := range row {
+			var left, upLeft byte
+			if i > 0 {
+				left, upLeft = row[i-1], prev[i-1]
+			}
+			switch kind {
+			case 1:
+				row[i] += left
+			case 2:
+				row[i] += prev[i]
+			case 3:
+				row[i] += byte((int(left) + int(prev[i])) / 2)
+			case 4:
+				row[i] += paeth(left, prev[i], upLeft)
+			}
+		}
+		out = append(out, row...)
+		prev = row
+	}
+	return out
+}
+
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	switch {
+	case pa <= pb && pa <= pc:
+		return a
+	case pb <= pc:
+		return b
+	}
+	return c
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func decodeASCIIHex(data []byte) []byte {
+	if idx := bytes.IndexByte(data, '>'); idx >= 0 {
+		data = data[:idx]
+	}
+	// data may share the file's buffer, so the terminator goes on a copy.
+	lx := &pdfLexer{data: append(bytes.Clone(data), '>')}
+	return lx.readHex()
+}
+
+func decodeASCII85(data []byte) []byte {
+	data = bytes.TrimPrefix(bytes.TrimSpace(data), []byte("<~"))
+	if idx := bytes.Index(data, []byte("~>")); idx >= 0 {
+		data = data[:idx]
+	}
+	out := make([]byte, len(data)*4/5+4)
+	n, _, err := ascii85.Decode(out, data, true)
+	if err != nil {
+		return nil
+	}
+	return out[:n]
+}
+
+func decodePDFText(s pdfString) string {
+	b := []byte(s)
+	if len(b) >= 2 && b[0] == 0xFE && b[1] == 0xFF {
+		u := make([]uint16, 0, len(b)/2)
+		for i := 2; i+1 < len(b); i += 2 {
+			u = append(u, uint16(b[i])<<8|uint16(b[i+1]))
+		}
+		return string(utf16.Decode(u))
+	}
+	if len(b) >= 3 && b[0] == 0xEF && b[1] == 0xBB && b[2] == 0xBF {
+		return string(b[3:])
+	}
+
+	decoded, err := charmap.Windows1252.NewDecoder().Bytes(b)
+	if err != nil {
+		return string(b)
+	}
+	return string(decoded)
+}
@@ -0,0 +1,119 @@
+package ingest
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strings"
+
+	"cadence/internal/preprocess"
+)
+
+func DetectFormat(filename string, content []byte) (Format, error) {
+	switch strings.ToLower(strings.TrimPrefix(path.Ext(filename), ".")) {
+	case "epub":
+		return FormatEPUB, nil
+	case "txt", "text":
+		return FormatText, nil
+	case "md", "markdown":
+		return FormatMarkdown, nil
+	case "html", "htm", "xhtml":
+		return FormatHTML, nil
+	case "docx":
+		return FormatDOCX, nil
+	case "odt":
+		return FormatODT, nil
+	case "pdf":
+		return FormatPDF, nil
+	}
+
+	if bytes.HasPrefix(bytes.TrimLeft(content[:min(len(content), 1024)], "\x00\r\n\t "), []byte("%PDF-")) {
+		return FormatPDF, nil
+	}
+
+	if bytes.HasPrefix(content, []byte("PK\x03\x04")) {
+		head := content[:min(len(content), 256)]
+		switch {
+		case bytes.Contains(head, []byte("application/epub+zip")):
+			return FormatEPUB, nil
+		case bytes.Contains(head, []byte("application/vnd.oasis.opendocument.text")):
+			return FormatODT, nil
+		}
+		if archive, err := openArchive(content); err == nil && archive.has("word/document.xml") {
+			return FormatDOCX, nil
+		}
+	}
+
+	head := bytes.ToLower(bytes.TrimSpace(content[:min(len(content), 512)]))
+	if bytes.HasPrefix(head, []byte("<!doctype html")) || bytes.HasPrefix(head, []byte("<html")) || bytes.Contains(head, []byte("<html")) {
+		return FormatHTML, nil
+	}
+
+	if isText(content) {
+		return FormatText, nil
+	}
+
+	return "", fmt.Errorf("%w: %s", ErrUnsupportedFormat, path.Ext(filename))
+}
+
+func Parse(filename string, content []byte) (*Document, error) {
+	format, err := DetectFormat(filename, content)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc *Document
+	switch format {
+	case FormatEPUB:
+		doc, err = ParseEPUB(content)
+	case FormatText:
+		doc, err = ParseText(content)
+	case FormatMarkdown:
+		doc, err = ParseMarkdown(content)
+	case FormatHTML:
+		doc, err = ParseHTML(content)
+	case FormatDOCX:
+		doc, err = ParseDOCX(content)
+	case FormatODT:
+		doc, err = ParseODT(content)
+	case FormatPDF:
+		doc, err = ParsePDF(content)
+	default:
+		err = fmt.Errorf("%w: %s", ErrUnsupportedFormat, format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	doc.Format = format
+	if err := doc.normalize(filename); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+func (d *Document) ApplyFootnotePolicy(policy preprocess.FootnotePolicy) {
+	for i := range d.Chapters {
+		c := &d.Chapters[i]
+		paragraphs := preprocess.ApplyFootnotePolicy(c.Paragraphs(), c.Notes, policy)
+
+		blocks := make([]Block, 0, len(paragraphs))
+		for j, text := range paragraphs {
+			if j < len(c.Blocks) {
+				b := c.Blocks[j]
+				b.Text = text
+				blocks = append(blocks, b)
+				continue
+			}
+
+			block := Block{Kind: BlockParagraph, Text: text}
+			if j == len(c.Blocks) {
+				block.Kind, block.Level = BlockHeading, 2
+			}
+			blocks = append(blocks, block)
+		}
+
+		c.Blocks = blocks
+		c.Notes = nil
+	}
+}
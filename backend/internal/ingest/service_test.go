@@ -0,0 +1,145 @@
+package ingest
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// buildPDF lays out numbered objects followed by a trailer. The parser
+// locates objects by scanning, so no cross-reference table is needed.
+func buildPDF(trailer string, objects ...string) []byte {
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	for i, obj := range objects {
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Root 1 0 R %s >>\n%%%%EOF\n", trailer)
+	return b.Bytes()
+}
+
+func pdfStreamObject(dict string, data []byte) string {
+	return fmt.Sprintf("<< /Length %d %s >>\nstream\n%s\nendstream", len(data), dict, data)
+}
+
+func textPDF(trailer string, content []byte, filter string) []byte {
+	return buildPDF(trailer,
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents 4 0 R /Resources << /Font << /F1 5 0 R >> >> >>",
+		pdfStreamObject(filter, content),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+	)
+}
+
+func deflate(data []byte) []byte {
+	var b bytes.Buffer
+	w := zlib.NewWriter(&b)
+	w.Write(data)
+	w.Close()
+	return b.Bytes()
+}
+
+func buildZip(files map[string]string) []byte {
+	var b bytes.Buffer
+	w := zip.NewWriter(&b)
+	for name, content := range files {
+		f, _ := w.Create(name)
+		f.Write([]byte(content))
+	}
+	w.Close()
+	return b.Bytes()
+}
+
+const testContainer = `<?xml version="1.0"?>
+<container xmlns="urn:oasis:names:tc:opendocument:xmlns:container" version="1.0">
+<rootfiles><rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/></rootfiles>
+</container>`
+
+func TestParseRejects(t *testing.T) {
+	pageText := []byte("BT /F1 12 Tf 72 720 Td (It was a bright cold day in April.) Tj ET")
+
+	tests := []struct {
+		name		string
+		filename	string
+		content		[]byte
+		err			error
+	}{
+		{"unknown binary", "book", []byte{0x00, 0x01, 0x02, 0xff, 0xfe, 0x00}, ErrUnsupportedFormat},
+		{"empty text", "book.txt", []byte("  \n\n  "), ErrEmptyDocument},
+		{"pdf without objects", "book.pdf", []byte("%PDF-1.7\nnothing here\n%%EOF"), ErrInvalidDocument},
+		{"pdf without pages", "book.pdf", buildPDF("", "<< /Type /Catalog >>"), ErrInvalidDocument},
+		{"pdf not a pdf", "book.pdf", []byte("just some text"), ErrInvalidDocument},
+		{"pdf unknown security handler", "book.pdf", textPDF("/Encrypt << /Filter /Adobe.PubSec /V 4 /R 4 >>", pageText, ""), ErrEncrypted},
+		{"pdf unsupported revision", "book.pdf", textPDF("/Encrypt << /Filter /Standard /V 5 /R 6 /Length 256 >>", pageText, ""), ErrEncrypted},
+		{"pdf oversized key", "book.pdf", textPDF("/Encrypt << /Filter /Standard /V 2 /R 3 /Length 4096 /O (x) /U (x) /P -4 >>", pageText, ""), ErrEncrypted},
+		{"pdf zero key", "book.pdf", textPDF("/Encrypt << /Filter /Standard /V 2 /R 3 /Length 0 /O (x) /U (x) /P -4 >>", pageText, ""), ErrEncrypted},
+		{"pdf wrong user password", "book.pdf", textPDF("/Encrypt << /Filter /Standard /V 1 /R 2 /O (owner) /U (0123456789abcdef0123456789abcdef) /P -4 >>", pageText, ""), ErrEncrypted},
+		{"pdf inflation bomb", "book.pdf", textPDF("", deflate(make([]byte, maxStreamSize+1)), "/Filter /FlateDecode"), ErrInvalidDocument},
+		{"epub not a zip", "book.epub", []byte("PK\x03\x04 truncated"), ErrInvalidDocument},
+		{"epub without container", "book.epub", buildZip(map[string]string{"mimetype": "application/epub+zip"}), ErrInvalidDocument},
+		{"epub missing package", "book.epub", buildZip(map[string]string{"META-INF/container.xml": testContainer}), ErrInvalidDocument},
+		{"epub with drm", "book.epub", buildZip(map[string]string{
+			"META-INF/container.xml":	testContainer,
+			"META-INF/encryption.xml":	`<encryption><EncryptedData><EncryptionMethod Algorithm="http://www.w3.org/2001/04/xmlenc#aes128-cbc"/></EncryptedData></encryption>`,
+		}), ErrEncrypted},
+		{"epub with unreadable encryption", "book.epub", buildZip(map[string]string{
+			"META-INF/container.xml":	testContainer,
+			"META-INF/encryption.xml":	`<encryption><EncryptedData>`,
+			"OEBPS/content.opf":		`<package><manifest><item id="c1" href="c1.xhtml" media-type="application/xhtml+xml"/></manifest><spine><itemref idref="c1"/></spine></package>`,
+			"OEBPS/c1.xhtml":			`<html><body><p>It was a bright cold day in April.</p></body></html>`,
+		}), ErrInvalidDocument},
+		{"epub oversized entry", "book.epub", buildZip(map[string]string{
+			"META-INF/container.xml":	testContainer,
+			"OEBPS/content.opf":		"<package>" + strings.Repeat(" ", maxEntrySize) + "</package>",
+		}), ErrInvalidDocument},
+		{"docx without body", "book.docx", buildZip(map[string]string{"[Content_Types].xml": "<Types/>"}), ErrInvalidDocument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := Parse(tt.filename, tt.content)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Parse() error = %v, want %v", err, tt.err)
+			}
+			if doc != nil {
+				t.Errorf("Parse() returned a document alongside error %v", err)
+			}
+		})
+	}
+}
+
+func TestParsePDF(t *testing.T) {
+	pageText := []byte("BT /F1 12 Tf 72 720 Td (It was a bright cold day in April.) Tj ET")
+
+	tests := []struct {
+		name	string
+		content	[]byte
+	}{
+		{"plain", textPDF("", pageText, "")},
+		{"deflated", textPDF("", deflate(pageText), "/Filter /FlateDecode")},
+		{"ascii hex", textPDF("", []byte(fmt.Sprintf("%X>", pageText)), "/Filter /ASCIIHexDecode")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := Parse("book.pdf", tt.content)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			var text strings.Builder
+			for _, ch := range doc.Chapters {
+				for _, p := range ch.Paragraphs() {
+					text.WriteString(p)
+				}
+			}
+			if !strings.Contains(text.String(), "bright cold day in April") {
+				t.Errorf("Parse() text = %q, want the page's sentence", text.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,374 @@
+package ingest
+
+import (
+	"bytes"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+)
+
+func DecodeText(content []byte, declared string) (string, string) {
+	switch {
+	case bytes.HasPrefix(content, []byte{0xEF, 0xBB, 0xBF}):
+		return string(content[3:]), "utf-8"
+	case bytes.HasPrefix(content, []byte{0xFF, 0xFE}):
+		return decodeWith(unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM), content[2:]), "utf-16le"
+	case bytes.HasPrefix(content, []byte{0xFE, 0xFF}):
+		return decodeWith(unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM), content[2:]), "utf-16be"
+	}
+
+	if endian, ok := sniffUTF16(content); ok {
+		if endian == unicode.LittleEndian {
+			return decodeWith(unicode.UTF16(endian, unicode.IgnoreBOM), content), "utf-16le"
+		}
+		return decodeWith(unicode.UTF16(endian, unicode.IgnoreBOM), content), "utf-16be"
+	}
+
+	if utf8.Valid(content) {
+		return string(content), "utf-8"
+	}
+
+	if declared != "" {
+		if enc, name := charset.Lookup(declared); enc != nil {
+			return decodeWith(enc, content), name
+		}
+	}
+	return decodeWith(charmap.Windows1252, content), "windows-1252"
+}
+
+func decodeWith(enc encoding.Encoding, content []byte) string {
+	decoded, err := enc.NewDecoder().Bytes(content)
+	if err != nil {
+		return strings.ToValidUTF8(string(content), "\ufffd")
+	}
+	return string(decoded)
+}
+
+func sniffUTF16(content []byte) (unicode.Endianness, bool) {
+	sample := content[:min(len(content), 4096)]
+	if len(sample) < 4 {
+		return unicode.LittleEndian, false
+	}
+
+	var evenZeros, oddZeros int
+	for i, b := range sample {
+		if b != 0 {
+			continue
+		}
+		if i%2 == 0 {
+			evenZeros++
+		} else {
+			oddZeros++
+		}
+	}
+
+	half := len(sample) / 2
+	switch {
+	case oddZeros > half*2/5 && evenZeros < half/20:
+		return unicode.LittleEndian, true
+	case evenZeros > half*2/5 && oddZeros < half/20:
+		return unicode.BigEndian, true
+	}
+	return unicode.LittleEndian, false
+}
+
+func isText(content []byte) bool {
+	if _, ok := sniffUTF16(content); ok {
+		return true
+	}
+
+	sample := content[:min(len(content), 4096)]
+	control := 0
+	for _, b := range sample {
+		if b < 0x09 || (b > 0x0D && b < 0x20 && b != 0x1B) {
+			control++
+		}
+	}
+	return len(sample) > 0 && control*100 < len(sample)
+}
+
+func ParseText(content []byte) (*Document, error) {
+	text, enc := DecodeText(content, "")
+	lines := strings.Split(normalizeNewlines(text), "\n")
+	doc := &Document{Properties: map[string]string{"encoding": enc}}
+	lines = stripGutenberg(lines, doc)
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	blank := 0
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			blank++
+		}
+	}
+	lineParagraphs := blank*20 < len(lines)
+
+	var blocks []Block
+	var paragraph []string
+	separators := 0
+
+	flush := func() {
+		if len(paragraph) == 0 {
+			return
+		}
+		text := strings.Join(paragraph, " ")
+		paragraph = paragraph[:0]
+		if level, ok := headingLevel(text); ok {
+			blocks = append(blocks, Block{Kind: BlockHeading, Level: level, Text: text})
+			return
+		}
+		blocks = append(blocks, Block{Kind: BlockParagraph, Text: text})
+	}
+
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			flush()
+		case separatorPattern.MatchString(trimmed):
+			flush()
+			if len([]rune(trimmed)) >= 5 && !strings.ContainsRune(trimmed, ' ') {
+				blocks = append(blocks, Block{Kind: blockSeparator})
+				separators++
+			}
+		case lineParagraphs:
+			flush()
+			paragraph = append(paragraph, trimmed)
+			flush()
+		default:
+			if len(paragraph) == 0 {
+				if _, ok := headingLevel(trimmed); ok {
+					paragraph = append(paragraph, trimmed)
+					flush()
+					continue
+				}
+			}
+			paragraph = append(paragraph, trimmed)
+		}
+	}
+	flush()
+
+	blocks = mergeHeadingSubtitles(blocks)
+	title, chapters, _ := splitChapters(blocks, separators > 0)
+	if doc.Title == "" {
+		doc.Title = title
+	}
+	doc.Chapters = chapters
+	return doc, nil
+}
+
+func ParseMarkdown(content []byte) (*Document, error) {
+	text, enc := DecodeText(content, "")
+	lines := strings.Split(normalizeNewlines(text), "\n")
+	doc := &Document{Properties: map[string]string{"encoding": enc}}
+	lines = stripFrontMatter(lines, doc)
+
+	var blocks []Block
+	var paragraph []string
+	kind := BlockParagraph
+	fenced := false
+	separators := 0
+
+	flush := func() {
+		if len(paragraph) > 0 {
+			blocks = append(blocks, Block{Kind: kind, Text: stripInlineMarkdown(strings.Join(paragraph, " "))})
+		}
+		paragraph = paragraph[:0]
+		kind = BlockParagraph
+	}
+
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			flush()
+			fenced = !fenced
+			continue
+		}
+		if fenced || strings.HasPrefix(line, "    ") && len(paragraph) == 0 && trimmed != "" {
+			continue
+		}
+
+		if m := atxHeadingPattern.FindStringSubmatch(trimmed); m != nil {
+			flush()
+			blocks = append(blocks, Block{Kind: BlockHeading, Level: len(m[1]), Text: stripInlineMarkdown(m[2])})
+			continue
+		}
+		if len(paragraph) == 1 && kind == BlockParagraph && setextPattern.MatchString(trimmed) {
+			level := 1
+			if trimmed[0] == '-' {
+				level = 2
+			}
+			blocks = append(blocks, Block{Kind: BlockHeading, Level: level, Text: stripInlineMarkdown(paragraph[0])})
+			paragraph = paragraph[:0]
+			continue
+		}
+
+		switch {
+		case trimmed == "":
+			flush()
+		case markdownRulePattern.MatchString(trimmed):
+			flush()
+			if i > 0 {
+				blocks = append(blocks, Block{Kind: blockSeparator})
+				separators++
+			}
+		case listItemPattern.MatchString(trimmed):
+			flush()
+			kind = BlockListItem
+			paragraph = append(paragraph, listItemPattern.ReplaceAllString(trimmed, ""))
+		case strings.HasPrefix(trimmed, ">"):
+			paragraph = append(paragraph, strings.TrimSpace(strings.TrimLeft(trimmed, "> ")))
+		case strings.HasPrefix(trimmed, "|"):
+			flush()
+			if cells := tableCells(trimmed); cells != "" {
+				blocks = append(blocks, Block{Kind: BlockParagraph, Text: cells})
+			}
+		default:
+			paragraph = append(paragraph, trimmed)
+		}
+	}
+	flush()
+
+	title, chapters, _ := splitChapters(blocks, separators > 1)
+	if doc.Title == "" {
+		doc.Title = title
+	}
+	doc.Chapters = chapters
+	return doc, nil
+}
+
+func normalizeNewlines(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	return strings.ReplaceAll(text, "\r", "\n")
+}
+
+func stripGutenberg(lines []string, doc *Document) []string {
+	start, end := -1, len(lines)
+	for i, line := range lines {
+		switch {
+		case start < 0 && gutenbergStartPattern.MatchString(line):
+			start = i
+		case start >= 0 && gutenbergEndPattern.MatchString(line):
+			end = i
+		}
+		if end < len(lines) {
+			break
+		}
+	}
+	if start < 0 {
+		return lines
+	}
+
+	for _, line := range lines[:start] {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "title":
+			doc.Title = strings.TrimSpace(value)
+		case "author":
+			doc.Authors = append(doc.Authors, strings.TrimSpace(value))
+		case "language":
+			doc.Language = strings.TrimSpace(value)
+		}
+	}
+	return lines[start+1 : end]
+}
+
+func stripFrontMatter(lines []string, doc *Document) []string {
+	if len(lines) == 0 || strings.TrimSpace(lines[0]) != "---" {
+		return lines
+	}
+
+	for i := 1; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if line == "---" || line == "..." {
+			return lines[i+1:]
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = strings.Trim(strings.TrimSpace(value), `"'`)
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "title":
+			doc.Title = value
+		case "author", "authors":
+			for _, author := range strings.Split(strings.Trim(value, "[]"), ",") {
+				if author = strings.Trim(strings.TrimSpace(author), `"'`); author != "" {
+					doc.Authors = append(doc.Authors, author)
+				}
+			}
+		case "lang", "language":
+			doc.Language = value
+		default:
+			if value != "" {
+				doc.Properties[strings.ToLower(strings.TrimSpace(key))] = value
+			}
+		}
+	}
+	return lines
+}
+
+func stripInlineMarkdown(text string) string {
+	text = markdownImagePattern.ReplaceAllString(text, "")
+	text = markdownLinkPattern.ReplaceAllString(text, "$1")
+	text = markdownTagPattern.ReplaceAllString(text, "")
+	text = markdownEmphasisPattern.ReplaceAllString(text, "$1")
+	return strings.ReplaceAll(text, "`", "")
+}
+
+func tableCells(line string) string {
+	if tableRulePattern.MatchString(line) {
+		return ""
+	}
+
+	var cells []string
+	for _, cell := range strings.Split(strings.Trim(line, "|"), "|") {
+		if cell = stripInlineMarkdown(strings.TrimSpace(cell)); cell != "" {
+			cells = append(cells, cell)
+		}
+	}
+	return strings.Join(cells, ", ")
+}
+
+func headingLevel(text string) (int, bool) {
+	if len(text) > 80 {
+		return 0, false
+	}
+	switch {
+	case partHeadingPattern.MatchString(text):
+		return 1, true
+	case chapterHeadingPattern.MatchString(text), romanHeadingPattern.MatchString(text):
+		return 2, true
+	}
+	return 0, false
+}
+
+func mergeHeadingSubtitles(blocks []Block) []Block {
+	merged := make([]Block, 0, len(blocks))
+	for i := 0; i < len(blocks); i++ {
+		b := blocks[i]
+		if b.Kind == BlockHeading && i+1 < len(blocks) && isSubtitle(blocks[i+1]) {
+			b.Text = strings.TrimRight(b.Text, ".:") + ": " + blocks[i+1].Text
+			i++
+		}
+		merged = append(merged, b)
+	}
+	return merged
+}
+
+func isSubtitle(b Block) bool {
+	if b.Kind != BlockParagraph || len(b.Text) > 60 {
+		return false
+	}
+	last := b.Text[len(b.Text)-1]
+	return last != '.' && last != '!' && last != '?' && last != ',' && last != '"'
+}
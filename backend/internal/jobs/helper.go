@@ -0,0 +1,161 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"cadence/internal/data"
+	"cadence/internal/events"
+	"cadence/internal/preprocess"
+	"cadence/internal/speech"
+	"cadence/internal/syncmap"
+)
+
+// Validate rejects settings the pool cannot run with: tickers panic on a
+// non-positive interval, and the heartbeat ticks at a third of the lease.
+func (c Config) Validate() error {
+	if c.Workers < 0 {
+		return fmt.Errorf("worker count must not be negative, got %d", c.Workers)
+	}
+	if c.ChunkChars <= 0 {
+		return fmt.Errorf("chunk size must be positive, got %d", c.ChunkChars)
+	}
+	if c.PollInterval <= 0 {
+		return fmt.Errorf("poll interval must be positive, got %s", c.PollInterval)
+	}
+	if c.Lease < time.Second {
+		return fmt.Errorf("lease must be at least a second, got %s", c.Lease)
+	}
+	return nil
+}
+
+func SplitChunks(text string, limit int) []Chunk {
+	var chunks []Chunk
+	start, end := -1, 0
+
+	emit := func() {
+		if start >= 0 && strings.TrimSpace(text[start:end]) != "" {
+			chunks = append(chunks, Chunk{Text: text[start:end], Start: start, End: end})
+		}
+		start = -1
+	}
+	add := func(s, e int) {
+		if start >= 0 && e-start > limit {
+			emit()
+		}
+		if start < 0 {
+			start = s
+		}
+		end = e
+	}
+
+	offset := 0
+	for _, paragraph := range strings.Split(text, "\n\n") {
+		pStart, pEnd := offset, offset+len(paragraph)
+		offset = pEnd + 2
+
+		if len(paragraph) <= limit {
+			add(pStart, pEnd)
+			continue
+		}
+
+		for _, span := range preprocess.SplitSentences(paragraph) {
+			sStart, sEnd := pStart+span.Start, pStart+span.End
+			for sEnd-sStart > limit {
+				cut := splitPoint(text, sStart, limit)
+				add(sStart, cut)
+				emit()
+				sStart = cut
+				for sStart < sEnd && text[sStart] == ' ' {
+					sStart++
+				}
+			}
+			if sStart < sEnd {
+				add(sStart, sEnd)
+			}
+		}
+	}
+	emit()
+	return chunks
+}
+
+func splitPoint(text string, start, limit int) int {
+	if i := strings.LastIndexFunc(text[start:start+limit], unicode.IsSpace); i > limit/2 {
+		return start + i
+	}
+	cut := start + limit
+	for cut > start+1 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return cut
+}
+
+func (p *Pool) publish(job *data.Job, eventType events.EventType, chapter *data.JobChapter) {
+	event := events.JobEvent(job, eventType)
+	if chapter != nil {
+		event = event.WithChapter(chapter)
+	}
+
+	p.mu.Lock()
+	t, ok := p.running[job.ID]
+	p.mu.Unlock()
+	if ok && job.Status == data.JobRunning {
+		done := job.CompletedChars - t.startChars
+		if elapsed := time.Since(t.started).Seconds(); done > 0 && elapsed > 0 {
+			eta := int(float64(job.TotalChars-job.CompletedChars) * elapsed / float64(done))
+			event.ETASeconds = &eta
+		}
+	}
+
+	if err := p.events.Publish(context.Background(), events.JobTopic(job.ID), event); err != nil {
+		p.logger.Printf("job %d: event publish error: %v", job.ID, err)
+	}
+}
+
+func (p *Pool) discard(job *data.Job) {
+	ctx := context.Background()
+	for _, prefix := range []string{fmt.Sprintf("jobs/%d/", job.ID), fmt.Sprintf("audio/%d/%d/", job.DocumentID, job.ID)} {
+		objects, err := p.store.List(ctx, prefix)
+		if err != nil {
+			p.logger.Printf("job %d: cleanup listing error: %v", job.ID, err)
+			continue
+		}
+		for _, object := range objects {
+			if err := p.store.Delete(ctx, object.Key); err != nil {
+				p.logger.Printf("job %d: cleanup error: %v", job.ID, err)
+			}
+		}
+	}
+}
+
+func chunkKey(job *data.Job, chapter *data.JobChapter, index int) string {
+	return fmt.Sprintf("jobs/%d/chapters/%04d/chunk-%05d.mp3", job.ID, chapter.Position, index)
+}
+
+func marksKey(job *data.Job, chapter *data.JobChapter, index int) string {
+	return fmt.Sprintf("jobs/%d/chapters/%04d/chunk-%05d.json", job.ID, chapter.Position, index)
+}
+
+func AudioKey(job *data.Job, chapter *data.JobChapter) string {
+	return fmt.Sprintf("audio/%d/%d/%04d.mp3", job.DocumentID, job.ID, chapter.Position)
+}
+
+func SyncKey(job *data.Job, chapter *data.JobChapter) string {
+	return fmt.Sprintf("audio/%d/%d/%04d.sync.json", job.DocumentID, job.ID, chapter.Position)
+}
+
+func marks(words []speech.WordBoundary) []syncmap.Mark {
+	marks := make([]syncmap.Mark, 0, len(words))
+	for _, w := range words {
+		marks = append(marks, syncmap.Mark{Text: w.Text, Offset: w.Offset.Seconds(), Duration: w.Duration.Seconds()})
+	}
+	return marks
+}
+
+func audioDuration(size int64) float64 {
+	return float64(size*8) / audioBitrate
+}
@@ -0,0 +1,36 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConfigValidate(t *testing.T) {
+	valid := Config{Workers: 2, ChunkChars: 3000, MaxAttempts: 3, PollInterval: 5 * time.Second, Lease: 120 * time.Second}
+
+	tests := []struct {
+		name	string
+		modify	func(*Config)
+		wantErr	bool
+	}{
+		{"defaults", func(c *Config) {}, false},
+		{"no workers", func(c *Config) { c.Workers = 0 }, false},
+		{"negative workers", func(c *Config) { c.Workers = -1 }, true},
+		{"zero chunk size", func(c *Config) { c.ChunkChars = 0 }, true},
+		{"zero poll", func(c *Config) { c.PollInterval = 0 }, true},
+		{"negative poll", func(c *Config) { c.PollInterval = -time.Second }, true},
+		{"zero lease", func(c *Config) { c.Lease = 0 }, true},
+		{"short lease", func(c *Config) { c.Lease = 2 * time.Nanosecond }, true},
+		{"one second lease", func(c *Config) { c.Lease = time.Second }, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := valid
+			tt.modify(&config)
+			if err := config.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"cadence/internal/data"
+	"cadence/internal/events"
+	"cadence/internal/hls"
+	"cadence/internal/storage"
+)
+
+const (
+	maxChunkAttempts	= 3
+	audioBitrate		= 48000
+)
+
+var (
+	ErrCanceled			= errors.New("job canceled")
+	ErrMissingDocument	= errors.New("document no longer exists")
+)
+
+var ssmlEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\"", "&quot;", "'", "&apos;")
+
+type Config struct {
+	Workers			int
+	ChunkChars		int
+	MaxAttempts		int
+	PollInterval	time.Duration
+	Lease			time.Duration
+}
+
+type Chunk struct {
+	Text			string
+	Start			int
+	End				int
+}
+
+type Pool struct {
+	repo			*data.Repository
+	store			storage.Storage
+	events			events.Broker
+	packager		*hls.Packager
+	config			Config
+	logger			*log.Logger
+	id				string
+	wake			chan struct{}
+	wg				sync.WaitGroup
+	mu				sync.Mutex
+	running			map[uint]*task
+}
+
+type task struct {
+	cancel			context.CancelCauseFunc
+	started			time.Time
+	startChars		int
+}
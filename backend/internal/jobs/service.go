@@ -0,0 +1,407 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"cadence/internal/data"
+	"cadence/internal/events"
+	"cadence/internal/hls"
+	"cadence/internal/preprocess"
+	"cadence/internal/speech"
+	"cadence/internal/storage"
+	"cadence/internal/syncmap"
+
+	"github.com/google/uuid"
+)
+
+func NewPool(repo *data.Repository, store storage.Storage, broker events.Broker, config Config, logger *log.Logger) *Pool {
+	host, _ := os.Hostname()
+	return &Pool{
+		repo:		repo,
+		store:		store,
+		events:		broker,
+		packager:	hls.New(repo, store, logger),
+		config:		config,
+		logger:		logger,
+		id:			fmt.Sprintf("%s-%s", host, uuid.NewString()[:8]),
+		wake:		make(chan struct{}, max(config.Workers, 1)),
+		running:	make(map[uint]*task),
+	}
+}
+
+func (p *Pool) Start(ctx context.Context) {
+	for i := 0; i < p.config.Workers; i++ {
+		p.wg.Add(1)
+		go p.work(ctx)
+	}
+}
+
+func (p *Pool) Wait() {
+	p.wg.Wait()
+}
+
+func (p *Pool) Notify() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (p *Pool) Cancel(jobID uint) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if t, ok := p.running[jobID]; ok {
+		t.cancel(ErrCanceled)
+	}
+}
+
+func (p *Pool) work(ctx context.Context) {
+	defer p.wg.Done()
+	ticker := time.NewTicker(p.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		for ctx.Err() == nil {
+			job, err := p.repo.ClaimJob(p.id, time.Now().Add(-p.config.Lease))
+			if err != nil {
+				if !errors.Is(err, data.ErrNotFound) {
+					p.logger.Printf("job claim error: %v", err)
+				}
+				break
+			}
+			p.run(ctx, job)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *Pool) run(parent context.Context, job *data.Job) {
+	ctx, cancel := context.WithCancelCause(parent)
+	p.mu.Lock()
+	p.running[job.ID] = &task{cancel: cancel, started: time.Now(), startChars: job.CompletedChars}
+	p.mu.Unlock()
+	p.publish(job, events.EventStatus, nil)
+
+	done := make(chan struct{})
+	go p.heartbeat(ctx, job, cancel, done)
+
+	err := p.process(ctx, job)
+	if err != nil && ctx.Err() != nil {
+		err = context.Cause(ctx)
+	}
+	cancel(nil)
+	<-done
+
+	p.mu.Lock()
+	delete(p.running, job.ID)
+	p.mu.Unlock()
+
+	if err == nil {
+		var book *data.Book
+		if book, err = p.repo.CompleteJob(job); err == nil {
+			p.publish(job, events.EventStatus, nil)
+			p.packageStream(parent, book)
+			return
+		}
+		if errors.Is(err, data.ErrNotFound) {
+			err = ErrMissingDocument
+		}
+	}
+
+	var status data.JobStatus
+	var message string
+	switch {
+	case errors.Is(err, data.ErrLeaseLost):
+		p.logger.Printf("job %d: lease lost, abandoning", job.ID)
+		return
+	case errors.Is(err, ErrCanceled):
+		status = data.JobCanceled
+	case parent.Err() != nil:
+		status = data.JobQueued
+	case errors.Is(err, ErrMissingDocument), errors.Is(err, speech.ErrInvalidInput), job.Attempts >= p.config.MaxAttempts:
+		status, message = data.JobFailed, err.Error()
+	default:
+		status, message = data.JobQueued, err.Error()
+	}
+
+	if status != data.JobCanceled {
+		p.logger.Printf("job %d: %v", job.ID, err)
+	}
+	if err := p.repo.FinishJob(job, status, message); err != nil {
+		p.logger.Printf("job %d: failed to record status %s: %v", job.ID, status, err)
+		return
+	}
+	if status == data.JobFailed || status == data.JobCanceled {
+		p.discard(job)
+	}
+	if status == data.JobFailed {
+		p.publish(job, events.EventError, nil)
+	}
+	p.publish(job, events.EventStatus, nil)
+}
+
+func (p *Pool) heartbeat(ctx context.Context, job *data.Job, cancel context.CancelCauseFunc, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(p.config.Lease / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			canceled, err := p.repo.Heartbeat(job)
+			switch {
+			case errors.Is(err, data.ErrLeaseLost):
+				cancel(data.ErrLeaseLost)
+			case err != nil:
+				p.logger.Printf("job %d: heartbeat error: %v", job.ID, err)
+			case canceled:
+				cancel(ErrCanceled)
+			}
+		}
+	}
+}
+
+func (p *Pool) process(ctx context.Context, job *data.Job) error {
+	if job.CancelRequested {
+		return ErrCanceled
+	}
+
+	doc, err := p.repo.DocumentByID(job.DocumentID)
+	if err != nil {
+		if errors.Is(err, data.ErrNotFound) {
+			return ErrMissingDocument
+		}
+		return err
+	}
+
+	chapters := make(map[uint]*data.Chapter, len(doc.Chapters))
+	for i := range doc.Chapters {
+		chapters[doc.Chapters[i].ID] = &doc.Chapters[i]
+	}
+
+	policy := preprocess.FootnotePolicy(job.FootnotePolicy)
+	for i := range job.Chapters {
+		jc := &job.Chapters[i]
+		if jc.Status == data.JobCompleted {
+			continue
+		}
+
+		chapter, ok := chapters[jc.ChapterID]
+		if !ok {
+			return ErrMissingDocument
+		}
+		if err := p.processChapter(ctx, job, jc, preprocess.PrepareText(chapter.Text, chapter.Notes, policy)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Pool) processChapter(ctx context.Context, job *data.Job, jc *data.JobChapter, text string) error {
+	chunks := SplitChunks(text, p.config.ChunkChars)
+	if jc.CompletedChunks > len(chunks) {
+		job.CompletedChars -= jc.CompletedChars
+		jc.CompletedChunks, jc.CompletedChars = 0, 0
+	}
+	jc.ChunkCount = len(chunks)
+	jc.Status = data.JobRunning
+
+	for i := jc.CompletedChunks; i < len(chunks); i++ {
+		if ctx.Err() != nil {
+			return context.Cause(ctx)
+		}
+
+		result, err := p.synthesize(ctx, job, chunks[i].Text)
+		if err != nil {
+			return fmt.Errorf("chapter %d, chunk %d: %w", jc.Position+1, i+1, err)
+		}
+		if err := p.storeChunk(ctx, job, jc, i, result); err != nil {
+			return err
+		}
+
+		completed := jc.CharCount * chunks[i].End / max(len(text), 1)
+		job.CompletedChars += completed - jc.CompletedChars
+		jc.CompletedChars = completed
+		jc.CompletedChunks = i + 1
+		if err := p.saveProgress(job, jc); err != nil {
+			return err
+		}
+		p.publish(job, events.EventProgress, jc)
+	}
+
+	if len(chunks) > 0 {
+		key := AudioKey(job, jc)
+		sizes, err := p.assemble(ctx, job, jc, key)
+		if err != nil {
+			return err
+		}
+		var size int64
+		for _, n := range sizes {
+			size += n
+		}
+		jc.AudioKey, jc.AudioSize, jc.Duration = key, size, audioDuration(size)
+
+		if jc.SyncKey, err = p.align(ctx, job, jc, text, chunks, sizes); err != nil {
+			return err
+		}
+	}
+
+	job.CompletedChars += jc.CharCount - jc.CompletedChars
+	jc.CompletedChars = jc.CharCount
+	jc.Status = data.JobCompleted
+	job.CompletedChapters++
+	if err := p.saveProgress(job, jc); err != nil {
+		return err
+	}
+	p.publish(job, events.EventChapter, jc)
+
+	for i := 0; i < len(chunks); i++ {
+		for _, key := range []string{chunkKey(job, jc, i), marksKey(job, jc, i)} {
+			if err := p.store.Delete(context.Background(), key); err != nil && !errors.Is(err, storage.ErrNotFound) {
+				p.logger.Printf("job %d: chunk cleanup error: %v", job.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// packageStream prepares the finished book for HLS playback. The book is
+// complete without it, so failures are only logged; the book can be
+// packaged again on request.
+func (p *Pool) packageStream(ctx context.Context, book *data.Book) {
+	if len(book.Chapters) == 0 {
+		return
+	}
+	if _, err := p.packager.Package(ctx, book); err != nil {
+		p.logger.Printf("book %d: hls packaging error: %v", book.ID, err)
+	}
+}
+
+func (p *Pool) saveProgress(job *data.Job, jc *data.JobChapter) error {
+	now := time.Now()
+	job.HeartbeatAt = &now
+	return p.repo.SaveJobProgress(job, jc)
+}
+
+func (p *Pool) storeChunk(ctx context.Context, job *data.Job, jc *data.JobChapter, index int, result *speech.Result) error {
+	var audio []byte
+	var words []speech.WordBoundary
+	if result != nil {
+		audio, words = result.Audio, result.Words
+	}
+
+	encoded, err := json.Marshal(marks(words))
+	if err != nil {
+		return err
+	}
+	if _, err := p.store.Put(ctx, marksKey(job, jc, index), bytes.NewReader(encoded), "application/json"); err != nil {
+		return err
+	}
+	_, err = p.store.Put(ctx, chunkKey(job, jc, index), bytes.NewReader(audio), "audio/mpeg")
+	return err
+}
+
+func (p *Pool) synthesize(ctx context.Context, job *data.Job, text string) (*speech.Result, error) {
+	for attempt := 1; ; attempt++ {
+		req := speech.Request{
+			Text:	ssmlEscaper.Replace(text),
+			Voice:	job.Voice,
+			Pitch:	job.Pitch,
+			Rate:	job.Rate,
+			Volume:	job.Volume,
+		}
+
+		result, err := req.SynthesizeWithBoundaries()
+		switch {
+		case err == nil:
+			return result, nil
+		case errors.Is(err, speech.ErrNoAudio):
+			return nil, nil
+		case errors.Is(err, speech.ErrInvalidInput), attempt >= maxChunkAttempts:
+			return nil, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, context.Cause(ctx)
+		case <-time.After(time.Duration(attempt) * 2 * time.Second):
+		}
+	}
+}
+
+func (p *Pool) assemble(ctx context.Context, job *data.Job, jc *data.JobChapter, key string) ([]int64, error) {
+	sizes := make([]int64, jc.ChunkCount)
+	pr, pw := io.Pipe()
+	go func() {
+		for i := 0; i < jc.ChunkCount; i++ {
+			r, err := p.store.Get(ctx, chunkKey(job, jc, i))
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			sizes[i], err = io.Copy(pw, r)
+			r.Close()
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+		pw.Close()
+	}()
+
+	_, err := p.store.Put(ctx, key, pr, "audio/mpeg")
+	pr.CloseWithError(err)
+	if err != nil {
+		return nil, err
+	}
+	return sizes, nil
+}
+
+// align builds the chapter's sync map from the word boundaries recorded for
+// each chunk. Chunks synthesized before boundaries were recorded have no
+// marks and are interpolated by character offset.
+func (p *Pool) align(ctx context.Context, job *data.Job, jc *data.JobChapter, text string, chunks []Chunk, sizes []int64) (string, error) {
+	segments := make([]syncmap.Segment, len(chunks))
+	for i, chunk := range chunks {
+		segments[i] = syncmap.Segment{Start: chunk.Start, End: chunk.End, Duration: audioDuration(sizes[i])}
+
+		r, err := p.store.Get(ctx, marksKey(job, jc, i))
+		if errors.Is(err, storage.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		err = json.NewDecoder(r).Decode(&segments[i].Marks)
+		r.Close()
+		if err != nil {
+			p.logger.Printf("job %d: chapter %d, chunk %d: discarding unreadable word boundaries: %v", job.ID, jc.Position+1, i+1, err)
+		}
+	}
+
+	encoded, err := json.Marshal(syncmap.Build(text, segments))
+	if err != nil {
+		return "", err
+	}
+	key := SyncKey(job, jc)
+	if _, err := p.store.Put(ctx, key, bytes.NewReader(encoded), "application/json"); err != nil {
+		return "", err
+	}
+	return key, nil
+}
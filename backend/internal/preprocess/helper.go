@@ -0,0 +1,159 @@
+package preprocess
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+func ParseFootnotePolicy(value string) (FootnotePolicy, error) {
+	switch policy := FootnotePolicy(strings.ToLower(strings.TrimSpace(value))); policy {
+	case "":
+		return FootnoteDrop, nil
+	case FootnoteDrop, FootnoteInline, FootnoteEndnotes:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrInvalidPolicy, value)
+	}
+}
+
+func IsReferenceHeading(text string) bool {
+	text = strings.ToLower(strings.Trim(strings.TrimSpace(text), ".:#* "))
+	return referenceHeadings[text]
+}
+
+func NormalizeMarker(marker string) string {
+	marker = strings.Trim(marker, "[]^")
+	var b strings.Builder
+	for _, r := range marker {
+		if d, ok := superscriptDigits[r]; ok {
+			b.WriteRune(d)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func expandMarker(marker string) []string {
+	marker = NormalizeMarker(marker)
+	if !strings.ContainsAny(marker, ",–-") {
+		return []string{marker}
+	}
+
+	var keys []string
+	for _, part := range strings.Split(marker, ",") {
+		part = strings.TrimSpace(part)
+		bounds := strings.FieldsFunc(part, func(r rune) bool { return r == '-' || r == '–' })
+		if len(bounds) != 2 {
+			keys = append(keys, part)
+			continue
+		}
+
+		var lo, hi int
+		if _, err := fmt.Sscanf(strings.TrimSpace(bounds[0])+" "+strings.TrimSpace(bounds[1]), "%d %d", &lo, &hi); err != nil || hi < lo || hi-lo > 50 {
+			keys = append(keys, part)
+			continue
+		}
+		for i := lo; i <= hi; i++ {
+			keys = append(keys, fmt.Sprint(i))
+		}
+	}
+	return keys
+}
+
+func CleanSpacing(text string) string {
+	text = spaceBeforePunct.ReplaceAllString(text, "$1")
+	text = multiSpace.ReplaceAllString(text, " ")
+	return strings.TrimSpace(text)
+}
+
+func SplitSentences(text string) []Span {
+	var spans []Span
+	start := 0
+	for start < len(text) && isSpaceByte(text[start]) {
+		start++
+	}
+
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		i += size
+		if r != '.' && r != '!' && r != '?' && r != '…' {
+			continue
+		}
+
+		end := i
+		for end < len(text) {
+			next, n := utf8.DecodeRuneInString(text[end:])
+			if !strings.ContainsRune(`.!?…"'”’)]»`, next) {
+				break
+			}
+			end += n
+		}
+		if loc := trailingMarker.FindStringIndex(text[end:]); loc != nil {
+			end += loc[1]
+		}
+		if end < len(text) && !isSpaceByte(text[end]) {
+			continue
+		}
+		if r == '.' && isAbbreviation(text[start:i-size], text[end:]) {
+			continue
+		}
+
+		next := end
+		for next < len(text) && isSpaceByte(text[next]) {
+			next++
+		}
+		if next < len(text) {
+			following, _ := utf8.DecodeRuneInString(text[next:])
+			if unicode.IsLower(following) {
+				continue
+			}
+		}
+
+		if strings.TrimSpace(text[start:end]) != "" {
+			spans = append(spans, Span{Start: start, End: end})
+		}
+		start = next
+		i = next
+	}
+
+	if rest := strings.TrimSpace(text[start:]); rest != "" {
+		end := len(text)
+		for end > start && isSpaceByte(text[end-1]) {
+			end--
+		}
+		spans = append(spans, Span{Start: start, End: end})
+	}
+	return spans
+}
+
+// isAbbreviation reports whether the period closing before belongs to an
+// abbreviation rather than ending the sentence. A lone capital counts only
+// as an initial leading into another initial or a name, as in "J. R. R.
+// Tolkien"; "I" is left out since it far more often ends a sentence.
+func isAbbreviation(before, after string) bool {
+	fields := strings.Fields(before)
+	if len(fields) == 0 {
+		return false
+	}
+	word := strings.TrimLeft(fields[len(fields)-1], `"'“‘([`)
+	if abbreviations[strings.ToLower(word)] {
+		return true
+	}
+	r, size := utf8.DecodeRuneInString(word)
+	if size != len(word) || !unicode.IsUpper(r) || r == 'I' {
+		return false
+	}
+	following := strings.Fields(after)
+	if len(following) == 0 {
+		return false
+	}
+	next, _ := utf8.DecodeRuneInString(following[0])
+	return unicode.IsUpper(next)
+}
+
+func isSpaceByte(b byte) bool {
+	return b == ' ' || b == '\n' || b == '\t' || b == '\r'
+}
@@ -0,0 +1,35 @@
+package preprocess
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitSentences(t *testing.T) {
+	tests := []struct {
+		name	string
+		text	string
+		want	[]string
+	}{
+		{"plain", "One. Two! Three?", []string{"One.", "Two!", "Three?"}},
+		{"title", "Mr. Smith left. He came back.", []string{"Mr. Smith left.", "He came back."}},
+		{"initials", "J. R. R. Tolkien wrote it. Then he rested.", []string{"J. R. R. Tolkien wrote it.", "Then he rested."}},
+		{"pronoun", "So did I. Then we left.", []string{"So did I.", "Then we left."}},
+		{"lowercase letter", "It was plan a. The next one failed.", []string{"It was plan a.", "The next one failed."}},
+		{"initial at end", "He signed it J. ", []string{"He signed it J."}},
+		{"lowercase continuation", "See fig. b for details.", []string{"See fig. b for details."}},
+		{"closing quote", `"Go." She went.`, []string{`"Go."`, "She went."}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, span := range SplitSentences(tt.text) {
+				got = append(got, tt.text[span.Start:span.End])
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SplitSentences(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
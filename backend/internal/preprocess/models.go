@@ -0,0 +1,56 @@
+package preprocess
+
+import (
+	"errors"
+	"regexp"
+)
+
+type FootnotePolicy string
+
+const (
+	FootnoteDrop		FootnotePolicy = "drop"
+	FootnoteInline		FootnotePolicy = "inline"
+	FootnoteEndnotes	FootnotePolicy = "end"
+)
+
+var (
+	ErrInvalidPolicy	= errors.New("invalid footnote policy")
+)
+
+type Notes map[string]string
+
+type Span struct {
+	Start		int
+	End			int
+}
+
+var (
+	markerPattern		= regexp.MustCompile(`\[\^?(\d{1,3}|[a-z])\]|\[(\d{1,3}(?:\s*[,–-]\s*\d{1,3})+)\]|[¹²³⁴⁵⁶⁷⁸⁹⁰]+`)
+	definitionPattern	= regexp.MustCompile(`^\s*(?:\[\^?(\d{1,3}|[a-z])\]:?|(\d{1,3})[.)]|([¹²³⁴⁵⁶⁷⁸⁹⁰]+))\s+(.+)$`)
+	markdownNotePattern	= regexp.MustCompile(`^\s*\[\^(\w+)\]:\s*(.+)$`)
+	trailingMarker		= regexp.MustCompile(`^(?:\[\^?[\d,\s–-]{1,12}\]|\[\^?[a-z]\]|[¹²³⁴⁵⁶⁷⁸⁹⁰]+)`)
+	spaceBeforePunct	= regexp.MustCompile(`\s+([.,;:!?])`)
+	multiSpace			= regexp.MustCompile(`[ \t]{2,}`)
+)
+
+var referenceHeadings = map[string]bool{
+	"notes":			true,
+	"footnotes":		true,
+	"endnotes":			true,
+	"references":		true,
+	"bibliography":		true,
+	"works cited":		true,
+	"sources":			true,
+	"citations":		true,
+}
+
+var superscriptDigits = map[rune]rune{
+	'⁰': '0', '¹': '1', '²': '2', '³': '3', '⁴': '4',
+	'⁵': '5', '⁶': '6', '⁷': '7', '⁸': '8', '⁹': '9',
+}
+
+var abbreviations = map[string]bool{
+	"mr": true, "mrs": true, "ms": true, "dr": true, "st": true, "jr": true, "sr": true,
+	"prof": true, "vs": true, "etc": true, "e.g": true, "i.e": true, "no": true,
+	"vol": true, "fig": true, "ch": true, "mt": true, "gen": true, "col": true, "lt": true,
+}
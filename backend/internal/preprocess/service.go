@@ -0,0 +1,203 @@
+package preprocess
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func ExtractNotes(paragraphs []string) ([]string, Notes) {
+	notes := make(Notes)
+	body := make([]string, 0, len(paragraphs))
+
+	for _, p := range paragraphs {
+		if m := markdownNotePattern.FindStringSubmatch(p); m != nil {
+			notes[m[1]] = strings.TrimSpace(m[2])
+			continue
+		}
+		body = append(body, p)
+	}
+
+	section := -1
+	for i := len(body) - 1; i >= 0; i-- {
+		if IsReferenceHeading(body[i]) {
+			section = i
+			break
+		}
+	}
+	if section < 0 || section == len(body)-1 {
+		return body, notes
+	}
+
+	entries := body[section+1:]
+	defined := 0
+	for _, entry := range entries {
+		if definitionPattern.MatchString(entry) {
+			defined++
+		}
+	}
+
+	heading := strings.ToLower(strings.Trim(strings.TrimSpace(body[section]), ".:#* "))
+	bibliography := heading == "references" || heading == "bibliography" || heading == "works cited" || heading == "sources"
+	if defined*2 < len(entries) && !bibliography {
+		return body, notes
+	}
+
+	last := ""
+	for i, entry := range entries {
+		if m := definitionPattern.FindStringSubmatch(entry); m != nil {
+			last = NormalizeMarker(m[1] + m[2] + m[3])
+			notes[last] = strings.TrimSpace(m[4])
+			continue
+		}
+		if last != "" && defined > 0 {
+			notes[last] += " " + strings.TrimSpace(entry)
+			continue
+		}
+		notes["ref-"+strconv.Itoa(i+1)] = strings.TrimSpace(entry)
+	}
+
+	return body[:section], notes
+}
+
+func FindMarkers(text string, notes Notes) []string {
+	var keys []string
+	for _, loc := range markerIndexes(text, notes) {
+		keys = append(keys, expandMarker(text[loc[0]:loc[1]])...)
+	}
+	return keys
+}
+
+func StripMarkers(text string, notes Notes) string {
+	locs := markerIndexes(text, notes)
+	if len(locs) == 0 {
+		return text
+	}
+
+	var b strings.Builder
+	prev := 0
+	for _, loc := range locs {
+		b.WriteString(text[prev:loc[0]])
+		prev = loc[1]
+	}
+	b.WriteString(text[prev:])
+	return CleanSpacing(b.String())
+}
+
+// PrepareText is the text a chapter is synthesized from: its stored text,
+// paragraphs separated by blank lines, with the footnote policy applied.
+func PrepareText(text string, notes Notes, policy FootnotePolicy) string {
+	paragraphs := strings.Split(text, "\n\n")
+	return strings.Join(ApplyFootnotePolicy(paragraphs, notes, policy), "\n\n")
+}
+
+func ApplyFootnotePolicy(paragraphs []string, notes Notes, policy FootnotePolicy) []string {
+	out := make([]string, 0, len(paragraphs))
+	read := make(map[string]bool)
+	var referenced []string
+
+	for _, p := range paragraphs {
+		if policy != FootnoteInline {
+			for _, key := range FindMarkers(p, notes) {
+				if _, ok := notes[key]; ok && !read[key] {
+					read[key] = true
+					referenced = append(referenced, key)
+				}
+			}
+			out = append(out, StripMarkers(p, notes))
+			continue
+		}
+
+		var b strings.Builder
+		for _, span := range SplitSentences(p) {
+			sentence := p[span.Start:span.End]
+			if b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			b.WriteString(StripMarkers(sentence, notes))
+
+			for _, key := range FindMarkers(sentence, notes) {
+				text, ok := notes[key]
+				if !ok || read[key] {
+					continue
+				}
+				read[key] = true
+				fmt.Fprintf(&b, " %s", noteSentence("Footnote", key, text))
+			}
+		}
+		out = append(out, b.String())
+	}
+
+	if policy != FootnoteEndnotes {
+		return out
+	}
+
+	for _, key := range sortedKeys(notes) {
+		if !read[key] && strings.HasPrefix(key, "ref-") {
+			referenced = append(referenced, key)
+		}
+	}
+	if len(referenced) == 0 {
+		return out
+	}
+
+	out = append(out, "Notes.")
+	for _, key := range referenced {
+		if strings.HasPrefix(key, "ref-") {
+			out = append(out, terminate(notes[key]))
+			continue
+		}
+		out = append(out, noteSentence("Note", key, notes[key]))
+	}
+	return out
+}
+
+func markerIndexes(text string, notes Notes) [][]int {
+	var locs [][]int
+	for _, loc := range markerPattern.FindAllStringIndex(text, -1) {
+		marker := text[loc[0]:loc[1]]
+		if !strings.HasPrefix(marker, "[") && (loc[0] == 0 || isSpaceByte(text[loc[0]-1])) {
+			continue
+		}
+
+		key := NormalizeMarker(marker)
+		if len(key) == 1 && key[0] >= 'a' && key[0] <= 'z' {
+			if _, ok := notes[key]; !ok {
+				continue
+			}
+		}
+		locs = append(locs, loc)
+	}
+	return locs
+}
+
+func noteSentence(label, key, text string) string {
+	return fmt.Sprintf("%s %s: %s", label, key, terminate(StripMarkers(text, nil)))
+}
+
+func terminate(text string) string {
+	text = strings.TrimSpace(text)
+	if text == "" || strings.ContainsAny(text[len(text)-1:], ".!?") {
+		return text
+	}
+	return text + "."
+}
+
+func sortedKeys(notes Notes) []string {
+	keys := make([]string, 0, len(notes))
+	for key := range notes {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := strings.TrimPrefix(keys[i], "ref-"), strings.TrimPrefix(keys[j], "ref-")
+		na, errA := strconv.Atoi(a)
+		nb, errB := strconv.Atoi(b)
+		if errA == nil && errB == nil && na != nb {
+			return na < nb
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
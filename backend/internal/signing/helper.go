@@ -0,0 +1,56 @@
+package signing
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+func ParseKeys(spec string) ([]Key, error) {
+	var keys []Key
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		id, secret, ok := strings.Cut(entry, ":")
+		if !ok || id == "" || strings.ContainsAny(id, "&=?#") {
+			return nil, fmt.Errorf("%w: expected id:secret", ErrInvalidKey)
+		}
+		decoded := []byte(secret)
+		if encoded, ok := strings.CutPrefix(secret, "base64:"); ok {
+			var err error
+			if decoded, err = base64.StdEncoding.DecodeString(encoded); err != nil {
+				return nil, fmt.Errorf("%w: key %s is not valid base64", ErrInvalidKey, id)
+			}
+		}
+		if len(decoded) < 32 {
+			return nil, fmt.Errorf("%w: key %s must be at least 32 bytes", ErrInvalidKey, id)
+		}
+		keys = append(keys, Key{ID: id, Secret: decoded})
+	}
+	if len(keys) == 0 {
+		return nil, ErrNoKeys
+	}
+	return keys, nil
+}
+
+func GenerateKey() (Key, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return Key{}, err
+	}
+	return Key{ID: "ephemeral-" + hex.EncodeToString(secret[:4]), Secret: secret}, nil
+}
+
+func mac(secret []byte, path string, userID uint, expires int64) []byte {
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte(path + "\n" + strconv.FormatUint(uint64(userID), 10) + "\n" + strconv.FormatInt(expires, 10)))
+	return h.Sum(nil)
+}
@@ -0,0 +1,31 @@
+package signing
+
+import (
+	"errors"
+)
+
+const (
+	ParamExpires		= "exp"
+	ParamUser			= "uid"
+	ParamKey			= "kid"
+	ParamSignature		= "sig"
+)
+
+var (
+	ErrNoKeys			= errors.New("no signing keys configured")
+	ErrInvalidKey		= errors.New("invalid signing key")
+	ErrMissing			= errors.New("missing signature")
+	ErrExpired			= errors.New("signature expired")
+	ErrInvalid			= errors.New("invalid signature")
+	ErrUnknownKey		= errors.New("unknown signing key")
+)
+
+type Key struct {
+	ID				string
+	Secret			[]byte
+}
+
+type Signer struct {
+	keys			[]Key
+	byID			map[string][]byte
+}
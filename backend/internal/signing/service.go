@@ -0,0 +1,69 @@
+package signing
+
+import (
+	"crypto/hmac"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+func NewSigner(keys []Key) (*Signer, error) {
+	if len(keys) == 0 {
+		return nil, ErrNoKeys
+	}
+
+	byID := make(map[string][]byte, len(keys))
+	for _, key := range keys {
+		if _, dup := byID[key.ID]; dup {
+			return nil, fmt.Errorf("%w: duplicate key id %s", ErrInvalidKey, key.ID)
+		}
+		byID[key.ID] = key.Secret
+	}
+	return &Signer{keys: keys, byID: byID}, nil
+}
+
+func (s *Signer) Sign(path string, userID uint, expires time.Time) url.Values {
+	key := s.keys[0]
+	exp := expires.Unix()
+	return url.Values{
+		ParamExpires:	{strconv.FormatInt(exp, 10)},
+		ParamUser:		{strconv.FormatUint(uint64(userID), 10)},
+		ParamKey:		{key.ID},
+		ParamSignature:	{base64.RawURLEncoding.EncodeToString(mac(key.Secret, path, userID, exp))},
+	}
+}
+
+func (s *Signer) SignURL(base, path string, userID uint, expires time.Time) string {
+	return base + path + "?" + s.Sign(path, userID, expires).Encode()
+}
+
+func (s *Signer) Verify(path string, query url.Values, now time.Time) (uint, error) {
+	sig := query.Get(ParamSignature)
+	if sig == "" {
+		return 0, ErrMissing
+	}
+
+	exp, err := strconv.ParseInt(query.Get(ParamExpires), 10, 64)
+	if err != nil {
+		return 0, ErrInvalid
+	}
+	userID, err := strconv.ParseUint(query.Get(ParamUser), 10, 32)
+	if err != nil {
+		return 0, ErrInvalid
+	}
+	secret, ok := s.byID[query.Get(ParamKey)]
+	if !ok {
+		return 0, ErrUnknownKey
+	}
+
+	given, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(given, mac(secret, path, uint(userID), exp)) {
+		return 0, ErrInvalid
+	}
+	if now.Unix() > exp {
+		return 0, ErrExpired
+	}
+	return uint(userID), nil
+}
@@ -0,0 +1,109 @@
+package signing
+
+import (
+	"bytes"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	current := Key{ID: "current", Secret: bytes.Repeat([]byte("c"), 32)}
+	previous := Key{ID: "previous", Secret: bytes.Repeat([]byte("p"), 32)}
+	signer, err := NewSigner([]Key{current, previous})
+	if err != nil {
+		t.Fatal(err)
+	}
+	old, err := NewSigner([]Key{previous})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Unix(1700000000, 0)
+	expires := now.Add(time.Hour)
+	const path = "/api/books/7/chapters/0/audio"
+
+	tests := []struct {
+		name	string
+		query	func() url.Values
+		path	string
+		now		time.Time
+		user	uint
+		err		error
+	}{
+		{"valid", func() url.Values { return signer.Sign(path, 42, expires) }, path, now, 42, nil},
+		{"rotated key", func() url.Values { return old.Sign(path, 42, expires) }, path, now, 42, nil},
+		{"expired", func() url.Values { return signer.Sign(path, 42, expires) }, path, expires.Add(time.Second), 0, ErrExpired},
+		{"other path", func() url.Values { return signer.Sign(path, 42, expires) }, "/api/books/8/chapters/0/audio", now, 0, ErrInvalid},
+		{"missing", func() url.Values { return url.Values{} }, path, now, 0, ErrMissing},
+		{"other user", func() url.Values {
+			q := signer.Sign(path, 42, expires)
+			q.Set(ParamUser, "43")
+			return q
+		}, path, now, 0, ErrInvalid},
+		{"extended", func() url.Values {
+			q := signer.Sign(path, 42, expires)
+			q.Set(ParamExpires, "9999999999")
+			return q
+		}, path, now, 0, ErrInvalid},
+		{"unknown key", func() url.Values {
+			q := signer.Sign(path, 42, expires)
+			q.Set(ParamKey, "retired")
+			return q
+		}, path, now, 0, ErrUnknownKey},
+		{"malformed signature", func() url.Values {
+			q := signer.Sign(path, 42, expires)
+			q.Set(ParamSignature, "not base64!")
+			return q
+		}, path, now, 0, ErrInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, err := signer.Verify(tt.path, tt.query(), tt.now)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Verify() error = %v, want %v", err, tt.err)
+			}
+			if user != tt.user {
+				t.Errorf("Verify() user = %d, want %d", user, tt.user)
+			}
+		})
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	secret := "0123456789abcdef0123456789abcdef"
+
+	tests := []struct {
+		name	string
+		spec	string
+		ids		[]string
+		err		error
+	}{
+		{"single", "a:" + secret, []string{"a"}, nil},
+		{"rotation", "new:" + secret + ", old:" + secret, []string{"new", "old"}, nil},
+		{"base64", "a:base64:MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=", []string{"a"}, nil},
+		{"empty", " , ", nil, ErrNoKeys},
+		{"short", "a:secret", nil, ErrInvalidKey},
+		{"no id", ":" + secret, nil, ErrInvalidKey},
+		{"bad base64", "a:base64:!!!", nil, ErrInvalidKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := ParseKeys(tt.spec)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("ParseKeys() error = %v, want %v", err, tt.err)
+			}
+			if len(keys) != len(tt.ids) {
+				t.Fatalf("ParseKeys() returned %d keys, want %d", len(keys), len(tt.ids))
+			}
+			for i, key := range keys {
+				if key.ID != tt.ids[i] {
+					t.Errorf("key %d id = %q, want %q", i, key.ID, tt.ids[i])
+				}
+			}
+		})
+	}
+}
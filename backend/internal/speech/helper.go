@@ -1,6 +1,7 @@
 package speech
 
 import (
+	"bytes"
 	"fmt"
 	"strings"
 	"regexp"
@@ -150,4 +151,29 @@ func (r *Request) SetRequestDefaults() {
 	if !strings.HasSuffix(r.Pitch, "Hz") {
 		r.Pitch += "Hz"
 	}
+}
+
+func parseMetadata(message []byte) []WordBoundary {
+	_, body, ok := bytes.Cut(message, []byte("\r\n\r\n"))
+	if !ok {
+		return nil
+	}
+
+	var meta metadataMessage
+	if err := json.Unmarshal(body, &meta); err != nil {
+		return nil
+	}
+
+	var words []WordBoundary
+	for _, m := range meta.Metadata {
+		if m.Type != "WordBoundary" {
+			continue
+		}
+		words = append(words, WordBoundary{
+			Text:		m.Data.Text.Text,
+			Offset:		time.Duration(m.Data.Offset) * tickDuration,
+			Duration:	time.Duration(m.Data.Duration) * tickDuration,
+		})
+	}
+	return words
 }
\ No newline at end of file
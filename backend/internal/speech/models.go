@@ -3,11 +3,15 @@ package speech
 import (
 	"errors"
 	"sync"
+	"time"
 )
 
 const (
 	minValue 			= -100
 	maxValue 			= 100
+
+	// Edge TTS reports boundary offsets in 100ns ticks.
+	tickDuration		= 100 * time.Nanosecond
 )
 
 var (
@@ -33,6 +37,30 @@ type Voice struct {
 	Locale    	string `json:"Locale"`
 }
 
+type WordBoundary struct {
+	Text		string			`json:"text"`
+	Offset		time.Duration	`json:"offset"`
+	Duration	time.Duration	`json:"duration"`
+}
+
+type Result struct {
+	Audio		[]byte
+	Words		[]WordBoundary
+}
+
+type metadataMessage struct {
+	Metadata	[]struct {
+		Type	string	`json:"Type"`
+		Data	struct {
+			Offset		int64	`json:"Offset"`
+			Duration	int64	`json:"Duration"`
+			Text		struct {
+				Text	string	`json:"Text"`
+			}	`json:"text"`
+		}	`json:"Data"`
+	}	`json:"Metadata"`
+}
+
 type VoiceRegistry struct {
 	Voices 		map[string]bool
 	Mu			sync.RWMutex
@@ -47,7 +47,16 @@ func (r *Request) GetSSML() (string, error) {
 }
 
 func ReadAudioResponse(c *websocket.Conn) ([]byte, error) {
+	result, err := readResponse(c)
+	if err != nil {
+		return nil, err
+	}
+	return result.Audio, nil
+}
+
+func readResponse(c *websocket.Conn) (*Result, error) {
 	var audioBuffer bytes.Buffer
+	var words []WordBoundary
 
 	for {
 		messageType, message, err := c.ReadMessage()
@@ -66,8 +75,10 @@ func ReadAudioResponse(c *websocket.Conn) ([]byte, error) {
 					return nil, errors.Join(ErrSynthesis, fmt.Errorf("failed to write audio data: %w", err))
 				}
 			}
+		case bytes.Contains(message, []byte("Path:audio.metadata")):
+			words = append(words, parseMetadata(message)...)
 		case bytes.Contains(message, []byte("Path:turn.end")):
-			return audioBuffer.Bytes(), nil
+			return &Result{Audio: audioBuffer.Bytes(), Words: words}, nil
 		case bytes.Contains(message, []byte("Path:error")):
 			return nil, errors.Join(ErrSynthesis, fmt.Errorf("service error: %s", string(message)))
 		}
@@ -77,10 +88,19 @@ func ReadAudioResponse(c *websocket.Conn) ([]byte, error) {
 		return nil, ErrNoAudio
 	}
 
-	return audioBuffer.Bytes(), nil
+	return &Result{Audio: audioBuffer.Bytes(), Words: words}, nil
 }
 
 func (r *Request) Synthesize() ([]byte, error) {
+	result, err := r.SynthesizeWithBoundaries()
+	if err != nil {
+		return nil, err
+	}
+
+	return result.Audio, nil
+}
+
+func (r *Request) SynthesizeWithBoundaries() (*Result, error) {
 	ssml, err := r.GetSSML()
 	if err != nil {
 		return nil, err
@@ -115,10 +135,5 @@ func (r *Request) Synthesize() ([]byte, error) {
 		return nil, errors.Join(ErrSynthesis, fmt.Errorf("failed to send SSML: %w", err))
 	}
 
-	audio, err := ReadAudioResponse(c)
-	if err != nil {
-		return nil, err
-	}
-
-	return audio, nil
+	return readResponse(c)
 }
\ No newline at end of file
@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	BackendFilesystem	= "filesystem"
+	BackendS3			= "s3"
+)
+
+const (
+	s3Service			= "s3"
+	s3Algorithm			= "AWS4-HMAC-SHA256"
+	s3UnsignedPayload	= "UNSIGNED-PAYLOAD"
+	s3TimeFormat		= "20060102T150405Z"
+	s3DateFormat		= "20060102"
+)
+
+var (
+	ErrNotFound			= errors.New("object not found")
+	ErrInvalidKey		= errors.New("invalid object key")
+	ErrInvalidRange		= errors.New("invalid range")
+	ErrStorage			= errors.New("storage error")
+	ErrConfig			= errors.New("invalid storage configuration")
+)
+
+type Storage interface {
+	Put(ctx context.Context, key string, r io.Reader, contentType string) (int64, error)
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error)
+	Stat(ctx context.Context, key string) (*ObjectInfo, error)
+	Delete(ctx context.Context, key string) error
+	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
+}
+
+type ObjectInfo struct {
+	Key				string		`json:"key"`
+	Size			int64		`json:"size"`
+	ContentType		string		`json:"content_type,omitempty"`
+	ETag			string		`json:"etag"`
+	ModTime			time.Time	`json:"mod_time"`
+}
+
+type Config struct {
+	Backend			string
+	Path			string
+	Endpoint		string
+	Region			string
+	Bucket			string
+	AccessKey		string
+	SecretKey		string
+	PathStyle		bool
+}
+
+type FileStore struct {
+	Root			string
+}
+
+type S3Store struct {
+	endpoint		*url.URL
+	bucket			string
+	region			string
+	accessKey		string
+	secretKey		string
+	pathStyle		bool
+	client			*http.Client
+}
+
+type s3ListResult struct {
+	Contents				[]struct {
+		Key					string		`xml:"Key"`
+		Size				int64		`xml:"Size"`
+		ETag				string		`xml:"ETag"`
+		LastModified		time.Time	`xml:"LastModified"`
+	}									`xml:"Contents"`
+	IsTruncated				bool		`xml:"IsTruncated"`
+	NextContinuationToken	string		`xml:"NextContinuationToken"`
+}
+
+type s3Error struct {
+	Code			string		`xml:"Code"`
+	Message			string		`xml:"Message"`
+}
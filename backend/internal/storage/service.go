@@ -0,0 +1,417 @@
+package storage
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LoadConfig reads the storage settings shared by every command from the
+// environment.
+func LoadConfig() Config {
+	return Config{
+		Backend:	getEnv("STORAGE_BACKEND", BackendFilesystem),
+		Path:		getEnv("STORAGE_PATH", "./storage"),
+		Endpoint:	getEnv("S3_ENDPOINT", ""),
+		Region:		getEnv("S3_REGION", "us-east-1"),
+		Bucket:		getEnv("S3_BUCKET", ""),
+		AccessKey:	getEnv("S3_ACCESS_KEY", ""),
+		SecretKey:	getEnv("S3_SECRET_KEY", ""),
+		PathStyle:	getEnv("S3_PATH_STYLE", "true") == "true",
+	}
+}
+
+func New(config Config) (Storage, error) {
+	switch config.Backend {
+	case "", BackendFilesystem:
+		return NewFileStore(config.Path)
+	case BackendS3:
+		return NewS3Store(config)
+	}
+	return nil, fmt.Errorf("%w: unknown backend %q", ErrConfig, config.Backend)
+}
+
+func NewFileStore(root string) (*FileStore, error) {
+	if err := os.MkdirAll(root, 0o750); err != nil {
+		return nil, errors.Join(ErrStorage, fmt.Errorf("failed to create storage root: %w", err))
+	}
+	return &FileStore{Root: root}, nil
+}
+
+func (s *FileStore) Put(ctx context.Context, key string, r io.Reader, _ string) (int64, error) {
+	target, err := s.path(key)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0o750); err != nil {
+		return 0, errors.Join(ErrStorage, err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".upload-*")
+	if err != nil {
+		return 0, errors.Join(ErrStorage, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	n, err := io.Copy(tmp, r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		return 0, errors.Join(ErrStorage, fmt.Errorf("failed to write %s: %w", key, err))
+	}
+
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return 0, errors.Join(ErrStorage, err)
+	}
+	return n, nil
+}
+
+func (s *FileStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	return s.GetRange(ctx, key, 0, -1)
+}
+
+func (s *FileStore) GetRange(_ context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	target, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(target)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	if err != nil {
+		return nil, errors.Join(ErrStorage, err)
+	}
+
+	if offset == 0 && length < 0 {
+		return f, nil
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, errors.Join(ErrStorage, err)
+	}
+	if offset < 0 || offset >= info.Size() && info.Size() > 0 {
+		f.Close()
+		return nil, fmt.Errorf("%w: offset %d beyond size %d", ErrInvalidRange, offset, info.Size())
+	}
+	if length < 0 || offset+length > info.Size() {
+		length = info.Size() - offset
+	}
+	return &limitedFile{Reader: io.NewSectionReader(f, offset, length), file: f}, nil
+}
+
+func (s *FileStore) Stat(_ context.Context, key string) (*ObjectInfo, error) {
+	target, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(target)
+	if errors.Is(err, fs.ErrNotExist) || err == nil && info.IsDir() {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	if err != nil {
+		return nil, errors.Join(ErrStorage, err)
+	}
+
+	object := fileInfo(key, info)
+	return &object, nil
+}
+
+func (s *FileStore) Delete(_ context.Context, key string) error {
+	target, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(target); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return errors.Join(ErrStorage, err)
+	}
+
+	root := filepath.Clean(s.Root)
+	for dir := filepath.Dir(target); dir != root && strings.HasPrefix(dir, root); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+	return nil
+}
+
+func (s *FileStore) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	dir := s.Root
+	if i := strings.LastIndex(prefix, "/"); i > 0 {
+		cleaned, err := CleanKey(prefix[:i])
+		if err != nil {
+			return nil, err
+		}
+		dir = filepath.Join(s.Root, filepath.FromSlash(cleaned))
+	}
+
+	var objects []ObjectInfo
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".upload-") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(s.Root, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		objects = append(objects, fileInfo(key, info))
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Join(ErrStorage, err)
+	}
+	return objects, nil
+}
+
+func NewS3Store(config Config) (*S3Store, error) {
+	endpoint, err := url.Parse(config.Endpoint)
+	if err != nil || endpoint.Host == "" || (endpoint.Scheme != "http" && endpoint.Scheme != "https") {
+		return nil, fmt.Errorf("%w: invalid S3 endpoint %q", ErrConfig, config.Endpoint)
+	}
+	if config.Bucket == "" || config.AccessKey == "" || config.SecretKey == "" {
+		return nil, fmt.Errorf("%w: S3 bucket and credentials are required", ErrConfig)
+	}
+
+	region := config.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	return &S3Store{
+		endpoint:	endpoint,
+		bucket:		config.Bucket,
+		region:		region,
+		accessKey:	config.AccessKey,
+		secretKey:	config.SecretKey,
+		pathStyle:	config.PathStyle,
+		client:		&http.Client{},
+	}, nil
+}
+
+func (s *S3Store) Put(ctx context.Context, key string, r io.Reader, contentType string) (int64, error) {
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		return 0, err
+	}
+
+	var body io.Reader = r
+	var size int64
+	switch v := r.(type) {
+	case interface{ Len() int }:
+		size = int64(v.Len())
+	default:
+		tmp, err := os.CreateTemp("", "cadence-s3-*")
+		if err != nil {
+			return 0, errors.Join(ErrStorage, err)
+		}
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+
+		if size, err = io.Copy(tmp, r); err != nil {
+			return 0, errors.Join(ErrStorage, fmt.Errorf("failed to buffer %s: %w", key, err))
+		}
+		if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+			return 0, errors.Join(ErrStorage, err)
+		}
+		body = tmp
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectURL(cleaned, nil).String(), io.NopCloser(body))
+	if err != nil {
+		return 0, errors.Join(ErrStorage, err)
+	}
+	// A zero length with any other body is sent chunked, which S3 rejects.
+	req.ContentLength = size
+	if size == 0 {
+		req.Body = http.NoBody
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := s.do(req, s3UnsignedPayload)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return size, nil
+}
+
+func (s *S3Store) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	return s.GetRange(ctx, key, 0, -1)
+}
+
+func (s *S3Store) GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if offset < 0 || length == 0 {
+		return nil, fmt.Errorf("%w: offset %d length %d", ErrInvalidRange, offset, length)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.objectURL(cleaned, nil).String(), nil)
+	if err != nil {
+		return nil, errors.Join(ErrStorage, err)
+	}
+	if offset > 0 || length > 0 {
+		req.Header.Set("Range", rangeHeader(offset, length))
+	}
+
+	resp, err := s.do(req, hashHex(nil))
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (s *S3Store) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, s.objectURL(cleaned, nil).String(), nil)
+	if err != nil {
+		return nil, errors.Join(ErrStorage, err)
+	}
+
+	resp, err := s.do(req, hashHex(nil))
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	modTime, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+	size, _ := strconv.ParseInt(resp.Header.Get("Content-Length"), 10, 64)
+	return &ObjectInfo{
+		Key:			cleaned,
+		Size:			size,
+		ContentType:	resp.Header.Get("Content-Type"),
+		ETag:			resp.Header.Get("ETag"),
+		ModTime:		modTime,
+	}, nil
+}
+
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, s.objectURL(cleaned, nil).String(), nil)
+	if err != nil {
+		return errors.Join(ErrStorage, err)
+	}
+
+	resp, err := s.do(req, hashHex(nil))
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *S3Store) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.objectURL("", query).String(), nil)
+		if err != nil {
+			return nil, errors.Join(ErrStorage, err)
+		}
+		resp, err := s.do(req, hashHex(nil))
+		if err != nil {
+			return nil, err
+		}
+
+		var result s3ListResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, errors.Join(ErrStorage, fmt.Errorf("failed to decode listing: %w", err))
+		}
+
+		for _, c := range result.Contents {
+			objects = append(objects, ObjectInfo{Key: c.Key, Size: c.Size, ETag: c.ETag, ModTime: c.LastModified})
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+func (s *S3Store) do(req *http.Request, payloadHash string) (*http.Response, error) {
+	s.sign(req, payloadHash, time.Now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, errors.Join(ErrStorage, err)
+	}
+	if resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, req.URL.Path)
+	case http.StatusRequestedRangeNotSatisfiable:
+		return nil, fmt.Errorf("%w: %s", ErrInvalidRange, req.Header.Get("Range"))
+	}
+
+	var s3err s3Error
+	xml.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&s3err)
+	if s3err.Code == "NoSuchKey" {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, req.URL.Path)
+	}
+	return nil, errors.Join(ErrStorage, fmt.Errorf("s3 %s %s: status %d %s %s", req.Method, req.URL.Path, resp.StatusCode, s3err.Code, s3err.Message))
+}
@@ -0,0 +1,457 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testBucket = "cadence"
+
+// fakeS3 is an in-memory stand-in for the parts of the S3 API the store
+// uses, with path-style addressing.
+type fakeS3 struct {
+	mu			sync.Mutex
+	objects		map[string][]byte
+	types		map[string]string
+	pageSize	int
+	requests	[]*http.Request
+	status		int
+	code		string
+}
+
+func newFakeS3(t *testing.T) (*fakeS3, *S3Store) {
+	t.Helper()
+
+	fake := &fakeS3{objects: map[string][]byte{}, types: map[string]string{}, pageSize: 1000}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	store, err := NewS3Store(Config{
+		Endpoint:	server.URL,
+		Bucket:		testBucket,
+		AccessKey:	"test",
+		SecretKey:	"secret",
+		PathStyle:	true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fake, store
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, r)
+
+	if !strings.HasPrefix(r.Header.Get("Authorization"), s3Algorithm+" Credential=test/") || r.Header.Get("X-Amz-Date") == "" {
+		f.fail(w, http.StatusForbidden, "AccessDenied")
+		return
+	}
+	if f.status != 0 {
+		f.fail(w, f.status, f.code)
+		return
+	}
+
+	key, ok := strings.CutPrefix(r.URL.Path, "/"+testBucket)
+	if !ok {
+		f.fail(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+	key = strings.TrimPrefix(key, "/")
+
+	switch {
+	case r.Method == http.MethodGet && key == "":
+		f.list(w, r)
+	case r.Method == http.MethodPut:
+		body, err := io.ReadAll(r.Body)
+		if err != nil || int64(len(body)) != r.ContentLength {
+			f.fail(w, http.StatusBadRequest, "IncompleteBody")
+			return
+		}
+		f.objects[key] = body
+		f.types[key] = r.Header.Get("Content-Type")
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		content, ok := f.objects[key]
+		if !ok {
+			f.fail(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set("Content-Type", f.types[key])
+		w.Header().Set("ETag", fmt.Sprintf(`"%d"`, len(content)))
+		if spec := r.Header.Get("Range"); spec != "" {
+			var start, end int
+			if _, err := fmt.Sscanf(spec, "bytes=%d-%d", &start, &end); err != nil {
+				end = len(content) - 1
+			}
+			if start >= len(content) {
+				f.fail(w, http.StatusRequestedRangeNotSatisfiable, "InvalidRange")
+				return
+			}
+			content = content[start:min(end+1, len(content))]
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			w.WriteHeader(http.StatusPartialContent)
+		} else {
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		}
+		if r.Method == http.MethodGet {
+			w.Write(content)
+		}
+	}
+}
+
+// list pages through the keys, using the last key of a page as the
+// continuation token.
+func (f *fakeS3) list(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	var keys []string
+	for key := range f.objects {
+		if strings.HasPrefix(key, query.Get("prefix")) && key > query.Get("continuation-token") {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	truncated := len(keys) > f.pageSize
+	if truncated {
+		keys = keys[:f.pageSize]
+	}
+
+	fmt.Fprint(w, "<ListBucketResult>")
+	for _, key := range keys {
+		fmt.Fprintf(w, "<Contents><Key>%s</Key><Size>%d</Size></Contents>", key, len(f.objects[key]))
+	}
+	fmt.Fprintf(w, "<IsTruncated>%t</IsTruncated>", truncated)
+	if truncated {
+		fmt.Fprintf(w, "<NextContinuationToken>%s</NextContinuationToken>", keys[len(keys)-1])
+	}
+	fmt.Fprint(w, "</ListBucketResult>")
+}
+
+func (f *fakeS3) fail(w http.ResponseWriter, status int, code string) {
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, code)
+}
+
+func (f *fakeS3) lastRequest() *http.Request {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests[len(f.requests)-1]
+}
+
+func TestS3StorePut(t *testing.T) {
+	fake, store := newFakeS3(t)
+	ctx := context.Background()
+	content := []byte("chapter audio")
+
+	tests := []struct {
+		name	string
+		body	io.Reader
+	}{
+		{"sized reader", bytes.NewReader(content)},
+		{"buffered reader", io.MultiReader(bytes.NewReader(content[:5]), bytes.NewReader(content[5:]))},
+		{"empty", io.MultiReader()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := "books/1/" + strings.ReplaceAll(tt.name, " ", "-") + ".mp3"
+			n, err := store.Put(ctx, key, tt.body, "audio/mpeg")
+			if err != nil {
+				t.Fatalf("Put() error = %v", err)
+			}
+
+			want := content
+			if tt.name == "empty" {
+				want = nil
+			}
+			if n != int64(len(want)) {
+				t.Errorf("Put() = %d, want %d", n, len(want))
+			}
+			if got := fake.objects[key]; !bytes.Equal(got, want) {
+				t.Errorf("stored %q, want %q", got, want)
+			}
+			req := fake.lastRequest()
+			if req.ContentLength != int64(len(want)) {
+				t.Errorf("Content-Length = %d, want %d", req.ContentLength, len(want))
+			}
+			if got := req.Header.Get("X-Amz-Content-Sha256"); got != s3UnsignedPayload {
+				t.Errorf("X-Amz-Content-Sha256 = %q, want %q", got, s3UnsignedPayload)
+			}
+			if got := fake.types[key]; got != "audio/mpeg" {
+				t.Errorf("Content-Type = %q, want audio/mpeg", got)
+			}
+		})
+	}
+}
+
+func TestS3StoreGetRange(t *testing.T) {
+	fake, store := newFakeS3(t)
+	fake.objects["books/1/00.mp3"] = []byte("0123456789")
+
+	tests := []struct {
+		name	string
+		key		string
+		offset	int64
+		length	int64
+		header	string
+		want	string
+		err		error
+	}{
+		{"whole object", "books/1/00.mp3", 0, -1, "", "0123456789", nil},
+		{"bounded", "books/1/00.mp3", 2, 3, "bytes=2-4", "234", nil},
+		{"open ended", "books/1/00.mp3", 7, -1, "bytes=7-", "789", nil},
+		{"from start", "books/1/00.mp3", 0, 4, "bytes=0-3", "0123", nil},
+		{"beyond end", "books/1/00.mp3", 20, -1, "bytes=20-", "", ErrInvalidRange},
+		{"negative offset", "books/1/00.mp3", -1, 4, "", "", ErrInvalidRange},
+		{"zero length", "books/1/00.mp3", 0, 0, "", "", ErrInvalidRange},
+		{"missing", "books/2/00.mp3", 0, -1, "", "", ErrNotFound},
+		{"traversal", "../00.mp3", 0, -1, "", "", ErrInvalidKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := len(fake.requests)
+			rc, err := store.GetRange(context.Background(), tt.key, tt.offset, tt.length)
+			if len(fake.requests) > requests {
+				if got := fake.lastRequest().Header.Get("Range"); got != tt.header {
+					t.Errorf("Range = %q, want %q", got, tt.header)
+				}
+			}
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("GetRange() error = %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			defer rc.Close()
+
+			got, err := io.ReadAll(rc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("GetRange() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestS3StoreList(t *testing.T) {
+	fake, store := newFakeS3(t)
+	fake.pageSize = 2
+	for _, key := range []string{"books/1/00.mp3", "books/1/01.mp3", "books/1/02.mp3", "books/1/03.mp3", "books/1/04.mp3", "books/2/00.mp3"} {
+		fake.objects[key] = []byte(key)
+	}
+
+	objects, err := store.List(context.Background(), "books/1/")
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+
+	var keys []string
+	for _, object := range objects {
+		keys = append(keys, object.Key)
+	}
+	want := "books/1/00.mp3 books/1/01.mp3 books/1/02.mp3 books/1/03.mp3 books/1/04.mp3"
+	if got := strings.Join(keys, " "); got != want {
+		t.Errorf("List() = %s, want %s", got, want)
+	}
+	if len(fake.requests) != 3 {
+		t.Errorf("List() made %d requests, want 3 pages", len(fake.requests))
+	}
+	for _, req := range fake.requests {
+		if got := req.URL.Query().Get("list-type"); got != "2" {
+			t.Errorf("list-type = %q, want 2", got)
+		}
+	}
+}
+
+func TestS3StoreErrors(t *testing.T) {
+	tests := []struct {
+		name	string
+		status	int
+		code	string
+		err		error
+	}{
+		{"not found", http.StatusNotFound, "", ErrNotFound},
+		{"no such key", http.StatusForbidden, "NoSuchKey", ErrNotFound},
+		{"access denied", http.StatusForbidden, "AccessDenied", ErrStorage},
+		{"invalid range", http.StatusRequestedRangeNotSatisfiable, "InvalidRange", ErrInvalidRange},
+		{"server error", http.StatusInternalServerError, "InternalError", ErrStorage},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, store := newFakeS3(t)
+			fake.status, fake.code = tt.status, tt.code
+
+			if _, err := store.Get(context.Background(), "books/1/00.mp3"); !errors.Is(err, tt.err) {
+				t.Errorf("Get() error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+
+	t.Run("delete missing", func(t *testing.T) {
+		_, store := newFakeS3(t)
+		if err := store.Delete(context.Background(), "books/1/00.mp3"); err != nil {
+			t.Errorf("Delete() error = %v", err)
+		}
+	})
+}
+
+// TestS3StoreServer runs a round trip against a real S3-compatible server,
+// such as the MinIO service in docker-compose.yml under the s3 profile.
+func TestS3StoreServer(t *testing.T) {
+	endpoint := os.Getenv("TEST_S3_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("TEST_S3_ENDPOINT is not set")
+	}
+
+	store, err := NewS3Store(Config{
+		Endpoint:	endpoint,
+		Bucket:		getEnv("TEST_S3_BUCKET", testBucket),
+		AccessKey:	getEnv("TEST_S3_ACCESS_KEY", "minioadmin"),
+		SecretKey:	getEnv("TEST_S3_SECRET_KEY", "minioadmin"),
+		PathStyle:	true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	prefix := fmt.Sprintf("test-%d/", time.Now().UnixNano())
+	key := prefix + "books/1/00.mp3"
+
+	if _, err := store.Put(ctx, key, io.MultiReader(strings.NewReader("0123456789")), "audio/mpeg"); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	t.Cleanup(func() { store.Delete(ctx, key) })
+
+	info, err := store.Stat(ctx, key)
+	if err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	}
+	if info.Size != 10 || info.ContentType != "audio/mpeg" {
+		t.Errorf("Stat() = %d bytes of %q, want 10 bytes of audio/mpeg", info.Size, info.ContentType)
+	}
+
+	rc, err := store.GetRange(ctx, key, 2, 3)
+	if err != nil {
+		t.Fatalf("GetRange() error = %v", err)
+	}
+	got, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil || string(got) != "234" {
+		t.Errorf("GetRange() = %q, %v, want 234", got, err)
+	}
+
+	objects, err := store.List(ctx, prefix)
+	if err != nil || len(objects) != 1 || objects[0].Key != key {
+		t.Errorf("List() = %v, %v, want %s", objects, err, key)
+	}
+
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := store.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() after Delete() error = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestFileStoreGetRange(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if _, err := store.Put(ctx, "books/1/00.mp3", strings.NewReader("0123456789"), "audio/mpeg"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Put(ctx, "books/1/empty.txt", strings.NewReader(""), "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name	string
+		key		string
+		offset	int64
+		length	int64
+		want	string
+		err		error
+	}{
+		{"whole file", "books/1/00.mp3", 0, -1, "0123456789", nil},
+		{"bounded", "books/1/00.mp3", 2, 3, "234", nil},
+		{"open ended", "books/1/00.mp3", 7, -1, "789", nil},
+		{"past end", "books/1/00.mp3", 8, 10, "89", nil},
+		{"last byte", "books/1/00.mp3", 9, 1, "9", nil},
+		{"at end", "books/1/00.mp3", 10, -1, "", ErrInvalidRange},
+		{"negative offset", "books/1/00.mp3", -1, 4, "", ErrInvalidRange},
+		{"empty file", "books/1/empty.txt", 0, 4, "", nil},
+		{"missing", "books/2/00.mp3", 0, -1, "", ErrNotFound},
+		{"traversal", "../00.mp3", 0, -1, "", ErrInvalidKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rc, err := store.GetRange(ctx, tt.key, tt.offset, tt.length)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("GetRange() error = %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			defer rc.Close()
+
+			got, err := io.ReadAll(rc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("GetRange() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileStoreRejectsTraversal(t *testing.T) {
+	root := t.TempDir()
+	store, err := NewFileStore(filepath.Join(root, "storage"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	for _, key := range []string{"../outside.txt", "books/../../outside.txt", "..\\outside.txt", "/../outside.txt"} {
+		t.Run(key, func(t *testing.T) {
+			if _, err := store.Put(ctx, key, strings.NewReader("data"), "text/plain"); !errors.Is(err, ErrInvalidKey) {
+				t.Errorf("Put() error = %v, want %v", err, ErrInvalidKey)
+			}
+			if _, err := store.Stat(ctx, key); !errors.Is(err, ErrInvalidKey) {
+				t.Errorf("Stat() error = %v, want %v", err, ErrInvalidKey)
+			}
+			if err := store.Delete(ctx, key); !errors.Is(err, ErrInvalidKey) {
+				t.Errorf("Delete() error = %v, want %v", err, ErrInvalidKey)
+			}
+		})
+	}
+
+	if _, err := os.Stat(filepath.Join(root, "outside.txt")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("file written outside the storage root: %v", err)
+	}
+}
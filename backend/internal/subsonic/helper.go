@@ -0,0 +1,166 @@
+package subsonic
+
+import (
+	"crypto/md5"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"cadence/internal/data"
+)
+
+func AlbumID(bookID uint) string {
+	return fmt.Sprintf("%s%d", albumPrefix, bookID)
+}
+
+func SongID(bookID uint, position int) string {
+	return fmt.Sprintf("%s%d-%d", songPrefix, bookID, position)
+}
+
+func ParseAlbumID(id string) (uint, error) {
+	value, ok := strings.CutPrefix(id, albumPrefix)
+	if !ok {
+		return 0, ErrInvalidID
+	}
+	bookID, err := strconv.ParseUint(value, 10, 32)
+	if err != nil || bookID == 0 {
+		return 0, ErrInvalidID
+	}
+	return uint(bookID), nil
+}
+
+func ParseSongID(id string) (uint, int, error) {
+	value, ok := strings.CutPrefix(id, songPrefix)
+	if !ok {
+		return 0, 0, ErrInvalidID
+	}
+	book, position, ok := strings.Cut(value, "-")
+	if !ok {
+		return 0, 0, ErrInvalidID
+	}
+	bookID, err := strconv.ParseUint(book, 10, 32)
+	if err != nil || bookID == 0 {
+		return 0, 0, ErrInvalidID
+	}
+	index, err := strconv.Atoi(position)
+	if err != nil || index < 0 {
+		return 0, 0, ErrInvalidID
+	}
+	return uint(bookID), index, nil
+}
+
+// ParseCoverID accepts the album or song IDs clients pass as coverArt.
+func ParseCoverID(id string) (uint, error) {
+	if bookID, _, err := ParseSongID(id); err == nil {
+		return bookID, nil
+	}
+	return ParseAlbumID(id)
+}
+
+// CheckPassword validates the credentials of a request: either the token
+// md5(password + salt) with its salt, or the password itself, optionally
+// hex-encoded with an "enc:" prefix.
+func CheckPassword(password, token, salt, plain string) bool {
+	if token != "" {
+		sum := md5.Sum([]byte(password + salt))
+		expected := hex.EncodeToString(sum[:])
+		return subtle.ConstantTimeCompare([]byte(expected), []byte(strings.ToLower(token))) == 1
+	}
+
+	if encoded, ok := strings.CutPrefix(plain, "enc:"); ok {
+		decoded, err := hex.DecodeString(encoded)
+		if err != nil {
+			return false
+		}
+		plain = string(decoded)
+	}
+	return plain != "" && subtle.ConstantTimeCompare([]byte(password), []byte(plain)) == 1
+}
+
+func OK() *Response {
+	return &Response{
+		Namespace:		namespace,
+		Status:			StatusOK,
+		Version:		APIVersion,
+		Type:			ServerType,
+		ServerVersion:	ServerVersion,
+		OpenSubsonic:	true,
+	}
+}
+
+func Failure(code ErrorCode, message string) *Response {
+	resp := OK()
+	resp.Status = StatusFailed
+	resp.Error = &Error{Code: code, Message: message}
+	return resp
+}
+
+func NewAlbum(book *data.Book, played *time.Time) Album {
+	album := Album{
+		ID:			AlbumID(book.ID),
+		Name:		book.Title,
+		Artist:		book.Author,
+		SongCount:	book.ChapterCount,
+		Duration:	seconds(book.Duration),
+		Created:	book.CreatedAt.UTC().Format(time.RFC3339),
+		Genre:		Genre,
+	}
+	if book.HasCover {
+		album.CoverArt = album.ID
+	}
+	if played != nil {
+		album.Played = played.UTC().Format(time.RFC3339)
+	}
+	return album
+}
+
+func NewAlbumDetail(book *data.Book, played *time.Time) *AlbumDetail {
+	detail := &AlbumDetail{Album: NewAlbum(book, played), Song: make([]Child, 0, len(book.Chapters))}
+	for i := range book.Chapters {
+		detail.Song = append(detail.Song, NewSong(book, &book.Chapters[i]))
+	}
+	return detail
+}
+
+func NewSong(book *data.Book, chapter *data.BookChapter) Child {
+	song := Child{
+		ID:				SongID(book.ID, chapter.Position),
+		Parent:			AlbumID(book.ID),
+		Title:			chapter.Title,
+		Album:			book.Title,
+		Artist:			book.Author,
+		Track:			chapter.Position + 1,
+		Genre:			Genre,
+		Size:			chapter.AudioSize,
+		ContentType:	"audio/mpeg",
+		Suffix:			"mp3",
+		Duration:		seconds(chapter.Duration),
+		BitRate:		BitRate,
+		Path:			songPath(book, chapter),
+		AlbumID:		AlbumID(book.ID),
+		Type:			"audiobook",
+		MediaType:		"song",
+		Created:		book.CreatedAt.UTC().Format(time.RFC3339),
+	}
+	if book.HasCover {
+		song.CoverArt = song.Parent
+	}
+	return song
+}
+
+func songPath(book *data.Book, chapter *data.BookChapter) string {
+	clean := strings.NewReplacer("/", "-", "\\", "-").Replace
+	author := book.Author
+	if author == "" {
+		author = "Unknown Author"
+	}
+	return fmt.Sprintf("%s/%s/%02d - %s.mp3", clean(author), clean(book.Title), chapter.Position+1, clean(chapter.Title))
+}
+
+func seconds(duration float64) int {
+	return int(math.Round(duration))
+}
@@ -0,0 +1,116 @@
+package subsonic
+
+import (
+	"encoding/xml"
+	"errors"
+)
+
+const (
+	APIVersion		= "1.16.1"
+	ServerType		= "cadence"
+	ServerVersion	= "1.0.0"
+	namespace		= "http://subsonic.org/restapi"
+
+	Genre			= "Audiobook"
+	BitRate			= 48
+	albumPrefix		= "al-"
+	songPrefix		= "tr-"
+)
+
+const (
+	StatusOK		= "ok"
+	StatusFailed	= "failed"
+)
+
+type ErrorCode int
+
+const (
+	ErrGeneric				ErrorCode = 0
+	ErrMissingParameter		ErrorCode = 10
+	ErrWrongCredentials		ErrorCode = 40
+	ErrTokenUnsupported		ErrorCode = 41
+	ErrNotAuthorized		ErrorCode = 50
+	ErrDataNotFound			ErrorCode = 70
+)
+
+var (
+	ErrInvalidID	= errors.New("invalid subsonic id")
+)
+
+// Response is the subsonic-response envelope. Exactly one payload field is
+// set on success; Error is set on failure. Subsonic reports failures in the
+// body with an HTTP 200.
+type Response struct {
+	XMLName			xml.Name		`xml:"subsonic-response" json:"-"`
+	Namespace		string			`xml:"xmlns,attr" json:"-"`
+	Status			string			`xml:"status,attr" json:"status"`
+	Version			string			`xml:"version,attr" json:"version"`
+	Type			string			`xml:"type,attr" json:"type"`
+	ServerVersion	string			`xml:"serverVersion,attr" json:"serverVersion"`
+	OpenSubsonic	bool			`xml:"openSubsonic,attr" json:"openSubsonic"`
+	Error			*Error			`xml:"error,omitempty" json:"error,omitempty"`
+	License			*License		`xml:"license,omitempty" json:"license,omitempty"`
+	Directory		*Directory		`xml:"directory,omitempty" json:"directory,omitempty"`
+	AlbumList2		*AlbumList		`xml:"albumList2,omitempty" json:"albumList2,omitempty"`
+	Album			*AlbumDetail	`xml:"album,omitempty" json:"album,omitempty"`
+}
+
+type Error struct {
+	Code			ErrorCode		`xml:"code,attr" json:"code"`
+	Message			string			`xml:"message,attr" json:"message"`
+}
+
+type License struct {
+	Valid			bool			`xml:"valid,attr" json:"valid"`
+}
+
+type Directory struct {
+	ID				string			`xml:"id,attr" json:"id"`
+	Name			string			`xml:"name,attr" json:"name"`
+	Child			[]Child			`xml:"child" json:"child"`
+}
+
+type AlbumList struct {
+	Album			[]Album			`xml:"album" json:"album"`
+}
+
+type Album struct {
+	ID				string			`xml:"id,attr" json:"id"`
+	Name			string			`xml:"name,attr" json:"name"`
+	Artist			string			`xml:"artist,attr,omitempty" json:"artist,omitempty"`
+	CoverArt		string			`xml:"coverArt,attr,omitempty" json:"coverArt,omitempty"`
+	SongCount		int				`xml:"songCount,attr" json:"songCount"`
+	Duration		int				`xml:"duration,attr" json:"duration"`
+	Created			string			`xml:"created,attr" json:"created"`
+	Genre			string			`xml:"genre,attr" json:"genre"`
+	Played			string			`xml:"played,attr,omitempty" json:"played,omitempty"`
+}
+
+type AlbumDetail struct {
+	Album
+	Song			[]Child			`xml:"song" json:"song"`
+}
+
+// Child is a song entry. Chapters are reported with type "audiobook" so
+// clients that distinguish it keep their position and skip shuffle.
+type Child struct {
+	ID				string			`xml:"id,attr" json:"id"`
+	Parent			string			`xml:"parent,attr" json:"parent"`
+	IsDir			bool			`xml:"isDir,attr" json:"isDir"`
+	Title			string			`xml:"title,attr" json:"title"`
+	Album			string			`xml:"album,attr" json:"album"`
+	Artist			string			`xml:"artist,attr,omitempty" json:"artist,omitempty"`
+	Track			int				`xml:"track,attr" json:"track"`
+	Genre			string			`xml:"genre,attr" json:"genre"`
+	CoverArt		string			`xml:"coverArt,attr,omitempty" json:"coverArt,omitempty"`
+	Size			int64			`xml:"size,attr" json:"size"`
+	ContentType		string			`xml:"contentType,attr" json:"contentType"`
+	Suffix			string			`xml:"suffix,attr" json:"suffix"`
+	Duration		int				`xml:"duration,attr" json:"duration"`
+	BitRate			int				`xml:"bitRate,attr" json:"bitRate"`
+	Path			string			`xml:"path,attr" json:"path"`
+	AlbumID			string			`xml:"albumId,attr" json:"albumId"`
+	Type			string			`xml:"type,attr" json:"type"`
+	MediaType		string			`xml:"mediaType,attr" json:"mediaType"`
+	Created			string			`xml:"created,attr" json:"created"`
+}
@@ -0,0 +1,44 @@
+package subsonic
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"regexp"
+)
+
+var callbackPattern = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$.]*$`)
+
+// ContentType reports the media type Encode produces for the requested
+// format: "json", "jsonp" or, by default, XML.
+func ContentType(format, callback string) string {
+	switch {
+	case format == "jsonp" && callbackPattern.MatchString(callback):
+		return "application/javascript; charset=utf-8"
+	case format == "json", format == "jsonp":
+		return "application/json; charset=utf-8"
+	}
+	return "application/xml; charset=utf-8"
+}
+
+// Encode writes the response in the requested format. JSONP falls back to
+// plain JSON when the callback is not a valid identifier.
+func (r *Response) Encode(w io.Writer, format, callback string) error {
+	switch format {
+	case "json", "jsonp":
+		body, err := json.Marshal(map[string]*Response{"subsonic-response": r})
+		if err != nil {
+			return err
+		}
+		if format == "jsonp" && callbackPattern.MatchString(callback) {
+			body = append(append([]byte(callback+"("), body...), ");"...)
+		}
+		_, err = w.Write(body)
+		return err
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	return xml.NewEncoder(w).Encode(r)
+}
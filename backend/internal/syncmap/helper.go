@@ -0,0 +1,101 @@
+package syncmap
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+func ParseTime(value string) (float64, error) {
+	m := timestampPattern.FindStringSubmatch(strings.TrimSpace(value))
+	if m == nil {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidTime, value)
+	}
+
+	seconds, _ := strconv.ParseFloat(m[3], 64)
+	if m[2] != "" {
+		if seconds >= 60 {
+			return 0, fmt.Errorf("%w: %q", ErrInvalidTime, value)
+		}
+		minutes, _ := strconv.Atoi(m[2])
+		seconds += float64(minutes) * 60
+	}
+	if m[1] != "" {
+		hours, _ := strconv.Atoi(m[1])
+		seconds += float64(hours) * 3600
+	}
+	return seconds, nil
+}
+
+func timeAt(anchors []anchor, pos int) float64 {
+	if len(anchors) == 0 {
+		return 0
+	}
+
+	i := sort.Search(len(anchors), func(i int) bool { return anchors[i].pos >= pos })
+	switch {
+	case i == len(anchors):
+		return anchors[len(anchors)-1].time
+	case i == 0, anchors[i].pos == pos:
+		return anchors[i].time
+	}
+
+	prev, next := anchors[i-1], anchors[i]
+	return prev.time + (next.time-prev.time)*float64(pos-prev.pos)/float64(next.pos-prev.pos)
+}
+
+func runeOffsets(text string) []int {
+	offsets := make([]int, len(text)+1)
+	n := 0
+	for i := 0; i < len(text); i++ {
+		if i > 0 && utf8.RuneStart(text[i]) {
+			n++
+		}
+		offsets[i] = n
+	}
+	offsets[len(text)] = utf8.RuneCountInString(text)
+	return offsets
+}
+
+func locate(spans []Span, t float64) int {
+	return sort.Search(len(spans), func(i int) bool { return spans[i].Begin > t }) - 1
+}
+
+func paragraphSpans(text string) [][2]int {
+	var spans [][2]int
+	offset := 0
+	for _, paragraph := range strings.Split(text, "\n\n") {
+		start, end := offset, offset+len(paragraph)
+		offset = end + 2
+
+		trimmed := strings.TrimSpace(paragraph)
+		if trimmed == "" {
+			continue
+		}
+		start += strings.Index(paragraph, trimmed)
+		spans = append(spans, [2]int{start, start + len(trimmed)})
+	}
+	return spans
+}
+
+// searchTokens splits text into lowercase runs of letters and digits.
+func searchTokens(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+func tokensAt(tokens []string, i int, want []string) bool {
+	if i+len(want) > len(tokens) {
+		return false
+	}
+	for j, w := range want {
+		if tokens[i+j] != w {
+			return false
+		}
+	}
+	return true
+}
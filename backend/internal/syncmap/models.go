@@ -0,0 +1,73 @@
+package syncmap
+
+import (
+	"errors"
+	"regexp"
+)
+
+const Version = 1
+
+// Words reported by the synthesizer are matched against the source text
+// within this many bytes of the previous match; anything further away is
+// treated as a normalization the text does not contain.
+const matchWindow = 96
+
+// findContextWords is how many words before a phrase Find uses to tell its
+// occurrences apart.
+const findContextWords = 3
+
+var (
+	ErrOutOfRange		= errors.New("position out of range")
+	ErrInvalidMap		= errors.New("invalid sync map")
+	ErrInvalidTime		= errors.New("invalid time")
+	ErrNoMatch			= errors.New("text not found")
+)
+
+var timestampPattern = regexp.MustCompile(`^(?:(?:(\d+):)?(\d{1,2}):)?(\d+(?:\.\d+)?)$`)
+
+// Map relates a chapter's audio to the text it was synthesized from. Text
+// offsets are Unicode code point indexes into Text; times are in seconds
+// from the start of the chapter audio.
+type Map struct {
+	Version			int			`json:"version"`
+	Duration		float64		`json:"duration"`
+	Text			string		`json:"text"`
+	Paragraphs		[]Span		`json:"paragraphs"`
+	Sentences		[]Span		`json:"sentences"`
+	Words			[]Span		`json:"words"`
+}
+
+type Span struct {
+	Index			int			`json:"index"`
+	TextStart		int			`json:"text_start"`
+	TextEnd			int			`json:"text_end"`
+	Begin			float64		`json:"begin"`
+	End				float64		`json:"end"`
+}
+
+type Location struct {
+	Time			float64		`json:"time"`
+	Paragraph		*Span		`json:"paragraph,omitempty"`
+	Sentence		*Span		`json:"sentence,omitempty"`
+	Word			*Span		`json:"word,omitempty"`
+}
+
+// Segment is one synthesized piece of the text, located by byte offsets,
+// with the word boundaries the synthesizer reported relative to its start.
+type Segment struct {
+	Start			int
+	End				int
+	Duration		float64
+	Marks			[]Mark
+}
+
+type Mark struct {
+	Text			string		`json:"text"`
+	Offset			float64		`json:"offset"`
+	Duration		float64		`json:"duration"`
+}
+
+type anchor struct {
+	pos				int
+	time			float64
+}
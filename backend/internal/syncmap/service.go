@@ -0,0 +1,166 @@
+package syncmap
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+
+	"cadence/internal/preprocess"
+)
+
+// Build aligns the synthesizer's word boundaries with text. Segments must
+// be in text order; audio positions between reported words are
+// interpolated by character offset.
+func Build(text string, segments []Segment) *Map {
+	m := &Map{Version: Version, Text: text}
+	var anchors []anchor
+	var words [][2]int
+
+	for _, seg := range segments {
+		start := m.Duration
+		anchors = append(anchors, anchor{pos: seg.Start, time: start})
+
+		cursor := seg.Start
+		last := start
+		for _, mark := range seg.Marks {
+			word := strings.TrimSpace(html.UnescapeString(mark.Text))
+			if word == "" {
+				continue
+			}
+			window := text[cursor:min(seg.End, cursor+len(word)+matchWindow)]
+			i := strings.Index(window, word)
+			if i < 0 {
+				continue
+			}
+
+			begin := max(start+mark.Offset, last)
+			end := max(begin+mark.Duration, begin)
+			pos := cursor + i
+			anchors = append(anchors, anchor{pos: pos, time: begin})
+			words = append(words, [2]int{pos, pos + len(word)})
+			m.Words = append(m.Words, Span{Begin: begin, End: min(end, start+seg.Duration)})
+			cursor, last = pos+len(word), begin
+		}
+
+		m.Duration = max(start+seg.Duration, last)
+		anchors = append(anchors, anchor{pos: seg.End, time: m.Duration})
+	}
+
+	offsets := runeOffsets(text)
+	for i := range m.Words {
+		m.Words[i].Index = i
+		m.Words[i].TextStart = offsets[words[i][0]]
+		m.Words[i].TextEnd = offsets[words[i][1]]
+	}
+
+	span := func(index, start, end int) Span {
+		return Span{
+			Index:		index,
+			TextStart:	offsets[start],
+			TextEnd:	offsets[end],
+			Begin:		timeAt(anchors, start),
+			End:		timeAt(anchors, end),
+		}
+	}
+	for _, p := range paragraphSpans(text) {
+		m.Paragraphs = append(m.Paragraphs, span(len(m.Paragraphs), p[0], p[1]))
+		for _, s := range preprocess.SplitSentences(text[p[0]:p[1]]) {
+			m.Sentences = append(m.Sentences, span(len(m.Sentences), p[0]+s.Start, p[0]+s.End))
+		}
+	}
+	return m
+}
+
+func Decode(r io.Reader) (*Map, error) {
+	var m Map
+	if err := json.NewDecoder(r).Decode(&m); err != nil {
+		return nil, errors.Join(ErrInvalidMap, err)
+	}
+	if m.Version != Version {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidMap, m.Version)
+	}
+	return &m, nil
+}
+
+// At reports the paragraph, sentence and word being spoken at t. Pauses
+// belong to the preceding paragraph and sentence, but not to any word.
+func (m *Map) At(t float64) (*Location, error) {
+	if t < 0 || t > m.Duration {
+		return nil, fmt.Errorf("%w: %.3fs", ErrOutOfRange, t)
+	}
+
+	loc := &Location{Time: t}
+	if i := max(locate(m.Paragraphs, t), 0); i < len(m.Paragraphs) {
+		loc.Paragraph = &m.Paragraphs[i]
+	}
+	if i := max(locate(m.Sentences, t), 0); i < len(m.Sentences) {
+		loc.Sentence = &m.Sentences[i]
+	}
+	if i := locate(m.Words, t); i >= 0 && t <= m.Words[i].End {
+		loc.Word = &m.Words[i]
+	}
+	return loc, nil
+}
+
+func (m *Map) Paragraph(index int) (*Span, error) {
+	if index < 0 || index >= len(m.Paragraphs) {
+		return nil, fmt.Errorf("%w: paragraph %d", ErrOutOfRange, index)
+	}
+	return &m.Paragraphs[index], nil
+}
+
+func (m *Map) Sentence(index int) (*Span, error) {
+	if index < 0 || index >= len(m.Sentences) {
+		return nil, fmt.Errorf("%w: sentence %d", ErrOutOfRange, index)
+	}
+	return &m.Sentences[index], nil
+}
+
+// Find returns the first word at which phrase is spoken, comparing words
+// case-insensitively and ignoring punctuation. When phrase occurs more than
+// once, an occurrence preceded by the last words of before is preferred.
+func (m *Map) Find(before, phrase string) (*Span, error) {
+	target := searchTokens(phrase)
+	if len(target) == 0 {
+		return nil, ErrNoMatch
+	}
+	context := searchTokens(before)
+	context = context[max(0, len(context)-findContextWords):]
+
+	// Words can hold several tokens ("don't") and tokens are what match.
+	runes := []rune(m.Text)
+	var tokens []string
+	var owners []int
+	for i, w := range m.Words {
+		for _, t := range searchTokens(string(runes[min(w.TextStart, len(runes)):min(w.TextEnd, len(runes))])) {
+			tokens = append(tokens, t)
+			owners = append(owners, i)
+		}
+	}
+
+	first := -1
+	for i := range tokens {
+		if !tokensAt(tokens, i, target) {
+			continue
+		}
+		if first < 0 {
+			first = i
+		}
+		if len(context) > 0 && i >= len(context) && tokensAt(tokens, i-len(context), context) {
+			return &m.Words[owners[i]], nil
+		}
+	}
+	if first < 0 {
+		return nil, ErrNoMatch
+	}
+	return &m.Words[owners[first]], nil
+}
+
+// Slice returns the text covered by span.
+func (m *Map) Slice(span Span) string {
+	runes := []rune(m.Text)
+	return string(runes[min(span.TextStart, len(runes)):min(span.TextEnd, len(runes))])
+}